@@ -0,0 +1,613 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: api/dbdiff/v1/dbdiff.proto
+
+package dbdiffv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Database is either a live database or a snapshot of its schema.
+type Database struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Database:
+	//
+	//	*Database_Url
+	//	*Database_SchemaJson
+	Database      isDatabase_Database `protobuf_oneof:"database"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Database) Reset() {
+	*x = Database{}
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Database) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Database) ProtoMessage() {}
+
+func (x *Database) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Database.ProtoReflect.Descriptor instead.
+func (*Database) Descriptor() ([]byte, []int) {
+	return file_api_dbdiff_v1_dbdiff_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Database) GetDatabase() isDatabase_Database {
+	if x != nil {
+		return x.Database
+	}
+	return nil
+}
+
+func (x *Database) GetUrl() string {
+	if x != nil {
+		if x, ok := x.Database.(*Database_Url); ok {
+			return x.Url
+		}
+	}
+	return ""
+}
+
+func (x *Database) GetSchemaJson() []byte {
+	if x != nil {
+		if x, ok := x.Database.(*Database_SchemaJson); ok {
+			return x.SchemaJson
+		}
+	}
+	return nil
+}
+
+type isDatabase_Database interface {
+	isDatabase_Database()
+}
+
+type Database_Url struct {
+	// URL of the database, or an environment alias from the server's
+	// configuration.
+	Url string `protobuf:"bytes,1,opt,name=url,proto3,oneof"`
+}
+
+type Database_SchemaJson struct {
+	// Schema as returned by Snapshot or `dbdiff inspect --format json`.
+	SchemaJson []byte `protobuf:"bytes,2,opt,name=schema_json,json=schemaJson,proto3,oneof"`
+}
+
+func (*Database_Url) isDatabase_Database() {}
+
+func (*Database_SchemaJson) isDatabase_Database() {}
+
+type Statement struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sql           string                 `protobuf:"bytes,1,opt,name=sql,proto3" json:"sql,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Statement) Reset() {
+	*x = Statement{}
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Statement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Statement) ProtoMessage() {}
+
+func (x *Statement) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Statement.ProtoReflect.Descriptor instead.
+func (*Statement) Descriptor() ([]byte, []int) {
+	return file_api_dbdiff_v1_dbdiff_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Statement) GetSql() string {
+	if x != nil {
+		return x.Sql
+	}
+	return ""
+}
+
+type DiffRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Driver defaults to the dialect of the snapshots, then to the server's.
+	Driver        string    `protobuf:"bytes,1,opt,name=driver,proto3" json:"driver,omitempty"`
+	Source        *Database `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	Target        *Database `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiffRequest) Reset() {
+	*x = DiffRequest{}
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiffRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiffRequest) ProtoMessage() {}
+
+func (x *DiffRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiffRequest.ProtoReflect.Descriptor instead.
+func (*DiffRequest) Descriptor() ([]byte, []int) {
+	return file_api_dbdiff_v1_dbdiff_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DiffRequest) GetDriver() string {
+	if x != nil {
+		return x.Driver
+	}
+	return ""
+}
+
+func (x *DiffRequest) GetSource() *Database {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *DiffRequest) GetTarget() *Database {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+type SnapshotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Driver        string                 `protobuf:"bytes,1,opt,name=driver,proto3" json:"driver,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotRequest) Reset() {
+	*x = SnapshotRequest{}
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotRequest) ProtoMessage() {}
+
+func (x *SnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotRequest.ProtoReflect.Descriptor instead.
+func (*SnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_api_dbdiff_v1_dbdiff_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SnapshotRequest) GetDriver() string {
+	if x != nil {
+		return x.Driver
+	}
+	return ""
+}
+
+func (x *SnapshotRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type SnapshotResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Schema encoded as JSON, as output by `dbdiff inspect --format json`.
+	SchemaJson    []byte `protobuf:"bytes,1,opt,name=schema_json,json=schemaJson,proto3" json:"schema_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotResponse) Reset() {
+	*x = SnapshotResponse{}
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotResponse) ProtoMessage() {}
+
+func (x *SnapshotResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotResponse.ProtoReflect.Descriptor instead.
+func (*SnapshotResponse) Descriptor() ([]byte, []int) {
+	return file_api_dbdiff_v1_dbdiff_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SnapshotResponse) GetSchemaJson() []byte {
+	if x != nil {
+		return x.SchemaJson
+	}
+	return nil
+}
+
+type ApplyRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Driver string                 `protobuf:"bytes,1,opt,name=driver,proto3" json:"driver,omitempty"`
+	Source *Database              `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	// Target is migrated and must therefore be a live database.
+	TargetUrl     string `protobuf:"bytes,3,opt,name=target_url,json=targetUrl,proto3" json:"target_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ApplyRequest) Reset() {
+	*x = ApplyRequest{}
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ApplyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplyRequest) ProtoMessage() {}
+
+func (x *ApplyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplyRequest.ProtoReflect.Descriptor instead.
+func (*ApplyRequest) Descriptor() ([]byte, []int) {
+	return file_api_dbdiff_v1_dbdiff_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ApplyRequest) GetDriver() string {
+	if x != nil {
+		return x.Driver
+	}
+	return ""
+}
+
+func (x *ApplyRequest) GetSource() *Database {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *ApplyRequest) GetTargetUrl() string {
+	if x != nil {
+		return x.TargetUrl
+	}
+	return ""
+}
+
+type VerifyRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Driver    string                 `protobuf:"bytes,1,opt,name=driver,proto3" json:"driver,omitempty"`
+	SourceUrl string                 `protobuf:"bytes,2,opt,name=source_url,json=sourceUrl,proto3" json:"source_url,omitempty"`
+	TargetUrl string                 `protobuf:"bytes,3,opt,name=target_url,json=targetUrl,proto3" json:"target_url,omitempty"`
+	// Empty database the plan gets applied to. Defaults to a temporary
+	// database when the server supports it.
+	ScratchUrl    string `protobuf:"bytes,4,opt,name=scratch_url,json=scratchUrl,proto3" json:"scratch_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyRequest) Reset() {
+	*x = VerifyRequest{}
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyRequest) ProtoMessage() {}
+
+func (x *VerifyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyRequest.ProtoReflect.Descriptor instead.
+func (*VerifyRequest) Descriptor() ([]byte, []int) {
+	return file_api_dbdiff_v1_dbdiff_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *VerifyRequest) GetDriver() string {
+	if x != nil {
+		return x.Driver
+	}
+	return ""
+}
+
+func (x *VerifyRequest) GetSourceUrl() string {
+	if x != nil {
+		return x.SourceUrl
+	}
+	return ""
+}
+
+func (x *VerifyRequest) GetTargetUrl() string {
+	if x != nil {
+		return x.TargetUrl
+	}
+	return ""
+}
+
+func (x *VerifyRequest) GetScratchUrl() string {
+	if x != nil {
+		return x.ScratchUrl
+	}
+	return ""
+}
+
+type VerifyResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Plan migrates the target to the source.
+	Plan []*Statement `protobuf:"bytes,1,rep,name=plan,proto3" json:"plan,omitempty"`
+	// Remaining are the statements still needed once the plan is applied.
+	// The plan is correct when there are none.
+	Remaining     []*Statement `protobuf:"bytes,2,rep,name=remaining,proto3" json:"remaining,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifyResponse) Reset() {
+	*x = VerifyResponse{}
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifyResponse) ProtoMessage() {}
+
+func (x *VerifyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_dbdiff_v1_dbdiff_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifyResponse.ProtoReflect.Descriptor instead.
+func (*VerifyResponse) Descriptor() ([]byte, []int) {
+	return file_api_dbdiff_v1_dbdiff_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *VerifyResponse) GetPlan() []*Statement {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
+func (x *VerifyResponse) GetRemaining() []*Statement {
+	if x != nil {
+		return x.Remaining
+	}
+	return nil
+}
+
+var File_api_dbdiff_v1_dbdiff_proto protoreflect.FileDescriptor
+
+const file_api_dbdiff_v1_dbdiff_proto_rawDesc = "" +
+	"\n" +
+	"\x1aapi/dbdiff/v1/dbdiff.proto\x12\tdbdiff.v1\"M\n" +
+	"\bDatabase\x12\x12\n" +
+	"\x03url\x18\x01 \x01(\tH\x00R\x03url\x12!\n" +
+	"\vschema_json\x18\x02 \x01(\fH\x00R\n" +
+	"schemaJsonB\n" +
+	"\n" +
+	"\bdatabase\"\x1d\n" +
+	"\tStatement\x12\x10\n" +
+	"\x03sql\x18\x01 \x01(\tR\x03sql\"\x7f\n" +
+	"\vDiffRequest\x12\x16\n" +
+	"\x06driver\x18\x01 \x01(\tR\x06driver\x12+\n" +
+	"\x06source\x18\x02 \x01(\v2\x13.dbdiff.v1.DatabaseR\x06source\x12+\n" +
+	"\x06target\x18\x03 \x01(\v2\x13.dbdiff.v1.DatabaseR\x06target\";\n" +
+	"\x0fSnapshotRequest\x12\x16\n" +
+	"\x06driver\x18\x01 \x01(\tR\x06driver\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\"3\n" +
+	"\x10SnapshotResponse\x12\x1f\n" +
+	"\vschema_json\x18\x01 \x01(\fR\n" +
+	"schemaJson\"r\n" +
+	"\fApplyRequest\x12\x16\n" +
+	"\x06driver\x18\x01 \x01(\tR\x06driver\x12+\n" +
+	"\x06source\x18\x02 \x01(\v2\x13.dbdiff.v1.DatabaseR\x06source\x12\x1d\n" +
+	"\n" +
+	"target_url\x18\x03 \x01(\tR\ttargetUrl\"\x86\x01\n" +
+	"\rVerifyRequest\x12\x16\n" +
+	"\x06driver\x18\x01 \x01(\tR\x06driver\x12\x1d\n" +
+	"\n" +
+	"source_url\x18\x02 \x01(\tR\tsourceUrl\x12\x1d\n" +
+	"\n" +
+	"target_url\x18\x03 \x01(\tR\ttargetUrl\x12\x1f\n" +
+	"\vscratch_url\x18\x04 \x01(\tR\n" +
+	"scratchUrl\"n\n" +
+	"\x0eVerifyResponse\x12(\n" +
+	"\x04plan\x18\x01 \x03(\v2\x14.dbdiff.v1.StatementR\x04plan\x122\n" +
+	"\tremaining\x18\x02 \x03(\v2\x14.dbdiff.v1.StatementR\tremaining2\xfe\x01\n" +
+	"\x06Dbdiff\x126\n" +
+	"\x04Diff\x12\x16.dbdiff.v1.DiffRequest\x1a\x14.dbdiff.v1.Statement0\x01\x12C\n" +
+	"\bSnapshot\x12\x1a.dbdiff.v1.SnapshotRequest\x1a\x1b.dbdiff.v1.SnapshotResponse\x128\n" +
+	"\x05Apply\x12\x17.dbdiff.v1.ApplyRequest\x1a\x14.dbdiff.v1.Statement0\x01\x12=\n" +
+	"\x06Verify\x12\x18.dbdiff.v1.VerifyRequest\x1a\x19.dbdiff.v1.VerifyResponseB7Z5github.com/quantumsheep/dbdiff/api/dbdiff/v1;dbdiffv1b\x06proto3"
+
+var (
+	file_api_dbdiff_v1_dbdiff_proto_rawDescOnce sync.Once
+	file_api_dbdiff_v1_dbdiff_proto_rawDescData []byte
+)
+
+func file_api_dbdiff_v1_dbdiff_proto_rawDescGZIP() []byte {
+	file_api_dbdiff_v1_dbdiff_proto_rawDescOnce.Do(func() {
+		file_api_dbdiff_v1_dbdiff_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_dbdiff_v1_dbdiff_proto_rawDesc), len(file_api_dbdiff_v1_dbdiff_proto_rawDesc)))
+	})
+	return file_api_dbdiff_v1_dbdiff_proto_rawDescData
+}
+
+var file_api_dbdiff_v1_dbdiff_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_api_dbdiff_v1_dbdiff_proto_goTypes = []any{
+	(*Database)(nil),         // 0: dbdiff.v1.Database
+	(*Statement)(nil),        // 1: dbdiff.v1.Statement
+	(*DiffRequest)(nil),      // 2: dbdiff.v1.DiffRequest
+	(*SnapshotRequest)(nil),  // 3: dbdiff.v1.SnapshotRequest
+	(*SnapshotResponse)(nil), // 4: dbdiff.v1.SnapshotResponse
+	(*ApplyRequest)(nil),     // 5: dbdiff.v1.ApplyRequest
+	(*VerifyRequest)(nil),    // 6: dbdiff.v1.VerifyRequest
+	(*VerifyResponse)(nil),   // 7: dbdiff.v1.VerifyResponse
+}
+var file_api_dbdiff_v1_dbdiff_proto_depIdxs = []int32{
+	0, // 0: dbdiff.v1.DiffRequest.source:type_name -> dbdiff.v1.Database
+	0, // 1: dbdiff.v1.DiffRequest.target:type_name -> dbdiff.v1.Database
+	0, // 2: dbdiff.v1.ApplyRequest.source:type_name -> dbdiff.v1.Database
+	1, // 3: dbdiff.v1.VerifyResponse.plan:type_name -> dbdiff.v1.Statement
+	1, // 4: dbdiff.v1.VerifyResponse.remaining:type_name -> dbdiff.v1.Statement
+	2, // 5: dbdiff.v1.Dbdiff.Diff:input_type -> dbdiff.v1.DiffRequest
+	3, // 6: dbdiff.v1.Dbdiff.Snapshot:input_type -> dbdiff.v1.SnapshotRequest
+	5, // 7: dbdiff.v1.Dbdiff.Apply:input_type -> dbdiff.v1.ApplyRequest
+	6, // 8: dbdiff.v1.Dbdiff.Verify:input_type -> dbdiff.v1.VerifyRequest
+	1, // 9: dbdiff.v1.Dbdiff.Diff:output_type -> dbdiff.v1.Statement
+	4, // 10: dbdiff.v1.Dbdiff.Snapshot:output_type -> dbdiff.v1.SnapshotResponse
+	1, // 11: dbdiff.v1.Dbdiff.Apply:output_type -> dbdiff.v1.Statement
+	7, // 12: dbdiff.v1.Dbdiff.Verify:output_type -> dbdiff.v1.VerifyResponse
+	9, // [9:13] is the sub-list for method output_type
+	5, // [5:9] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_api_dbdiff_v1_dbdiff_proto_init() }
+func file_api_dbdiff_v1_dbdiff_proto_init() {
+	if File_api_dbdiff_v1_dbdiff_proto != nil {
+		return
+	}
+	file_api_dbdiff_v1_dbdiff_proto_msgTypes[0].OneofWrappers = []any{
+		(*Database_Url)(nil),
+		(*Database_SchemaJson)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_dbdiff_v1_dbdiff_proto_rawDesc), len(file_api_dbdiff_v1_dbdiff_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_dbdiff_v1_dbdiff_proto_goTypes,
+		DependencyIndexes: file_api_dbdiff_v1_dbdiff_proto_depIdxs,
+		MessageInfos:      file_api_dbdiff_v1_dbdiff_proto_msgTypes,
+	}.Build()
+	File_api_dbdiff_v1_dbdiff_proto = out.File
+	file_api_dbdiff_v1_dbdiff_proto_goTypes = nil
+	file_api_dbdiff_v1_dbdiff_proto_depIdxs = nil
+}
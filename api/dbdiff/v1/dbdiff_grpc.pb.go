@@ -0,0 +1,264 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: api/dbdiff/v1/dbdiff.proto
+
+package dbdiffv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Dbdiff_Diff_FullMethodName     = "/dbdiff.v1.Dbdiff/Diff"
+	Dbdiff_Snapshot_FullMethodName = "/dbdiff.v1.Dbdiff/Snapshot"
+	Dbdiff_Apply_FullMethodName    = "/dbdiff.v1.Dbdiff/Apply"
+	Dbdiff_Verify_FullMethodName   = "/dbdiff.v1.Dbdiff/Verify"
+)
+
+// DbdiffClient is the client API for Dbdiff service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Dbdiff compares database schemas and migrates databases, as the dbdiff
+// command line does. Servers apply their own flags, such as ignore rules, to
+// every call.
+type DbdiffClient interface {
+	// Diff streams the statements migrating the target to the source.
+	Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Statement], error)
+	// Snapshot introspects a database.
+	Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotResponse, error)
+	// Apply executes the statements migrating the target to the source on the
+	// target, as dbdiff apply does, then streams them. The server must enable it
+	// with --allow-apply.
+	Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Statement], error)
+	// Verify applies the plan to a copy of the target in a scratch database
+	// and reports whatever is left to migrate afterwards.
+	Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error)
+}
+
+type dbdiffClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDbdiffClient(cc grpc.ClientConnInterface) DbdiffClient {
+	return &dbdiffClient{cc}
+}
+
+func (c *dbdiffClient) Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Statement], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Dbdiff_ServiceDesc.Streams[0], Dbdiff_Diff_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DiffRequest, Statement]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dbdiff_DiffClient = grpc.ServerStreamingClient[Statement]
+
+func (c *dbdiffClient) Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SnapshotResponse)
+	err := c.cc.Invoke(ctx, Dbdiff_Snapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dbdiffClient) Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Statement], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Dbdiff_ServiceDesc.Streams[1], Dbdiff_Apply_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ApplyRequest, Statement]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dbdiff_ApplyClient = grpc.ServerStreamingClient[Statement]
+
+func (c *dbdiffClient) Verify(ctx context.Context, in *VerifyRequest, opts ...grpc.CallOption) (*VerifyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VerifyResponse)
+	err := c.cc.Invoke(ctx, Dbdiff_Verify_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DbdiffServer is the server API for Dbdiff service.
+// All implementations must embed UnimplementedDbdiffServer
+// for forward compatibility.
+//
+// Dbdiff compares database schemas and migrates databases, as the dbdiff
+// command line does. Servers apply their own flags, such as ignore rules, to
+// every call.
+type DbdiffServer interface {
+	// Diff streams the statements migrating the target to the source.
+	Diff(*DiffRequest, grpc.ServerStreamingServer[Statement]) error
+	// Snapshot introspects a database.
+	Snapshot(context.Context, *SnapshotRequest) (*SnapshotResponse, error)
+	// Apply executes the statements migrating the target to the source on the
+	// target, as dbdiff apply does, then streams them. The server must enable it
+	// with --allow-apply.
+	Apply(*ApplyRequest, grpc.ServerStreamingServer[Statement]) error
+	// Verify applies the plan to a copy of the target in a scratch database
+	// and reports whatever is left to migrate afterwards.
+	Verify(context.Context, *VerifyRequest) (*VerifyResponse, error)
+	mustEmbedUnimplementedDbdiffServer()
+}
+
+// UnimplementedDbdiffServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDbdiffServer struct{}
+
+func (UnimplementedDbdiffServer) Diff(*DiffRequest, grpc.ServerStreamingServer[Statement]) error {
+	return status.Error(codes.Unimplemented, "method Diff not implemented")
+}
+func (UnimplementedDbdiffServer) Snapshot(context.Context, *SnapshotRequest) (*SnapshotResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Snapshot not implemented")
+}
+func (UnimplementedDbdiffServer) Apply(*ApplyRequest, grpc.ServerStreamingServer[Statement]) error {
+	return status.Error(codes.Unimplemented, "method Apply not implemented")
+}
+func (UnimplementedDbdiffServer) Verify(context.Context, *VerifyRequest) (*VerifyResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Verify not implemented")
+}
+func (UnimplementedDbdiffServer) mustEmbedUnimplementedDbdiffServer() {}
+func (UnimplementedDbdiffServer) testEmbeddedByValue()                {}
+
+// UnsafeDbdiffServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DbdiffServer will
+// result in compilation errors.
+type UnsafeDbdiffServer interface {
+	mustEmbedUnimplementedDbdiffServer()
+}
+
+func RegisterDbdiffServer(s grpc.ServiceRegistrar, srv DbdiffServer) {
+	// If the following call panics, it indicates UnimplementedDbdiffServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Dbdiff_ServiceDesc, srv)
+}
+
+func _Dbdiff_Diff_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DiffRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DbdiffServer).Diff(m, &grpc.GenericServerStream[DiffRequest, Statement]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dbdiff_DiffServer = grpc.ServerStreamingServer[Statement]
+
+func _Dbdiff_Snapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DbdiffServer).Snapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dbdiff_Snapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DbdiffServer).Snapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Dbdiff_Apply_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ApplyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DbdiffServer).Apply(m, &grpc.GenericServerStream[ApplyRequest, Statement]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Dbdiff_ApplyServer = grpc.ServerStreamingServer[Statement]
+
+func _Dbdiff_Verify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DbdiffServer).Verify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Dbdiff_Verify_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DbdiffServer).Verify(ctx, req.(*VerifyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Dbdiff_ServiceDesc is the grpc.ServiceDesc for Dbdiff service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Dbdiff_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dbdiff.v1.Dbdiff",
+	HandlerType: (*DbdiffServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Snapshot",
+			Handler:    _Dbdiff_Snapshot_Handler,
+		},
+		{
+			MethodName: "Verify",
+			Handler:    _Dbdiff_Verify_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Diff",
+			Handler:       _Dbdiff_Diff_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Apply",
+			Handler:       _Dbdiff_Apply_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/dbdiff/v1/dbdiff.proto",
+}
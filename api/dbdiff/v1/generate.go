@@ -0,0 +1,5 @@
+// Package dbdiffv1 holds the gRPC API served by `dbdiff serve --grpc-listen`,
+// generated from dbdiff.proto.
+package dbdiffv1
+
+//go:generate protoc --proto_path=../../.. --go_out=../../.. --go_opt=paths=source_relative --go-grpc_out=../../.. --go-grpc_opt=paths=source_relative api/dbdiff/v1/dbdiff.proto
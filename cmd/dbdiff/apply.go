@@ -0,0 +1,340 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/plan"
+	"github.com/urfave/cli/v3"
+)
+
+func applyCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "apply",
+		Usage:     "Apply a plan saved by `dbdiff plan` to its target",
+		UsageText: "dbdiff apply [options] --plan <file> [target | --targets <file>]",
+		Description: "The target is introspected again before applying anything. The plan is refused when the " +
+			"target schema changed since it was planned, in which case a new plan has to be made. " +
+			"Use the same comparison flags as when planning, such as --ignore-table, for the schemas to hash the same.",
+		Action: applyAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:      "plan",
+				Usage:     "Plan file to apply, - for stdin",
+				Required:  true,
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "targets",
+				Usage:     "File listing the databases to apply the plan to instead of the target, one URL or environment alias per line, e.g. every tenant shard",
+				TakesFile: true,
+			},
+			&cli.IntFlag{
+				Name:  "parallel",
+				Usage: "Maximum number of --targets migrated at once",
+				Value: 4,
+			},
+			&cli.StringFlag{
+				Name:      "state-file",
+				Usage:     "File recording the statements applied to each target, read back to resume an interrupted apply where it stopped, unless the target changed since",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "audit-log",
+				Usage:     "File every applied statement is appended to as a JSON line, with its time, duration, rows affected and error, - for stdout. Defaults to audit_log from the config file",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "verify-key",
+				Usage:     "PEM encoded Ed25519 public key the plan must be signed with, see dbdiff plan --sign-key",
+				TakesFile: true,
+			},
+			&cli.BoolFlag{
+				Name:  "check-steps",
+				Usage: "Run sanity checks around risky statements, such as comparing the row counts of a rebuilt table and the original one before dropping it, and stop applying the plan when one fails",
+			},
+			&cli.BoolFlag{
+				Name:  "require-approval",
+				Usage: "Refuse to apply the plan without --approval-token, as do approval.required and require_approval environments in the config file",
+			},
+			&cli.StringFlag{
+				Name:  "approval-token",
+				Usage: "Token of the second operator approving the plan, see dbdiff approve. Checked against the keys of approval.approvers in the config file",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "target",
+				UsageText: "Database to migrate. Defaults to the target recorded in the plan, which lacks any password",
+			},
+		},
+	}
+}
+
+func applyAction(ctx context.Context, cmd *cli.Command) error {
+	file, err := readPlanFile(cmd.String("plan"))
+	if err != nil {
+		return err
+	}
+
+	if keyPath := cmd.String("verify-key"); keyPath != "" {
+		err := verifyPlanFile(file, keyPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	config, err := loadConfig(cmd.String("config"))
+	if err != nil {
+		return err
+	}
+
+	targetURLs := []string{cmp.Or(cmd.StringArg("target"), file.Target.URL)}
+	if path := cmd.String("targets"); path != "" {
+		if cmd.StringArg("target") != "" {
+			return fmt.Errorf("--targets and the target argument are mutually exclusive")
+		}
+
+		targetURLs, err = readTargetsFile(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The plan may ask for an approval, but never waive the one the config
+	// or the operator requires
+	if cmd.Bool("require-approval") || file.RequiresApproval || slices.ContainsFunc(targetURLs, config.requiresApproval) {
+		err := checkPlanApproval(file, cmd.String("approval-token"), config.Approval.Approvers)
+		if err != nil {
+			return err
+		}
+	}
+
+	progress, err := loadApplyProgress(cmd.String("state-file"), file)
+	if err != nil {
+		return err
+	}
+
+	if cmd.String("targets") != "" {
+		return applyBatch(ctx, cmd, config, file, targetURLs, progress)
+	}
+
+	targetURL := targetURLs[0]
+	if progress.target(targetURL).Done {
+		fmt.Fprintf(cmd.Root().Writer, "-- Plan already applied to %s according to %s\n", redactURL(targetURL), progress.path)
+		return nil
+	}
+	return applyAndNotify(ctx, cmd, config, file, targetURL, cmd.Root().Writer, progress)
+}
+
+// applyAndNotify applies file to targetURL, writing the statements to w, and
+// notifies the webhooks of config of the outcome.
+func applyAndNotify(ctx context.Context, cmd *cli.Command, config *Config, file *plan.File, targetURL string, w io.Writer, progress *applyProgress) error {
+	e := event{
+		Event:      applyCompletedEvent,
+		Source:     file.Source.URL,
+		Target:     redactURL(targetURL),
+		Statements: file.Statements,
+	}
+
+	err := applyPlanFile(ctx, cmd, file, targetURL, cmp.Or(cmd.String("audit-log"), config.AuditLog), w, progress)
+	var drift *driftError
+	switch {
+	case errors.As(err, &drift):
+		e.Event = driftEvent
+		e.Summary = fmt.Sprintf("Target %s changed since the plan was made, the plan was not applied", e.Target)
+		if drift.Applied > 0 {
+			e.Summary = fmt.Sprintf("Target %s changed since statement %d of the plan was applied, the remaining statements were not applied", e.Target, drift.Applied)
+		}
+	case err != nil:
+		e.Event = applyFailedEvent
+		e.Summary = fmt.Sprintf("Failed to apply plan with %d statement(s) to %s", len(file.Statements), e.Target)
+		e.Error = err.Error()
+	default:
+		e.Summary = fmt.Sprintf("Applied plan with %d statement(s) to %s", len(file.Statements), e.Target)
+	}
+
+	notify(ctx, cmd.Root().ErrWriter, config, e)
+	return err
+}
+
+// driftError reports a target whose schema changed since it was planned, or
+// since the last statement applied before an apply was interrupted.
+type driftError struct {
+	Planned string
+	Actual  string
+	Applied int
+}
+
+func (e *driftError) Error() string {
+	if e.Applied > 0 {
+		return fmt.Sprintf("target schema changed since statement %d of the plan was applied (%s, expected %s), inspect it before applying the remaining statements", e.Applied, e.Actual, cmp.Or(e.Planned, "no hash recorded"))
+	}
+	return fmt.Sprintf("target schema changed since the plan was made (%s, planned against %s), create a new plan", e.Actual, e.Planned)
+}
+
+// applyPlanFile executes the statements of file on targetURL, once checked
+// that the target is still the one the plan was made against, and writes them
+// to w. Each statement is recorded in the audit log at auditPath, unless
+// empty. Targets progress records as partially migrated resume after their
+// last applied statement.
+func applyPlanFile(ctx context.Context, cmd *cli.Command, file *plan.File, targetURL string, auditPath string, w io.Writer, progress *applyProgress) error {
+	driverName := cmp.Or(cmd.String("driver"), file.Dialect)
+
+	driver, err := openNamedDriver(ctx, cmd, driverName, targetURL, targetURL, drivers.WithReadOnly(false))
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	executor, ok := drivers.As[drivers.Executor](driver)
+	if !ok {
+		return fmt.Errorf("%s driver cannot execute statements", driverName)
+	}
+
+	target, err := driver.Introspect(ctx, drivers.TargetSide)
+	if err != nil {
+		return fmt.Errorf("failed to inspect target database: %w", err)
+	}
+
+	if target.Dialect != file.Dialect {
+		return fmt.Errorf("plan was made for %s, not %s", file.Dialect, target.Dialect)
+	}
+
+	// Partially migrated targets no longer hash like the planned one, but
+	// like they did once their last statement was applied
+	applied, expected := 0, file.Target.Hash
+	if resumed := progress.target(targetURL); resumed.Applied > 0 {
+		applied, expected = resumed.Applied, resumed.Hash
+	}
+	if hash := target.Hash(); hash != expected {
+		return &driftError{Planned: expected, Actual: hash, Applied: applied}
+	}
+
+	runChecks := func(int) error { return nil }
+	if cmd.Bool("check-steps") {
+		checker, ok := drivers.As[drivers.StepChecker](driver)
+		if !ok {
+			return fmt.Errorf("%s driver cannot check the steps of plans", driverName)
+		}
+
+		checks := checker.StepChecks(file.Statements)
+		runChecks = func(i int) error {
+			for _, check := range checks[i] {
+				passed, err := checker.RunStepCheck(ctx, drivers.TargetSide, check)
+				if err != nil {
+					return fmt.Errorf("failed to check that %s: %w", check.Description, err)
+				}
+				if !passed && i == len(file.Statements) {
+					return fmt.Errorf("check failed once the plan was applied: expected %s", check.Description)
+				}
+				if !passed {
+					return fmt.Errorf("check failed before statement %d, the %d remaining statement(s) were not applied: expected %s", i+1, len(file.Statements)-i, check.Description)
+				}
+				fmt.Fprintf(w, "-- Checked that %s\n", check.Description)
+			}
+			return nil
+		}
+	}
+
+	exec := func(statement string) error {
+		return executor.Exec(ctx, drivers.TargetSide, statement)
+	}
+	if auditPath != "" {
+		audit, err := openAuditLog(auditPath, targetURL, file.Checksum)
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		defer audit.Close()
+
+		exec = func(statement string) error {
+			return audit.exec(ctx, executor, statement)
+		}
+	}
+
+	// The audit log replaces the statements on stdout
+	if auditPath == "-" {
+		w = io.Discard
+	}
+
+	// Progress is saved along with the schema each statement leaves, which
+	// takes introspecting the target again
+	recordProgress := func(applied int) error {
+		if progress == nil {
+			return nil
+		}
+
+		target, err := driver.Introspect(ctx, drivers.TargetSide)
+		if err != nil {
+			return fmt.Errorf("failed to inspect target database after statement %d: %w", applied, err)
+		}
+		return progress.record(targetURL, applied, applied == len(file.Statements), target.Hash())
+	}
+
+	if applied > 0 {
+		fmt.Fprintf(w, "-- Resuming after statement %d of %d\n", applied, len(file.Statements))
+	}
+
+	for i, statement := range file.Statements[applied:] {
+		err := runChecks(applied + i)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w, statement)
+
+		err = exec(statement)
+		if err != nil {
+			return fmt.Errorf("failed to execute statement: %w\n%s", err, statement)
+		}
+
+		err = recordProgress(applied + i + 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = runChecks(len(file.Statements))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "-- Plan applied: %d statement(s)\n", len(file.Statements))
+	return nil
+}
+
+// verifyPlanFile checks that file was signed with the private key of the
+// public key at keyPath.
+func verifyPlanFile(file *plan.File, keyPath string) error {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+
+	key, err := plan.ParsePublicKey(data)
+	if err != nil {
+		return fmt.Errorf("invalid verification key %s: %w", keyPath, err)
+	}
+	return file.Verify(key)
+}
+
+// readPlanFile reads the plan file at path, or stdin for -.
+func readPlanFile(path string) (*plan.File, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	return plan.ReadFile(r)
+}
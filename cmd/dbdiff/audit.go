@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+)
+
+// auditEntry is a line of the audit log, recording a statement applied to a
+// target.
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	Target string    `json:"target"`
+
+	// Plan is the checksum of the plan file the statement comes from, when
+	// it was sealed.
+	Plan string `json:"plan,omitempty"`
+
+	Statement  string  `json:"statement"`
+	DurationMS float64 `json:"duration_ms"`
+
+	// RowsAffected is missing when the driver does not report it.
+	RowsAffected *int64 `json:"rows_affected,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// auditLog appends a JSON line per applied statement to a file, or writes
+// them to stdout.
+type auditLog struct {
+	w      io.Writer
+	closer io.Closer
+
+	target string
+	plan   string
+}
+
+// openAuditLog opens the audit log at path, - for stdout, creating it when
+// missing. Entries are only ever appended.
+func openAuditLog(path string, target string, plan string) (*auditLog, error) {
+	log := &auditLog{w: os.Stdout, target: redactURL(target), plan: plan}
+	if path == "-" {
+		return log, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	log.w, log.closer = f, f
+	return log, nil
+}
+
+// exec runs statement on the target of executor and records it, along with
+// its outcome.
+func (l *auditLog) exec(ctx context.Context, executor drivers.Executor, statement string) error {
+	entry := auditEntry{Time: time.Now().UTC(), Target: l.target, Plan: l.plan, Statement: statement}
+
+	var err error
+	if rowsExecutor, ok := executor.(drivers.RowsExecutor); ok {
+		var rows int64
+		rows, err = rowsExecutor.ExecRows(ctx, drivers.TargetSide, statement)
+		if err == nil && rows >= 0 {
+			entry.RowsAffected = &rows
+		}
+	} else {
+		err = executor.Exec(ctx, drivers.TargetSide, statement)
+	}
+
+	entry.DurationMS = float64(time.Since(entry.Time).Microseconds()) / 1000
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	// A statement missing from the log is worse than a failed apply
+	logErr := json.NewEncoder(l.w).Encode(entry)
+	if err != nil {
+		return err
+	}
+	return logErr
+}
+
+func (l *auditLog) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// azureDatabaseResource is the resource Azure AD tokens are requested for to
+// sign in to Azure Database for PostgreSQL.
+const azureDatabaseResource = "https://ossrdbms-aad.database.windows.net"
+
+// azureTokenTimeout bounds each token request, notably to the instance
+// metadata service, which does not answer outside of Azure.
+const azureTokenTimeout = 10 * time.Second
+
+// azureADParam matches the azure_ad parameter of keyword/value connection
+// strings.
+var azureADParam = regexp.MustCompile(`(?:^|\s)azure_ad\s*=\s*(\S+)`)
+
+// withoutAzureAD removes the azure_ad parameter from a connection string, in
+// either URL or keyword/value form, reporting whether it requested Azure AD
+// authentication.
+func withoutAzureAD(connectionString string) (string, bool) {
+	if strings.HasPrefix(connectionString, "postgres://") || strings.HasPrefix(connectionString, "postgresql://") {
+		u, err := url.Parse(connectionString)
+		if err != nil || !u.Query().Has("azure_ad") {
+			return connectionString, false
+		}
+
+		query := u.Query()
+		enabled, _ := strconv.ParseBool(query.Get("azure_ad"))
+		query.Del("azure_ad")
+		u.RawQuery = query.Encode()
+		return u.String(), enabled
+	}
+
+	match := azureADParam.FindStringSubmatch(connectionString)
+	if match == nil {
+		return connectionString, false
+	}
+
+	enabled, _ := strconv.ParseBool(strings.Trim(match[1], `'`))
+	return strings.TrimSpace(azureADParam.ReplaceAllString(connectionString, "")), enabled
+}
+
+// azureTokens fetches Azure AD access tokens to use as database passwords,
+// from the managed identity of the host or else from the Azure CLI, reusing
+// them until they are about to expire.
+type azureTokens struct {
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func (t *azureTokens) password(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Until(t.expiresAt) > time.Minute {
+		return t.token, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, azureTokenTimeout)
+	defer cancel()
+
+	token, expiresAt, err := fetchAzureToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Azure AD token: %w", err)
+	}
+
+	t.token, t.expiresAt = token, expiresAt
+	return token, nil
+}
+
+// fetchAzureToken asks, in order, the managed identity endpoint of App
+// Service and Container Apps, the Azure CLI when installed, and the instance
+// metadata service of virtual machines.
+func fetchAzureToken(ctx context.Context) (string, time.Time, error) {
+	if endpoint := os.Getenv("IDENTITY_ENDPOINT"); endpoint != "" {
+		query := url.Values{"resource": {azureDatabaseResource}, "api-version": {"2019-08-01"}}
+		if clientID := os.Getenv("AZURE_CLIENT_ID"); clientID != "" {
+			query.Set("client_id", clientID)
+		}
+		return requestAzureToken(ctx, endpoint+"?"+query.Encode(), "X-IDENTITY-HEADER", os.Getenv("IDENTITY_HEADER"))
+	}
+
+	if _, err := exec.LookPath("az"); err == nil {
+		return azureCLIToken(ctx)
+	}
+
+	query := url.Values{"resource": {azureDatabaseResource}, "api-version": {"2018-02-01"}}
+	if clientID := os.Getenv("AZURE_CLIENT_ID"); clientID != "" {
+		query.Set("client_id", clientID)
+	}
+	return requestAzureToken(ctx, "http://169.254.169.254/metadata/identity/oauth2/token?"+query.Encode(), "Metadata", "true")
+}
+
+// azureToken is the token response of managed identity endpoints and the
+// Azure CLI, which spell their fields differently.
+type azureToken struct {
+	AccessToken    string          `json:"access_token"`
+	CLIAccessToken string          `json:"accessToken"`
+	ExpiresOn      json.RawMessage `json:"expires_on"`
+}
+
+func (t *azureToken) parse() (string, time.Time, error) {
+	token := t.AccessToken
+	if token == "" {
+		token = t.CLIAccessToken
+	}
+	if token == "" {
+		return "", time.Time{}, errors.New("response lacks an access token")
+	}
+
+	// expires_on is a number of seconds since the epoch, quoted by managed
+	// identity endpoints. Tokens without it are fetched again soon.
+	expiresOn, err := strconv.ParseInt(strings.Trim(string(t.ExpiresOn), `"`), 10, 64)
+	if err != nil {
+		return token, time.Now().Add(5 * time.Minute), nil
+	}
+	return token, time.Unix(expiresOn, 0), nil
+}
+
+func requestAzureToken(ctx context.Context, endpoint string, header string, value string) (string, time.Time, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	request.Header.Set(header, value)
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return "", time.Time{}, fmt.Errorf("unexpected status %s from managed identity endpoint", response.Status)
+	}
+
+	var token azureToken
+	err = json.NewDecoder(response.Body).Decode(&token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token.parse()
+}
+
+func azureCLIToken(ctx context.Context) (string, time.Time, error) {
+	output, err := exec.CommandContext(ctx, "az", "account", "get-access-token", "--resource", azureDatabaseResource, "--output", "json").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", time.Time{}, fmt.Errorf("az account get-access-token failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", time.Time{}, err
+	}
+
+	var token azureToken
+	err = json.Unmarshal(output, &token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token.parse()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/quantumsheep/dbdiff/plan"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/sync/errgroup"
+)
+
+// batchResult is the outcome of applying a plan to one of several targets.
+type batchResult struct {
+	target   string
+	status   string
+	duration time.Duration
+	err      error
+}
+
+// applyBatch applies file to every target, --parallel at a time, reporting
+// each target once done and a summary at the end. Failing targets do not
+// stop the others. Targets progress records as migrated are skipped.
+func applyBatch(ctx context.Context, cmd *cli.Command, config *Config, file *plan.File, targetURLs []string, progress *applyProgress) error {
+	if len(targetURLs) == 0 {
+		return fmt.Errorf("no target listed in --targets")
+	}
+
+	w := cmd.Root().Writer
+	var mu sync.Mutex
+
+	results := make([]*batchResult, len(targetURLs))
+
+	var g errgroup.Group
+	g.SetLimit(max(int(cmd.Int("parallel")), 1))
+
+	for i, targetURL := range targetURLs {
+		result := &batchResult{target: redactURL(targetURL), status: "skipped"}
+		results[i] = result
+
+		if progress.target(targetURL).Done {
+			result.status = "applied before"
+			continue
+		}
+
+		g.Go(func() error {
+			// Targets not started yet are left alone once interrupted
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			start := time.Now()
+			err := applyAndNotify(ctx, cmd, config, file, targetURL, io.Discard, progress)
+			result.duration = time.Since(start)
+			result.err = err
+
+			var drift *driftError
+			switch {
+			case errors.As(err, &drift):
+				result.status = "drifted"
+			case err != nil:
+				result.status = "failed"
+			default:
+				result.status = "applied"
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fmt.Fprintf(w, "-- %s: %s after %s: %s\n", result.target, result.status, result.duration.Round(time.Millisecond), firstLine(err.Error()))
+			} else {
+				fmt.Fprintf(w, "-- %s: applied %d statement(s) in %s\n", result.target, len(file.Statements), result.duration.Round(time.Millisecond))
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	fmt.Fprintln(w, "\n-- Summary")
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "-- TARGET\tSTATUS\tDURATION")
+	failed := 0
+	for _, result := range results {
+		if result.status != "applied" && result.status != "applied before" {
+			failed++
+		}
+		fmt.Fprintf(table, "-- %s\t%s\t%s\n", result.target, result.status, result.duration.Round(time.Millisecond))
+	}
+
+	err := table.Flush()
+	if err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets failed", failed, len(targetURLs))
+	}
+	return nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/urfave/cli/v3"
+)
+
+// complete suggests driver names after --driver, and subcommands along with
+// the environment aliases from the config file in place of database URLs.
+func complete(ctx context.Context, cmd *cli.Command) {
+	// The shell calls `dbdiff <args...> --generate-shell-completion`, the
+	// word being completed is the one before the completion flag.
+	var lastArg string
+	if len(os.Args) > 2 {
+		lastArg = os.Args[len(os.Args)-2]
+	}
+
+	if lastArg == "--driver" {
+		for _, name := range drivers.Names() {
+			fmt.Fprintln(cmd.Root().Writer, name)
+		}
+		return
+	}
+
+	cli.DefaultCompleteWithFlags(ctx, cmd)
+	if strings.HasPrefix(lastArg, "-") {
+		return
+	}
+
+	config, err := loadConfig(cmd.String("config"))
+	if err != nil {
+		return
+	}
+
+	aliases := make([]string, 0, len(config.Environments))
+	for alias := range config.Environments {
+		aliases = append(aliases, alias)
+	}
+	slices.Sort(aliases)
+
+	for _, alias := range aliases {
+		fmt.Fprintln(cmd.Root().Writer, alias)
+	}
+}
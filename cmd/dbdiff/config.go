@@ -0,0 +1,245 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/policy"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigPath is read when --config is not given, if it exists.
+const defaultConfigPath = "dbdiff.yaml"
+
+type Config struct {
+	// Environments are aliases usable in place of database URLs, e.g.
+	// `dbdiff staging production`.
+	Environments map[string]Environment `yaml:"environments"`
+
+	Lint LintConfig `yaml:"lint"`
+
+	// Policies reject plans making disallowed changes, such as dropping
+	// audit tables.
+	Policies []policy.Policy `yaml:"policies"`
+
+	// Notifications are webhooks called on drift and apply events.
+	Notifications []Notification `yaml:"notifications"`
+
+	// Monitors are the pairs of databases `dbdiff monitor` checks for drift.
+	Monitors []Monitor `yaml:"monitors"`
+
+	// IgnoreColumns lists, by table, the columns left out of diffs, e.g.
+	// `ignore_columns: {users: [legacy_flags]}`. Names may be globs.
+	IgnoreColumns schema.IgnoredColumns `yaml:"ignore_columns"`
+
+	// AuditLog is the file `dbdiff apply` appends every applied statement
+	// to. Overridden by --audit-log.
+	AuditLog string `yaml:"audit_log"`
+
+	// MaxRewriteRows refuses plans rewriting tables estimated to hold more
+	// rows, e.g. `max_rewrite_rows: 1_000_000`. Overridden by
+	// --max-rewrite-rows.
+	MaxRewriteRows int64 `yaml:"max_rewrite_rows"`
+
+	// Approval lists who may approve plans before `dbdiff apply` runs them.
+	Approval ApprovalConfig `yaml:"approval"`
+}
+
+type ApprovalConfig struct {
+	// Required makes `dbdiff apply` refuse every plan lacking an approval
+	// token, see `dbdiff approve`. Environments may require it on their own.
+	Required bool `yaml:"required"`
+
+	// Approvers are the files holding the PEM encoded Ed25519 public keys of
+	// the operators whose approval tokens are accepted, e.g.
+	// `approvers: [keys/alice.pub.pem]`.
+	Approvers []string `yaml:"approvers"`
+}
+
+type Environment struct {
+	Driver string `yaml:"driver"`
+
+	// URL and Password may reference HashiCorp Vault secrets, as
+	// vault:<path>#<key>, read when the environment is used.
+	URL string `yaml:"url"`
+
+	// Password, when set, is added to URL so that the URL itself can be
+	// committed, e.g. `password: vault:secret/data/db#password`. Defaults to
+	// the password stored by `dbdiff login`, if any.
+	Password string `yaml:"password"`
+
+	// TLS overrides the TLS flags for this environment, field by field.
+	TLS TLSSettings `yaml:"tls"`
+
+	// SSH is the jump host the database is reached through, as
+	// [user@]host[:port]. Overrides --ssh.
+	SSH string `yaml:"ssh"`
+
+	// RequireApproval makes `dbdiff apply` refuse plans lacking an approval
+	// token when migrating this environment, e.g. production only.
+	RequireApproval bool `yaml:"require_approval"`
+}
+
+type TLSSettings struct {
+	Mode     string `yaml:"sslmode"`
+	RootCert string `yaml:"sslrootcert"`
+	Cert     string `yaml:"sslcert"`
+	Key      string `yaml:"sslkey"`
+}
+
+type LintConfig struct {
+	// Severities overrides the severity of lint rules by name, e.g.
+	// `drop-column: error` or `non-concurrent-index: off`.
+	Severities map[string]string `yaml:"severities"`
+}
+
+type Notification struct {
+	// URL receives a POST request for each event.
+	URL string `yaml:"url"`
+
+	// Type is the payload format: http (default) for the event as JSON,
+	// slack for an incoming webhook message.
+	Type string `yaml:"type"`
+
+	// Events restricts the notification to some events: drift, apply, or
+	// apply.completed and apply.failed. Empty matches every event.
+	Events []string `yaml:"events"`
+
+	// Headers are added to http requests, e.g. for authentication.
+	Headers map[string]string `yaml:"headers"`
+}
+
+// loadConfig reads the configuration file at path. A missing file yields an
+// empty configuration unless it was explicitly requested.
+func loadConfig(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	err = yaml.Unmarshal(content, config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	for _, p := range config.Policies {
+		err := p.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	return config, nil
+}
+
+// resolve replaces environment aliases with their database URL, along with
+// the driver they declare, if any.
+func (c *Config) resolve(urlOrAlias string) (string, string) {
+	environment, found := c.Environments[urlOrAlias]
+	if !found {
+		return urlOrAlias, ""
+	}
+	return environment.URL, environment.Driver
+}
+
+// resolveURL is resolve reading the Vault secrets the environment references,
+// and adding its password to its URL, or else the one dbdiff login stored.
+func (c *Config) resolveURL(ctx context.Context, secrets *vaultSecrets, urlOrAlias string) (string, error) {
+	environment, found := c.Environments[urlOrAlias]
+	if !found {
+		return urlOrAlias, nil
+	}
+
+	databaseURL, err := secrets.resolve(ctx, environment.URL)
+	if err != nil {
+		return "", fmt.Errorf("environment %s: %w", urlOrAlias, err)
+	}
+
+	if environment.Password == "" {
+		password, found, err := keyringPassword(ctx, urlOrAlias)
+		if err != nil {
+			return "", fmt.Errorf("environment %s: failed to read OS keyring: %w", urlOrAlias, err)
+		}
+		if !found {
+			return databaseURL, nil
+		}
+		return withPassword(databaseURL, password), nil
+	}
+
+	password, err := secrets.resolve(ctx, environment.Password)
+	if err != nil {
+		return "", fmt.Errorf("environment %s: %w", urlOrAlias, err)
+	}
+	return withPassword(databaseURL, password), nil
+}
+
+// withPassword sets the password of a connection string, in either URL or
+// keyword/value form.
+func withPassword(connectionString string, password string) string {
+	if strings.Contains(connectionString, "://") {
+		u, err := url.Parse(connectionString)
+		if err != nil {
+			// Leave it to the driver to report
+			return connectionString
+		}
+
+		u.User = url.UserPassword(u.User.Username(), password)
+		return u.String()
+	}
+
+	value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(password)
+	return fmt.Sprintf("%s password='%s'", connectionString, value)
+}
+
+// tls returns the TLS settings of the --ssl* flags, overridden by the ones
+// of the environment urlOrAlias refers to.
+func (c *Config) tls(cmd *cli.Command, urlOrAlias string) drivers.TLSConfig {
+	environment := c.Environments[urlOrAlias]
+
+	return drivers.TLSConfig{
+		Mode:     cmp.Or(environment.TLS.Mode, cmd.String("sslmode")),
+		RootCert: cmp.Or(environment.TLS.RootCert, cmd.String("sslrootcert")),
+		Cert:     cmp.Or(environment.TLS.Cert, cmd.String("sslcert")),
+		Key:      cmp.Or(environment.TLS.Key, cmd.String("sslkey")),
+	}
+}
+
+// ssh returns the jump host of the environment urlOrAlias refers to, or else
+// the one of --ssh.
+func (c *Config) ssh(cmd *cli.Command, urlOrAlias string) string {
+	return cmp.Or(c.Environments[urlOrAlias].SSH, cmd.String("ssh"))
+}
+
+// requiresApproval reports whether plans migrating urlOrAlias need an approval
+// token, because of approval.required or of the environment it refers to,
+// by alias or by URL.
+func (c *Config) requiresApproval(urlOrAlias string) bool {
+	if c.Approval.Required {
+		return true
+	}
+
+	for alias, environment := range c.Environments {
+		if environment.RequireApproval && (alias == urlOrAlias || environment.URL == urlOrAlias) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+)
+
+// printWithContract writes the statements migrating the target to w, except
+// the deferred destructive ones which go to the file at path, and returns how
+// many statements there were in total.
+func printWithContract(ctx context.Context, driver drivers.Driver, w io.Writer, path string) (int, error) {
+	planner, ok := drivers.As[drivers.ObjectPlanner](driver)
+	if !ok {
+		return 0, fmt.Errorf("driver cannot group statements by object")
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := 0
+	for object, err := range planner.ObjectStatements(ctx) {
+		if err != nil {
+			return count, fmt.Errorf("failed to diff databases: %w", err)
+		}
+
+		out := w
+		if object.Contract {
+			out = file
+		}
+		for _, statement := range object.Statements {
+			fmt.Fprintln(out, statement)
+		}
+		count += len(object.Statements)
+	}
+
+	return count, file.Close()
+}
+
+// printExpandContract writes the statements migrating the target to w, under
+// a comment telling when to apply each phase, and returns how many statements
+// there were.
+func printExpandContract(ctx context.Context, driver drivers.Driver, w io.Writer) (int, error) {
+	planner, ok := drivers.As[drivers.ObjectPlanner](driver)
+	if !ok {
+		return 0, fmt.Errorf("driver cannot group statements by object")
+	}
+
+	count := 0
+	phase := ""
+	for object, err := range planner.ObjectStatements(ctx) {
+		if err != nil {
+			return count, fmt.Errorf("failed to diff databases: %w", err)
+		}
+
+		switch {
+		case !object.Contract && phase == "":
+			phase = "expand"
+			fmt.Fprintln(w, "-- Expand phase: apply before deploying the application")
+		case object.Contract && phase != "contract":
+			if phase != "" {
+				fmt.Fprintln(w)
+			}
+			phase = "contract"
+			fmt.Fprintln(w, "-- Contract phase: apply once every instance of the previous version of the application has stopped")
+		}
+
+		for _, statement := range object.Statements {
+			fmt.Fprintln(w, statement)
+		}
+		count += len(object.Statements)
+	}
+
+	return count, nil
+}
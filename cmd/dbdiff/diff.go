@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+)
+
+func diffAction(ctx context.Context, cmd *cli.Command) error {
+	sourceDatabaseURL := cmd.StringArg("source")
+	if sourceDatabaseURL == "" {
+		return fmt.Errorf("source database URL is required")
+	}
+
+	targetDatabaseURLs, err := diffTargets(cmd)
+	if err != nil {
+		return err
+	}
+
+	var opts []drivers.Option
+
+	// The snapshot stands in for the target, which is the source database
+	// as it was
+	if since := cmd.String("since"); since != "" {
+		if len(targetDatabaseURLs) > 0 {
+			return fmt.Errorf("--since compares a single database against a snapshot, not against other databases")
+		}
+
+		stored, err := newSnapshotStore(cmd).load(since)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, drivers.WithTargetSnapshot(stored.Schema))
+		targetDatabaseURLs = []string{sourceDatabaseURL}
+	}
+
+	report := printStatements
+	switch cmd.String("format") {
+	case "github":
+		report = githubReport
+	case "json":
+		if len(targetDatabaseURLs) > 1 {
+			return fmt.Errorf("--format json requires a single target database")
+		}
+		report = jsonReport
+	}
+
+	// Diffs failing --fail-on are only reported once every plan is written
+	policy, err := parseFailOnPolicy(cmd.String("fail-on"))
+	if err != nil {
+		return err
+	}
+	failOn := &failOnRecorder{policy: policy}
+
+	if policy != failOnNone {
+		opts = append(opts, failOn.option())
+	}
+
+	if directory := cmd.String("split-output"); directory != "" {
+		if len(targetDatabaseURLs) != 1 {
+			return fmt.Errorf("--split-output requires a single target database")
+		}
+
+		count, err := writeSplitPlan(ctx, cmd, directory, sourceDatabaseURL, targetDatabaseURLs[0], opts...)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.Root().ErrWriter, "Plan with %d statement(s) written to %s\n", count, directory)
+		return failOn.err()
+	}
+
+	if cmd.String("contract-output") != "" && len(targetDatabaseURLs) != 1 {
+		return fmt.Errorf("--contract-output requires a single target database")
+	}
+
+	switch len(targetDatabaseURLs) {
+	case 0:
+		return fmt.Errorf("target database URL is required")
+	case 1:
+		_, err := report(ctx, cmd, os.Stdout, sourceDatabaseURL, targetDatabaseURLs[0], opts...)
+		if err != nil {
+			return err
+		}
+		return failOn.err()
+	}
+
+	err = fanOut(ctx, cmd, os.Stdout, sourceDatabaseURL, targetDatabaseURLs, report, opts...)
+	if err != nil {
+		return err
+	}
+	return failOn.err()
+}
+
+// reportFunc writes the plan migrating targetURL to sourceURL and returns how
+// many statements it has.
+type reportFunc func(ctx context.Context, cmd *cli.Command, w io.Writer, sourceURL string, targetURL string, opts ...drivers.Option) (int, error)
+
+// diffTargets returns the target arguments followed by the ones listed in
+// --targets-file.
+func diffTargets(cmd *cli.Command) ([]string, error) {
+	targets := cmd.StringArgs("targets")
+
+	path := cmd.String("targets-file")
+	if path == "" {
+		return targets, nil
+	}
+
+	listed, err := readTargetsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return append(targets, listed...), nil
+}
+
+// readTargetsFile reads a file listing databases, one URL or environment
+// alias per line.
+func readTargetsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var targets []string
+
+	// One URL or environment alias per line, blank lines and # comments
+	// are skipped
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read targets file: %w", err)
+	}
+
+	return targets, nil
+}
+
+// printStatements writes the statements migrating targetURL to sourceURL and
+// returns how many there were.
+func printStatements(ctx context.Context, cmd *cli.Command, w io.Writer, sourceURL string, targetURL string, opts ...drivers.Option) (int, error) {
+	if cmd.Bool("header") {
+		// The plan is rendered from the very schemas the header hashes
+		source, target, err := introspectBoth(ctx, cmd, "", sourceURL, targetURL, opts...)
+		if err != nil {
+			return 0, err
+		}
+		printHeader(w, sourceURL, source, targetURL, target)
+
+		opts = append(opts, drivers.WithSourceSnapshot(source), drivers.WithTargetSnapshot(target))
+	}
+
+	driver, err := openDriver(ctx, cmd, sourceURL, targetURL, opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer driver.Close()
+
+	if path := cmd.String("contract-output"); path != "" {
+		return printWithContract(ctx, driver, w, path)
+	}
+	if cmd.Bool("expand-contract") {
+		return printExpandContract(ctx, driver, w)
+	}
+
+	count := 0
+	for statement, err := range driver.Statements(ctx) {
+		if err != nil {
+			return count, fmt.Errorf("failed to diff databases: %w", err)
+		}
+
+		fmt.Fprintln(w, statement)
+		count++
+	}
+
+	return count, nil
+}
+
+// printHeader writes the comment block opening plans generated with
+// --header, tracing a script back to what it was generated from.
+func printHeader(w io.Writer, sourceURL string, source *schema.Schema, targetURL string, target *schema.Schema) {
+	fmt.Fprintf(w, "-- Generated by dbdiff %s\n", buildVersion())
+	fmt.Fprintf(w, "-- Source: %s (%s)\n", redactURL(sourceURL), source.Hash())
+	fmt.Fprintf(w, "-- Target: %s (%s)\n", redactURL(targetURL), target.Hash())
+	fmt.Fprintf(w, "-- Generated at: %s\n\n", time.Now().UTC().Format(time.RFC3339))
+}
+
+// fanOut compares the source against every target in turn, writing a report
+// per target followed by a summary of which ones differ. Failing targets are
+// reported without stopping the others.
+func fanOut(ctx context.Context, cmd *cli.Command, w io.Writer, sourceURL string, targetURLs []string, report reportFunc, opts ...drivers.Option) error {
+	// Introspect the source once rather than for every target
+	if cmd.String("cache-dir") == "" {
+		opts = append(opts, drivers.WithCache(drivers.NewIntrospectionCache("")))
+	}
+
+	type result struct {
+		target     string
+		statements int
+		err        error
+	}
+
+	var results []result
+	failed := 0
+
+	for _, targetURL := range targetURLs {
+		target := redactURL(targetURL)
+		fmt.Fprintf(w, "-- Target: %s\n", target)
+
+		statements, err := report(ctx, cmd, w, sourceURL, targetURL, opts...)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			fmt.Fprintf(w, "-- Error: %s\n", err)
+			failed++
+		}
+		fmt.Fprintln(w)
+
+		results = append(results, result{target: target, statements: statements, err: err})
+	}
+
+	fmt.Fprintln(w, "-- Summary")
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "-- TARGET\tSTATUS\tSTATEMENTS")
+	for _, result := range results {
+		status := "in sync"
+		switch {
+		case result.err != nil:
+			status = "error"
+		case result.statements > 0:
+			status = "differs"
+		}
+
+		fmt.Fprintf(table, "-- %s\t%s\t%d\n", result.target, status, result.statements)
+	}
+
+	err := table.Flush()
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d targets failed", failed, len(targetURLs))
+	}
+
+	return nil
+}
+
+// redactedParameters are the connection parameters holding secrets, in the
+// query of connection URLs and in keyword/value connection strings alike.
+var redactedParameters = map[string]bool{
+	"password":    true,
+	"sslpassword": true,
+	"_key":        true,
+	"_auth_pass":  true,
+}
+
+// connectionParameter matches the keyword/value pairs of connection strings
+// such as host=db password='se cret', values being quoted when they hold
+// spaces.
+var connectionParameter = regexp.MustCompile(`([A-Za-z_]+)(\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+// keywordValueDSN matches connection strings made of keyword/value pairs,
+// as opposed to URLs and file paths.
+var keywordValueDSN = regexp.MustCompile(`^\s*[A-Za-z_]+\s*=`)
+
+// queryParameter matches the name=value pairs of URL queries.
+var queryParameter = regexp.MustCompile(`([?&])([^=&#]+)=([^&#]*)`)
+
+// redactUnparsedURL hides the secrets of URLs url.Parse or url.ParseQuery
+// reject, such as passwords holding invalid escapes. The password is whatever
+// lies between the scheme and the last @, and query pairs are matched one by
+// one.
+func redactUnparsedURL(databaseURL string) string {
+	scheme, rest, found := strings.Cut(databaseURL, "://")
+	if !found {
+		scheme, rest = "", databaseURL
+	}
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		user, _, hasPassword := strings.Cut(rest[:at], ":")
+		if hasPassword {
+			user += ":xxxxx"
+		}
+		rest = user + rest[at:]
+	}
+	rest = queryParameter.ReplaceAllStringFunc(rest, func(parameter string) string {
+		match := queryParameter.FindStringSubmatch(parameter)
+		if !redactedParameters[strings.ToLower(match[2])] {
+			return parameter
+		}
+		return match[1] + match[2] + "=xxxxx"
+	})
+	if found {
+		return scheme + "://" + rest
+	}
+	return rest
+}
+
+// redactURL hides the password of connection URLs and keyword/value
+// connection strings, and the key of encrypted SQLite databases, so reports
+// can be shared.
+func redactURL(databaseURL string) string {
+	if !strings.Contains(databaseURL, "://") && keywordValueDSN.MatchString(databaseURL) {
+		return connectionParameter.ReplaceAllStringFunc(databaseURL, func(parameter string) string {
+			match := connectionParameter.FindStringSubmatch(parameter)
+			if !redactedParameters[strings.ToLower(match[1])] {
+				return parameter
+			}
+			return match[1] + match[2] + "xxxxx"
+		})
+	}
+
+	parsed, err := url.Parse(databaseURL)
+	if err != nil {
+		return redactUnparsedURL(databaseURL)
+	}
+	query, err := url.ParseQuery(parsed.RawQuery)
+	if err != nil {
+		return redactUnparsedURL(databaseURL)
+	}
+
+	redacted := false
+	for name := range query {
+		if redactedParameters[strings.ToLower(name)] {
+			query.Set(name, "xxxxx")
+			redacted = true
+		}
+	}
+	if redacted {
+		parsed.RawQuery = query.Encode()
+	} else if parsed.User == nil {
+		return databaseURL
+	}
+	return parsed.Redacted()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactURL(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{"URLUserinfo", "postgres://app:secret@db:5432/app", "postgres://app:xxxxx@db:5432/app"},
+		{"URLQuery", "postgres://db/app?sslmode=require&password=secret", "postgres://db/app?password=xxxxx&sslmode=require"},
+		{"URLSSLPassword", "postgres://app@db/app?sslpassword=secret", "postgres://app@db/app?sslpassword=xxxxx"},
+		{"URLInvalidEscape", "postgres://bob:p%zz@db/app", "postgres://bob:xxxxx@db/app"},
+		{"URLInvalidEscapeWithAt", "postgres://bob:p@%zz@db/app?sslpassword=s%zz&sslmode=require", "postgres://bob:xxxxx@db/app?sslpassword=xxxxx&sslmode=require"},
+		{"URLInvalidEscapeInQuery", "postgres://db/app?password=s%zz", "postgres://db/app?password=xxxxx"},
+		{"URLWithoutSecret", "postgres://db/app?sslmode=require", "postgres://db/app?sslmode=require"},
+		{"KeywordValue", "host=db user=app password=secret dbname=app", "host=db user=app password=xxxxx dbname=app"},
+		{"KeywordValueQuoted", `host=db password='se cr\'et' sslpassword = other`, "host=db password=xxxxx sslpassword = xxxxx"},
+		{"KeywordValueWithoutSecret", "host=db dbname=app", "host=db dbname=app"},
+		{"SQLiteKey", "/tmp/app.db?_key=secret", "/tmp/app.db?_key=xxxxx"},
+		{"SQLitePath", "/tmp/app.db", "/tmp/app.db"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, redactURL(test.url))
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+)
+
+func docsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "docs",
+		Usage:     "Generate Markdown documentation of the tables and views of a database",
+		UsageText: "dbdiff docs [options] <url>",
+		Description: "Writes a page per table, listing its columns, constraints, indexes and triggers along with the tables it references " +
+			"and the ones referencing it, and an index page linking them and listing the views. Pages of tables that no longer exist are left alone.",
+		Action: docsAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:      "output",
+				Aliases:   []string{"o"},
+				Usage:     "Directory to write the pages to, created when missing",
+				Value:     "docs",
+				TakesFile: true,
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "url",
+				UsageText: "Database connection URL, path or environment alias",
+			},
+		},
+	}
+}
+
+func docsAction(ctx context.Context, cmd *cli.Command) error {
+	databaseURL := cmd.StringArg("url")
+	if databaseURL == "" {
+		return fmt.Errorf("database URL is required")
+	}
+
+	s, err := introspectDatabase(ctx, cmd, "", databaseURL)
+	if err != nil {
+		return err
+	}
+	s = s.Sorted()
+
+	directory := cmd.String("output")
+	err = os.MkdirAll(directory, 0o755)
+	if err != nil {
+		return err
+	}
+
+	pages := map[string]string{"index.md": schemaIndexPage(s)}
+	for _, table := range s.Tables {
+		pages[tablePageName(table.Name)] = tablePage(s, table)
+	}
+
+	for name, content := range pages {
+		err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0o644)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(cmd.Root().ErrWriter, "Documented %d table(s) and %d view(s) in %s\n", len(s.Tables), len(s.Views), directory)
+	return nil
+}
+
+var unsafePageName = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// tablePageName is the file name of the page of a table.
+func tablePageName(table string) string {
+	return unsafePageName.ReplaceAllString(table, "_") + ".md"
+}
+
+// schemaIndexPage lists the tables, linking to their page, and the views of
+// s along with their definition.
+func schemaIndexPage(s *schema.Schema) string {
+	var b strings.Builder
+
+	b.WriteString("# Schema\n\n")
+	fmt.Fprintf(&b, "Generated by dbdiff from a %s database.\n", s.Dialect)
+
+	if len(s.Tables) > 0 {
+		b.WriteString("\n## Tables\n\n")
+		b.WriteString("| Table | Columns | Referenced by |\n")
+		b.WriteString("| --- | --- | --- |\n")
+		for _, table := range s.Tables {
+			fmt.Fprintf(&b, "| [%s](%s) | %d | %d |\n", markdownCell(table.Name), tablePageName(table.Name), len(table.Columns), len(referencingTables(s, table.Name)))
+		}
+	}
+
+	if len(s.Views) > 0 {
+		b.WriteString("\n## Views\n")
+		for _, view := range s.Views {
+			fmt.Fprintf(&b, "\n### %s\n\n", view.Name)
+			if dependencies := s.ViewDependencies(view); len(dependencies) > 0 {
+				fmt.Fprintf(&b, "Depends on %s.\n\n", tableLinks(s, dependencies))
+			}
+			fmt.Fprintf(&b, "```sql\n%s\n```\n", strings.TrimSpace(view.Def))
+		}
+	}
+
+	return b.String()
+}
+
+// tablePage documents a table of s.
+func tablePage(s *schema.Schema, table *schema.Table) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n[Schema](index.md)\n", table.Name)
+
+	b.WriteString("\n## Columns\n\n")
+	b.WriteString("| Column | Type | Nullable | Default | Primary key |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, column := range table.Columns {
+		nullable := "yes"
+		if column.NotNull {
+			nullable = "no"
+		}
+		primaryKey := ""
+		if column.PrimaryKey {
+			primaryKey = "yes"
+		}
+		defaultValue := ""
+		if column.Default.Valid {
+			defaultValue = "`" + markdownCell(column.Default.String) + "`"
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | %s | %s | %s |\n", markdownCell(column.Name), markdownCell(column.Type), nullable, defaultValue, primaryKey)
+	}
+
+	if len(table.Constraints) > 0 {
+		b.WriteString("\n## Constraints\n\n")
+		b.WriteString("| Constraint | Type | Definition |\n")
+		b.WriteString("| --- | --- | --- |\n")
+		for _, constraint := range table.Constraints {
+			fmt.Fprintf(&b, "| %s | %s | `%s` |\n", markdownCell(constraint.Name), constraintTypes[constraint.Type], markdownCell(constraint.Def))
+		}
+	}
+
+	if len(table.ForeignKeys) > 0 {
+		b.WriteString("\n## Foreign keys\n\n")
+		for _, fk := range table.ForeignKeys {
+			fmt.Fprintf(&b, "- `%s`\n", describeForeignKey(fk))
+		}
+	}
+
+	if len(table.Indexes) > 0 {
+		b.WriteString("\n## Indexes\n\n")
+		b.WriteString("| Index | Columns | Unique | Definition |\n")
+		b.WriteString("| --- | --- | --- | --- |\n")
+		for _, index := range table.Indexes {
+			unique := ""
+			if index.Unique {
+				unique = "yes"
+			}
+			definition := ""
+			if index.Def != "" {
+				definition = "`" + markdownCell(index.Def) + "`"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", markdownCell(index.Name), markdownCell(strings.Join(index.Columns, ", ")), unique, definition)
+		}
+	}
+
+	if len(table.Triggers) > 0 {
+		b.WriteString("\n## Triggers\n")
+		for _, trigger := range table.Triggers {
+			fmt.Fprintf(&b, "\n### %s\n\n```sql\n%s\n```\n", trigger.Name, strings.TrimSpace(trigger.Def))
+		}
+	}
+
+	if references := table.References(); len(references) > 0 {
+		fmt.Fprintf(&b, "\n## References\n\n%s\n", tableLinks(s, references))
+	}
+	if referencing := referencingTables(s, table.Name); len(referencing) > 0 {
+		fmt.Fprintf(&b, "\n## Referenced by\n\n%s\n", tableLinks(s, referencing))
+	}
+
+	return b.String()
+}
+
+var constraintTypes = map[string]string{
+	"p": "primary key",
+	"u": "unique",
+	"c": "check",
+	"f": "foreign key",
+	"x": "exclusion",
+}
+
+// referencingTables lists the tables of s whose foreign keys reference the
+// named table.
+func referencingTables(s *schema.Schema, name string) []string {
+	var referencing []string
+	for _, table := range s.Tables {
+		if slices.Contains(table.References(), name) {
+			referencing = append(referencing, table.Name)
+		}
+	}
+	return referencing
+}
+
+// tableLinks lists names, linking the ones of tables of s to their page.
+func tableLinks(s *schema.Schema, names []string) string {
+	links := make([]string, len(names))
+	for i, name := range names {
+		if _, found := s.TableByName(name); found {
+			links[i] = fmt.Sprintf("[%s](%s)", name, tablePageName(name))
+		} else {
+			links[i] = name
+		}
+	}
+	return strings.Join(links, ", ")
+}
+
+// markdownCell escapes s for a Markdown table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/urfave/cli/v3"
+)
+
+func driftCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "drift",
+		Usage:     "Check a database against an expected schema snapshot",
+		UsageText: "dbdiff drift [options] --expected <schema.json> <url>",
+		Description: "Prints the statements bringing the database back to the snapshot written by `dbdiff inspect --format json`, " +
+			"and exits with code 2 when there are any, so that a nightly job fails when the database drifted. " +
+			"Drift notifications are sent along the way.",
+		Action: driftAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:      "expected",
+				Usage:     "Schema snapshot the database is expected to match",
+				Required:  true,
+				TakesFile: true,
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "url",
+				UsageText: "Database connection URL, path or environment alias",
+			},
+		},
+	}
+}
+
+// driftDetectedError reports a database deviating from its expected schema,
+// once the offending statements were written. It exits with failOnExitCode,
+// like diffs failing --fail-on.
+type driftDetectedError struct {
+	database   string
+	statements int
+}
+
+func (e *driftDetectedError) Error() string {
+	return fmt.Sprintf("%s drifted from its expected schema, %d statement(s) needed to bring it back", e.database, e.statements)
+}
+
+func driftAction(ctx context.Context, cmd *cli.Command) error {
+	databaseURL := cmd.StringArg("url")
+	if databaseURL == "" {
+		return fmt.Errorf("database URL is required")
+	}
+
+	config, err := loadConfig(cmd.String("config"))
+	if err != nil {
+		return err
+	}
+
+	monitor := Monitor{SourceSnapshot: cmd.String("expected"), Target: databaseURL}
+	objects, err := checkDrift(ctx, cmd, monitor)
+	if err != nil {
+		return err
+	}
+
+	var statements []string
+	for _, object := range objects {
+		// Session settings are part of every plan, they are not drift
+		if object.Type == drivers.SettingsObject {
+			continue
+		}
+		statements = append(statements, object.Statements...)
+	}
+	if len(statements) == 0 {
+		fmt.Fprintf(cmd.Root().ErrWriter, "%s matches %s\n", redactURL(databaseURL), monitor.SourceSnapshot)
+		return nil
+	}
+
+	for _, object := range objects {
+		for _, statement := range object.Statements {
+			fmt.Fprintln(cmd.Root().Writer, statement)
+		}
+	}
+
+	drift := &driftDetectedError{database: redactURL(databaseURL), statements: len(statements)}
+	notify(ctx, cmd.Root().ErrWriter, config, event{
+		Event:      driftEvent,
+		Source:     monitor.SourceSnapshot,
+		Target:     drift.database,
+		Summary:    drift.Error(),
+		Statements: statements,
+	})
+	return drift
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/policy"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+)
+
+// openDriver opens the driver comparing sourceURL to targetURL, configured by
+// the global flags then extraOpts. Both URLs may be environment aliases from
+// the config file, whose driver is used unless --driver is set.
+func openDriver(ctx context.Context, cmd *cli.Command, sourceURL string, targetURL string, extraOpts ...drivers.Option) (drivers.Driver, error) {
+	return openNamedDriver(ctx, cmd, "", sourceURL, targetURL, extraOpts...)
+}
+
+// openNamedDriver is openDriver using the driver registered as driverName,
+// unless empty.
+func openNamedDriver(ctx context.Context, cmd *cli.Command, driverName string, sourceURL string, targetURL string, extraOpts ...drivers.Option) (drivers.Driver, error) {
+	setup, err := newDriverSetup(ctx, cmd, driverName, sourceURL, targetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := append(setup.opts, drivers.WithSourceDSN(setup.sourceDSN), drivers.WithTargetDSN(setup.targetDSN))
+	opts = append(opts, extraOpts...)
+
+	driver, err := drivers.Open(setup.driverName, opts...)
+	if err != nil {
+		if setup.tunnels != nil {
+			setup.tunnels.Close()
+		}
+		return nil, fmt.Errorf("failed to create %s driver: %w", setup.driverName, err)
+	}
+
+	if setup.tunnels != nil {
+		return &tunneledDriver{Driver: driver, tunnels: setup.tunnels}, nil
+	}
+
+	return driver, nil
+}
+
+// introspectDatabase reads the schema of a single database, for commands that
+// describe it rather than compare it to another, configured like openDriver.
+// No target is set up, see drivers.Introspect.
+func introspectDatabase(ctx context.Context, cmd *cli.Command, driverName string, databaseURL string, extraOpts ...drivers.Option) (*schema.Schema, error) {
+	setup, err := newDriverSetup(ctx, cmd, driverName, databaseURL, "")
+	if err != nil {
+		return nil, err
+	}
+	if setup.tunnels != nil {
+		defer setup.tunnels.Close()
+	}
+
+	s, err := drivers.Introspect(ctx, setup.sourceDSN, setup.driverName, append(setup.opts, extraOpts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect database: %w", err)
+	}
+	return s, nil
+}
+
+// openSchemaRenderer opens the driver of the dialect of s, configured by the
+// global flags, with s on both sides, for rendering s without connecting to
+// any database.
+func openSchemaRenderer(ctx context.Context, cmd *cli.Command, s *schema.Schema) (drivers.Driver, error) {
+	return openNamedDriver(ctx, cmd, s.Dialect, "", "", drivers.WithSourceSnapshot(s), drivers.WithTargetSnapshot(s))
+}
+
+// driverSetup is what opening a driver takes once the config file and the
+// global flags are read: the driver name, the data source names with their
+// secrets, the other options and the SSH tunnels to close along with the
+// driver, if any.
+type driverSetup struct {
+	driverName string
+	sourceDSN  string
+	targetDSN  string
+	opts       []drivers.Option
+	tunnels    *sshTunnels
+}
+
+// newDriverSetup resolves sourceURL and targetURL, which may be environment
+// aliases, and builds the driver options from the global flags. Sides with an
+// empty URL are left unset, without SSH tunnel.
+func newDriverSetup(ctx context.Context, cmd *cli.Command, driverName string, sourceURL string, targetURL string) (*driverSetup, error) {
+	config, err := loadConfig(cmd.String("config"))
+	if err != nil {
+		return nil, err
+	}
+
+	driverName = cmp.Or(driverName, resolveDriverName(cmd, config, sourceURL, targetURL))
+	sourceTLS := config.tls(cmd, sourceURL)
+	targetTLS := config.tls(cmd, targetURL)
+	var sourceSSH, targetSSH string
+	if sourceURL != "" {
+		sourceSSH = config.ssh(cmd, sourceURL)
+	}
+	if targetURL != "" {
+		targetSSH = config.ssh(cmd, targetURL)
+	}
+	secrets := newVaultSecrets()
+	sourceURL, err = config.resolveURL(ctx, secrets, sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	targetURL, err = config.resolveURL(ctx, secrets, targetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceURL, sourceAzureAD := withoutAzureAD(sourceURL)
+	targetURL, targetAzureAD := withoutAzureAD(targetURL)
+
+	opts := []drivers.Option{
+		drivers.WithSourceTLS(sourceTLS),
+		drivers.WithTargetTLS(targetTLS),
+		drivers.WithConcurrency(cmd.Int("concurrency")),
+		drivers.WithQueryTimeout(cmd.Duration("query-timeout")),
+		drivers.WithMaxOpenConns(cmd.Int("max-open-conns")),
+		drivers.WithMaxIdleConns(cmd.Int("max-idle-conns")),
+		drivers.WithConnectTimeout(cmd.Duration("connect-timeout")),
+		drivers.WithPgxPool(cmd.Bool("pgxpool")),
+		drivers.WithReadOnly(cmd.Bool("read-only")),
+		drivers.WithSQLiteKey(cmd.String("sqlite-key")),
+		drivers.WithPragmas(cmd.Bool("pragmas")),
+		drivers.WithUserVersion(cmd.String("user-version")),
+		drivers.WithStrictTypes(cmd.Bool("strict-types")),
+		drivers.WithStrictDefinitions(cmd.Bool("strict-definitions")),
+		drivers.WithCaseInsensitiveNames(cmd.Bool("case-insensitive-names")),
+		drivers.WithLockTimeout(cmd.Duration("lock-timeout")),
+		drivers.WithStatementTimeout(cmd.Duration("statement-timeout")),
+		drivers.WithSchemaFilter(cmd.String("schema")),
+		drivers.WithIntrospectionMode(drivers.IntrospectionMode(cmd.String("introspection"))),
+		drivers.WithAnnotations(cmd.Bool("annotate")),
+		drivers.WithLockWarnings(cmd.Int64("large-table-rows"), cmd.StringSlice("hot-table")...),
+		drivers.WithOnlineDDL(cmd.Bool("online")),
+		drivers.WithDeferredValidation(cmd.Bool("defer-validation")),
+		drivers.WithBackfillBatchSize(cmd.Int("backfill-batch-size")),
+		drivers.WithSequenceAlignment(cmd.Bool("align-sequences")),
+		drivers.WithTables(cmd.StringSlice("table")...),
+		drivers.WithDeterministicOrder(cmd.Bool("sorted")),
+		drivers.WithDeferredDestructive(cmd.Bool("defer-destructive") || cmd.String("contract-output") != ""),
+		drivers.WithExpandContract(cmd.Bool("expand-contract")),
+	}
+
+	ignoreRules, err := ignoreRules(cmd)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, drivers.WithIgnoreRules(ignoreRules...))
+
+	skippedTypes, err := skippedTypes(cmd)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, drivers.WithSkippedTypes(skippedTypes...))
+
+	typeEquivalence, err := typeEquivalence(cmd)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, drivers.WithTypeEquivalence(typeEquivalence))
+
+	err = config.IgnoreColumns.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid ignore_columns: %w", err)
+	}
+	opts = append(opts, drivers.WithIgnoredColumns(config.IgnoreColumns))
+
+	for _, value := range cmd.StringSlice("map-table") {
+		mapping, err := schema.ParseNameMapping(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --map-table value: %w", err)
+		}
+		opts = append(opts, drivers.WithTableMappings(mapping))
+	}
+
+	if mapping := cmd.String("map-schema"); mapping != "" {
+		sourceSchema, targetSchema, ok := strings.Cut(mapping, "=")
+		if !ok || sourceSchema == "" || targetSchema == "" {
+			return nil, fmt.Errorf("invalid --map-schema value %q, expected SOURCE=TARGET", mapping)
+		}
+		if schemaName := cmd.String("schema"); schemaName != "" && schemaName != targetSchema {
+			return nil, fmt.Errorf("--map-schema compares against %s, not --schema %s", targetSchema, schemaName)
+		}
+		opts = append(opts, drivers.WithSourceSchema(sourceSchema), drivers.WithSchemaFilter(targetSchema))
+	}
+
+	if cmd.Bool("redact-names") {
+		opts = append(opts, drivers.WithRedactedNames(cmd.String("redact-key")))
+	}
+
+	templateVars, err := parseTemplateVars("template-var", cmd.StringSlice("template-var"))
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, drivers.WithTemplateVars(templateVars...))
+
+	if cmd.Bool("verbose") {
+		logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		opts = append(opts, drivers.WithLogger(logger))
+	}
+
+	if cmd.Bool("progress") {
+		opts = append(opts, drivers.WithProgress(logProgress(os.Stderr)))
+	}
+
+	if cacheDir := cmd.String("cache-dir"); cacheDir != "" {
+		opts = append(opts, drivers.WithCache(drivers.NewIntrospectionCache(cacheDir)))
+	}
+
+	tokens := &azureTokens{}
+	if sourceAzureAD {
+		opts = append(opts, drivers.WithSourcePasswordFunc(tokens.password))
+	}
+	if targetAzureAD {
+		opts = append(opts, drivers.WithTargetPasswordFunc(tokens.password))
+	}
+
+	var tunnels *sshTunnels
+	if sourceSSH != "" || targetSSH != "" {
+		tunnels = newSSHTunnels(cmd)
+
+		for _, side := range []struct {
+			destination string
+			option      func(drivers.DialFunc) drivers.Option
+		}{
+			{sourceSSH, drivers.WithSourceDialFunc},
+			{targetSSH, drivers.WithTargetDialFunc},
+		} {
+			if side.destination == "" {
+				continue
+			}
+
+			dial, err := tunnels.dialFunc(ctx, side.destination)
+			if err != nil {
+				tunnels.Close()
+				return nil, err
+			}
+			opts = append(opts, side.option(dial))
+		}
+	}
+
+	if !cmd.Bool("allow-large-rewrites") {
+		opts = append(opts, drivers.WithMaxRewriteRows(cmp.Or(cmd.Int64("max-rewrite-rows"), config.MaxRewriteRows)))
+	}
+
+	if len(config.Policies) > 0 {
+		opts = append(opts, drivers.WithDiffCheck(policy.Check(config.Policies...)))
+	}
+
+	return &driverSetup{
+		driverName: driverName,
+		sourceDSN:  sourceURL,
+		targetDSN:  targetURL,
+		opts:       opts,
+		tunnels:    tunnels,
+	}, nil
+}
+
+// resolveDriverName returns the driver selected by --driver, or else the one
+// declared by the environments sourceURL and targetURL refer to.
+func resolveDriverName(cmd *cli.Command, config *Config, sourceURL string, targetURL string) string {
+	_, sourceDriver := config.resolve(sourceURL)
+	_, targetDriver := config.resolve(targetURL)
+
+	return cmp.Or(cmd.String("driver"), sourceDriver, targetDriver, "sqlite3")
+}
+
+// driverNameFor loads the config file to resolve the driver comparing
+// sourceURL and targetURL, see resolveDriverName.
+func driverNameFor(cmd *cli.Command, sourceURL string, targetURL string) (string, error) {
+	config, err := loadConfig(cmd.String("config"))
+	if err != nil {
+		return "", err
+	}
+	return resolveDriverName(cmd, config, sourceURL, targetURL), nil
+}
+
+// ignoreRules builds the ignore rules from the --ignore-* flags.
+func ignoreRules(cmd *cli.Command) ([]schema.IgnoreRule, error) {
+	var rules []schema.IgnoreRule
+
+	for _, objectType := range schema.ObjectTypes {
+		flag := "ignore-" + string(objectType)
+		for _, pattern := range cmd.StringSlice(flag) {
+			rule, err := schema.IgnoreGlob(objectType, pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --%s pattern %q: %w", flag, pattern, err)
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// skippedTypes lists the object types left out of plans by --only and
+// --skip.
+func skippedTypes(cmd *cli.Command) ([]schema.ObjectType, error) {
+	skipped, err := schema.ParseObjectTypes(cmd.String("skip"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid --skip value: %w", err)
+	}
+
+	if cmd.IsSet("only") {
+		only, err := schema.ParseObjectTypes(cmd.String("only"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --only value: %w", err)
+		}
+		for _, objectType := range schema.ObjectTypes {
+			if !slices.Contains(only, objectType) {
+				skipped = append(skipped, objectType)
+			}
+		}
+	}
+
+	return skipped, nil
+}
+
+// typeEquivalence builds the type equivalence hook from --equivalent-types.
+func typeEquivalence(cmd *cli.Command) (schema.TypeEquivalence, error) {
+	var pairs [][2]string
+
+	for _, value := range cmd.StringSlice("equivalent-types") {
+		a, b, found := strings.Cut(value, "=")
+		if !found || a == "" || b == "" {
+			return nil, fmt.Errorf("invalid --equivalent-types value %q, expected TYPE=TYPE", value)
+		}
+		pairs = append(pairs, [2]string{strings.TrimSpace(a), strings.TrimSpace(b)})
+	}
+
+	return schema.EquivalentTypes(pairs...), nil
+}
+
+// logProgress logs progress updates at most once per second, plus the
+// completion of every phase.
+func logProgress(w io.Writer) drivers.ProgressFunc {
+	var last time.Time
+
+	return func(progress drivers.Progress) {
+		if progress.Done < progress.Total && time.Since(last) < time.Second {
+			return
+		}
+		last = time.Now()
+
+		phase := string(progress.Phase)
+		if progress.Side != "" {
+			phase += " of " + string(progress.Side)
+		}
+
+		fmt.Fprintf(w, "%s: %d/%d tables\n", phase, progress.Done, progress.Total)
+	}
+}
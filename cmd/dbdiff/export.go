@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+)
+
+func exportCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "export",
+		Usage:     "Export the schema of a database for other tools",
+		UsageText: "dbdiff export [options] --format <format> <url>",
+		Description: "sqlc writes a schema.sql for sqlc: the statements creating the tables, indexes, triggers and views, " +
+			"in dependency then name order so that exports of the same schema are identical, " +
+			"without session settings, ownership or privileges. " +
+			"go writes a Go file declaring a struct per table, with a field per column tagged with its db and json names, " +
+			"to scaffold data access code.",
+		Action: exportAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "format",
+				Usage:    "Export format: sqlc or go",
+				Required: true,
+				Validator: func(s string) error {
+					switch s {
+					case "sqlc", "go":
+						return nil
+					}
+					return fmt.Errorf("unsupported format: %s", s)
+				},
+			},
+			&cli.StringFlag{
+				Name:  "package",
+				Usage: "Package name of the Go file written by --format go",
+				Value: "models",
+			},
+			&cli.StringFlag{
+				Name:      "output",
+				Aliases:   []string{"o"},
+				Usage:     "File to write the export to, - for stdout",
+				Value:     "-",
+				TakesFile: true,
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "url",
+				UsageText: "Database connection URL, path or environment alias",
+			},
+		},
+	}
+}
+
+func exportAction(ctx context.Context, cmd *cli.Command) error {
+	databaseURL := cmd.StringArg("url")
+	if databaseURL == "" {
+		return fmt.Errorf("database URL is required")
+	}
+
+	s, err := introspectDatabase(ctx, cmd, "", databaseURL)
+	if err != nil {
+		return err
+	}
+	s = s.Sorted()
+
+	export := func(w io.Writer) error {
+		return writeGoStructs(w, cmd.String("package"), s)
+	}
+	if cmd.String("format") == "sqlc" {
+		driver, err := openSchemaRenderer(ctx, cmd, s)
+		if err != nil {
+			return err
+		}
+		defer driver.Close()
+
+		renderer, ok := drivers.As[drivers.ObjectRenderer](driver)
+		if !ok {
+			return fmt.Errorf("driver cannot group statements by object")
+		}
+		export = func(w io.Writer) error {
+			return writeSQLCSchema(w, renderer, s)
+		}
+	}
+
+	out := cmd.String("output")
+	if out == "-" {
+		return export(cmd.Root().Writer)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+
+	err = export(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeSQLCSchema writes the statements creating s, an object per
+// paragraph, leaving out the session settings sqlc has no use for.
+func writeSQLCSchema(w io.Writer, renderer drivers.ObjectRenderer, s *schema.Schema) error {
+	first := true
+	for object, err := range renderer.RenderObjects(schema.Compare(s, &schema.Schema{Dialect: s.Dialect})) {
+		if err != nil {
+			return err
+		}
+		if object.Type == drivers.SettingsObject {
+			continue
+		}
+
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+
+		for _, statement := range object.Statements {
+			fmt.Fprintln(w, statement)
+		}
+	}
+	return nil
+}
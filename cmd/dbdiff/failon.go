@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// failOnExitCode is the exit code of diffs failing the --fail-on policy, set
+// apart from the 1 of errors so that pipelines can tell drift from failures.
+const failOnExitCode = 2
+
+// failOnPolicy tells which diffs make dbdiff exit with failOnExitCode.
+type failOnPolicy string
+
+const (
+	// failOnNone always exits 0 once the plan is written.
+	failOnNone failOnPolicy = "none"
+
+	// failOnAny fails whenever a target differs from the source.
+	failOnAny failOnPolicy = "any"
+
+	// failOnDestructive fails when the plan drops tables, views, columns or
+	// other objects, see schema.Diff.IsDestructive.
+	failOnDestructive failOnPolicy = "destructive"
+)
+
+func parseFailOnPolicy(s string) (failOnPolicy, error) {
+	switch policy := failOnPolicy(s); policy {
+	case failOnNone, failOnAny, failOnDestructive:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unsupported --fail-on policy: %s", s)
+}
+
+// failOnError reports diffs failing the --fail-on policy, once their plan was
+// written.
+type failOnError struct {
+	policy  failOnPolicy
+	targets int
+}
+
+func (e *failOnError) Error() string {
+	if e.policy == failOnDestructive {
+		return fmt.Sprintf("%d target(s) need destructive changes", e.targets)
+	}
+	return fmt.Sprintf("%d target(s) differ from the source", e.targets)
+}
+
+// failOnRecorder checks the diffs of every target against the --fail-on
+// policy, counting the ones failing it.
+type failOnRecorder struct {
+	policy failOnPolicy
+
+	mu     sync.Mutex
+	failed int
+}
+
+// option registers the recorder as a check of the diffs the driver renders.
+func (r *failOnRecorder) option() drivers.Option {
+	return drivers.WithDiffCheck(func(diff *schema.Diff) error {
+		var fails bool
+		switch r.policy {
+		case failOnAny:
+			fails = !diff.IsEmpty()
+		case failOnDestructive:
+			fails = diff.IsDestructive()
+		}
+
+		if fails {
+			r.mu.Lock()
+			r.failed++
+			r.mu.Unlock()
+		}
+		return nil
+	})
+}
+
+// err returns a failOnError when any diff failed the policy.
+func (r *failOnRecorder) err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failed == 0 {
+		return nil
+	}
+	return &failOnError{policy: r.policy, targets: r.failed}
+}
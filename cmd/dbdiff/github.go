@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/lint"
+	"github.com/urfave/cli/v3"
+)
+
+// githubReport is printStatements for GitHub Actions: the plan goes in a
+// collapsed log group, each lint finding becomes a workflow annotation, and a
+// Markdown summary is appended to the job summary when GITHUB_STEP_SUMMARY is
+// set.
+func githubReport(ctx context.Context, cmd *cli.Command, w io.Writer, sourceURL string, targetURL string, opts ...drivers.Option) (int, error) {
+	driver, err := openDriver(ctx, cmd, sourceURL, targetURL, opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer driver.Close()
+
+	var statements []string
+	for statement, err := range driver.Statements(ctx) {
+		if err != nil {
+			return 0, fmt.Errorf("failed to diff databases: %w", err)
+		}
+		statements = append(statements, statement)
+	}
+
+	config, err := loadConfig(cmd.String("config"))
+	if err != nil {
+		return 0, err
+	}
+
+	lintOpts, err := lintOptions(cmd, config)
+	if err != nil {
+		return 0, err
+	}
+	lintOpts = append(lintOpts, lint.WithDialect(resolveDriverName(cmd, config, sourceURL, targetURL)))
+
+	findings := lint.Lint(statements, lintOpts...)
+
+	fmt.Fprintf(w, "::group::Plan for %s (%d statements)\n", redactURL(targetURL), len(statements))
+	for _, statement := range statements {
+		fmt.Fprintln(w, statement)
+	}
+	fmt.Fprintln(w, "::endgroup::")
+
+	for _, finding := range findings {
+		fmt.Fprintf(w, "::%s title=%s::%s\n",
+			githubAnnotationLevel(finding.Severity),
+			githubEscapeProperty("dbdiff "+finding.Rule),
+			githubEscapeData(finding.Message+"\n"+statements[finding.Statement]),
+		)
+	}
+
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		err := appendGitHubSummary(path, redactURL(targetURL), statements, findings)
+		if err != nil {
+			return len(statements), fmt.Errorf("failed to write job summary: %w", err)
+		}
+	}
+
+	return len(statements), nil
+}
+
+func githubAnnotationLevel(severity lint.Severity) string {
+	switch severity {
+	case lint.Error:
+		return "error"
+	case lint.Warning:
+		return "warning"
+	}
+	return "notice"
+}
+
+// githubEscapeData escapes the message of a workflow command.
+func githubEscapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubEscapeProperty escapes a property value of a workflow command.
+func githubEscapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+func appendGitHubSummary(path string, target string, statements []string, findings []*lint.Finding) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "### Schema diff: `%s`\n\n", target)
+
+	if len(statements) == 0 {
+		summary.WriteString("The target matches the source.\n\n")
+		_, err = file.WriteString(summary.String())
+		return err
+	}
+
+	fmt.Fprintf(&summary, "%d statement(s) migrate the target to the source.\n\n", len(statements))
+
+	if len(findings) > 0 {
+		summary.WriteString("| Severity | Rule | Statement | Finding |\n|---|---|---|---|\n")
+		for _, finding := range findings {
+			fmt.Fprintf(&summary, "| %s | `%s` | %d | %s |\n",
+				finding.Severity, finding.Rule, finding.Statement+1, strings.ReplaceAll(finding.Message, "|", `\|`))
+		}
+		summary.WriteString("\n")
+	}
+
+	summary.WriteString("<details><summary>Plan</summary>\n\n```sql\n")
+	for _, statement := range statements {
+		summary.WriteString(statement + "\n")
+	}
+	summary.WriteString("```\n\n</details>\n\n")
+
+	_, err = file.WriteString(summary.String())
+	return err
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// writeGoStructs writes a Go file declaring a struct per table of s, with a
+// field per column tagged with its db and json names. Columns of types
+// without a Go counterpart are typed any, nullable ones are pointers.
+func writeGoStructs(w io.Writer, packageName string, s *schema.Schema) error {
+	if !token.IsIdentifier(packageName) {
+		return fmt.Errorf("invalid package name %q", packageName)
+	}
+
+	var body strings.Builder
+	imports := map[string]bool{}
+	structNames := map[string]bool{}
+
+	for _, table := range s.Tables {
+		structName := uniqueGoName(structNames, goName(singular(table.Name)))
+
+		fmt.Fprintf(&body, "\n// %s is a row of the %s table.\n", structName, table.Name)
+		fmt.Fprintf(&body, "type %s struct {\n", structName)
+
+		fieldNames := map[string]bool{}
+		for _, column := range table.Columns {
+			goType, importPath := goColumnType(s.Dialect, column.Type)
+			if importPath != "" {
+				imports[importPath] = true
+			}
+			// Slices, any and json.RawMessage hold NULL as nil already
+			if !column.NotNull && !column.PrimaryKey && goType != "any" && !strings.HasPrefix(goType, "[]") && goType != "json.RawMessage" {
+				goType = "*" + goType
+			}
+
+			tag := fmt.Sprintf(`db:%s json:%s`, strconv.Quote(column.Name), strconv.Quote(column.Name))
+			fmt.Fprintf(&body, "\t%s %s `%s`\n", uniqueGoName(fieldNames, goName(column.Name)), goType, tag)
+		}
+		body.WriteString("}\n")
+	}
+
+	var file strings.Builder
+	file.WriteString("// Code generated by dbdiff export. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&file, "// Package %s holds the tables of a %s database.\n", packageName, s.Dialect)
+	fmt.Fprintf(&file, "package %s\n", packageName)
+	if len(imports) > 0 {
+		file.WriteString("\nimport (\n")
+		for _, path := range slices.Sorted(maps.Keys(imports)) {
+			fmt.Fprintf(&file, "\t%s\n", strconv.Quote(path))
+		}
+		file.WriteString(")\n")
+	}
+	file.WriteString(body.String())
+
+	source, err := format.Source([]byte(file.String()))
+	if err != nil {
+		return fmt.Errorf("failed to format Go structs: %w", err)
+	}
+
+	_, err = w.Write(source)
+	return err
+}
+
+// goColumnType maps a column type of dialect to a Go type, along with the
+// package it needs to be imported, if any.
+func goColumnType(dialect string, columnType string) (goType string, importPath string) {
+	t := strings.ToLower(strings.TrimSpace(columnType))
+
+	if dialect == "sqlite3" {
+		// Following the type affinity rules of SQLite, with the date and
+		// boolean types the driver scans as such
+		switch {
+		case strings.Contains(t, "int"):
+			return "int64", ""
+		case strings.Contains(t, "char"), strings.Contains(t, "clob"), strings.Contains(t, "text"):
+			return "string", ""
+		case t == "" || strings.Contains(t, "blob"):
+			return "[]byte", ""
+		case strings.Contains(t, "real"), strings.Contains(t, "floa"), strings.Contains(t, "doub"):
+			return "float64", ""
+		case strings.Contains(t, "date"), strings.Contains(t, "time"):
+			return "time.Time", "time"
+		case strings.Contains(t, "bool"):
+			return "bool", ""
+		}
+		return "float64", ""
+	}
+
+	base, _, _ := strings.Cut(t, "(")
+	switch strings.TrimSpace(base) {
+	case "smallint", "int2", "smallserial":
+		return "int16", ""
+	case "integer", "int", "int4", "serial":
+		return "int32", ""
+	case "bigint", "int8", "bigserial":
+		return "int64", ""
+	case "real", "float4":
+		return "float32", ""
+	case "double precision", "float8", "float":
+		return "float64", ""
+	case "boolean", "bool":
+		return "bool", ""
+	case "numeric", "decimal", "money", "interval",
+		"text", "character varying", "varchar", "character", "char", "citext", "name", "uuid", "inet", "cidr", "macaddr", "xml":
+		return "string", ""
+	case "date", "timestamp", "timestamp without time zone", "timestamp with time zone", "timestamptz",
+		"time", "time without time zone", "time with time zone", "timetz":
+		return "time.Time", "time"
+	case "json", "jsonb":
+		return "json.RawMessage", "encoding/json"
+	case "bytea", "blob":
+		return "[]byte", ""
+	}
+	return "any", ""
+}
+
+var goNameSeparator = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// goInitialisms are the words written in upper case in Go names.
+var goInitialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "id": true, "ip": true, "json": true,
+	"sql": true, "uid": true, "uri": true, "url": true, "uuid": true, "xml": true,
+}
+
+// goName turns a snake_case or camelCase name into an exported Go
+// identifier, e.g. user_id into UserID.
+func goName(name string) string {
+	var b strings.Builder
+	for _, word := range goNameSeparator.Split(name, -1) {
+		if word == "" {
+			continue
+		}
+		if goInitialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+
+	identifier := b.String()
+	if identifier == "" || !token.IsIdentifier(identifier) {
+		// Names starting with a digit, or made of symbols only
+		identifier = "X" + identifier
+	}
+	return identifier
+}
+
+// uniqueGoName returns name, suffixed with a number when taken already, and
+// marks it as taken.
+func uniqueGoName(taken map[string]bool, name string) string {
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	taken[unique] = true
+	return unique
+}
+
+// singular guesses the singular of an English plural table name, leaving
+// names it cannot tell are plural alone.
+func singular(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss") && !strings.HasSuffix(lower, "us") && !strings.HasSuffix(lower, "is"):
+		return name[:len(name)-1]
+	}
+	return name
+}
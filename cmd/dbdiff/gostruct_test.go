@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteGoStructs(t *testing.T) {
+	s := &schema.Schema{
+		Dialect: "sqlite3",
+		Tables: []*schema.Table{{
+			Name: "users",
+			Columns: []*schema.Column{
+				{Name: "id", Type: "INTEGER", NotNull: true, PrimaryKey: true},
+				{Name: "name", Type: "TEXT"},
+			},
+		}},
+	}
+
+	var output strings.Builder
+	require.NoError(t, writeGoStructs(&output, "models", s))
+	require.Equal(t, "// Code generated by dbdiff export. DO NOT EDIT.\n\n"+
+		"// Package models holds the tables of a sqlite3 database.\n"+
+		"package models\n\n"+
+		"// User is a row of the users table.\n"+
+		"type User struct {\n"+
+		"\tID   int64   `db:\"id\" json:\"id\"`\n"+
+		"\tName *string `db:\"name\" json:\"name\"`\n"+
+		"}\n", output.String())
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+)
+
+func graphCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "graph",
+		Usage:     "Print the dependency graph of the tables and views of a database",
+		UsageText: "dbdiff graph [options] <url>\ndbdiff graph [options] <source url> <target url>",
+		Description: "Tables point to the tables their foreign keys reference, views to the tables and views they select from. " +
+			"Given two databases, the graph holds the objects of both and highlights the ones the plan migrating the target adds, changes or removes.",
+		Action: graphAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Output format: dot for Graphviz, or mermaid",
+				Value: "dot",
+				Validator: func(s string) error {
+					switch s {
+					case "dot", "mermaid":
+						return nil
+					}
+					return fmt.Errorf("unsupported format: %s", s)
+				},
+			},
+		},
+	}
+}
+
+// graphStatus tells how the plan changes a node of the graph, empty when it
+// does not.
+type graphStatus string
+
+const (
+	graphAdded    graphStatus = "added"
+	graphModified graphStatus = "modified"
+	graphRemoved  graphStatus = "removed"
+)
+
+type graphNode struct {
+	name   string
+	view   bool
+	status graphStatus
+}
+
+type graphEdge struct {
+	from, to string
+
+	// foreignKey tells foreign keys apart from view dependencies.
+	foreignKey bool
+}
+
+type dependencyGraph struct {
+	nodes []*graphNode
+	edges []graphEdge
+}
+
+func graphAction(ctx context.Context, cmd *cli.Command) error {
+	var graph *dependencyGraph
+
+	switch args := cmd.Args().Slice(); len(args) {
+	case 1:
+		s, err := introspectDatabase(ctx, cmd, "", args[0])
+		if err != nil {
+			return err
+		}
+		graph = newDependencyGraph(s)
+	case 2:
+		source, target, err := introspectBoth(ctx, cmd, "", args[0], args[1])
+		if err != nil {
+			return err
+		}
+
+		// The plan is computed from the very schemas the graph is drawn from
+		driver, err := openDriver(ctx, cmd, args[0], args[1], drivers.WithSourceSnapshot(source), drivers.WithTargetSnapshot(target))
+		if err != nil {
+			return err
+		}
+		defer driver.Close()
+
+		planner, ok := drivers.As[drivers.ObjectPlanner](driver)
+		if !ok {
+			return fmt.Errorf("driver cannot group statements by object")
+		}
+		plan, err := drivers.CollectPlan(ctx, planner)
+		if err != nil {
+			return fmt.Errorf("failed to diff databases: %w", err)
+		}
+
+		graph = newDiffGraph(source, target, plan)
+	default:
+		return fmt.Errorf("expected one database URL, or a source and a target")
+	}
+
+	w := cmd.Root().Writer
+	if cmd.String("format") == "mermaid" {
+		return graph.writeMermaid(w)
+	}
+	return graph.writeDot(w)
+}
+
+// newDependencyGraph builds the graph of the tables and views of s.
+func newDependencyGraph(s *schema.Schema) *dependencyGraph {
+	graph := &dependencyGraph{}
+
+	for _, table := range s.Tables {
+		graph.nodes = append(graph.nodes, &graphNode{name: table.Name})
+		for _, reference := range table.References() {
+			graph.edges = append(graph.edges, graphEdge{from: table.Name, to: reference, foreignKey: true})
+		}
+	}
+	for _, view := range s.Views {
+		graph.nodes = append(graph.nodes, &graphNode{name: view.Name, view: true})
+		for _, dependency := range s.ViewDependencies(view) {
+			graph.edges = append(graph.edges, graphEdge{from: view.Name, to: dependency})
+		}
+	}
+
+	// Tables left out of introspection, such as by --table, are not drawn
+	graph.edges = slices.DeleteFunc(graph.edges, func(edge graphEdge) bool {
+		return !graph.has(edge.to)
+	})
+
+	return graph
+}
+
+// newDiffGraph builds the graph of the source, along with the objects plan
+// removes from the target, and marks the objects plan changes.
+func newDiffGraph(source *schema.Schema, target *schema.Schema, plan drivers.Plan) *dependencyGraph {
+	graph := newDependencyGraph(source)
+
+	existing := newDependencyGraph(target)
+	for _, node := range existing.nodes {
+		if !graph.has(node.name) {
+			graph.nodes = append(graph.nodes, &graphNode{name: node.name, view: node.view, status: graphRemoved})
+		}
+	}
+	for _, edge := range existing.edges {
+		if !slices.Contains(graph.edges, edge) {
+			graph.edges = append(graph.edges, edge)
+		}
+	}
+
+	for _, object := range plan {
+		name := object.Name
+		switch object.Type {
+		case schema.TableObject, schema.ViewObject:
+		case schema.IndexObject, schema.TriggerObject:
+			// Changing an index or a trigger changes its table
+			name = cmp.Or(owningTable(source, object.Type, name), owningTable(target, object.Type, name))
+		default:
+			continue
+		}
+
+		node := graph.node(name)
+		if node == nil || node.status != "" {
+			continue
+		}
+		node.status = graphModified
+		if !existing.has(name) {
+			node.status = graphAdded
+		}
+	}
+
+	return graph
+}
+
+// owningTable returns the table of s holding the named index or trigger.
+func owningTable(s *schema.Schema, objectType schema.ObjectType, name string) string {
+	for _, table := range s.Tables {
+		var found bool
+		if objectType == schema.IndexObject {
+			_, found = table.IndexByName(name)
+		} else {
+			_, found = table.TriggerByName(name)
+		}
+		if found {
+			return table.Name
+		}
+	}
+	return ""
+}
+
+func (g *dependencyGraph) node(name string) *graphNode {
+	for _, node := range g.nodes {
+		if node.name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+func (g *dependencyGraph) has(name string) bool {
+	return g.node(name) != nil
+}
+
+// graphColors fill the nodes changed by the plan.
+var graphColors = map[graphStatus]string{
+	graphAdded:    "#d4edda",
+	graphModified: "#fff3cd",
+	graphRemoved:  "#f8d7da",
+}
+
+func (g *dependencyGraph) writeDot(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph schema {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, node := range g.nodes {
+		var attributes []string
+		if node.view {
+			attributes = append(attributes, "shape=ellipse")
+		}
+		if color, ok := graphColors[node.status]; ok {
+			style := "filled"
+			if node.status == graphRemoved {
+				style = "filled,dashed"
+			}
+			attributes = append(attributes, "style="+strconv.Quote(style), "fillcolor="+strconv.Quote(color), "tooltip="+strconv.Quote(string(node.status)))
+		}
+
+		fmt.Fprintf(&b, "  %s", strconv.Quote(node.name))
+		if len(attributes) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attributes, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	for _, edge := range g.edges {
+		fmt.Fprintf(&b, "  %s -> %s", strconv.Quote(edge.from), strconv.Quote(edge.to))
+		if !edge.foreignKey {
+			b.WriteString(" [style=dashed]")
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (g *dependencyGraph) writeMermaid(w io.Writer) error {
+	var b strings.Builder
+
+	// Names may hold characters Mermaid ids cannot, nodes are numbered
+	ids := make(map[string]string, len(g.nodes))
+	for i, node := range g.nodes {
+		ids[node.name] = "n" + strconv.Itoa(i)
+	}
+
+	b.WriteString("flowchart LR\n")
+	for _, node := range g.nodes {
+		label := strings.ReplaceAll(node.name, `"`, "#quot;")
+		if node.view {
+			fmt.Fprintf(&b, "  %s([\"%s\"])\n", ids[node.name], label)
+		} else {
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[node.name], label)
+		}
+	}
+	for _, edge := range g.edges {
+		arrow := "-->"
+		if !edge.foreignKey {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", ids[edge.from], arrow, ids[edge.to])
+	}
+
+	for _, status := range []graphStatus{graphAdded, graphModified, graphRemoved} {
+		var changed []string
+		for _, node := range g.nodes {
+			if node.status == status {
+				changed = append(changed, ids[node.name])
+			}
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "  classDef %s fill:%s\n", status, graphColors[status])
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(changed, ","), status)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	dbdiffv1 "github.com/quantumsheep/dbdiff/api/dbdiff/v1"
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func serveGRPC(ctx context.Context, cmd *cli.Command, security *serveSecurity, listener net.Listener) error {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, request any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			err := authorizeGRPC(ctx, security)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, request)
+		}),
+		grpc.StreamInterceptor(func(server any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			err := authorizeGRPC(stream.Context(), security)
+			if err != nil {
+				return err
+			}
+			return handler(server, stream)
+		}),
+	}
+	if security.tls != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(security.tls)))
+	}
+
+	server := grpc.NewServer(opts...)
+	dbdiffv1.RegisterDbdiffServer(server, &grpcServer{cmd: cmd, security: security, allowApply: cmd.Bool("allow-apply")})
+
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening for gRPC on %s\n", listener.Addr())
+
+	return server.Serve(listener)
+}
+
+// authorizeGRPC checks the authorization metadata of the call in ctx.
+func authorizeGRPC(ctx context.Context, security *serveSecurity) error {
+	var authorization string
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		authorization = values[0]
+	}
+	if !security.authorize(authorization) {
+		return status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+	}
+	return nil
+}
+
+// grpcServer implements dbdiffv1.DbdiffServer with the global flags of cmd.
+type grpcServer struct {
+	dbdiffv1.UnimplementedDbdiffServer
+
+	cmd      *cli.Command
+	security *serveSecurity
+
+	// allowApply enables Apply, see --allow-apply.
+	allowApply bool
+}
+
+func (s *grpcServer) Diff(request *dbdiffv1.DiffRequest, stream grpc.ServerStreamingServer[dbdiffv1.Statement]) error {
+	ctx := stream.Context()
+
+	driver, err := s.open(ctx, request.Driver, request.Source, request.Target)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	for statement, err := range driver.Statements(ctx) {
+		if err != nil {
+			return fmt.Errorf("failed to diff databases: %w", err)
+		}
+
+		err = stream.Send(&dbdiffv1.Statement{Sql: statement})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *grpcServer) Snapshot(ctx context.Context, request *dbdiffv1.SnapshotRequest) (*dbdiffv1.SnapshotResponse, error) {
+	if request.Url == "" {
+		return nil, status.Error(codes.InvalidArgument, "url is required")
+	}
+
+	err := s.security.checkURLs(s.cmd, request.Url)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	snapshot, err := introspectDatabase(ctx, s.cmd, request.Driver, request.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dbdiffv1.SnapshotResponse{SchemaJson: schemaJSON}, nil
+}
+
+// Apply plans and applies the migration of the target the way dbdiff apply
+// does with a plan it was just given: the target is checked not to have
+// changed since it was planned, the policies and --max-rewrite-rows apply,
+// statements are recorded in the audit log and webhooks are notified.
+func (s *grpcServer) Apply(request *dbdiffv1.ApplyRequest, stream grpc.ServerStreamingServer[dbdiffv1.Statement]) error {
+	ctx := stream.Context()
+
+	if !s.allowApply {
+		return status.Error(codes.PermissionDenied, "Apply is disabled, see dbdiff serve --allow-apply")
+	}
+	if request.TargetUrl == "" {
+		return status.Error(codes.InvalidArgument, "target_url is required")
+	}
+
+	var sourceURL string
+	var opts []drivers.Option
+	driverName := request.Driver
+	switch source := request.Source.GetDatabase().(type) {
+	case *dbdiffv1.Database_Url:
+		sourceURL = source.Url
+	case *dbdiffv1.Database_SchemaJson:
+		var snapshot schema.Schema
+		err := json.Unmarshal(source.SchemaJson, &snapshot)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid source schema: %v", err)
+		}
+		driverName = cmp.Or(driverName, snapshot.Dialect)
+		opts = append(opts, drivers.WithSourceSnapshot(&snapshot))
+	default:
+		return status.Error(codes.InvalidArgument, "source is required")
+	}
+
+	err := s.security.checkURLs(s.cmd, sourceURL, request.TargetUrl)
+	if err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	config, err := loadConfig(s.cmd.String("config"))
+	if err != nil {
+		return err
+	}
+	if config.requiresApproval(request.TargetUrl) {
+		return status.Error(codes.FailedPrecondition, "target requires an approved plan, see dbdiff promote and dbdiff apply --approval-token")
+	}
+
+	file, err := createPlanFile(ctx, s.cmd, driverName, sourceURL, request.TargetUrl, opts...)
+	if err != nil {
+		return err
+	}
+
+	err = applyAndNotify(ctx, s.cmd, config, file, request.TargetUrl, io.Discard, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range file.Statements {
+		err := stream.Send(&dbdiffv1.Statement{Sql: statement})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *grpcServer) Verify(ctx context.Context, request *dbdiffv1.VerifyRequest) (*dbdiffv1.VerifyResponse, error) {
+	if request.SourceUrl == "" || request.TargetUrl == "" {
+		return nil, status.Error(codes.InvalidArgument, "source_url and target_url are required")
+	}
+
+	err := s.security.checkURLs(s.cmd, request.SourceUrl, request.TargetUrl, request.ScratchUrl)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	driverName, err := driverNameFor(s.cmd, request.SourceUrl, request.TargetUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := verifyPlan(ctx, s.cmd, cmp.Or(request.Driver, driverName), request.SourceUrl, request.TargetUrl, request.ScratchUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dbdiffv1.VerifyResponse{
+		Plan:      grpcStatements(result.Plan),
+		Remaining: grpcStatements(result.Remaining),
+	}, nil
+}
+
+// open opens the driver comparing source to target, which are either URLs or
+// snapshots. driverName defaults to the dialect of the snapshots.
+func (s *grpcServer) open(ctx context.Context, driverName string, source *dbdiffv1.Database, target *dbdiffv1.Database, extraOpts ...drivers.Option) (drivers.Driver, error) {
+	var urls [2]string
+	opts := extraOpts
+
+	for i, side := range []struct {
+		name     string
+		database *dbdiffv1.Database
+		option   func(*schema.Schema) drivers.Option
+	}{
+		{"source", source, drivers.WithSourceSnapshot},
+		{"target", target, drivers.WithTargetSnapshot},
+	} {
+		switch database := side.database.GetDatabase().(type) {
+		case *dbdiffv1.Database_Url:
+			err := s.security.checkURLs(s.cmd, database.Url)
+			if err != nil {
+				return nil, status.Error(codes.PermissionDenied, err.Error())
+			}
+			urls[i] = database.Url
+		case *dbdiffv1.Database_SchemaJson:
+			var snapshot schema.Schema
+			err := json.Unmarshal(database.SchemaJson, &snapshot)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid %s schema: %v", side.name, err)
+			}
+
+			driverName = cmp.Or(driverName, snapshot.Dialect)
+			opts = append(opts, side.option(&snapshot))
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "%s is required", side.name)
+		}
+	}
+
+	driver, err := openNamedDriver(ctx, s.cmd, driverName, urls[0], urls[1], opts...)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return driver, nil
+}
+
+func grpcStatements(statements []string) []*dbdiffv1.Statement {
+	messages := make([]*dbdiffv1.Statement, len(statements))
+	for i, statement := range statements {
+		messages[i] = &dbdiffv1.Statement{Sql: statement}
+	}
+	return messages
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+)
+
+func inspectCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "inspect",
+		Usage:     "Print the introspected schema of a single database",
+		UsageText: "dbdiff inspect [options] <url>",
+		Action:    inspectAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Output format: sql, json or tree",
+				Value: "sql",
+				Validator: func(s string) error {
+					switch s {
+					case "sql", "json", "tree":
+						return nil
+					}
+					return fmt.Errorf("unsupported format: %s", s)
+				},
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "url",
+				UsageText: "Database connection URL, path or environment alias",
+			},
+		},
+	}
+}
+
+func inspectAction(ctx context.Context, cmd *cli.Command) error {
+	databaseURL := cmd.StringArg("url")
+	if databaseURL == "" {
+		return fmt.Errorf("database URL is required")
+	}
+
+	s, err := introspectDatabase(ctx, cmd, "", databaseURL)
+	if err != nil {
+		return err
+	}
+	if cmd.Bool("sorted") {
+		s = s.Sorted()
+	}
+
+	w := cmd.Root().Writer
+
+	switch cmd.String("format") {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(s)
+	case "tree":
+		printSchemaTree(w, s)
+		return nil
+	}
+
+	driver, err := openSchemaRenderer(ctx, cmd, s)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	return printSchemaSQL(w, driver, s)
+}
+
+// creationStatements yields the statements creating s from scratch.
+func creationStatements(renderer drivers.Renderer, s *schema.Schema) iter.Seq2[string, error] {
+	// An empty target turns the whole schema into creation statements
+	return renderer.Render(schema.Compare(s, &schema.Schema{Dialect: s.Dialect}))
+}
+
+// printSchemaSQL writes the statements creating s from scratch.
+func printSchemaSQL(w io.Writer, renderer drivers.Renderer, s *schema.Schema) error {
+	for statement, err := range creationStatements(renderer, s) {
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w, statement)
+	}
+
+	return nil
+}
+
+type treeNode struct {
+	label    string
+	children []*treeNode
+}
+
+func (n *treeNode) add(label string) *treeNode {
+	child := &treeNode{label: label}
+	n.children = append(n.children, child)
+	return child
+}
+
+func printSchemaTree(w io.Writer, s *schema.Schema) {
+	for _, table := range s.Tables {
+		root := &treeNode{label: "table " + table.Name}
+
+		columns := root.add("columns")
+		for _, column := range table.Columns {
+			columns.add(describeColumn(column))
+		}
+
+		if len(table.Indexes) > 0 {
+			indexes := root.add("indexes")
+			for _, index := range table.Indexes {
+				indexes.add(describeIndex(index))
+			}
+		}
+
+		if len(table.Constraints) > 0 {
+			constraints := root.add("constraints")
+			for _, constraint := range table.Constraints {
+				constraints.add(constraint.Name + " " + constraint.Def)
+			}
+		}
+
+		if len(table.ForeignKeys) > 0 {
+			foreignKeys := root.add("foreign keys")
+			for _, fk := range table.ForeignKeys {
+				foreignKeys.add(describeForeignKey(fk))
+			}
+		}
+
+		if len(table.Triggers) > 0 {
+			triggers := root.add("triggers")
+			for _, trigger := range table.Triggers {
+				triggers.add(trigger.Name)
+			}
+		}
+
+		printTree(w, root, "", "")
+	}
+
+	for _, view := range s.Views {
+		fmt.Fprintln(w, "view "+view.Name)
+	}
+	for _, operator := range s.Operators {
+		fmt.Fprintln(w, "operator "+operator.Name)
+	}
+	for _, aggregate := range s.Aggregates {
+		fmt.Fprintln(w, "aggregate "+aggregate.Name)
+	}
+	for _, cast := range s.Casts {
+		fmt.Fprintln(w, "cast "+cast.Name)
+	}
+}
+
+func printTree(w io.Writer, node *treeNode, prefix string, childPrefix string) {
+	fmt.Fprintln(w, prefix+node.label)
+
+	for i, child := range node.children {
+		if i == len(node.children)-1 {
+			printTree(w, child, childPrefix+"└── ", childPrefix+"    ")
+		} else {
+			printTree(w, child, childPrefix+"├── ", childPrefix+"│   ")
+		}
+	}
+}
+
+func describeColumn(column *schema.Column) string {
+	parts := []string{column.Name, column.Type}
+	if column.PrimaryKey {
+		parts = append(parts, "PRIMARY KEY")
+	}
+	if column.NotNull {
+		parts = append(parts, "NOT NULL")
+	}
+	if column.Default.Valid {
+		parts = append(parts, "DEFAULT "+column.Default.String)
+	}
+	return strings.Join(parts, " ")
+}
+
+func describeIndex(index *schema.Index) string {
+	description := index.Name
+	if index.Unique {
+		description += " UNIQUE"
+	}
+	if len(index.Columns) > 0 {
+		description += " (" + strings.Join(index.Columns, ", ") + ")"
+	}
+	return description
+}
+
+func describeForeignKey(fk *schema.ForeignKey) string {
+	description := fmt.Sprintf("(%s) -> %s (%s)", strings.Join(fk.From, ", "), fk.Table, strings.Join(fk.To, ", "))
+	if fk.OnUpdate != "" && fk.OnUpdate != "NO ACTION" {
+		description += " ON UPDATE " + fk.OnUpdate
+	}
+	if fk.OnDelete != "" && fk.OnDelete != "NO ACTION" {
+		description += " ON DELETE " + fk.OnDelete
+	}
+	return description
+}
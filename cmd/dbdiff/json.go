@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+)
+
+// jsonPlanFormatVersion is the version of the --format json output, see
+// jsonschema/diff.schema.json.
+const jsonPlanFormatVersion = 1
+
+// jsonPlan is the plan written by --format json.
+type jsonPlan struct {
+	FormatVersion int              `json:"format_version"`
+	Statements    []*jsonStatement `json:"statements"`
+}
+
+type jsonStatement struct {
+	Type     schema.ObjectType      `json:"type"`
+	Name     string                 `json:"name"`
+	SQL      string                 `json:"sql"`
+	Class    drivers.StatementClass `json:"class,omitempty"`
+	Contract bool                   `json:"contract,omitempty"`
+
+	// Impact is set on statements going through whole tables of the target,
+	// see drivers.ImpactEstimator.
+	Impact *drivers.StatementImpact `json:"impact,omitempty"`
+}
+
+// jsonReport is printStatements writing the plan as JSON, along with the
+// object each statement migrates and its estimated impact.
+func jsonReport(ctx context.Context, cmd *cli.Command, w io.Writer, sourceURL string, targetURL string, opts ...drivers.Option) (int, error) {
+	driver, err := openDriver(ctx, cmd, sourceURL, targetURL, opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer driver.Close()
+
+	planner, ok := drivers.As[drivers.ObjectPlanner](driver)
+	if !ok {
+		return 0, fmt.Errorf("driver cannot group statements by object")
+	}
+
+	plan := &jsonPlan{FormatVersion: jsonPlanFormatVersion, Statements: []*jsonStatement{}}
+	var statements []string
+	for object, err := range planner.ObjectStatements(ctx) {
+		if err != nil {
+			return 0, fmt.Errorf("failed to diff databases: %w", err)
+		}
+
+		for _, statement := range object.Statements {
+			plan.Statements = append(plan.Statements, &jsonStatement{
+				Type:     object.Type,
+				Name:     object.Name,
+				SQL:      statement,
+				Class:    object.Class,
+				Contract: object.Contract,
+			})
+			statements = append(statements, statement)
+		}
+	}
+
+	if estimator, ok := drivers.As[drivers.ImpactEstimator](driver); ok && len(statements) > 0 {
+		impacts, err := estimator.EstimateImpact(ctx, statements)
+		if err != nil {
+			return 0, err
+		}
+		for i, impact := range impacts {
+			plan.Statements[i].Impact = impact
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return len(statements), encoder.Encode(plan)
+}
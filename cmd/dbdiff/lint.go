@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/lint"
+	"github.com/quantumsheep/dbdiff/plan"
+	"github.com/urfave/cli/v3"
+)
+
+func lintCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "lint",
+		Usage:     "Flag risky statements in a migration plan",
+		UsageText: "dbdiff lint [options] <plan.sql|->\ndbdiff lint [options] <url1> <url2>",
+		Description: "Lints a plan file, or the plan generated between two databases. " +
+			"Exits with an error when any finding has the error severity.",
+		Action: lintAction,
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "severity",
+				Usage: "Severity of a rule, as RULE=off|info|warning|error (e.g. drop-column=error). Can be repeated",
+			},
+			&cli.BoolFlag{
+				Name:  "list-rules",
+				Usage: "List the available rules and their default severity",
+			},
+		},
+	}
+}
+
+func lintAction(ctx context.Context, cmd *cli.Command) error {
+	w := cmd.Root().Writer
+
+	if cmd.Bool("list-rules") {
+		for _, rule := range lint.Rules {
+			fmt.Fprintf(w, "%-26s %-8s %s\n", rule.Name, rule.Severity, rule.Description)
+		}
+		return nil
+	}
+
+	config, err := loadConfig(cmd.String("config"))
+	if err != nil {
+		return err
+	}
+
+	opts, err := lintOptions(cmd, config)
+	if err != nil {
+		return err
+	}
+
+	var statements []plan.Statement
+	var location func(statement plan.Statement) string
+
+	switch args := cmd.Args().Slice(); len(args) {
+	case 1:
+		statements, err = readPlan(args[0])
+		if err != nil {
+			return err
+		}
+
+		location = func(statement plan.Statement) string {
+			return fmt.Sprintf("%s:%d", args[0], statement.Line)
+		}
+
+		opts = append(opts, lint.WithDialect(resolveDriverName(cmd, config, "", "")))
+	case 2:
+		driver, err := openDriver(ctx, cmd, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		defer driver.Close()
+
+		for statement, err := range driver.Statements(ctx) {
+			if err != nil {
+				return fmt.Errorf("failed to diff databases: %w", err)
+			}
+			statements = append(statements, plan.Statement{SQL: statement, Line: len(statements) + 1})
+		}
+
+		// Generated statements are numbered rather than located by line
+		location = func(statement plan.Statement) string {
+			return fmt.Sprintf("statement %d", statement.Line)
+		}
+
+		opts = append(opts, lint.WithDialect(resolveDriverName(cmd, config, args[0], args[1])))
+	default:
+		return fmt.Errorf("expected a plan file or two database URLs")
+	}
+
+	sqls := make([]string, len(statements))
+	for i, statement := range statements {
+		sqls[i] = statement.SQL
+	}
+
+	errors := 0
+	for _, finding := range lint.Lint(sqls, opts...) {
+		fmt.Fprintf(w, "%s: %s\n", location(statements[finding.Statement]), finding)
+		if finding.Severity == lint.Error {
+			errors++
+		}
+	}
+
+	if errors > 0 {
+		return fmt.Errorf("%d lint error(s)", errors)
+	}
+
+	return nil
+}
+
+// lintOptions applies the severities from the config file, then the ones
+// from --severity.
+func lintOptions(cmd *cli.Command, config *Config) ([]lint.Option, error) {
+	var opts []lint.Option
+
+	add := func(name string, level string) error {
+		if _, found := lint.RuleByName(name); !found {
+			return fmt.Errorf("unknown lint rule: %s", name)
+		}
+
+		severity, err := lint.ParseSeverity(level)
+		if err != nil {
+			return err
+		}
+
+		opts = append(opts, lint.WithSeverity(name, severity))
+		return nil
+	}
+
+	for name, level := range config.Lint.Severities {
+		err := add(name, level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file: %w", err)
+		}
+	}
+
+	for _, value := range cmd.StringSlice("severity") {
+		name, level, found := strings.Cut(value, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid severity %q, expected RULE=LEVEL", value)
+		}
+
+		err := add(name, level)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return opts, nil
+}
+
+// readPlan reads the statements of a plan file, or of stdin for "-".
+func readPlan(path string) ([]plan.Statement, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	var statements []plan.Statement
+	for statement, err := range plan.Split(r) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		statements = append(statements, statement)
+	}
+
+	return statements, nil
+}
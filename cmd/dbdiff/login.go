@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+)
+
+// keyringService names the entries dbdiff stores in the OS keyring, one per
+// environment.
+const keyringService = "dbdiff"
+
+// errNoKeyring is returned on systems without a supported keyring.
+var errNoKeyring = fmt.Errorf("no OS keyring available on %s, expected macOS Keychain or Secret Service (secret-tool)", runtime.GOOS)
+
+func loginCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "login",
+		Usage:     "Store the password of an environment in the OS keyring, used whenever the environment is referenced without a password of its own",
+		UsageText: "dbdiff login [options] <environment>",
+		Action:    loginAction,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "delete",
+				Usage: "Remove the stored password instead",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "environment",
+				UsageText: "Environment alias from the config file",
+			},
+		},
+	}
+}
+
+func loginAction(ctx context.Context, cmd *cli.Command) error {
+	alias := cmd.StringArg("environment")
+	if alias == "" {
+		return fmt.Errorf("environment is required")
+	}
+
+	config, err := loadConfig(cmd.String("config"))
+	if err != nil {
+		return err
+	}
+	if _, found := config.Environments[alias]; !found {
+		return fmt.Errorf("unknown environment %s", alias)
+	}
+
+	if cmd.Bool("delete") {
+		return deleteKeyringPassword(ctx, alias)
+	}
+
+	password, err := readPassword(fmt.Sprintf("Password for %s: ", alias))
+	if err != nil {
+		return err
+	}
+
+	err = setKeyringPassword(ctx, alias, password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.Root().ErrWriter, "Stored the password of %s in the OS keyring\n", alias)
+	return nil
+}
+
+// readPassword prompts for a password without echoing it, or reads a line
+// of stdin when it is not a terminal.
+func readPassword(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return string(password), nil
+}
+
+// keyringPassword returns the password stored for an environment by dbdiff
+// login, if any. Systems without a keyring have none.
+func keyringPassword(ctx context.Context, alias string) (string, bool, error) {
+	var cmd *exec.Cmd
+	switch {
+	case runtime.GOOS == "darwin":
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", keyringService, "-a", alias, "-w")
+	case hasCommand("secret-tool"):
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", keyringService, "account", alias)
+	default:
+		return "", false, nil
+	}
+
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// Both tools fail on missing entries
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return strings.TrimRight(string(output), "\n"), true, nil
+}
+
+func setKeyringPassword(ctx context.Context, alias string, password string) error {
+	var cmd *exec.Cmd
+	switch {
+	case runtime.GOOS == "darwin":
+		// -U updates the entry stored by a previous login. A trailing -w
+		// prompts for the password, twice, instead of exposing it in the
+		// process list
+		cmd = exec.CommandContext(ctx, "security", "add-generic-password", "-U", "-s", keyringService, "-a", alias, "-w")
+		cmd.Stdin = strings.NewReader(password + "\n" + password + "\n")
+	case hasCommand("secret-tool"):
+		cmd = exec.CommandContext(ctx, "secret-tool", "store", "--label", "dbdiff "+alias, "service", keyringService, "account", alias)
+		cmd.Stdin = strings.NewReader(password)
+	default:
+		return errNoKeyring
+	}
+
+	return runKeyringCommand(cmd)
+}
+
+func deleteKeyringPassword(ctx context.Context, alias string) error {
+	var cmd *exec.Cmd
+	switch {
+	case runtime.GOOS == "darwin":
+		cmd = exec.CommandContext(ctx, "security", "delete-generic-password", "-s", keyringService, "-a", alias)
+	case hasCommand("secret-tool"):
+		cmd = exec.CommandContext(ctx, "secret-tool", "clear", "service", keyringService, "account", alias)
+	default:
+		return errNoKeyring
+	}
+
+	return runKeyringCommand(cmd)
+}
+
+func runKeyringCommand(cmd *exec.Cmd) error {
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", cmd.Args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
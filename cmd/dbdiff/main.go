@@ -2,93 +2,408 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/quantumsheep/dbdiff/drivers"
 	"github.com/urfave/cli/v3"
 )
 
 func main() {
+	// External drivers must be known before --driver gets validated
+	drivers.RegisterPlugins()
+
 	cmd := &cli.Command{
 		Name:        "dbdiff",
 		Description: "Compare database schemas and generate migration scripts",
-		Action:      action,
-		UsageText:   "dbdiff [global options] <url1> <url2>",
+		Action:      diffAction,
+		UsageText:   "dbdiff [global options] <url1> <url2> [url3 ...]\ndbdiff [global options] --since <snapshot id> <url>",
+		Version:     buildVersion(),
+		Commands: []*cli.Command{
+			applyCommand(),
+			approveCommand(),
+			docsCommand(),
+			driftCommand(),
+			exportCommand(),
+			graphCommand(),
+			inspectCommand(),
+			lintCommand(),
+			loginCommand(),
+			monitorCommand(),
+			planCommand(),
+			promoteCommand(),
+			renderCommand(),
+			serveCommand(),
+			snapshotCommand(),
+			squashCommand(),
+			testCommand(),
+			verifyCommand(),
+			versionCommand(),
+		},
+		EnableShellCompletion: true,
+		ConfigureShellCompletionCommand: func(cmd *cli.Command) {
+			cmd.Hidden = false
+		},
+		ShellComplete: complete,
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "Configuration file defining environment aliases. Defaults to " + defaultConfigPath + " when present",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Output format of the plan: sql, json listing each statement with the object it migrates and its estimated impact, or github for GitHub Actions annotations and job summary",
+				Value: "sql",
+				Local: true,
+				Validator: func(s string) error {
+					switch s {
+					case "sql", "json", "github":
+						return nil
+					}
+					return fmt.Errorf("unsupported format: %s", s)
+				},
+			},
+			&cli.BoolFlag{
+				Name:  "header",
+				Usage: "Start the plan with a comment recording the dbdiff version, both databases, their schema hashes and the generation time",
+				Local: true,
+			},
+			&cli.StringFlag{
+				Name:  "split-output",
+				Usage: "Write the plan to a directory instead, as one file per changed object plus a manifest.json listing them in order",
+				Local: true,
+			},
+			&cli.StringFlag{
+				Name:      "contract-output",
+				Usage:     "Write the statements dropping objects to this file instead, to be applied once the application stopped using them. Implies --defer-destructive",
+				TakesFile: true,
+				Local:     true,
+			},
+			&cli.StringFlag{
+				Name:  "fail-on",
+				Usage: "When to exit with status 2 once the plan is written: none, any when a target differs from the source, or destructive when the plan drops tables, views, columns or other objects",
+				Value: string(failOnNone),
+				Local: true,
+				Validator: func(s string) error {
+					_, err := parseFailOnPolicy(s)
+					return err
+				},
+			},
+			&cli.StringFlag{
+				Name:  "targets-file",
+				Usage: "File listing additional target databases, one URL or environment alias per line",
+			},
 			&cli.StringFlag{
 				Name:  "driver",
-				Usage: "Database driver to use. Supported drivers: sqlite3, postgres",
+				Usage: "Database driver to use. Supported drivers: " + strings.Join(drivers.Names(), ", "),
 				Validator: func(s string) error {
-					if slices.Contains([]string{"sqlite3", "postgres"}, s) {
+					if slices.Contains(drivers.Names(), s) {
 						return nil
 					}
 					return fmt.Errorf("unsupported driver: %s", s)
 				},
 			},
+			&cli.IntFlag{
+				Name:  "concurrency",
+				Usage: "Maximum number of introspection queries to run in parallel against each database",
+				Value: drivers.DefaultConcurrency,
+			},
+			&cli.DurationFlag{
+				Name:  "query-timeout",
+				Usage: "Maximum duration of each introspection query (e.g. 30s). Disabled by default",
+			},
+			&cli.StringFlag{
+				Name:  "cache-dir",
+				Usage: "Directory where introspection results are cached between runs, keyed by schema version",
+			},
+			&cli.BoolFlag{
+				Name:  "verbose",
+				Usage: "Log introspection queries, timings and comparison decisions to stderr",
+			},
+			&cli.BoolFlag{
+				Name:  "progress",
+				Usage: "Periodically log introspection and comparison progress to stderr",
+			},
+			&cli.StringFlag{
+				Name:  "schema",
+				Usage: "Schema to compare (postgres). Defaults to the connection's current schema",
+			},
+			&cli.StringFlag{
+				Name:  "since",
+				Usage: "Compare the database against a snapshot saved by dbdiff snapshot save, given by id, printing what changed since",
+				Local: true,
+			},
+			&cli.StringFlag{
+				Name:      "snapshot-dir",
+				Usage:     "Directory of the snapshots saved by dbdiff snapshot save",
+				Value:     defaultSnapshotDir,
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:  "map-schema",
+				Usage: "Compare a source schema against a differently named target schema, as SOURCE=TARGET (e.g. app_v2=app), for blue/green cutovers (postgres)",
+			},
+			&cli.StringFlag{
+				Name:  "introspection",
+				Usage: "How to read schemas (postgres): catalog, querying the system catalogs, or pg_dump, parsing the output of pg_dump --schema-only found in PATH",
+				Value: string(drivers.CatalogIntrospection),
+				Validator: func(s string) error {
+					_, err := drivers.ParseIntrospectionMode(s)
+					return err
+				},
+			},
+			&cli.StringSliceFlag{
+				Name:  "table",
+				Usage: "Only introspect and compare this table, leaving views and every other table out. Can be repeated",
+			},
+			&cli.StringFlag{
+				Name:  "only",
+				Usage: "Only generate changes to these types of objects, as a comma-separated list of tables, indexes, triggers, views, aggregates, operators and casts",
+			},
+			&cli.StringFlag{
+				Name:  "skip",
+				Usage: "Leave changes to these types of objects out of the plan, as a comma-separated list of tables, indexes, triggers, views, aggregates, operators and casts",
+			},
+			&cli.StringSliceFlag{
+				Name:  "map-table",
+				Usage: "Compare source tables under another name, as SOURCE=TARGET patterns where % stands for the common part (e.g. 'wp_%=%'). Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "ignore-table",
+				Usage: "Glob matching table names to leave out of the comparison. Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "ignore-index",
+				Usage: "Glob matching index names to leave out of the comparison. Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "ignore-trigger",
+				Usage: "Glob matching trigger names to leave out of the comparison. Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "ignore-view",
+				Usage: "Glob matching view names to leave out of the comparison. Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "ignore-aggregate",
+				Usage: "Glob matching aggregate signatures, such as total(*), to leave out of the comparison (Postgres). Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "ignore-operator",
+				Usage: "Glob matching operator signatures, such as ===(integer, integer), to leave out of the comparison (Postgres). Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "ignore-cast",
+				Usage: "Glob matching casts, such as (text AS email), to leave out of the comparison (Postgres). Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "equivalent-types",
+				Usage: "Pair of column types to consider equal, as TYPE=TYPE (e.g. text=varchar). Can be repeated",
+			},
+			&cli.BoolFlag{
+				Name:  "strict-types",
+				Usage: "Compare column types exactly, instead of ignoring case, spacing and aliases such as INT and INTEGER",
+			},
+			&cli.BoolFlag{
+				Name:  "strict-definitions",
+				Usage: "Compare view and trigger definitions verbatim, instead of ignoring comments, whitespace and quoting (sqlite)",
+			},
+			&cli.BoolFlag{
+				Name:  "case-insensitive-names",
+				Usage: "Match tables, columns and other objects whose names only differ by case, such as Users and users",
+			},
+			&cli.BoolFlag{
+				Name:  "sorted",
+				Usage: "Order statements by dependencies then name rather than by creation order, so that identical schemas always give byte-identical plans",
+			},
+			&cli.BoolFlag{
+				Name:  "defer-destructive",
+				Usage: "Order statements dropping tables, columns, indexes and other objects after all the others",
+			},
+			&cli.BoolFlag{
+				Name:  "expand-contract",
+				Usage: "Split the plan into an expand phase to apply before deploying, adding objects and nullable columns, and a contract phase to apply after, dropping objects and adding NOT NULL constraints",
+			},
+			&cli.BoolFlag{
+				Name:  "annotate",
+				Usage: "Precede statements with a comment explaining why they were generated, and the ones rewriting, scanning or locking tables with their estimated row count",
+			},
+			&cli.Int64Flag{
+				Name:  "large-table-rows",
+				Usage: "Estimated number of rows from which annotated plans warn about ACCESS EXCLUSIVE locks on a table",
+				Value: drivers.DefaultLargeTableRows,
+			},
+			&cli.Int64Flag{
+				Name:  "max-rewrite-rows",
+				Usage: "Refuse plans rewriting a table estimated to hold more rows, such as SQLite table rebuilds. Defaults to max_rewrite_rows from the config file",
+			},
+			&cli.BoolFlag{
+				Name:  "allow-large-rewrites",
+				Usage: "Generate plans rewriting tables regardless of --max-rewrite-rows",
+			},
+			&cli.BoolFlag{
+				Name:  "redact-names",
+				Usage: "Replace table, column and other object names with stable pseudonyms in the plan, e.g. to share it with a vendor without revealing the data model",
+			},
+			&cli.StringFlag{
+				Name:  "redact-key",
+				Usage: "Secret the pseudonyms of --redact-names are derived from, keeping them from being guessed by hashing common names",
+			},
+			&cli.StringSliceFlag{
+				Name:  "template-var",
+				Usage: "Write this name as a {{NAME}} placeholder in generated statements, as NAME=VALUE (e.g. schema=app), to fill in later with dbdiff render. Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "hot-table",
+				Usage: "Table on which annotated plans warn about any ACCESS EXCLUSIVE lock, such as one serving most queries. Can be repeated",
+			},
+			&cli.IntFlag{
+				Name:  "max-open-conns",
+				Usage: "Maximum number of connections opened to each database. Defaults to the concurrency",
+			},
+			&cli.IntFlag{
+				Name:  "max-idle-conns",
+				Usage: "Maximum number of idle connections kept for each database. Defaults to max-open-conns",
+			},
+			&cli.DurationFlag{
+				Name:  "connect-timeout",
+				Usage: "Timeout for establishing a database connection (postgres)",
+			},
+			&cli.DurationFlag{
+				Name:  "lock-timeout",
+				Usage: "Set lock_timeout at the start of the plan so DDL waiting on locks fails fast (postgres)",
+			},
+			&cli.DurationFlag{
+				Name:  "statement-timeout",
+				Usage: "Set statement_timeout at the start of the plan (postgres)",
+			},
+			&cli.BoolFlag{
+				Name:  "online",
+				Usage: "Avoid long exclusive locks on existing tables: create and drop indexes concurrently, validate constraints separately and backfill before adding NOT NULL (postgres)",
+			},
+			&cli.BoolFlag{
+				Name:  "defer-validation",
+				Usage: "Add foreign key and check constraints to existing tables NOT VALID, and validate them at the end of the plan so that validation can be scheduled separately (postgres)",
+			},
+			&cli.IntFlag{
+				Name:  "backfill-batch-size",
+				Usage: "Add NOT NULL columns to existing tables as nullable, backfill their default by batches of this many rows, each committed on its own, then constrain them (postgres)",
+			},
+			&cli.BoolFlag{
+				Name:  "align-sequences",
+				Usage: "Move sequences past the existing values of the columns they become the default of, so that new rows do not collide with them (postgres)",
+			},
+			&cli.BoolFlag{
+				Name:  "read-only",
+				Usage: "Open both databases read-only, guaranteeing the diff never writes to them. Disable to compare against SQLite files that do not exist yet",
+				Value: true,
+			},
+			&cli.StringFlag{
+				Name:  "sqlite-key",
+				Usage: "Key unlocking encrypted SQLite databases, on builds with the sqlcipher tag (sqlite3). A _key parameter in a connection string overrides it for that database",
+			},
+			&cli.StringFlag{
+				Name:  "user-version",
+				Usage: "End plans changing anything with PRAGMA user_version set to this integer, or to the target's plus one with auto (sqlite3)",
+				Validator: func(s string) error {
+					if s == drivers.AutoUserVersion {
+						return nil
+					}
+					_, err := strconv.ParseInt(s, 10, 32)
+					if err != nil {
+						return fmt.Errorf("expected %s or an integer, got %q", drivers.AutoUserVersion, s)
+					}
+					return nil
+				},
+			},
+			&cli.BoolFlag{
+				Name:  "pragmas",
+				Usage: "Also compare the settings persisted in the database file: user_version, application_id, page_size, encoding and WAL journal mode (sqlite3)",
+			},
+			&cli.StringFlag{
+				Name:  "sslmode",
+				Usage: "TLS mode: disable, allow, prefer, require, verify-ca or verify-full (postgres). Overrides the connection strings",
+				Validator: func(s string) error {
+					switch s {
+					case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+						return nil
+					}
+					return fmt.Errorf("unsupported sslmode: %s", s)
+				},
+			},
+			&cli.StringFlag{
+				Name:      "sslrootcert",
+				Usage:     "Certificate authorities verifying the server certificate, for verify-ca and verify-full (postgres)",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "sslcert",
+				Usage:     "Client certificate (postgres)",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "sslkey",
+				Usage:     "Private key of the client certificate (postgres)",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:  "ssh",
+				Usage: "Reach the databases through an SSH jump host, as [user@]host[:port] (postgres)",
+			},
+			&cli.StringFlag{
+				Name:      "ssh-key",
+				Usage:     "Private key authenticating to the SSH jump host. Defaults to the SSH agent and ~/.ssh keys",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "ssh-known-hosts",
+				Usage:     "Known hosts file verifying the SSH jump host key. Defaults to ~/.ssh/known_hosts",
+				TakesFile: true,
+			},
+			&cli.BoolFlag{
+				Name:  "pgxpool",
+				Usage: "Use a native pgx connection pool instead of the database/sql one (postgres)",
+			},
 		},
 		Arguments: []cli.Argument{
 			&cli.StringArg{
 				Name:      "source",
-				UsageText: "Database connection URL or path for the source database",
+				UsageText: "Database connection URL, path or environment alias for the source database",
 			},
-			&cli.StringArg{
-				Name:      "target",
-				UsageText: "Database connection URL or path for the target database",
+			&cli.StringArgs{
+				Name:      "targets",
+				UsageText: "Database connection URLs, paths or environment aliases for the target databases",
+				Min:       0,
+				Max:       -1,
 			},
 		},
 	}
-	cmd.Run(context.Background(), os.Args)
-}
-
-func action(ctx context.Context, cmd *cli.Command) error {
-	sourceDatabaseURL := cmd.StringArg("source")
-	if sourceDatabaseURL == "" {
-		return fmt.Errorf("source database URL is required")
-	}
-
-	targetDatabaseURL := cmd.StringArg("target")
-	if targetDatabaseURL == "" {
-		return fmt.Errorf("target database URL is required")
-	}
 
-	var driver drivers.Driver
-	var err error
+	// Cancel in-flight queries on Ctrl-C or when a CI runner times out
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	driverFlag := cmd.String("driver")
-	if driverFlag == "" {
-		driverFlag = "sqlite3"
-	}
+	err := cmd.Run(ctx, os.Args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 
-	switch driverFlag {
-	case "sqlite3":
-		driver, err = drivers.NewSQLiteDriver(&drivers.SQLLiteDriverConfig{
-			SourceDatabasePath: sourceDatabaseURL,
-			TargetDatabasePath: targetDatabaseURL,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to create sqlite3 driver: %w", err)
-		}
-	case "postgres":
-		driver, err = drivers.NewPostgresDriver(&drivers.PostgresDriverConfig{
-			SourceConnectionString: sourceDatabaseURL,
-			TargetConnectionString: targetDatabaseURL,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to create postgres driver: %w", err)
+		var limitErr *drivers.RewriteLimitError
+		if errors.As(err, &limitErr) {
+			fmt.Fprintln(os.Stderr, "Schedule the rewrite, then pass --allow-large-rewrites to generate the plan anyway")
 		}
-	default:
-		return fmt.Errorf("unsupported driver: %s", cmd.String("driver"))
-	}
-	defer driver.Close()
+		stop()
 
-	diff, err := driver.Diff(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to diff databases: %w", err)
+		var failOnErr *failOnError
+		var driftErr *driftDetectedError
+		if errors.As(err, &failOnErr) || errors.As(err, &driftErr) {
+			os.Exit(failOnExitCode)
+		}
+		os.Exit(1)
 	}
-
-	fmt.Println(diff)
-
-	return nil
 }
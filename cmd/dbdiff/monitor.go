@@ -0,0 +1,359 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/sync/errgroup"
+)
+
+func monitorCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "monitor",
+		Usage:     "Periodically diff databases and expose the drift as Prometheus metrics",
+		UsageText: "dbdiff monitor [options] [<source> <target>]",
+		Description: "Compares the pairs of the monitors section of the config file, or the source and target arguments, " +
+			"every --interval. GET /metrics reports the statements needed to bring each target back to its source, " +
+			"and drift notifications are sent when a target starts drifting. The global flags configure every diff.",
+		Action: monitorAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "listen",
+				Usage: "Address to serve metrics on",
+				Value: "localhost:9187",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "Time between two checks of each pair",
+				Value: 5 * time.Minute,
+			},
+			&cli.BoolFlag{
+				Name:  "healthz",
+				Usage: "Serve GET /healthz, failing while the last check of any pair failed",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "source",
+				UsageText: "Database holding the desired schema",
+			},
+			&cli.StringArg{
+				Name:      "target",
+				UsageText: "Database checked for drift",
+			},
+		},
+	}
+}
+
+func monitorAction(ctx context.Context, cmd *cli.Command) error {
+	config, err := loadConfig(cmd.String("config"))
+	if err != nil {
+		return err
+	}
+
+	monitors := config.Monitors
+	if source, target := cmd.StringArg("source"), cmd.StringArg("target"); source != "" || target != "" {
+		monitors = []Monitor{{Source: source, Target: target}}
+	}
+	if len(monitors) == 0 {
+		return fmt.Errorf("source and target database URLs are required, unless the config file lists monitors")
+	}
+
+	interval := cmd.Duration("interval")
+	if interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+
+	metrics := &driftMetrics{}
+	for i := range monitors {
+		err := monitors[i].validate()
+		if err != nil {
+			return err
+		}
+		metrics.results = append(metrics.results, &driftResult{monitor: monitors[i].name()})
+	}
+
+	listener, err := net.Listen("tcp", cmd.String("listen"))
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics)
+	if cmd.Bool("healthz") {
+		mux.HandleFunc("GET /healthz", metrics.serveHealth)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return runHTTPServer(ctx, listener, mux)
+	})
+
+	for i, monitor := range monitors {
+		g.Go(func() error {
+			watchDrift(ctx, cmd, config, monitor, interval, metrics.results[i])
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+// Monitor is a pair of databases `dbdiff monitor` checks for drift.
+type Monitor struct {
+	// Name labels the metrics of the pair. Defaults to the target.
+	Name string `yaml:"name"`
+
+	// Source and Target are database URLs or environment aliases. Either
+	// can be replaced by a schema snapshot written by `dbdiff inspect
+	// --format json`, e.g. to check a database against a committed schema.
+	Source         string `yaml:"source"`
+	Target         string `yaml:"target"`
+	SourceSnapshot string `yaml:"source_snapshot"`
+	TargetSnapshot string `yaml:"target_snapshot"`
+}
+
+func (m Monitor) name() string {
+	return cmp.Or(m.Name, redactURL(m.Target), m.TargetSnapshot)
+}
+
+func (m Monitor) validate() error {
+	for _, side := range []struct {
+		name     string
+		url      string
+		snapshot string
+	}{
+		{"source", m.Source, m.SourceSnapshot},
+		{"target", m.Target, m.TargetSnapshot},
+	} {
+		switch {
+		case side.url != "" && side.snapshot != "":
+			return fmt.Errorf("monitor %s: %s and %s_snapshot are mutually exclusive", m.name(), side.name, side.name)
+		case side.url == "" && side.snapshot == "":
+			return fmt.Errorf("monitor %s: %s or %s_snapshot is required", m.name(), side.name, side.name)
+		}
+	}
+	return nil
+}
+
+// watchDrift checks monitor every interval until ctx is done, recording the
+// outcome of each check in result.
+func watchDrift(ctx context.Context, cmd *cli.Command, config *Config, monitor Monitor, interval time.Duration, result *driftResult) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		objects, err := checkDrift(ctx, cmd, monitor)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "drift check failed", "monitor", result.monitor, "error", err)
+		}
+
+		statements, started := result.record(objects, err)
+		if started {
+			notify(ctx, cmd.Root().ErrWriter, config, event{
+				Event:      driftEvent,
+				Source:     cmp.Or(redactURL(monitor.Source), monitor.SourceSnapshot),
+				Target:     cmp.Or(redactURL(monitor.Target), monitor.TargetSnapshot),
+				Summary:    fmt.Sprintf("Target %s drifted from its source, %d statement(s) needed to bring it back", result.monitor, len(statements)),
+				Statements: statements,
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkDrift returns the plan migrating the target of monitor to its source.
+func checkDrift(ctx context.Context, cmd *cli.Command, monitor Monitor) ([]*drivers.ObjectStatements, error) {
+	var opts []drivers.Option
+	var dialect string
+
+	for _, side := range []struct {
+		path   string
+		option func(*schema.Schema) drivers.Option
+	}{
+		{monitor.SourceSnapshot, drivers.WithSourceSnapshot},
+		{monitor.TargetSnapshot, drivers.WithTargetSnapshot},
+	} {
+		if side.path == "" {
+			continue
+		}
+
+		snapshot, err := readSnapshot(side.path)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, side.option(snapshot))
+		dialect = cmp.Or(dialect, snapshot.Dialect)
+	}
+
+	driver, err := openNamedDriver(ctx, cmd, dialect, monitor.Source, monitor.Target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+
+	planner, ok := drivers.As[drivers.ObjectPlanner](driver)
+	if !ok {
+		return nil, fmt.Errorf("driver cannot group statements by object")
+	}
+
+	plan, err := drivers.CollectPlan(ctx, planner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff databases: %w", err)
+	}
+	return plan, nil
+}
+
+// readSnapshot reads a schema written by `dbdiff inspect --format json`.
+func readSnapshot(path string) (*schema.Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot schema.Schema
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema snapshot %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
+
+// driftResult is the outcome of the last check of a monitor.
+type driftResult struct {
+	monitor string
+
+	mu        sync.Mutex
+	checked   time.Time
+	succeeded bool
+	drifting  bool
+
+	// statements and objects count the statements of the last successful
+	// check, objects by object type.
+	statements int
+	objects    map[schema.ObjectType]int
+}
+
+// record saves the outcome of a check, returning the statements of the plan
+// along with whether the target started drifting with this check.
+func (r *driftResult) record(objects []*drivers.ObjectStatements, err error) ([]string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checked = time.Now()
+	r.succeeded = err == nil
+	if err != nil {
+		return nil, false
+	}
+
+	var statements []string
+	counts := map[schema.ObjectType]int{}
+	for _, object := range objects {
+		if object.Type == drivers.SettingsObject {
+			continue
+		}
+		statements = append(statements, object.Statements...)
+		counts[object.Type]++
+	}
+
+	drifted := !r.drifting && len(statements) > 0
+	r.drifting = len(statements) > 0
+	r.statements = len(statements)
+	r.objects = counts
+	return statements, drifted
+}
+
+// driftMetrics serves the results of every monitor in the Prometheus text
+// exposition format.
+type driftMetrics struct {
+	results []*driftResult
+}
+
+func (m *driftMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+func (m *driftMetrics) write(w io.Writer) {
+	gauge := func(name string, help string, value func(r *driftResult) (string, bool)) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, result := range m.results {
+			result.mu.Lock()
+			if v, ok := value(result); ok {
+				fmt.Fprintf(w, "%s{monitor=%s} %s\n", name, prometheusLabel(result.monitor), v)
+			}
+			result.mu.Unlock()
+		}
+	}
+
+	// Drift is unknown until a check succeeds, and checks until one ran
+	gauge("dbdiff_drift_statements_total", "Statements needed to bring the target back to its source, as of the last successful check.", func(r *driftResult) (string, bool) {
+		return fmt.Sprint(r.statements), r.objects != nil
+	})
+	gauge("dbdiff_last_check_timestamp_seconds", "Unix time of the last check.", func(r *driftResult) (string, bool) {
+		return fmt.Sprint(r.checked.Unix()), !r.checked.IsZero()
+	})
+	gauge("dbdiff_last_check_success", "Whether the last check succeeded.", func(r *driftResult) (string, bool) {
+		if r.succeeded {
+			return "1", true
+		}
+		return "0", !r.checked.IsZero()
+	})
+
+	fmt.Fprintln(w, "# HELP dbdiff_drift_objects Objects differing between the target and its source by object type, as of the last successful check.")
+	fmt.Fprintln(w, "# TYPE dbdiff_drift_objects gauge")
+	for _, result := range m.results {
+		result.mu.Lock()
+		types := make([]string, 0, len(result.objects))
+		for objectType := range result.objects {
+			types = append(types, string(objectType))
+		}
+		slices.Sort(types)
+		for _, objectType := range types {
+			fmt.Fprintf(w, "dbdiff_drift_objects{monitor=%s,type=%s} %d\n", prometheusLabel(result.monitor), prometheusLabel(objectType), result.objects[schema.ObjectType(objectType)])
+		}
+		result.mu.Unlock()
+	}
+}
+
+// serveHealth fails while the last check of any monitor failed.
+func (m *driftMetrics) serveHealth(w http.ResponseWriter, r *http.Request) {
+	for _, result := range m.results {
+		result.mu.Lock()
+		failed := !result.checked.IsZero() && !result.succeeded
+		result.mu.Unlock()
+
+		if failed {
+			http.Error(w, fmt.Sprintf("last check of %s failed", result.monitor), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// prometheusLabel quotes a label value of the Prometheus text format.
+func prometheusLabel(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	driftEvent          = "drift"
+	applyCompletedEvent = "apply.completed"
+	applyFailedEvent    = "apply.failed"
+)
+
+// notificationTimeout bounds each webhook call, so that an unreachable
+// endpoint does not hold the command.
+const notificationTimeout = 10 * time.Second
+
+// slackMaxStatements caps the statements quoted in Slack messages, which
+// are meant to be read rather than applied.
+const slackMaxStatements = 20
+
+// event is the JSON payload of http notifications.
+type event struct {
+	Event  string    `json:"event"`
+	Time   time.Time `json:"time"`
+	Source string    `json:"source,omitempty"`
+	Target string    `json:"target"`
+
+	// Summary is a single line describing the event.
+	Summary string `json:"summary"`
+
+	// Statements are the plan that drifted or was applied.
+	Statements []string `json:"statements"`
+
+	// Error is why an apply failed.
+	Error string `json:"error,omitempty"`
+}
+
+// matches reports whether n is subscribed to the event named name. Events
+// also match the group before their dot, such as apply for apply.failed.
+func (n Notification) matches(name string) bool {
+	if len(n.Events) == 0 {
+		return true
+	}
+
+	group, _, _ := strings.Cut(name, ".")
+	return slices.Contains(n.Events, name) || slices.Contains(n.Events, group)
+}
+
+// notify calls the webhooks of the config subscribed to e. Failures are
+// reported to w without failing the command, whose outcome matters more
+// than its notifications.
+func notify(ctx context.Context, w io.Writer, config *Config, e event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	for _, notification := range config.Notifications {
+		if !notification.matches(e.Event) {
+			continue
+		}
+
+		err := send(ctx, notification, e)
+		if err != nil {
+			fmt.Fprintf(w, "Failed to send %s notification to %s: %s\n", e.Event, webhookHost(notification.URL), err)
+		}
+	}
+}
+
+func send(ctx context.Context, notification Notification, e event) error {
+	var payload any = e
+	switch notification.Type {
+	case "", "http":
+	case "slack":
+		payload = slackMessage(e)
+	default:
+		return fmt.Errorf("unsupported notification type: %s", notification.Type)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, notification.URL, bytes.NewReader(body))
+	if err != nil {
+		return withoutURL(err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range notification.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	return nil
+}
+
+// webhookHost returns the host of a webhook URL, the only part safe to print:
+// the path and query of webhooks such as Slack's are secrets.
+func webhookHost(webhookURL string) string {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || parsed.Host == "" {
+		return "webhook"
+	}
+	return parsed.Host
+}
+
+// withoutURL unwraps the *url.Error of failed requests, which embeds the
+// webhook URL.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// slackMessage formats e for Slack incoming webhooks.
+func slackMessage(e event) map[string]string {
+	var text strings.Builder
+	text.WriteString(e.Summary)
+
+	if e.Error != "" {
+		fmt.Fprintf(&text, "\n> %s", e.Error)
+	}
+
+	if len(e.Statements) > 0 {
+		statements := e.Statements
+		if len(statements) > slackMaxStatements {
+			statements = statements[:slackMaxStatements]
+		}
+
+		text.WriteString("\n```\n")
+		text.WriteString(strings.Join(statements, "\n"))
+		if omitted := len(e.Statements) - len(statements); omitted > 0 {
+			fmt.Fprintf(&text, "\n-- %d more statement(s)", omitted)
+		}
+		text.WriteString("\n```")
+	}
+
+	return map[string]string{"text": text.String()}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifyFailureHidesWebhookURL(t *testing.T) {
+	for _, webhookURL := range []string{
+		// Nothing listens on port 1
+		"http://127.0.0.1:1/services/T000/B000/s3cret",
+		"http://127.0.0.1:1/hooks?token=s3cret",
+		"http://127.0.0.1:1/\x7fs3cret",
+	} {
+		t.Run(webhookURL, func(t *testing.T) {
+			var output bytes.Buffer
+			config := &Config{Notifications: []Notification{{URL: webhookURL, Type: "slack"}}}
+			notify(t.Context(), &output, config, event{Event: driftEvent, Summary: "drift"})
+
+			require.Contains(t, output.String(), "Failed to send drift notification")
+			require.NotContains(t, output.String(), "s3cret")
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/plan"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/sync/errgroup"
+)
+
+func planCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "plan",
+		Usage:     "Save the plan migrating the target to the source, to apply it later",
+		UsageText: "dbdiff plan [options] --out <file> <source> <target>",
+		Description: "The plan file is JSON holding the statements along with a hash of both schemas, " +
+			"so that `dbdiff apply` refuses to run it once the target changed, and a checksum of its content, " +
+			"so that it refuses plans edited after being written.",
+		Action: planAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:      "out",
+				Usage:     "File to write the plan to, - for stdout",
+				Required:  true,
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "sign-key",
+				Usage:     "PEM encoded Ed25519 private key signing the plan, for dbdiff apply --verify-key to check who wrote it",
+				TakesFile: true,
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "source",
+				UsageText: "Database holding the desired schema",
+			},
+			&cli.StringArg{
+				Name:      "target",
+				UsageText: "Database the plan migrates",
+			},
+		},
+	}
+}
+
+func planAction(ctx context.Context, cmd *cli.Command) error {
+	sourceURL := cmd.StringArg("source")
+	targetURL := cmd.StringArg("target")
+	if sourceURL == "" || targetURL == "" {
+		return fmt.Errorf("source and target database URLs are required")
+	}
+
+	file, err := createPlanFile(ctx, cmd, "", sourceURL, targetURL)
+	if err != nil {
+		return err
+	}
+
+	err = sealPlanFile(file, cmd.String("sign-key"))
+	if err != nil {
+		return err
+	}
+
+	out := cmd.String("out")
+	if out == "-" {
+		return file.Write(cmd.Root().Writer)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+
+	err = file.Write(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.Root().ErrWriter, "Plan with %d statement(s) saved to %s\n", len(file.Statements), out)
+	return nil
+}
+
+// createPlanFile introspects both databases once and renders the plan from
+// these very schemas, so that the hashes describe what was compared.
+// driverName defaults to the one of the environments, opts such as
+// snapshots are passed on to the driver.
+func createPlanFile(ctx context.Context, cmd *cli.Command, driverName string, sourceURL string, targetURL string, opts ...drivers.Option) (*plan.File, error) {
+	source, target, err := introspectBoth(ctx, cmd, driverName, sourceURL, targetURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := openNamedDriver(ctx, cmd, driverName, sourceURL, targetURL, drivers.WithSourceSnapshot(source), drivers.WithTargetSnapshot(target))
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+
+	file := &plan.File{
+		Version:    plan.FileVersion,
+		Dialect:    target.Dialect,
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+		Source:     plan.Database{URL: redactURL(sourceURL), Hash: source.Hash()},
+		Target:     plan.Database{URL: redactURL(targetURL), Hash: target.Hash()},
+		Statements: []string{},
+	}
+
+	for statement, err := range driver.Statements(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff databases: %w", err)
+		}
+		file.Statements = append(file.Statements, statement)
+	}
+
+	return file, nil
+}
+
+// sealPlanFile sets the checksum of file, so that apply refuses it once
+// edited, and signs it with the private key at keyPath when set.
+func sealPlanFile(file *plan.File, keyPath string) error {
+	if keyPath == "" {
+		return file.Seal()
+	}
+
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+
+	key, err := plan.ParsePrivateKey(data)
+	if err != nil {
+		return fmt.Errorf("invalid signing key %s: %w", keyPath, err)
+	}
+	return file.Sign(key)
+}
+
+// introspectBoth reads the schemas of both databases concurrently, with the
+// driver registered as driverName unless empty.
+func introspectBoth(ctx context.Context, cmd *cli.Command, driverName string, sourceURL string, targetURL string, opts ...drivers.Option) (source *schema.Schema, target *schema.Schema, err error) {
+	driver, err := openNamedDriver(ctx, cmd, driverName, sourceURL, targetURL, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer driver.Close()
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() (err error) {
+		source, err = driver.Introspect(gctx, drivers.SourceSide)
+		if err != nil {
+			return fmt.Errorf("failed to inspect source database: %w", err)
+		}
+		return nil
+	})
+	g.Go(func() (err error) {
+		target, err = driver.Introspect(gctx, drivers.TargetSide)
+		if err != nil {
+			return fmt.Errorf("failed to inspect target database: %w", err)
+		}
+		return nil
+	})
+
+	err = g.Wait()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return source, target, nil
+}
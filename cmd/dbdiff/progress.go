@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/quantumsheep/dbdiff/plan"
+)
+
+// applyProgress records which statements of a plan were applied to each
+// target, so that an interrupted apply resumes where it stopped instead of
+// applying statements twice. It is saved after every statement.
+type applyProgress struct {
+	path string
+	mu   sync.Mutex
+
+	// Plan is the digest of the plan being applied, see plan.File.Digest.
+	Plan string `json:"plan"`
+
+	// Targets are keyed by the digest of their URL, see progressKey.
+	Targets map[string]*targetProgress `json:"targets"`
+}
+
+type targetProgress struct {
+	// Applied counts the statements applied, from the start of the plan.
+	Applied int  `json:"applied"`
+	Done    bool `json:"done,omitempty"`
+
+	// Hash is the hash of the target schema once the applied statements
+	// ran, for resumed applies to refuse targets changed in between.
+	Hash string `json:"hash,omitempty"`
+}
+
+// progressKey keys the progress of targetURL by its digest, telling apart
+// URLs that only differ by their credentials without writing them down.
+func progressKey(targetURL string) string {
+	sum := sha256.Sum256([]byte(targetURL))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// loadApplyProgress reads the progress file at path, starting afresh when it
+// does not exist. Progress made applying another plan is refused. A nil
+// progress, for an empty path, records nothing.
+func loadApplyProgress(path string, file *plan.File) (*applyProgress, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	digest, err := file.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &applyProgress{path: path, Plan: digest, Targets: map[string]*targetProgress{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return progress, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, progress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid progress file %s: %w", path, err)
+	}
+	if progress.Plan != digest {
+		return nil, fmt.Errorf("progress file %s records another plan, remove it to apply this one", path)
+	}
+	if progress.Targets == nil {
+		progress.Targets = map[string]*targetProgress{}
+	}
+
+	return progress, nil
+}
+
+// target returns the progress of applying the plan to targetURL.
+func (p *applyProgress) target(targetURL string) targetProgress {
+	if p == nil {
+		return targetProgress{}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if progress, ok := p.Targets[progressKey(targetURL)]; ok {
+		return *progress
+	}
+	return targetProgress{}
+}
+
+// record saves that the first applied statements of the plan were applied to
+// targetURL, leaving its schema hashed as hash, done telling whether it was
+// the whole plan.
+func (p *applyProgress) record(targetURL string, applied int, done bool, hash string) error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Targets[progressKey(targetURL)] = &targetProgress{Applied: applied, Done: done, Hash: hash}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Written next to the file then renamed over it, so that a crash never
+	// leaves it half written
+	temp, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(append(data, '\n'))
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), p.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+	return nil
+}
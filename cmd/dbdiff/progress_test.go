@@ -0,0 +1,26 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/quantumsheep/dbdiff/plan"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyProgress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	file := &plan.File{Statements: []string{`CREATE TABLE "a" (id integer);`, `CREATE TABLE "b" (id integer);`}}
+
+	progress, err := loadApplyProgress(path, file)
+	require.NoError(t, err)
+	require.NoError(t, progress.record("postgres://app:first@db/app", 1, false, "sha256:after-first"))
+
+	progress, err = loadApplyProgress(path, file)
+	require.NoError(t, err)
+	require.Equal(t, targetProgress{Applied: 1, Hash: "sha256:after-first"}, progress.target("postgres://app:first@db/app"))
+
+	// URLs only differing by their credentials are distinct targets
+	require.Equal(t, targetProgress{}, progress.target("postgres://app:second@db/app"))
+	require.NotContains(t, progress.Targets, redactURL("postgres://app:first@db/app"))
+}
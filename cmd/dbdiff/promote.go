@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/quantumsheep/dbdiff/plan"
+	"github.com/urfave/cli/v3"
+)
+
+func promoteCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "promote",
+		Usage:     "Plan promoting the schema of an environment to the next one, for review",
+		UsageText: "dbdiff promote [options] <source> <target>",
+		Description: "Writes the plan migrating the target, such as prod, to the source, such as staging, and prints its statements. " +
+			"Nothing is applied: once reviewed, the plan is applied with `dbdiff apply --plan`. " +
+			"With --require-approval, apply also wants the token a second operator gets from `dbdiff approve`, " +
+			"as it does for environments requiring approval in the config file.",
+		Action: promoteAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:      "out",
+				Usage:     "File to write the plan to",
+				Value:     "promote.json",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "sign-key",
+				Usage:     "PEM encoded Ed25519 private key signing the plan, for dbdiff apply --verify-key to check who wrote it",
+				TakesFile: true,
+			},
+			&cli.BoolFlag{
+				Name:  "require-approval",
+				Usage: "Refuse to apply the plan without the approval token of a second operator",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "source",
+				UsageText: "Environment holding the schema to promote",
+			},
+			&cli.StringArg{
+				Name:      "target",
+				UsageText: "Environment to promote the schema to",
+			},
+		},
+	}
+}
+
+func approveCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "approve",
+		Usage:     "Print the token approving a plan written by `dbdiff promote --require-approval`",
+		UsageText: "dbdiff approve [options] --plan <file> --key <file>",
+		Description: "The token is the signature of the plan by the reviewer's key. It approves this very plan only, " +
+			"and is checked by `dbdiff apply --approval-token` against the approval.approvers keys of the config file.",
+		Action: approveAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:      "plan",
+				Usage:     "Plan file to approve",
+				Required:  true,
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "key",
+				Usage:     "PEM encoded Ed25519 private key of the reviewer",
+				Required:  true,
+				TakesFile: true,
+			},
+		},
+	}
+}
+
+func promoteAction(ctx context.Context, cmd *cli.Command) error {
+	sourceURL := cmd.StringArg("source")
+	targetURL := cmd.StringArg("target")
+	if sourceURL == "" || targetURL == "" {
+		return fmt.Errorf("source and target environments are required")
+	}
+
+	config, err := loadConfig(cmd.String("config"))
+	if err != nil {
+		return err
+	}
+
+	file, err := createPlanFile(ctx, cmd, "", sourceURL, targetURL)
+	if err != nil {
+		return err
+	}
+	if len(file.Statements) == 0 {
+		fmt.Fprintf(cmd.Root().ErrWriter, "Nothing to promote, %s already matches %s\n", redactURL(targetURL), redactURL(sourceURL))
+		return nil
+	}
+
+	file.RequiresApproval = cmd.Bool("require-approval")
+	err = sealPlanFile(file, cmd.String("sign-key"))
+	if err != nil {
+		return err
+	}
+
+	out := cmd.String("out")
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+
+	err = file.Write(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	for _, statement := range file.Statements {
+		fmt.Fprintln(cmd.Root().Writer, statement)
+	}
+
+	w := cmd.Root().ErrWriter
+	fmt.Fprintf(w, "\nPlan promoting %s to %s with %d statement(s) saved to %s\n", redactURL(sourceURL), redactURL(targetURL), len(file.Statements), out)
+	if file.RequiresApproval || config.requiresApproval(targetURL) {
+		fmt.Fprintf(w, "Once a second operator ran `dbdiff approve --plan %s --key <key>`, apply it with:\n", out)
+		fmt.Fprintf(w, "  dbdiff apply --plan %s --approval-token <token> %s\n", out, redactURL(targetURL))
+	} else {
+		fmt.Fprintf(w, "Once reviewed, apply it with:\n  dbdiff apply --plan %s %s\n", out, redactURL(targetURL))
+	}
+	return nil
+}
+
+func approveAction(ctx context.Context, cmd *cli.Command) error {
+	file, err := readPlanFile(cmd.String("plan"))
+	if err != nil {
+		return err
+	}
+
+	keyPath := cmd.String("key")
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+
+	key, err := plan.ParsePrivateKey(data)
+	if err != nil {
+		return fmt.Errorf("invalid approval key %s: %w", keyPath, err)
+	}
+
+	token, err := file.Approve(key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.Root().Writer, token)
+	return nil
+}
+
+// checkPlanApproval checks that a plan requiring approval was approved with
+// the private key of one of the public keys at approvers, the trusted
+// approvers of the config file, by someone else than the author who signed
+// it.
+func checkPlanApproval(file *plan.File, token string, approvers []string) error {
+	if len(approvers) == 0 {
+		return fmt.Errorf("plan requires the approval of a second operator, but no approval.approvers are trusted in the config file")
+	}
+	if token == "" {
+		return fmt.Errorf("plan requires the approval of a second operator, pass --approval-token, see `dbdiff approve`")
+	}
+
+	for _, keyPath := range approvers {
+		data, err := os.ReadFile(keyPath)
+		if err != nil {
+			return err
+		}
+
+		key, err := plan.ParsePublicKey(data)
+		if err != nil {
+			return fmt.Errorf("invalid approver key %s: %w", keyPath, err)
+		}
+
+		if file.CheckApproval(token, key) != nil {
+			continue
+		}
+		if file.Signature != "" && file.Verify(key) == nil {
+			return fmt.Errorf("plan was approved with the key it was signed with, approval must come from a second operator")
+		}
+		return nil
+	}
+	return fmt.Errorf("approval token does not match the plan and any of the approval.approvers of the config file")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quantumsheep/dbdiff/plan"
+	"github.com/stretchr/testify/require"
+)
+
+// approverKey generates an Ed25519 key pair, returning the private key and
+// the file holding the PEM encoded public key.
+func approverKey(t *testing.T) (ed25519.PrivateKey, string) {
+	public, private, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "approver.pub.pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+	require.NoError(t, err)
+	return private, path
+}
+
+func TestCheckPlanApproval(t *testing.T) {
+	author, authorPath := approverKey(t)
+	reviewer, reviewerPath := approverKey(t)
+	outsider, _ := approverKey(t)
+
+	file := &plan.File{Statements: []string{`DROP TABLE "t";`}}
+	require.NoError(t, file.Sign(author))
+
+	token, err := file.Approve(reviewer)
+	require.NoError(t, err)
+	require.NoError(t, checkPlanApproval(file, token, []string{authorPath, reviewerPath}))
+
+	t.Run("WithoutApprovers", func(t *testing.T) {
+		require.ErrorContains(t, checkPlanApproval(file, token, nil), "no approval.approvers")
+	})
+
+	t.Run("WithoutToken", func(t *testing.T) {
+		require.ErrorContains(t, checkPlanApproval(file, "", []string{reviewerPath}), "pass --approval-token")
+	})
+
+	t.Run("UntrustedApprover", func(t *testing.T) {
+		token, err := file.Approve(outsider)
+		require.NoError(t, err)
+		require.Error(t, checkPlanApproval(file, token, []string{reviewerPath}))
+	})
+
+	t.Run("ApprovedByAuthor", func(t *testing.T) {
+		token, err := file.Approve(author)
+		require.NoError(t, err)
+		require.ErrorContains(t, checkPlanApproval(file, token, []string{authorPath}), "signed with")
+	})
+}
+
+func TestConfigRequiresApproval(t *testing.T) {
+	config := &Config{Environments: map[string]Environment{
+		"staging": {URL: "postgres://staging/app"},
+		"prod":    {URL: "postgres://prod/app", RequireApproval: true},
+	}}
+	require.True(t, config.requiresApproval("prod"))
+	require.True(t, config.requiresApproval("postgres://prod/app"))
+	require.False(t, config.requiresApproval("staging"))
+	require.False(t, config.requiresApproval("postgres://other/app"))
+
+	config.Approval.Required = true
+	require.True(t, config.requiresApproval("staging"))
+}
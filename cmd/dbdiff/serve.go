@@ -0,0 +1,405 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/sync/errgroup"
+)
+
+func serveCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "serve",
+		Usage:     "Serve diffs over HTTP and gRPC",
+		UsageText: "dbdiff serve [options]",
+		Description: "POST /diff compares the databases or schema snapshots of a JSON request, e.g.\n" +
+			`{"driver": "postgres", "source": "<url>", "targetSchema": <dbdiff inspect --format json output>}` + "\n" +
+			"and responds with the plan as SQL, or as JSON with ?format=json. The global flags configure every diff.",
+		Action: serveAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "listen",
+				Usage: "Address to serve HTTP on. Empty disables HTTP. Addresses other than loopback ones require --auth-token or --tls-client-ca",
+				Value: "localhost:8080",
+			},
+			&cli.StringFlag{
+				Name:  "grpc-listen",
+				Usage: "Address to serve the gRPC API on, see api/dbdiff/v1/dbdiff.proto. Empty disables gRPC. Addresses other than loopback ones require --auth-token or --tls-client-ca",
+			},
+			&cli.StringFlag{
+				Name:      "tls-cert",
+				Usage:     "PEM encoded certificate to serve HTTP and gRPC over TLS with, along with --tls-key",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "tls-key",
+				Usage:     "PEM encoded private key of --tls-cert",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "tls-client-ca",
+				Usage:     "PEM encoded certificates of the authorities signing client certificates, which every client must then present",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:    "auth-token",
+				Usage:   "Token every client must send as an Authorization: Bearer header, or gRPC metadata. Without it or --tls-client-ca, clients may only name the environments of the config file, not database URLs or paths",
+				Sources: cli.EnvVars("DBDIFF_SERVE_TOKEN"),
+			},
+			&cli.BoolFlag{
+				Name:  "allow-apply",
+				Usage: "Enable the gRPC Apply method, migrating databases. Requires --tls-cert, and --auth-token or --tls-client-ca",
+			},
+		},
+	}
+}
+
+// serveSecurity is how the server authenticates its clients.
+type serveSecurity struct {
+	// tls is nil when serving in plain text.
+	tls *tls.Config
+
+	// token is the bearer token clients must send, when set.
+	token string
+}
+
+// authenticated tells whether clients must prove who they are, with a token
+// or a client certificate. Only authenticated clients may name databases by
+// URL or path, which the server would otherwise open for anyone reaching it.
+func (s *serveSecurity) authenticated() bool {
+	return s.token != "" || (s.tls != nil && s.tls.ClientAuth == tls.RequireAndVerifyClientCert)
+}
+
+// authorize checks the Authorization header value sent by a client.
+func (s *serveSecurity) authorize(authorization string) bool {
+	if s.token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// checkURLs refuses the urls that are not environment aliases of the config
+// file unless clients are authenticated, so that unauthenticated servers only
+// open the databases their operator configured.
+func (s *serveSecurity) checkURLs(cmd *cli.Command, urls ...string) error {
+	if s.authenticated() {
+		return nil
+	}
+
+	config, err := loadConfig(cmd.String("config"))
+	if err != nil {
+		return err
+	}
+	for _, url := range urls {
+		if _, found := config.Environments[url]; url != "" && !found {
+			return fmt.Errorf("%s is not an environment of the config file, the only databases unauthenticated clients may name, see --auth-token and --tls-client-ca", redactURL(url))
+		}
+	}
+	return nil
+}
+
+// checkListenAddress refuses to serve unauthenticated clients on addresses
+// other hosts can reach.
+func (s *serveSecurity) checkListenAddress(flag string, address string) error {
+	if s.authenticated() {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid --%s %s: %w", flag, address, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("--%s %s is not a loopback address, serving it requires --auth-token or --tls-client-ca", flag, address)
+	}
+	return nil
+}
+
+// loadServeSecurity reads the TLS and authentication flags of cmd.
+func loadServeSecurity(cmd *cli.Command) (*serveSecurity, error) {
+	security := &serveSecurity{token: cmd.String("auth-token")}
+
+	certPath, keyPath := cmd.String("tls-cert"), cmd.String("tls-key")
+	if (certPath == "") != (keyPath == "") {
+		return nil, fmt.Errorf("--tls-cert and --tls-key go together")
+	}
+	if certPath != "" {
+		certificate, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load --tls-cert: %w", err)
+		}
+		security.tls = &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	}
+
+	if caPath := cmd.String("tls-client-ca"); caPath != "" {
+		if security.tls == nil {
+			return nil, fmt.Errorf("--tls-client-ca requires --tls-cert")
+		}
+		data, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificate found in --tls-client-ca %s", caPath)
+		}
+		security.tls.ClientCAs = pool
+		security.tls.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	// Apply runs DDL with the credentials of the server, only over
+	// encrypted connections from known clients
+	if cmd.Bool("allow-apply") && (security.tls == nil || !security.authenticated()) {
+		return nil, fmt.Errorf("--allow-apply requires --tls-cert, and --auth-token or --tls-client-ca")
+	}
+
+	for _, flag := range []string{"listen", "grpc-listen"} {
+		if address := cmd.String(flag); address != "" {
+			err := security.checkListenAddress(flag, address)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return security, nil
+}
+
+func serveAction(ctx context.Context, cmd *cli.Command) error {
+	httpAddress := cmd.String("listen")
+	grpcAddress := cmd.String("grpc-listen")
+	if httpAddress == "" && grpcAddress == "" {
+		return fmt.Errorf("--listen or --grpc-listen is required")
+	}
+
+	security, err := loadServeSecurity(cmd)
+	if err != nil {
+		return err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	if httpAddress != "" {
+		listener, err := net.Listen("tcp", httpAddress)
+		if err != nil {
+			return err
+		}
+		if security.tls != nil {
+			listener = tls.NewListener(listener, security.tls)
+		}
+
+		g.Go(func() error {
+			return serveHTTP(ctx, cmd, security, listener)
+		})
+	}
+
+	if grpcAddress != "" {
+		listener, err := net.Listen("tcp", grpcAddress)
+		if err != nil {
+			return err
+		}
+
+		g.Go(func() error {
+			return serveGRPC(ctx, cmd, security, listener)
+		})
+	}
+
+	return g.Wait()
+}
+
+func serveHTTP(ctx context.Context, cmd *cli.Command, security *serveSecurity, listener net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("POST /diff", &diffHandler{cmd: cmd, security: security})
+
+	return runHTTPServer(ctx, listener, requireToken(security, mux))
+}
+
+// requireToken refuses the requests lacking the token of security.
+func requireToken(security *serveSecurity, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !security.authorize(r.Header.Get("Authorization")) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// runHTTPServer serves handler on listener until ctx is done, then waits for
+// in-flight requests to complete.
+func runHTTPServer(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening for HTTP on %s\n", listener.Addr())
+
+	err := server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// diffRequest is the body of POST /diff. Each side is either a database URL
+// or environment alias, or a schema snapshot.
+type diffRequest struct {
+	// Driver defaults to the dialect of the snapshots, then to --driver and
+	// the environments.
+	Driver string `json:"driver"`
+
+	Source       string         `json:"source"`
+	Target       string         `json:"target"`
+	SourceSchema *schema.Schema `json:"sourceSchema"`
+	TargetSchema *schema.Schema `json:"targetSchema"`
+}
+
+type diffResponse struct {
+	Statements []string `json:"statements"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type diffHandler struct {
+	cmd      *cli.Command
+	security *serveSecurity
+}
+
+func (h *diffHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	asJSON := r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+
+	fail := func(status int, err error) {
+		slog.WarnContext(r.Context(), "diff failed", "status", status, "error", err)
+
+		if asJSON {
+			writeJSON(w, status, errorResponse{Error: err.Error()})
+		} else {
+			http.Error(w, err.Error(), status)
+		}
+	}
+
+	var request diffRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<20)).Decode(&request)
+	if err != nil {
+		fail(http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+
+	statements, err := h.diff(r.Context(), &request)
+	var badRequest *badRequestError
+	if errors.As(err, &badRequest) {
+		fail(http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		fail(http.StatusInternalServerError, err)
+		return
+	}
+
+	if asJSON {
+		writeJSON(w, http.StatusOK, diffResponse{Statements: statements})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/sql; charset=utf-8")
+	for _, statement := range statements {
+		fmt.Fprintln(w, statement)
+	}
+}
+
+type badRequestError struct {
+	message string
+}
+
+func (e *badRequestError) Error() string {
+	return e.message
+}
+
+func (h *diffHandler) diff(ctx context.Context, request *diffRequest) ([]string, error) {
+	var opts []drivers.Option
+
+	for _, side := range []struct {
+		name     string
+		url      string
+		snapshot *schema.Schema
+		option   func(*schema.Schema) drivers.Option
+	}{
+		{"source", request.Source, request.SourceSchema, drivers.WithSourceSnapshot},
+		{"target", request.Target, request.TargetSchema, drivers.WithTargetSnapshot},
+	} {
+		switch {
+		case side.url != "" && side.snapshot != nil:
+			return nil, &badRequestError{fmt.Sprintf("%s and %sSchema are mutually exclusive", side.name, side.name)}
+		case side.url == "" && side.snapshot == nil:
+			return nil, &badRequestError{fmt.Sprintf("%s or %sSchema is required", side.name, side.name)}
+		case side.snapshot != nil:
+			opts = append(opts, side.option(side.snapshot))
+		}
+	}
+
+	err := h.security.checkURLs(h.cmd, request.Source, request.Target)
+	if err != nil {
+		return nil, &badRequestError{err.Error()}
+	}
+
+	var snapshotDialect string
+	if request.SourceSchema != nil {
+		snapshotDialect = request.SourceSchema.Dialect
+	}
+	if request.TargetSchema != nil {
+		snapshotDialect = cmp.Or(snapshotDialect, request.TargetSchema.Dialect)
+	}
+
+	driver, err := openNamedDriver(ctx, h.cmd, cmp.Or(request.Driver, snapshotDialect), request.Source, request.Target, opts...)
+	if err != nil {
+		return nil, &badRequestError{err.Error()}
+	}
+	defer driver.Close()
+
+	statements := []string{}
+	for statement, err := range driver.Statements(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff databases: %w", err)
+		}
+		statements = append(statements, statement)
+	}
+
+	return statements, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+)
+
+// defaultSnapshotDir is where snapshots are stored unless --snapshot-dir says
+// otherwise.
+const defaultSnapshotDir = ".dbdiff/snapshots"
+
+func snapshotCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "snapshot",
+		Usage: "Save and browse the history of a database schema",
+		Description: "Snapshots are stored as JSON files in --snapshot-dir, to be committed or archived. " +
+			"`dbdiff --since <id> <url>` prints what changed in a database since a snapshot.",
+		Commands: []*cli.Command{
+			{
+				Name:      "save",
+				Usage:     "Save a snapshot of the schema of a database",
+				UsageText: "dbdiff snapshot save [options] <url>",
+				Action:    snapshotSaveAction,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "message",
+						Aliases: []string{"m"},
+						Usage:   "Note recorded with the snapshot, such as the release it was taken for",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{
+						Name:      "url",
+						UsageText: "Database connection URL, path or environment alias",
+					},
+				},
+			},
+			{
+				Name:      "list",
+				Usage:     "List the saved snapshots, oldest first",
+				UsageText: "dbdiff snapshot list [options]",
+				Action:    snapshotListAction,
+			},
+			{
+				Name:      "show",
+				Usage:     "Print a saved snapshot",
+				UsageText: "dbdiff snapshot show [options] <id>",
+				Action:    snapshotShowAction,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Output format: json, sql or tree",
+						Value: "json",
+						Validator: func(s string) error {
+							switch s {
+							case "json", "sql", "tree":
+								return nil
+							}
+							return fmt.Errorf("unsupported format: %s", s)
+						},
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{
+						Name:      "id",
+						UsageText: "Snapshot id, or a unique prefix of it",
+					},
+				},
+			},
+		},
+	}
+}
+
+// storedSnapshot is a snapshot file of the store.
+type storedSnapshot struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Database is the URL the schema was read from, without its password.
+	Database string `json:"database"`
+	Message  string `json:"message,omitempty"`
+
+	// Hash is the hash of Schema, see schema.Schema.Hash.
+	Hash   string         `json:"hash"`
+	Schema *schema.Schema `json:"schema"`
+}
+
+// snapshotStore keeps snapshots as <id>.json files of a directory. Ids start
+// with the creation time, so that they sort chronologically.
+type snapshotStore struct {
+	dir string
+}
+
+func newSnapshotStore(cmd *cli.Command) *snapshotStore {
+	return &snapshotStore{dir: cmd.String("snapshot-dir")}
+}
+
+func (s *snapshotStore) save(databaseURL string, message string, snapshot *schema.Schema) (*storedSnapshot, error) {
+	err := os.MkdirAll(s.dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := snapshot.Hash()
+	now := time.Now().UTC()
+	stored := &storedSnapshot{
+		ID:        now.Format("20060102T150405Z") + "-" + strings.TrimPrefix(hash, "sha256:")[:8],
+		CreatedAt: now,
+		Database:  redactURL(databaseURL),
+		Message:   message,
+		Hash:      hash,
+		Schema:    snapshot,
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	// Never overwrite a snapshot, even one saved the same second
+	f, err := os.OpenFile(filepath.Join(s.dir, stored.ID+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+// ids lists the ids of the stored snapshots, oldest first.
+func (s *snapshotStore) ids() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// load reads the snapshot whose id is id, or starts with it when that is
+// unambiguous.
+func (s *snapshotStore) load(id string) (*storedSnapshot, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, candidate := range ids {
+		if candidate == id {
+			matches = []string{candidate}
+			break
+		}
+		if strings.HasPrefix(candidate, id) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	switch {
+	case id == "" || len(matches) == 0:
+		return nil, fmt.Errorf("no snapshot %q in %s", id, s.dir)
+	case len(matches) > 1:
+		return nil, fmt.Errorf("snapshot id %q is ambiguous, it matches %s", id, strings.Join(matches, ", "))
+	}
+
+	path := filepath.Join(s.dir, matches[0]+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored storedSnapshot
+	err = json.Unmarshal(data, &stored)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	if stored.Schema == nil {
+		return nil, fmt.Errorf("invalid snapshot %s: no schema", path)
+	}
+	return &stored, nil
+}
+
+func snapshotSaveAction(ctx context.Context, cmd *cli.Command) error {
+	databaseURL := cmd.StringArg("url")
+	if databaseURL == "" {
+		return fmt.Errorf("database URL is required")
+	}
+
+	s, err := introspectDatabase(ctx, cmd, "", databaseURL)
+	if err != nil {
+		return err
+	}
+
+	stored, err := newSnapshotStore(cmd).save(databaseURL, cmd.String("message"), s)
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	fmt.Fprintln(cmd.Root().Writer, stored.ID)
+	return nil
+}
+
+func snapshotListAction(ctx context.Context, cmd *cli.Command) error {
+	store := newSnapshotStore(cmd)
+
+	ids, err := store.ids()
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(cmd.Root().Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tCREATED\tDATABASE\tTABLES\tVIEWS\tMESSAGE")
+	for _, id := range ids {
+		stored, err := store.load(id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%d\t%s\n", stored.ID, stored.CreatedAt.Local().Format(time.DateTime), stored.Database, len(stored.Schema.Tables), len(stored.Schema.Views), firstLine(stored.Message))
+	}
+	return table.Flush()
+}
+
+func snapshotShowAction(ctx context.Context, cmd *cli.Command) error {
+	stored, err := newSnapshotStore(cmd).load(cmd.StringArg("id"))
+	if err != nil {
+		return err
+	}
+
+	w := cmd.Root().Writer
+
+	switch cmd.String("format") {
+	case "sql":
+		// Rendering only needs the driver of the snapshot's dialect, which
+		// never queries the database it was taken from
+		driver, err := openNamedDriver(ctx, cmd, stored.Schema.Dialect, "", "", drivers.WithSourceSnapshot(stored.Schema), drivers.WithTargetSnapshot(stored.Schema))
+		if err != nil {
+			return err
+		}
+		defer driver.Close()
+
+		return printSchemaSQL(w, driver, stored.Schema)
+	case "tree":
+		printSchemaTree(w, stored.Schema)
+		return nil
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stored.Schema)
+}
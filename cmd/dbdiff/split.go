@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/urfave/cli/v3"
+)
+
+// splitManifestName lists the files written by --split-output in the order
+// they must be applied.
+const splitManifestName = "manifest.json"
+
+type splitManifestEntry struct {
+	File string `json:"file"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+
+	// Contract marks the files deferred to the end with --defer-destructive.
+	Contract bool `json:"contract,omitempty"`
+
+	// SHA256 is the checksum of the file, so that reviewed files can be
+	// checked to be unchanged before they are applied.
+	SHA256 string `json:"sha256"`
+}
+
+// writeSplitPlan writes the plan migrating targetURL to sourceURL to
+// directory, one file per object along with the manifest, and returns how
+// many statements it has.
+func writeSplitPlan(ctx context.Context, cmd *cli.Command, directory string, sourceURL string, targetURL string, opts ...drivers.Option) (int, error) {
+	err := os.MkdirAll(directory, 0o755)
+	if err != nil {
+		return 0, err
+	}
+
+	// Files left from a previous plan would be mistaken for this one's
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) > 0 {
+		return 0, fmt.Errorf("split output directory %s is not empty", directory)
+	}
+
+	driver, err := openDriver(ctx, cmd, sourceURL, targetURL, opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer driver.Close()
+
+	planner, ok := drivers.As[drivers.ObjectPlanner](driver)
+	if !ok {
+		return 0, fmt.Errorf("driver cannot group statements by object")
+	}
+
+	manifest := []splitManifestEntry{}
+	count := 0
+
+	for object, err := range planner.ObjectStatements(ctx) {
+		if err != nil {
+			return count, fmt.Errorf("failed to diff databases: %w", err)
+		}
+
+		entry := splitManifestEntry{
+			File:     splitFileName(len(manifest)+1, object),
+			Type:     string(object.Type),
+			Name:     object.Name,
+			Contract: object.Contract,
+		}
+
+		content := strings.Join(object.Statements, "\n") + "\n"
+		sum := sha256.Sum256([]byte(content))
+		entry.SHA256 = hex.EncodeToString(sum[:])
+
+		err := os.WriteFile(filepath.Join(directory, entry.File), []byte(content), 0o644)
+		if err != nil {
+			return count, err
+		}
+
+		manifest = append(manifest, entry)
+		count += len(object.Statements)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return count, err
+	}
+
+	return count, os.WriteFile(filepath.Join(directory, splitManifestName), append(data, '\n'), 0o644)
+}
+
+// splitFileName names the file of the n-th object of a plan, e.g.
+// 002_idx_users_name.index.sql. The number keeps files in plan order and
+// apart when an object is migrated in several steps.
+func splitFileName(n int, object *drivers.ObjectStatements) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, object.Name)
+
+	if object.Type == "" {
+		return fmt.Sprintf("%03d_%s.sql", n, "plan")
+	}
+	return fmt.Sprintf("%03d_%s.%s.sql", n, name, object.Type)
+}
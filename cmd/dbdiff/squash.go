@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/internal/containers"
+	"github.com/quantumsheep/dbdiff/plan"
+	"github.com/urfave/cli/v3"
+)
+
+func squashCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "squash",
+		Usage:     "Replay a migrations directory into a scratch database and print the resulting schema",
+		UsageText: "dbdiff squash [options] <migrations directory>",
+		Description: "Migrations are the .sql files of the directory, or the up.sql files of its subdirectories, " +
+			"applied in name order. Down migrations (*.down.sql, down.sql) are skipped.",
+		Action: squashAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "scratch",
+				Usage: "Empty database to replay the migrations into, refused when it holds any table or view. Defaults to a temporary SQLite file; required for other drivers",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "directory",
+				UsageText: "Directory holding the migrations",
+			},
+		},
+	}
+}
+
+func squashAction(ctx context.Context, cmd *cli.Command) error {
+	directory := cmd.StringArg("directory")
+	if directory == "" {
+		return fmt.Errorf("migrations directory is required")
+	}
+
+	migrations, err := findMigrations(directory)
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		return fmt.Errorf("no migrations found in %s", directory)
+	}
+
+	driverName, err := driverNameFor(cmd, "", "")
+	if err != nil {
+		return err
+	}
+
+	scratchURL, cleanup, err := scratchDatabase(ctx, cmd, driverName, cmd.String("scratch"))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	driver, err := openScratchDriver(ctx, cmd, driverName, scratchURL)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	for _, migration := range migrations {
+		err := replayFile(ctx, driver, migration)
+		if err != nil {
+			return err
+		}
+	}
+
+	s, err := driver.Introspect(ctx, drivers.SourceSide)
+	if err != nil {
+		return fmt.Errorf("failed to inspect scratch database: %w", err)
+	}
+
+	return printSchemaSQL(cmd.Root().Writer, driver, s)
+}
+
+// findMigrations lists the up migrations of a directory in the order they
+// apply.
+func findMigrations(directory string) ([]string, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []string
+	for _, entry := range entries {
+		path := filepath.Join(directory, entry.Name())
+
+		if entry.IsDir() {
+			up := filepath.Join(path, "up.sql")
+			if _, err := os.Stat(up); err == nil {
+				migrations = append(migrations, up)
+			}
+			continue
+		}
+
+		if strings.HasSuffix(entry.Name(), ".sql") && !strings.HasSuffix(entry.Name(), ".down.sql") {
+			migrations = append(migrations, path)
+		}
+	}
+
+	// os.ReadDir already sorts by name, keep it explicit since the order
+	// matters
+	slices.Sort(migrations)
+
+	return migrations, nil
+}
+
+// scratchDatabase returns scratchURL, once checked to be empty, or else a
+// temporary SQLite file for the sqlite3 driver. Builds with the
+// testcontainers tag start a Postgres container instead of requiring a
+// scratch URL. cleanup removes the temporary database and must be called once
+// done with it.
+func scratchDatabase(ctx context.Context, cmd *cli.Command, driverName string, scratchURL string) (url string, cleanup func(), err error) {
+	if scratchURL != "" {
+		err := checkScratchEmpty(ctx, cmd, driverName, scratchURL)
+		if err != nil {
+			return "", nil, err
+		}
+		return scratchURL, func() {}, nil
+	}
+
+	switch driverName {
+	case "sqlite3":
+	case "postgres":
+		dsn, terminate, err := containers.Postgres(ctx)
+		if errors.Is(err, containers.ErrUnsupported) {
+			return "", nil, fmt.Errorf("a scratch database is required with the %s driver", driverName)
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to start scratch database: %w", err)
+		}
+		return dsn, func() { terminate() }, nil
+	default:
+		return "", nil, fmt.Errorf("a scratch database is required with the %s driver", driverName)
+	}
+
+	directory, err := os.MkdirTemp("", "dbdiff-scratch-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	return filepath.Join(directory, "scratch.sqlite"), func() { os.RemoveAll(directory) }, nil
+}
+
+// checkScratchEmpty refuses scratch databases holding any object, which
+// statements replayed there could alter or drop. --table and ignore rules are
+// left out, for filtered objects to count too.
+func checkScratchEmpty(ctx context.Context, cmd *cli.Command, driverName string, scratchURL string) error {
+	unfiltered := func(c *drivers.DriverConfig) {
+		c.Tables = nil
+		c.Ignore = nil
+		c.Cache = nil
+	}
+
+	s, err := introspectDatabase(ctx, cmd, driverName, scratchURL, unfiltered)
+	if err != nil {
+		return err
+	}
+
+	if len(s.Tables)+len(s.Views)+len(s.Aggregates)+len(s.Operators)+len(s.Casts) > 0 {
+		return fmt.Errorf("scratch database %s is not empty, refusing to use it", redactURL(scratchURL))
+	}
+	return nil
+}
+
+// openScratchDriver opens a writable driver on the scratch database, on both
+// sides.
+func openScratchDriver(ctx context.Context, cmd *cli.Command, driverName string, scratchURL string) (scratchDriver, error) {
+	driver, err := openNamedDriver(ctx, cmd, driverName, scratchURL, scratchURL, drivers.WithReadOnly(false))
+	if err != nil {
+		return nil, err
+	}
+
+	executor, ok := driver.(scratchDriver)
+	if !ok {
+		driver.Close()
+		return nil, fmt.Errorf("driver cannot execute statements")
+	}
+
+	return executor, nil
+}
+
+type scratchDriver interface {
+	drivers.Driver
+	drivers.Executor
+}
+
+// execStatements executes statements one by one on the source side.
+func execStatements(ctx context.Context, executor drivers.Executor, statements iter.Seq2[string, error]) error {
+	for statement, err := range statements {
+		if err != nil {
+			return err
+		}
+
+		err = executor.Exec(ctx, drivers.SourceSide, statement)
+		if err != nil {
+			return fmt.Errorf("failed to execute statement: %w\n%s", err, statement)
+		}
+	}
+
+	return nil
+}
+
+// replayFile executes every statement of a SQL file on the source side.
+func replayFile(ctx context.Context, executor drivers.Executor, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for statement, err := range plan.Split(file) {
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		err = executor.Exec(ctx, drivers.SourceSide, statement.SQL)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, statement.Line, err)
+		}
+	}
+
+	return nil
+}
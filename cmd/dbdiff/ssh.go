@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTunnels opens one SSH connection per jump host, shared by every database
+// reached through it.
+type sshTunnels struct {
+	cmd     *cli.Command
+	clients map[string]*ssh.Client
+	closers []io.Closer
+}
+
+func newSSHTunnels(cmd *cli.Command) *sshTunnels {
+	return &sshTunnels{
+		cmd:     cmd,
+		clients: make(map[string]*ssh.Client),
+	}
+}
+
+// dialFunc returns a dial function forwarding connections through the jump
+// host at destination, given as [user@]host[:port].
+func (t *sshTunnels) dialFunc(ctx context.Context, destination string) (drivers.DialFunc, error) {
+	client, found := t.clients[destination]
+	if !found {
+		var err error
+		client, err = t.connect(ctx, destination)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to SSH host %s: %w", destination, err)
+		}
+
+		t.clients[destination] = client
+		t.closers = append(t.closers, client)
+	}
+
+	return client.DialContext, nil
+}
+
+func (t *sshTunnels) connect(ctx context.Context, destination string) (*ssh.Client, error) {
+	username, addr, found := strings.Cut(destination, "@")
+	if !found {
+		addr = destination
+
+		current, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		username = current.Username
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	hostKeyCallback, err := knownhosts.New(t.knownHostsPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %w", err)
+	}
+
+	auth, err := t.authMethods()
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         t.cmd.Duration("connect-timeout"),
+	}
+
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(clientConn, channels, requests), nil
+}
+
+func (t *sshTunnels) knownHostsPath() string {
+	if path := t.cmd.String("ssh-known-hosts"); path != "" {
+		return path
+	}
+
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+// authMethods authenticates with --ssh-key when given, or else with the SSH
+// agent and the default keys without passphrase.
+func (t *sshTunnels) authMethods() ([]ssh.AuthMethod, error) {
+	if path := t.cmd.String("ssh-key"); path != "" {
+		signer, err := readPrivateKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SSH key %s: %w", path, err)
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	var methods []ssh.AuthMethod
+
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			t.closers = append(t.closers, conn)
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		signer, err := readPrivateKey(filepath.Join(home, ".ssh", name))
+		if err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if len(methods) == 0 {
+		return nil, errors.New("no SSH agent or key available, use --ssh-key")
+	}
+
+	return methods, nil
+}
+
+func readPrivateKey(path string) (ssh.Signer, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(content)
+}
+
+func (t *sshTunnels) Close() error {
+	var errs []error
+	for _, closer := range t.closers {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// tunneledDriver closes the SSH tunnels along with the driver using them.
+type tunneledDriver struct {
+	drivers.Driver
+	tunnels *sshTunnels
+}
+
+func (d *tunneledDriver) Close() error {
+	return errors.Join(d.Driver.Close(), d.tunnels.Close())
+}
+
+// Unwrap returns the tunneled driver, for drivers.As to find the optional
+// interfaces it implements.
+func (d *tunneledDriver) Unwrap() drivers.Driver {
+	return d.Driver
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/urfave/cli/v3"
+)
+
+func renderCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "render",
+		Usage:     "Fill the placeholders of a plan generated with --template-var",
+		UsageText: "dbdiff render --var NAME=VALUE [--var NAME=VALUE...] <plan.sql|->",
+		Description: "Replaces every {{NAME}} placeholder of the plan with its value and prints the result, " +
+			"e.g. to apply a plan generated against one tenant schema to another. " +
+			"Fails when a placeholder has no value.",
+		Action: renderAction,
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "var",
+				Usage: "Value of a placeholder, as NAME=VALUE (e.g. schema=tenant_42). Can be repeated",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "plan",
+				UsageText: "Plan file, or - for stdin",
+			},
+		},
+	}
+}
+
+func renderAction(ctx context.Context, cmd *cli.Command) error {
+	path := cmd.StringArg("plan")
+	if path == "" {
+		return fmt.Errorf("plan file is required")
+	}
+
+	vars, err := parseTemplateVars("var", cmd.StringSlice("var"))
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]string, len(vars))
+	for _, v := range vars {
+		values[v.Name] = v.Value
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	rendered, err := drivers.FillTemplate(string(data), values)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(cmd.Root().Writer, rendered)
+	return err
+}
+
+// parseTemplateVars parses the NAME=VALUE values of flag.
+func parseTemplateVars(flag string, values []string) ([]drivers.TemplateVar, error) {
+	var vars []drivers.TemplateVar
+	for _, value := range values {
+		v, err := drivers.ParseTemplateVar(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s value: %w", flag, err)
+		}
+		vars = append(vars, v)
+	}
+	return vars, nil
+}
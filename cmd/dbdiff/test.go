@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v3"
+)
+
+func testCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "test",
+		Usage:     "Check that a migrations directory builds the desired schema",
+		UsageText: "dbdiff test [options] --migrations <directory> --desired <schema.sql>",
+		Description: "The migrations are replayed into a scratch database and the desired schema is loaded into another one, " +
+			"as with `dbdiff squash`. The statements migrating the first to the second are printed, failing unless there are none, " +
+			"which catches migrations edited by hand that drifted from the canonical schema.",
+		Action: testAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:      "migrations",
+				Usage:     "Directory holding the migrations",
+				Required:  true,
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "desired",
+				Usage:     "SQL file creating the desired schema",
+				Required:  true,
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:  "scratch",
+				Usage: "Empty database to replay the migrations into, refused when it holds any table or view. Defaults to a temporary SQLite file; required for other drivers",
+			},
+			&cli.StringFlag{
+				Name:  "desired-scratch",
+				Usage: "Empty database to load the desired schema into, same defaults as --scratch",
+			},
+		},
+	}
+}
+
+func testAction(ctx context.Context, cmd *cli.Command) error {
+	directory := cmd.String("migrations")
+	migrations, err := findMigrations(directory)
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		return fmt.Errorf("no migrations found in %s", directory)
+	}
+
+	driverName, err := driverNameFor(cmd, "", "")
+	if err != nil {
+		return err
+	}
+
+	migratedURL, cleanup, err := scratchDatabase(ctx, cmd, driverName, cmd.String("scratch"))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	err = replayInto(ctx, cmd, driverName, migratedURL, migrations...)
+	if err != nil {
+		return err
+	}
+
+	desiredURL, cleanup, err := scratchDatabase(ctx, cmd, driverName, cmd.String("desired-scratch"))
+	if err != nil {
+		return fmt.Errorf("--desired-scratch: %w", err)
+	}
+	defer cleanup()
+
+	err = replayInto(ctx, cmd, driverName, desiredURL, cmd.String("desired"))
+	if err != nil {
+		return err
+	}
+
+	driver, err := openNamedDriver(ctx, cmd, driverName, desiredURL, migratedURL)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	w := cmd.Root().Writer
+	count := 0
+	for statement, err := range driver.Statements(ctx) {
+		if err != nil {
+			return fmt.Errorf("failed to diff databases: %w", err)
+		}
+
+		fmt.Fprintln(w, statement)
+		count++
+	}
+
+	if count > 0 {
+		return fmt.Errorf("migrations of %s do not build %s: %d statement(s) still needed", directory, cmd.String("desired"), count)
+	}
+
+	fmt.Fprintf(w, "-- Migrations match: %d migration(s) replayed\n", len(migrations))
+	return nil
+}
+
+// replayInto executes the SQL files at paths, in order, in the scratch
+// database at scratchURL.
+func replayInto(ctx context.Context, cmd *cli.Command, driverName string, scratchURL string, paths ...string) error {
+	driver, err := openScratchDriver(ctx, cmd, driverName, scratchURL)
+	if err != nil {
+		return err
+	}
+	defer driver.Close()
+
+	for _, path := range paths {
+		err := replayFile(ctx, driver, path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
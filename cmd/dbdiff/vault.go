@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// vaultPrefix marks config values read from HashiCorp Vault, as
+// vault:<path>#<key>, e.g. vault:secret/data/db#password.
+const vaultPrefix = "vault:"
+
+// vaultTimeout bounds each request to Vault.
+const vaultTimeout = 10 * time.Second
+
+// vaultSecrets reads secrets from the Vault server at VAULT_ADDR,
+// authenticated with VAULT_TOKEN or the token `vault login` saved, reading
+// each path once.
+type vaultSecrets struct {
+	secrets map[string]map[string]any
+}
+
+func newVaultSecrets() *vaultSecrets {
+	return &vaultSecrets{secrets: make(map[string]map[string]any)}
+}
+
+// resolve returns value, or the secret it references when prefixed with
+// vault:.
+func (v *vaultSecrets) resolve(ctx context.Context, value string) (string, error) {
+	reference, found := strings.CutPrefix(value, vaultPrefix)
+	if !found {
+		return value, nil
+	}
+
+	path, key, found := strings.Cut(reference, "#")
+	if !found || path == "" || key == "" {
+		return "", fmt.Errorf("invalid Vault reference %q, expected vault:<path>#<key>", value)
+	}
+
+	secret, found := v.secrets[path]
+	if !found {
+		var err error
+		secret, err = readVaultSecret(ctx, path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read Vault secret %s: %w", path, err)
+		}
+		v.secrets[path] = secret
+	}
+
+	field, ok := secret[key].(string)
+	if !ok {
+		return "", fmt.Errorf("Vault secret %s has no %s string", path, key)
+	}
+	return field, nil
+}
+
+func readVaultSecret(ctx context.Context, path string) (map[string]any, error) {
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return nil, errors.New("VAULT_ADDR is not set")
+	}
+
+	token, err := vaultToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, vaultTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		request.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Version 2 of the KV engine nests the secret along with its metadata
+	if data, ok := body.Data["data"].(map[string]any); ok {
+		if _, versioned := body.Data["metadata"]; versioned {
+			return data, nil
+		}
+	}
+	return body.Data, nil
+}
+
+// vaultToken returns VAULT_TOKEN, or else the token saved by `vault login`.
+func vaultToken() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return "", errors.New("VAULT_TOKEN is not set and ~/.vault-token cannot be read, run vault login")
+	}
+	return strings.TrimSpace(string(content)), nil
+}
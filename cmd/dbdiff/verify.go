@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/urfave/cli/v3"
+)
+
+func verifyCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "verify",
+		Usage:     "Check that the generated plan turns the target into the source",
+		UsageText: "dbdiff verify [options] <source> <target>",
+		Description: "The target schema is copied into a scratch database, where the plan gets applied. " +
+			"The scratch database is then compared against the source, failing unless they match.",
+		Action: verifyAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "scratch",
+				Usage: "Empty database to apply the plan to, refused when it holds any table or view. Defaults to a temporary SQLite file; required for other drivers",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:      "source",
+				UsageText: "Database holding the desired schema",
+			},
+			&cli.StringArg{
+				Name:      "target",
+				UsageText: "Database the plan migrates",
+			},
+		},
+	}
+}
+
+func verifyAction(ctx context.Context, cmd *cli.Command) error {
+	sourceURL := cmd.StringArg("source")
+	targetURL := cmd.StringArg("target")
+	if sourceURL == "" || targetURL == "" {
+		return fmt.Errorf("source and target database URLs are required")
+	}
+
+	driverName, err := driverNameFor(cmd, sourceURL, targetURL)
+	if err != nil {
+		return err
+	}
+
+	result, err := verifyPlan(ctx, cmd, driverName, sourceURL, targetURL, cmd.String("scratch"))
+	if err != nil {
+		return err
+	}
+
+	w := cmd.Root().Writer
+	for _, statement := range result.Remaining {
+		fmt.Fprintln(w, statement)
+	}
+	if len(result.Remaining) > 0 {
+		return fmt.Errorf("plan does not converge: %d statement(s) still needed after applying it", len(result.Remaining))
+	}
+
+	fmt.Fprintf(w, "-- Plan verified: %d statement(s) applied\n", len(result.Plan))
+	return nil
+}
+
+// verification is the outcome of verifyPlan.
+type verification struct {
+	// Plan migrates the target to the source.
+	Plan []string
+
+	// Remaining are the statements still needed once Plan is applied to a
+	// copy of the target. The plan is correct when there are none.
+	Remaining []string
+}
+
+// verifyPlan generates the plan between sourceURL and targetURL, applies it
+// to a copy of the target in the scratch database and compares the result
+// against the source again.
+func verifyPlan(ctx context.Context, cmd *cli.Command, driverName string, sourceURL string, targetURL string, scratchURL string) (*verification, error) {
+	driver, err := openNamedDriver(ctx, cmd, driverName, sourceURL, targetURL)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+
+	target, err := driver.Introspect(ctx, drivers.TargetSide)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect target database: %w", err)
+	}
+
+	result := &verification{}
+	for statement, err := range driver.Statements(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff databases: %w", err)
+		}
+		result.Plan = append(result.Plan, statement)
+	}
+
+	scratchURL, cleanup, err := scratchDatabase(ctx, cmd, driverName, scratchURL)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	err = applyToScratch(ctx, cmd, driverName, scratchURL, target, result.Plan)
+	if err != nil {
+		return nil, err
+	}
+
+	// The plan applied to a copy of the target must leave nothing to migrate
+	rediff, err := openNamedDriver(ctx, cmd, driverName, sourceURL, scratchURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rediff.Close()
+
+	for statement, err := range rediff.Statements(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff databases: %w", err)
+		}
+		result.Remaining = append(result.Remaining, statement)
+	}
+
+	return result, nil
+}
+
+// applyToScratch recreates target in the scratch database, then applies plan
+// on top of it.
+func applyToScratch(ctx context.Context, cmd *cli.Command, driverName string, scratchURL string, target *schema.Schema, plan []string) error {
+	scratch, err := openScratchDriver(ctx, cmd, driverName, scratchURL)
+	if err != nil {
+		return err
+	}
+	defer scratch.Close()
+
+	err = execStatements(ctx, scratch, creationStatements(scratch, target))
+	if err != nil {
+		return fmt.Errorf("failed to copy target schema: %w", err)
+	}
+
+	err = execStatements(ctx, scratch, func(yield func(string, error) bool) {
+		for _, statement := range plan {
+			if !yield(statement, nil) {
+				return
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply plan: %w", err)
+	}
+
+	return nil
+}
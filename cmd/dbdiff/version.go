@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/urfave/cli/v3"
+)
+
+// Build metadata, set by release builds with
+// -ldflags "-X main.version=... -X main.commit=... -X main.date=...".
+// Builds without them fall back to what the Go toolchain recorded.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+func versionCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "version",
+		Usage: "Print the version, build metadata and compiled-in drivers",
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			printVersion(cmd.Root().Writer)
+			return nil
+		},
+	}
+}
+
+func printVersion(w io.Writer) {
+	commit, date := buildCommit()
+
+	fmt.Fprintf(w, "dbdiff %s\n", buildVersion())
+	fmt.Fprintf(w, "commit: %s\n", commit)
+	fmt.Fprintf(w, "built: %s\n", date)
+	fmt.Fprintf(w, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(w, "drivers: %s\n", strings.Join(drivers.Names(), ", "))
+}
+
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+
+	// Set when installed with go install ...@version
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+
+	return "(devel)"
+}
+
+func buildCommit() (string, string) {
+	commit, date := commit, date
+
+	if info, ok := debug.ReadBuildInfo(); ok && commit == "" {
+		settings := make(map[string]string)
+		for _, setting := range info.Settings {
+			settings[setting.Key] = setting.Value
+		}
+
+		commit = settings["vcs.revision"]
+		if commit != "" && settings["vcs.modified"] == "true" {
+			commit += "-dirty"
+		}
+		if date == "" {
+			date = settings["vcs.time"]
+		}
+	}
+
+	if commit == "" {
+		commit = "unknown"
+	}
+	if date == "" {
+		date = "unknown"
+	}
+
+	return commit, date
+}
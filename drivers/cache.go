@@ -0,0 +1,136 @@
+package drivers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// schemaCacheKind keys cached schemas. Bump it whenever the JSON encoding of
+// schema.Schema, or what introspection fills it with, changes so stale
+// entries are not decoded into the new one.
+const schemaCacheKind = "schema/v4"
+
+// tablesCacheKind keys cached schemas restricted to some tables, which must
+// not be mistaken for whole ones.
+func tablesCacheKind(tables []string) string {
+	if len(tables) == 0 {
+		return schemaCacheKind
+	}
+	return schemaCacheKind + "/tables=" + strings.Join(tables, ",")
+}
+
+// IntrospectionCache keeps introspection results keyed by a cheap schema
+// fingerprint, so databases whose schema did not change are not read again.
+// Entries are held in memory and, when Dir is set, persisted there across runs.
+type IntrospectionCache struct {
+	Dir string
+
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func NewIntrospectionCache(dir string) *IntrospectionCache {
+	return &IntrospectionCache{
+		Dir:     dir,
+		entries: make(map[string][]byte),
+	}
+}
+
+func (c *IntrospectionCache) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(hash[:])+".json")
+}
+
+// Get decodes the entry stored under key into value and reports whether it
+// was found.
+func (c *IntrospectionCache) Get(key string, value any) (bool, error) {
+	c.mu.Lock()
+	data, found := c.entries[key]
+	c.mu.Unlock()
+
+	if !found && c.Dir != "" {
+		var err error
+
+		data, err = os.ReadFile(c.path(key))
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		found = true
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	// Entries written by another version or corrupted are treated as misses
+	err := json.Unmarshal(data, value)
+	if err != nil {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (c *IntrospectionCache) Set(key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = data
+	c.mu.Unlock()
+
+	if c.Dir == "" {
+		return nil
+	}
+
+	err = os.MkdirAll(c.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.path(key), data, 0o644)
+}
+
+// cached returns the value stored for the fingerprint and kind, loading and
+// storing it on a miss. An empty fingerprint means the database cannot be
+// identified reliably and always bypasses the cache.
+func cached[T any](cache *IntrospectionCache, fingerprint string, kind string, load func() (T, error)) (T, error) {
+	if cache == nil || fingerprint == "" {
+		return load()
+	}
+
+	key := kind + "@" + fingerprint
+
+	var value T
+	found, err := cache.Get(key, &value)
+	if err != nil {
+		return value, err
+	}
+	if found {
+		return value, nil
+	}
+
+	value, err = load()
+	if err != nil {
+		return value, err
+	}
+
+	err = cache.Set(key, value)
+	if err != nil {
+		return value, err
+	}
+
+	return value, nil
+}
@@ -0,0 +1,26 @@
+package drivers
+
+import "context"
+
+// StepCheck is a sanity check run while a plan is applied, between two of
+// its statements. Query returns a single row and column, true when the check
+// passes.
+type StepCheck struct {
+	// Description tells what passing the check means, e.g. that a rebuilt
+	// table holds as many rows as the original one.
+	Description string
+	Query       string
+}
+
+// StepChecker is implemented by drivers generating sanity checks for the
+// risky steps of their plans, such as dropping a table once copied into its
+// rebuilt version, so that applying a plan stops before losing data.
+type StepChecker interface {
+	// StepChecks returns the checks to run before each statement, keyed by
+	// its index. Checks keyed by len(statements) run after the last one.
+	StepChecks(statements []string) map[int][]StepCheck
+
+	// RunStepCheck runs check against one of the databases, reporting
+	// whether it passed.
+	RunStepCheck(ctx context.Context, side Side, check StepCheck) (bool, error)
+}
@@ -0,0 +1,35 @@
+// Package drivers compares the schemas of two databases and generates the
+// statements migrating one to the other.
+//
+// A driver is created with the source and target databases, plus any
+// Option:
+//
+//	driver, err := drivers.NewSQLiteDriver(
+//		drivers.WithSourceDSN("desired.sqlite"),
+//		drivers.WithTargetDSN("current.sqlite"),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	defer driver.Close()
+//
+//	for statement, err := range driver.Statements(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(statement)
+//	}
+//
+// Statements chains the three steps of a diff, which can also be run
+// separately: Driver.Introspect reads either database into a
+// schema.Schema, schema.Compare diffs two schemas, and Renderer.Render turns
+// the diff into statements of the driver's dialect.
+//
+// Introspect reads a single database into a schema.Schema, for tools that
+// need the schema model alone.
+//
+// The Driver and Renderer interfaces, the Option functions and the schema
+// package follow semantic versioning. Exported driver methods reading a
+// single kind of object (GetColumns, GetIndexes, ...) are building
+// blocks of Introspect and may change between minor versions.
+package drivers
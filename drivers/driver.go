@@ -1,8 +1,321 @@
 package drivers
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
 
+	"github.com/quantumsheep/dbdiff/schema"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultConcurrency is the number of introspection queries a driver runs in
+// parallel against a single database when no concurrency is configured.
+const DefaultConcurrency = 8
+
+// Renderer turns a schema diff into the statements of a given dialect.
+type Renderer interface {
+	Render(diff *schema.Diff) iter.Seq2[string, error]
+}
+
+// Driver introspects a pair of databases and renders the statements
+// migrating the target to the source. Introspect, schema.Compare and Render
+// can also be called separately, e.g. to compare a single introspection
+// against multiple targets.
 type Driver interface {
+	Renderer
+
 	Close() error
+
+	// Introspect reads the schema of one of the databases.
+	Introspect(ctx context.Context, side Side) (*schema.Schema, error)
+
+	// Diff returns the whole migration script at once.
 	Diff(ctx context.Context) (string, error)
+
+	// Statements yields each statement of the migration script as soon as it
+	// is generated, so large plans never have to be held in memory.
+	Statements(ctx context.Context) iter.Seq2[string, error]
+}
+
+// Executor is implemented by drivers able to run statements against one of
+// their databases, e.g. to replay migrations or apply a plan to a scratch
+// database. Drivers opened read-only reject statements that write.
+type Executor interface {
+	Exec(ctx context.Context, side Side, statement string) error
+}
+
+// RowsExecutor is implemented by executors able to tell how many rows each
+// statement affected, e.g. to record applied statements in an audit log.
+// Rows is -1 when the database does not report it.
+type RowsExecutor interface {
+	ExecRows(ctx context.Context, side Side, statement string) (rows int64, err error)
+}
+
+// Wrapper is implemented by drivers wrapping another one, e.g. to close
+// resources along with it, see As.
+type Wrapper interface {
+	Unwrap() Driver
+}
+
+// As returns the first driver of the chain unwrapped from driver that
+// implements T, like errors.As, so that wrappers do not hide the optional
+// interfaces of the driver they wrap, such as Executor or ObjectPlanner.
+func As[T any](driver Driver) (T, bool) {
+	for driver != nil {
+		if implementation, ok := driver.(T); ok {
+			return implementation, true
+		}
+
+		wrapper, ok := driver.(Wrapper)
+		if !ok {
+			break
+		}
+		driver = wrapper.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// planOptions are the driver settings shaping its plans.
+type planOptions struct {
+	progress *progressReporter
+
+	// check, when set, rejects diffs before they get rendered.
+	check DiffCheckFunc
+
+	// deferDestructive renders the statements removing objects last, along
+	// with the ones adding NOT NULL constraints with deferNotNull.
+	deferDestructive bool
+	deferNotNull     bool
+
+	// classify tells the impact of statements on the target, see
+	// ImpactEstimator. With annotate, statements going through or locking
+	// tables are annotated with it, and lockWarnings flags the ones blocking
+	// large or hot tables.
+	classify     impactClassifier
+	annotate     bool
+	lockWarnings lockWarnings
+
+	// maxRewriteRows, when positive, rejects plans rewriting tables
+	// estimated to hold more rows, see WithMaxRewriteRows.
+	maxRewriteRows int64
+
+	// tableMappings rename source tables before comparing them, see
+	// schema.Schema.MapTableNames.
+	tableMappings []schema.NameMapping
+
+	// templateVars turn names into placeholders, see TemplateVar.
+	templateVars []TemplateVar
+
+	// redactNames replaces object names with pseudonyms keyed by
+	// redactKey, see WithRedactedNames.
+	redactNames bool
+	redactKey   string
+
+	// trailer, when set, returns the object ending plans that change
+	// anything, such as a bump of SQLite's user_version.
+	trailer func(diff *schema.Diff) (*ObjectStatements, error)
+
+	compare []schema.CompareOption
+}
+
+// planObjects introspects both databases concurrently, compares them and
+// renders the resulting diff with renderer, unless rejected by the check.
+func planObjects(ctx context.Context, driver Driver, renderer ObjectRenderer, opts planOptions) iter.Seq2[*ObjectStatements, error] {
+	return func(yield func(*ObjectStatements, error) bool) {
+		var source, target *schema.Schema
+
+		g, gctx := errgroup.WithContext(ctx)
+		g.Go(func() (err error) {
+			source, err = driver.Introspect(gctx, SourceSide)
+			return err
+		})
+		g.Go(func() (err error) {
+			target, err = driver.Introspect(gctx, TargetSide)
+			return err
+		})
+
+		err := g.Wait()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		opts.progress.report(ComparisonPhase, "", 0, len(source.Tables))
+		diff := schema.Compare(source.MapTableNames(opts.tableMappings...), target, opts.compare...)
+		opts.progress.report(ComparisonPhase, "", len(source.Tables), len(source.Tables))
+
+		if opts.check != nil {
+			err := opts.check(diff)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+
+		var counts map[string]int64
+		if estimator, ok := driver.(RowEstimator); ok && opts.classify != nil && (opts.annotate || opts.maxRewriteRows > 0) {
+			counts, err = estimator.EstimateRows(ctx, TargetSide)
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to estimate row counts: %w", err))
+				return
+			}
+		}
+
+		// Removals are deferred by first migrating to the expanded schema,
+		// holding the objects of both sides, then to the source one
+		phases := []*schema.Diff{diff}
+		if opts.deferDestructive {
+			expand, contract := diff.Phases(opts.deferNotNull, opts.compare...)
+			phases = []*schema.Diff{expand, contract}
+		}
+
+		// Redacted plans are rendered from a diff naming objects by their
+		// pseudonyms, the actual names never making it into statements
+		if opts.redactNames {
+			redactor := newNameRedactor(opts.redactKey, diff.Source, diff.Target)
+			for i, phase := range phases {
+				phases[i] = redactor.diff(phase)
+			}
+			counts = redactor.counts(counts)
+			opts.lockWarnings.hot = redactor.names(opts.lockWarnings.hot)
+		}
+
+		objects := renderPhases(ctx, renderer, phases)
+		if opts.classify != nil && opts.maxRewriteRows > 0 {
+			objects = checkedObjects(objects, rewriteLimit(opts.classify, counts, opts.maxRewriteRows))
+		}
+
+		changed := false
+		for object, err := range objects {
+			if object != nil && object.Type != SettingsObject {
+				changed = true
+			}
+			if object != nil && opts.classify != nil && opts.annotate {
+				object.Statements = annotateImpact(opts.classify, counts, opts.lockWarnings, object.Statements)
+			}
+			if object != nil && len(opts.templateVars) > 0 {
+				object.Statements = templatize(object.Statements, opts.templateVars)
+			}
+
+			if !yield(object, err) || err != nil {
+				return
+			}
+		}
+
+		// The trailer ends the plan, with the contract phase when there is one
+		if changed && opts.trailer != nil {
+			object, err := opts.trailer(diff)
+			if object != nil {
+				object.Contract = len(phases) > 1
+			}
+			yield(object, err)
+		}
+	}
+}
+
+// renderPhases renders each diff in turn, the objects of all but the first
+// one being part of the contract phase.
+func renderPhases(ctx context.Context, renderer ObjectRenderer, phases []*schema.Diff) iter.Seq2[*ObjectStatements, error] {
+	return func(yield func(*ObjectStatements, error) bool) {
+		for i, phase := range phases {
+			contract := i > 0
+			for object, err := range renderer.RenderObjects(phase) {
+				// Consumers may apply statements as they come, give them a
+				// chance to stop between each object
+				if err == nil {
+					err = ctx.Err()
+				}
+				if object != nil {
+					object.Contract = contract
+				}
+
+				if !yield(object, err) || err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// checkedObjects renders every object before yielding the first one, so that
+// a plan failing check on any object is never partially applied.
+func checkedObjects(objects iter.Seq2[*ObjectStatements, error], check func(*ObjectStatements) error) iter.Seq2[*ObjectStatements, error] {
+	return func(yield func(*ObjectStatements, error) bool) {
+		var checked []*ObjectStatements
+		for object, err := range objects {
+			if err == nil {
+				err = check(object)
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			checked = append(checked, object)
+		}
+
+		for _, object := range checked {
+			if !yield(object, nil) {
+				return
+			}
+		}
+	}
+}
+
+// planStatements is planObjects yielding statements one by one.
+func planStatements(ctx context.Context, driver Driver, renderer ObjectRenderer, opts planOptions) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for statement, err := range flattenObjects(planObjects(ctx, driver, renderer, opts)) {
+			// Consumers may apply statements as they come, give them a
+			// chance to stop between each of them
+			if err == nil {
+				err = ctx.Err()
+			}
+
+			if !yield(statement, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// annotate prefixes the first of statements with a comment explaining why
+// they were generated, when enabled.
+func annotate(enabled bool, statements []string, reasons ...string) []string {
+	if !enabled || len(statements) == 0 || len(reasons) == 0 {
+		return statements
+	}
+
+	annotated := slices.Clone(statements)
+	annotated[0] = "-- " + strings.Join(reasons, "; ") + "\n" + annotated[0]
+	return annotated
+}
+
+// createTriggers returns the statements creating triggers, whose
+// definitions are whole CREATE TRIGGER statements.
+func createTriggers(triggers []*schema.Trigger) []string {
+	statements := make([]string, len(triggers))
+	for i, trigger := range triggers {
+		statements[i] = trigger.Def + ";"
+	}
+	return statements
+}
+
+func collectStatements(statements iter.Seq2[string, error]) (string, error) {
+	var diff strings.Builder
+
+	for statement, err := range statements {
+		if err != nil {
+			return "", err
+		}
+
+		diff.WriteString(statement)
+		diff.WriteString("\n")
+	}
+
+	return strings.TrimSpace(diff.String()), nil
 }
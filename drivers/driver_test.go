@@ -0,0 +1,31 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// wrappedDriver hides the optional interfaces of the driver it embeds.
+type wrappedDriver struct {
+	Driver
+}
+
+func (d *wrappedDriver) Unwrap() Driver {
+	return d.Driver
+}
+
+func TestAs(t *testing.T) {
+	driver := NewTestSQLiteDriver(t)
+	wrapped := &wrappedDriver{Driver: &wrappedDriver{Driver: driver}}
+
+	_, ok := Driver(wrapped).(ObjectPlanner)
+	require.False(t, ok)
+
+	planner, ok := As[ObjectPlanner](wrapped)
+	require.True(t, ok)
+	require.Same(t, driver, planner)
+
+	_, ok = As[ObjectPlanner](&wrappedDriver{})
+	require.False(t, ok)
+}
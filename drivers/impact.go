@@ -0,0 +1,254 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// StatementImpact estimates how heavy running a plan statement against the
+// target database is, so that long migrations can be scheduled accordingly.
+type StatementImpact struct {
+	// Table is the existing table the statement goes through or locks.
+	Table string `json:"table"`
+
+	// Rows estimates how many rows Table holds, -1 when unknown.
+	Rows int64 `json:"rows"`
+
+	// Rewrite reports statements rewriting every row of Table, and Scan
+	// the ones reading every row, e.g. to validate a constraint or build
+	// an index.
+	Rewrite bool `json:"rewrite"`
+	Scan    bool `json:"scan"`
+
+	// Lock is the lock the statement takes on Table, such as ACCESS
+	// EXCLUSIVE, for dialects locking tables. Postgres only.
+	Lock string `json:"lock,omitempty"`
+
+	// Warning is set on statements blocking every query on large or hot
+	// tables while they run, see WithLockWarnings.
+	Warning string `json:"warning,omitempty"`
+}
+
+// String describes the impact, such as "rewrites users under ACCESS
+// EXCLUSIVE lock, about 1200 rows".
+func (i *StatementImpact) String() string {
+	var description string
+	switch {
+	case i.Rewrite:
+		description = "rewrites " + i.Table
+	case i.Scan:
+		description = "scans " + i.Table
+	default:
+		description = fmt.Sprintf("takes %s lock on %s", i.Lock, i.Table)
+	}
+	if (i.Rewrite || i.Scan) && i.Lock != "" {
+		description += fmt.Sprintf(" under %s lock", i.Lock)
+	}
+
+	if i.Rows < 0 {
+		return description + ", row count unknown"
+	}
+	return fmt.Sprintf("%s, about %d rows", description, i.Rows)
+}
+
+// AccessExclusiveLock is the Postgres lock blocking every query on a table,
+// reads included.
+const AccessExclusiveLock = "ACCESS EXCLUSIVE"
+
+// DefaultLargeTableRows is the number of rows from which tables are large
+// enough for ACCESS EXCLUSIVE locks on them to get a warning.
+const DefaultLargeTableRows = 1_000_000
+
+// lockWarnings selects the tables on which ACCESS EXCLUSIVE locks get a
+// warning: the ones holding at least largeRows rows, and the hot ones.
+type lockWarnings struct {
+	largeRows int64
+	hot       []string
+}
+
+// warn returns the warning about impact, if any.
+func (w lockWarnings) warn(impact *StatementImpact) string {
+	if impact.Lock != AccessExclusiveLock {
+		return ""
+	}
+
+	switch {
+	case slices.Contains(w.hot, impact.Table):
+		return fmt.Sprintf("ACCESS EXCLUSIVE lock blocks every query on hot table %s until the statement completes", impact.Table)
+	case w.largeRows > 0 && impact.Rows >= w.largeRows:
+		return fmt.Sprintf("ACCESS EXCLUSIVE lock blocks every query on large table %s until the statement completes", impact.Table)
+	}
+	return ""
+}
+
+// RewriteLimitError rejects plans rewriting a table estimated to hold more
+// rows than allowed, see WithMaxRewriteRows.
+type RewriteLimitError struct {
+	Table string
+	Rows  int64
+	Limit int64
+}
+
+func (e *RewriteLimitError) Error() string {
+	return fmt.Sprintf("plan rewrites table %s, about %d rows, over the limit of %d rows", e.Table, e.Rows, e.Limit)
+}
+
+// rewriteLimit returns a check rejecting objects whose statements rewrite
+// tables holding more than limit rows according to counts. Tables with an
+// unknown row count pass.
+func rewriteLimit(classify impactClassifier, counts map[string]int64, limit int64) func(*ObjectStatements) error {
+	return func(object *ObjectStatements) error {
+		for _, impact := range classifyImpact(classify, counts, lockWarnings{}, object.Statements) {
+			if impact != nil && impact.Rewrite && impact.Rows > limit {
+				return &RewriteLimitError{Table: impact.Table, Rows: impact.Rows, Limit: limit}
+			}
+		}
+		return nil
+	}
+}
+
+// RowEstimator is implemented by drivers able to estimate how many rows the
+// tables of their databases hold, from statistics when available.
+type RowEstimator interface {
+	EstimateRows(ctx context.Context, side Side) (map[string]int64, error)
+}
+
+// ImpactEstimator is implemented by drivers able to tell which statements of
+// a plan go through or lock existing tables of the target database.
+type ImpactEstimator interface {
+	// EstimateImpact returns the impact of each statement, nil for the ones
+	// not going through nor locking existing tables.
+	EstimateImpact(ctx context.Context, statements []string) ([]*StatementImpact, error)
+}
+
+// impactClassifier tells which table each statement goes through in whole
+// or locks, if any, and how. Row counts are left for the caller. Statements
+// are classified together as their impact may depend on the statements
+// preceding them.
+type impactClassifier func(statements []string) []*StatementImpact
+
+// estimateImpact classifies statements and fills in the row counts of the
+// target tables they go through.
+func estimateImpact(ctx context.Context, rows RowEstimator, classify impactClassifier, warnings lockWarnings, statements []string) ([]*StatementImpact, error) {
+	counts, err := rows.EstimateRows(ctx, TargetSide)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate row counts: %w", err)
+	}
+	return classifyImpact(classify, counts, warnings, statements), nil
+}
+
+// classifyImpact classifies statements, estimating the rows of their tables
+// from counts. Tables missing from counts are created by the plan and hold
+// no rows, statements going through them have no impact. A nil counts
+// leaves every row count unknown.
+func classifyImpact(classify impactClassifier, counts map[string]int64, warnings lockWarnings, statements []string) []*StatementImpact {
+	stripped := make([]string, len(statements))
+	for i, statement := range statements {
+		stripped[i] = leadingComments.ReplaceAllString(statement, "")
+	}
+
+	impacts := classify(stripped)
+	for i, impact := range impacts {
+		if impact == nil {
+			continue
+		}
+
+		impact.Rows = -1
+		if counts != nil {
+			rows, found := counts[impact.Table]
+			if !found {
+				impacts[i] = nil
+				continue
+			}
+			impact.Rows = rows
+		}
+		impact.Warning = warnings.warn(impact)
+	}
+	return impacts
+}
+
+// annotateImpact prefixes statements with a comment describing their
+// impact, estimated from counts, followed by their warning if any.
+func annotateImpact(classify impactClassifier, counts map[string]int64, warnings lockWarnings, statements []string) []string {
+	impacts := classifyImpact(classify, counts, warnings, statements)
+	if !slices.ContainsFunc(impacts, func(impact *StatementImpact) bool { return impact != nil }) {
+		return statements
+	}
+
+	annotated := slices.Clone(statements)
+	for i, impact := range impacts {
+		if impact == nil {
+			continue
+		}
+
+		comment := "-- " + impact.String() + "\n"
+		if impact.Warning != "" {
+			comment += "-- WARNING: " + impact.Warning + "\n"
+		}
+
+		// The impact goes right above the statement, after the comment
+		// explaining why it was generated
+		statement := annotated[i]
+		end := strings.LastIndex(leadingComments.FindString(statement), "\n") + 1
+		annotated[i] = statement[:end] + comment + statement[end:]
+	}
+	return annotated
+}
+
+// leadingComments matches the comments annotating a statement.
+var leadingComments = regexp.MustCompile(`^(?:\s*--[^\n]*\n)*\s*`)
+
+// statementIdentifier matches a possibly quoted and schema-qualified name in
+// a statement.
+const statementIdentifier = `((?:"(?:[^"]|"")*"|[^\s"(;,.]+)(?:\.(?:"(?:[^"]|"")*"|[^\s"(;,.]+))?)`
+
+// unqualifiedName unquotes a name matched by statementIdentifier and strips
+// its schema, if any.
+func unqualifiedName(name string) string {
+	parts := namePart.FindAllString(name, -1)
+	last := parts[len(parts)-1]
+	if strings.HasPrefix(last, `"`) {
+		return strings.ReplaceAll(last[1:len(last)-1], `""`, `"`)
+	}
+	return last
+}
+
+var namePart = regexp.MustCompile(`"(?:[^"]|"")*"|[^."]+`)
+
+// impactPattern tells which table statements matching pattern go through or
+// lock, the first submatch of pattern, and how.
+type impactPattern struct {
+	pattern *regexp.Regexp
+	rewrite bool
+	scan    bool
+	lock    string
+}
+
+// classifyByPatterns returns a classifier matching statements against
+// patterns in order.
+func classifyByPatterns(patterns []impactPattern) impactClassifier {
+	return func(statements []string) []*StatementImpact {
+		impacts := make([]*StatementImpact, len(statements))
+		for i, statement := range statements {
+			for _, p := range patterns {
+				match := p.pattern.FindStringSubmatch(statement)
+				if match != nil {
+					impacts[i] = &StatementImpact{Table: unqualifiedName(match[1]), Rewrite: p.rewrite, Scan: p.scan, Lock: p.lock}
+					break
+				}
+			}
+		}
+		return impacts
+	}
+}
+
+// updatePattern matches UPDATE statements, such as the ones backfilling
+// columns, which rewrite the rows they match.
+var updatePattern = regexp.MustCompile(`(?is)^UPDATE\s+(?:ONLY\s+)?` + statementIdentifier)
+
+// createIndexImpactPattern matches index creations, which read the whole table
+// they index.
+var createIndexImpactPattern = regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?.*?\sON\s+(?:ONLY\s+)?` + statementIdentifier)
@@ -0,0 +1,228 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"iter"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// ObjectStatements are consecutive statements of a plan migrating a single
+// object. An object may come up more than once in a plan, e.g. views dropped
+// before rebuilding the tables they query and created again afterwards.
+type ObjectStatements struct {
+	Type       schema.ObjectType `json:"type"`
+	Name       string            `json:"name"`
+	Statements []string          `json:"statements"`
+
+	// Class tells how the statements change the target. It is empty for
+	// statements configuring the session, see SettingsObject.
+	Class StatementClass `json:"class,omitempty"`
+
+	// Contract marks statements deferred to the end of the plan because
+	// they remove objects or add NOT NULL constraints, see
+	// WithDeferredDestructive and WithExpandContract.
+	Contract bool `json:"contract,omitempty"`
+}
+
+// StatementClass tells how statements change the target database, e.g. for
+// tools embedding dbdiff to only apply some plans unattended.
+type StatementClass string
+
+const (
+	// AdditiveClass statements create objects or alter them in place,
+	// keeping every row and definition of the target.
+	AdditiveClass StatementClass = "additive"
+
+	// RebuildClass statements recreate existing objects: tables copied
+	// into a new one, or indexes, constraints, triggers and views dropped
+	// and created again.
+	RebuildClass StatementClass = "rebuild"
+
+	// DestructiveClass statements drop objects or columns of the target,
+	// along with their data.
+	DestructiveClass StatementClass = "destructive"
+)
+
+// tableClass classifies the statements migrating a table diff, rebuild
+// telling whether the renderer recreates the table.
+func tableClass(diff *schema.TableDiff, rebuild bool) StatementClass {
+	switch {
+	case diff.IsDestructive():
+		return DestructiveClass
+	case rebuild || recreates(diff.Constraints) || recreates(diff.Indexes) || recreates(diff.Triggers):
+		return RebuildClass
+	}
+	return AdditiveClass
+}
+
+// changeClass classifies the statements migrating an object dropped and
+// created again when modified, such as a view.
+func changeClass[T any](change *schema.Change[T]) StatementClass {
+	switch change.Kind {
+	case schema.Removed:
+		return DestructiveClass
+	case schema.Modified:
+		return RebuildClass
+	}
+	return AdditiveClass
+}
+
+func recreates[T any](changes []*schema.Change[T]) bool {
+	for _, change := range changes {
+		if change.Kind == schema.Modified {
+			return true
+		}
+	}
+	return false
+}
+
+// Plan is a whole plan, grouped by object, see CollectPlan.
+type Plan []*ObjectStatements
+
+// CollectPlan reads the whole plan of planner.
+func CollectPlan(ctx context.Context, planner ObjectPlanner) (Plan, error) {
+	var plan Plan
+	for object, err := range planner.ObjectStatements(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, object)
+	}
+	return plan, nil
+}
+
+// Has reports whether any statement of the plan is of class.
+func (p Plan) Has(class StatementClass) bool {
+	for _, object := range p {
+		if object.Class == class {
+			return true
+		}
+	}
+	return false
+}
+
+// HasDestructive reports whether the plan drops objects or columns.
+func (p Plan) HasDestructive() bool {
+	return p.Has(DestructiveClass)
+}
+
+// HasRebuild reports whether the plan recreates existing objects.
+func (p Plan) HasRebuild() bool {
+	return p.Has(RebuildClass)
+}
+
+// Statements returns the statements of the plan, in order.
+func (p Plan) Statements() []string {
+	var statements []string
+	for _, object := range p {
+		statements = append(statements, object.Statements...)
+	}
+	return statements
+}
+
+// SettingsObject groups statements configuring the session the plan runs
+// in, rather than migrating a schema object.
+const SettingsObject schema.ObjectType = "settings"
+
+// PragmaObject groups the statements changing a setting persisted in the
+// database file, see WithPragmas.
+const PragmaObject schema.ObjectType = "pragma"
+
+// ObjectRenderer is implemented by renderers able to tell which object each
+// statement migrates, e.g. to write them to separate files.
+type ObjectRenderer interface {
+	RenderObjects(diff *schema.Diff) iter.Seq2[*ObjectStatements, error]
+}
+
+// ObjectPlanner is implemented by drivers able to group the statements of
+// their plan by object, see ObjectRenderer.
+type ObjectPlanner interface {
+	ObjectStatements(ctx context.Context) iter.Seq2[*ObjectStatements, error]
+}
+
+// ObjectEmitFunc receives the statements migrating an object, in plan order.
+type ObjectEmitFunc func(objectType schema.ObjectType, name string, class StatementClass, statements ...string) error
+
+var errStopEmitting = errors.New("statement consumer stopped")
+
+// emitObjects turns a function pushing statements into an iterator,
+// stopping the producer as soon as the consumer breaks out of the loop.
+// Objects without statements are skipped.
+func emitObjects(produce func(emit ObjectEmitFunc) error) iter.Seq2[*ObjectStatements, error] {
+	return func(yield func(*ObjectStatements, error) bool) {
+		err := produce(func(objectType schema.ObjectType, name string, class StatementClass, statements ...string) error {
+			if len(statements) == 0 {
+				return nil
+			}
+			if !yield(&ObjectStatements{Type: objectType, Name: name, Class: class, Statements: statements}, nil) {
+				return errStopEmitting
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopEmitting) {
+			yield(nil, err)
+		}
+	}
+}
+
+// flattenObjects yields the statements of objects one by one.
+func flattenObjects(objects iter.Seq2[*ObjectStatements, error]) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		for object, err := range objects {
+			if err != nil {
+				yield("", err)
+				return
+			}
+
+			for _, statement := range object.Statements {
+				if !yield(statement, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// splitTableDiff splits a table diff only changing indexes and triggers into
+// a diff per index and trigger, which migrate independently of each other.
+// Other diffs are returned whole, as the migration of their table.
+func splitTableDiff(diff *schema.TableDiff) []*objectDiff {
+	whole := []*objectDiff{{Type: schema.TableObject, Name: diff.Name(), TableDiff: diff}}
+	if diff.Kind != schema.Modified || !diff.Columns.IsEmpty() || diff.ForeignKeysChanged || len(diff.Constraints) > 0 {
+		return whole
+	}
+
+	var parts []*objectDiff
+	for _, change := range diff.Indexes {
+		part := *diff
+		part.Indexes, part.Triggers = []*schema.Change[*schema.Index]{change}, nil
+		parts = append(parts, &objectDiff{Type: schema.IndexObject, Name: changeName(change, func(i *schema.Index) string { return i.Name }), TableDiff: &part})
+	}
+	for _, change := range diff.Triggers {
+		part := *diff
+		part.Indexes, part.Triggers = nil, []*schema.Change[*schema.Trigger]{change}
+		parts = append(parts, &objectDiff{Type: schema.TriggerObject, Name: changeName(change, func(t *schema.Trigger) string { return t.Name }), TableDiff: &part})
+	}
+
+	return parts
+}
+
+// objectDiff is the part of a table diff migrating a single object.
+type objectDiff struct {
+	Type      schema.ObjectType
+	Name      string
+	TableDiff *schema.TableDiff
+}
+
+func viewName(v *schema.View) string {
+	return v.Name
+}
+
+func changeName[T any](change *schema.Change[T], name func(T) string) string {
+	if change.Kind == schema.Removed {
+		return name(change.Target)
+	}
+	return name(change.Source)
+}
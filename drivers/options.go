@@ -0,0 +1,560 @@
+package drivers
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// DriverConfig holds the settings shared by every driver. Build it through
+// Option values rather than directly so new settings can be added without
+// breaking callers.
+type DriverConfig struct {
+	// SourceDSN and TargetDSN locate the databases being compared: a file
+	// path for SQLite, a connection string for Postgres.
+	SourceDSN string
+	TargetDSN string
+
+	// Logger receives diagnostics from the driver. Defaults to discarding
+	// everything.
+	Logger *slog.Logger
+
+	// Introspection selects how schemas are read, CatalogIntrospection by
+	// default. Postgres only.
+	Introspection IntrospectionMode
+
+	// SchemaFilter restricts introspection to a single schema. Defaults to
+	// the connection's current schema. SQLite only has the main schema and
+	// ignores it.
+	SchemaFilter string
+
+	// SourceSchema, when set, is introspected on the source side instead of
+	// SchemaFilter, so that a schema compares against a differently named
+	// one, e.g. app_v2 against app. Postgres only.
+	SourceSchema string
+
+	// Ignore hides the objects it matches from introspection results and
+	// therefore from diffs.
+	Ignore schema.IgnoreRules
+
+	// IgnoredColumns leave the differences of some columns out of diffs,
+	// see schema.WithIgnoredColumns.
+	IgnoredColumns schema.IgnoredColumns
+
+	// TableMappings compare source tables under another name, see
+	// schema.Schema.MapTableNames.
+	TableMappings []schema.NameMapping
+
+	// TypeEquivalences declare column types that compare equal despite being
+	// spelled differently.
+	TypeEquivalences []schema.TypeEquivalence
+
+	// StrictTypes compares column types exactly as the databases report
+	// them. By default, types only differing by case, spacing or aliases
+	// such as INT and INTEGER compare equal, see schema.NormalizeType.
+	StrictTypes bool
+
+	// StrictDefinitions compares view and trigger definitions verbatim. By
+	// default, SQLite definitions only differing by comments, whitespace or
+	// quoting compare equal.
+	StrictDefinitions bool
+
+	// CaseInsensitiveNames matches objects whose names only differ by case
+	// as the same object, see schema.WithCaseInsensitiveNames.
+	CaseInsensitiveNames bool
+
+	// Concurrency limits how many introspection queries run in parallel
+	// against each database. Defaults to DefaultConcurrency.
+	Concurrency int
+
+	// QueryTimeout bounds every introspection query, so that a slow
+	// database fails the diff instead of stalling it. Zero disables it.
+	QueryTimeout time.Duration
+
+	// Cache, when set, skips introspecting databases whose schema did not
+	// change since they were last read.
+	Cache *IntrospectionCache
+
+	// Progress, when set, is called as tables get introspected and compared.
+	Progress ProgressFunc
+
+	// MaxOpenConns caps the number of connections opened to each database.
+	// Defaults to Concurrency.
+	MaxOpenConns int
+
+	// MaxIdleConns is the number of connections kept open between queries.
+	// Defaults to MaxOpenConns so introspection bursts reuse connections.
+	// Ignored with UsePgxPool, which keeps idle connections on its own.
+	MaxIdleConns int
+
+	// ConnectTimeout bounds how long establishing a connection may take.
+	// Zero keeps the connect_timeout from the connection string, if any.
+	// Postgres only.
+	ConnectTimeout time.Duration
+
+	// SourceTLS and TargetTLS secure the connections to each database.
+	// Postgres only.
+	SourceTLS TLSConfig
+	TargetTLS TLSConfig
+
+	// SourceDialFunc and TargetDialFunc, when set, open the network
+	// connections to each database, e.g. through an SSH tunnel. Host names
+	// are then resolved by the dialer rather than locally. Postgres only.
+	SourceDialFunc DialFunc
+	TargetDialFunc DialFunc
+
+	// SourcePasswordFunc and TargetPasswordFunc, when set, provide the
+	// password of each new connection to each database, such as short-lived
+	// access tokens. Postgres only.
+	SourcePasswordFunc PasswordFunc
+	TargetPasswordFunc PasswordFunc
+
+	// SQLiteDriverName is the database/sql driver opening SQLite databases.
+	// Defaults to "sqlite3" from go-sqlite3 on cgo builds, and to "sqlite"
+	// from the pure Go modernc.org/sqlite otherwise. SQLite only.
+	SQLiteDriverName string
+
+	// SQLiteKey unlocks encrypted SQLite databases, on builds linking
+	// SQLCipher. A _key parameter in a data source name overrides it for
+	// that database. SQLite only.
+	SQLiteKey string
+
+	// UserVersion, when set, ends SQLite plans changing anything with a
+	// PRAGMA user_version statement: AutoUserVersion increments the
+	// target's user_version, integers are set as is. SQLite only.
+	UserVersion string
+
+	// Pragmas also compares the settings persisted in SQLite database
+	// files, such as user_version, which some applications track their own
+	// migrations with. SQLite only.
+	Pragmas bool
+
+	// ReadOnly opens both databases so that they reject writes: SQLite files
+	// with mode=ro, Postgres sessions with default_transaction_read_only.
+	// Drivers only ever read, this guards against bugs and is recommended
+	// when pointing at production.
+	ReadOnly bool
+
+	// LockTimeout and StatementTimeout are set at the start of generated
+	// plans, bounding how long each migration statement may wait on locks
+	// or run. Zero leaves them out. Postgres only.
+	LockTimeout      time.Duration
+	StatementTimeout time.Duration
+
+	// Online migrates existing tables with statements avoiding long
+	// exclusive locks: indexes created and dropped concurrently, foreign
+	// key and check constraints validated separately, and NOT NULL
+	// constraints added after backfilling defaults. Such statements cannot
+	// run inside a transaction. Postgres only.
+	Online bool
+
+	// DeferValidation adds foreign key and check constraints to existing
+	// tables NOT VALID, and validates them at the end of the plan in
+	// statements of their own, so that the validation scanning each table
+	// can be scheduled separately. Postgres only.
+	DeferValidation bool
+
+	// BackfillBatchSize, when positive, adds NOT NULL columns to existing
+	// tables as with Online, backfilling their default in batches of this
+	// many rows, each committed on its own, so that no transaction locks
+	// every row of a large table. Postgres only.
+	BackfillBatchSize int
+
+	// AlignSequences follows statements making existing columns draw their
+	// default from a sequence with a setval call moving the sequence past
+	// the values already in the column, so that new rows do not collide
+	// with existing ones. Postgres only.
+	AlignSequences bool
+
+	// Annotate prefixes generated statements with a comment explaining why
+	// they were generated, such as the column whose type changed, and with
+	// their impact, see ImpactEstimator.
+	Annotate bool
+
+	// LargeTableRows and HotTables select the tables on which statements
+	// taking ACCESS EXCLUSIVE locks get a warning: the ones estimated to
+	// hold at least LargeTableRows rows, DefaultLargeTableRows by default,
+	// and the hot tables named. Postgres only.
+	LargeTableRows int64
+	HotTables      []string
+
+	// MaxRewriteRows, when positive, fails plans rewriting a table
+	// estimated to hold more rows, such as SQLite table rebuilds or Postgres
+	// column type changes, with a RewriteLimitError. Plans are then rendered
+	// whole before their first statement is yielded. Tables whose row count
+	// is unknown are not limited.
+	MaxRewriteRows int64
+
+	// Tables restricts introspection to the named tables, leaving views
+	// out, so that diffing a few tables does not read the whole database.
+	// Empty introspects everything.
+	Tables []string
+
+	// SkippedTypes leaves changes to these types of objects out of plans,
+	// see schema.WithSkippedTypes.
+	SkippedTypes []schema.ObjectType
+
+	// DeterministicOrder orders plans regardless of the order objects were
+	// created in, see schema.WithDeterministicOrder.
+	DeterministicOrder bool
+
+	// DeferDestructive renders the statements dropping objects after all
+	// the others, so that a plan applied halfway never leaves the target
+	// missing objects still in use. See schema.Diff.Expanded.
+	DeferDestructive bool
+
+	// ExpandContract splits plans into an expand phase, safe to apply before
+	// deploying the next version of the application, and a contract phase
+	// to apply afterwards: DeferDestructive, plus NOT NULL constraints only
+	// added once both versions stopped running.
+	ExpandContract bool
+
+	// Snapshots replace introspecting either database with a schema
+	// provided upfront. The DSN of a side with a snapshot is never used.
+	Snapshots Snapshots
+
+	// DiffCheck, when set, is called with every diff before it gets
+	// rendered. An error aborts the plan, e.g. when it makes disallowed
+	// changes.
+	DiffCheck DiffCheckFunc
+
+	// UsePgxPool backs both connections with a pgxpool.Pool instead of the
+	// database/sql connection pool. Postgres only.
+	UsePgxPool bool
+
+	// RedactNames replaces object names with pseudonyms in generated
+	// statements, see WithRedactedNames.
+	RedactNames bool
+	RedactKey   string
+
+	// TemplateVars turn names into placeholders in generated statements,
+	// see TemplateVar.
+	TemplateVars []TemplateVar
+}
+
+// DiffCheckFunc rejects a diff by returning an error.
+type DiffCheckFunc func(diff *schema.Diff) error
+
+// DialFunc opens a network connection to addr, like net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+// PasswordFunc returns the password to connect to a database with.
+type PasswordFunc func(ctx context.Context) (string, error)
+
+// Option configures a driver.
+type Option func(*DriverConfig)
+
+// NewDriverConfig applies opts on top of the defaults.
+func NewDriverConfig(opts ...Option) *DriverConfig {
+	config := &DriverConfig{
+		Concurrency: DefaultConcurrency,
+	}
+
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if !config.StrictTypes {
+		config.TypeEquivalences = append([]schema.TypeEquivalence{schema.NormalizedTypes}, config.TypeEquivalences...)
+	}
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.DiscardHandler)
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultConcurrency
+	}
+	if config.MaxOpenConns <= 0 {
+		config.MaxOpenConns = config.Concurrency
+	}
+	if config.MaxIdleConns <= 0 {
+		config.MaxIdleConns = config.MaxOpenConns
+	}
+	if config.LargeTableRows <= 0 {
+		config.LargeTableRows = DefaultLargeTableRows
+	}
+	if config.SQLiteDriverName == "" {
+		config.SQLiteDriverName = defaultSQLiteDriverName
+	}
+
+	return config
+}
+
+func WithSourceDSN(dsn string) Option {
+	return func(c *DriverConfig) { c.SourceDSN = dsn }
+}
+
+func WithTargetDSN(dsn string) Option {
+	return func(c *DriverConfig) { c.TargetDSN = dsn }
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *DriverConfig) { c.Logger = logger }
+}
+
+func WithSchemaFilter(schema string) Option {
+	return func(c *DriverConfig) { c.SchemaFilter = schema }
+}
+
+// WithSourceSchema introspects schema on the source side, the target side
+// keeping to SchemaFilter. References qualified with schema in the source
+// definitions are qualified with the target schema instead.
+func WithSourceSchema(schema string) Option {
+	return func(c *DriverConfig) { c.SourceSchema = schema }
+}
+
+func WithIntrospectionMode(mode IntrospectionMode) Option {
+	return func(c *DriverConfig) { c.Introspection = mode }
+}
+
+// WithIgnoreRules hides the objects matched by rules, in addition to the ones
+// already ignored.
+func WithIgnoreRules(rules ...schema.IgnoreRule) Option {
+	return func(c *DriverConfig) { c.Ignore = append(c.Ignore, rules...) }
+}
+
+// WithTypeEquivalence adds hooks declaring column types equivalent, see
+// schema.EquivalentTypes.
+func WithTypeEquivalence(equivalences ...schema.TypeEquivalence) Option {
+	return func(c *DriverConfig) { c.TypeEquivalences = append(c.TypeEquivalences, equivalences...) }
+}
+
+func WithStrictTypes(strict bool) Option {
+	return func(c *DriverConfig) { c.StrictTypes = strict }
+}
+
+func WithStrictDefinitions(strict bool) Option {
+	return func(c *DriverConfig) { c.StrictDefinitions = strict }
+}
+
+func WithCaseInsensitiveNames(enabled bool) Option {
+	return func(c *DriverConfig) { c.CaseInsensitiveNames = enabled }
+}
+
+func WithConcurrency(concurrency int) Option {
+	return func(c *DriverConfig) { c.Concurrency = concurrency }
+}
+
+func WithQueryTimeout(timeout time.Duration) Option {
+	return func(c *DriverConfig) { c.QueryTimeout = timeout }
+}
+
+func WithCache(cache *IntrospectionCache) Option {
+	return func(c *DriverConfig) { c.Cache = cache }
+}
+
+func WithProgress(progress ProgressFunc) Option {
+	return func(c *DriverConfig) { c.Progress = progress }
+}
+
+func WithMaxOpenConns(n int) Option {
+	return func(c *DriverConfig) { c.MaxOpenConns = n }
+}
+
+func WithMaxIdleConns(n int) Option {
+	return func(c *DriverConfig) { c.MaxIdleConns = n }
+}
+
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(c *DriverConfig) { c.ConnectTimeout = timeout }
+}
+
+func WithPgxPool(enabled bool) Option {
+	return func(c *DriverConfig) { c.UsePgxPool = enabled }
+}
+
+// WithTLS secures the connections to both databases.
+func WithTLS(tls TLSConfig) Option {
+	return func(c *DriverConfig) {
+		c.SourceTLS = tls
+		c.TargetTLS = tls
+	}
+}
+
+func WithSourceTLS(tls TLSConfig) Option {
+	return func(c *DriverConfig) { c.SourceTLS = tls }
+}
+
+func WithTargetTLS(tls TLSConfig) Option {
+	return func(c *DriverConfig) { c.TargetTLS = tls }
+}
+
+// WithDialFunc opens the connections to both databases with dial.
+func WithDialFunc(dial DialFunc) Option {
+	return func(c *DriverConfig) {
+		c.SourceDialFunc = dial
+		c.TargetDialFunc = dial
+	}
+}
+
+func WithSourceDialFunc(dial DialFunc) Option {
+	return func(c *DriverConfig) { c.SourceDialFunc = dial }
+}
+
+func WithTargetDialFunc(dial DialFunc) Option {
+	return func(c *DriverConfig) { c.TargetDialFunc = dial }
+}
+
+func WithSourcePasswordFunc(password PasswordFunc) Option {
+	return func(c *DriverConfig) { c.SourcePasswordFunc = password }
+}
+
+func WithTargetPasswordFunc(password PasswordFunc) Option {
+	return func(c *DriverConfig) { c.TargetPasswordFunc = password }
+}
+
+func WithSQLiteDriverName(name string) Option {
+	return func(c *DriverConfig) { c.SQLiteDriverName = name }
+}
+
+func WithSQLiteKey(key string) Option {
+	return func(c *DriverConfig) { c.SQLiteKey = key }
+}
+
+// AutoUserVersion bumps the user_version of the target by one, see
+// WithUserVersion.
+const AutoUserVersion = "auto"
+
+func WithUserVersion(version string) Option {
+	return func(c *DriverConfig) { c.UserVersion = version }
+}
+
+func WithPragmas(enabled bool) Option {
+	return func(c *DriverConfig) { c.Pragmas = enabled }
+}
+
+func WithReadOnly(readOnly bool) Option {
+	return func(c *DriverConfig) { c.ReadOnly = readOnly }
+}
+
+func WithLockTimeout(timeout time.Duration) Option {
+	return func(c *DriverConfig) { c.LockTimeout = timeout }
+}
+
+func WithStatementTimeout(timeout time.Duration) Option {
+	return func(c *DriverConfig) { c.StatementTimeout = timeout }
+}
+
+func WithOnlineDDL(enabled bool) Option {
+	return func(c *DriverConfig) { c.Online = enabled }
+}
+
+func WithDeferredValidation(enabled bool) Option {
+	return func(c *DriverConfig) { c.DeferValidation = enabled }
+}
+
+func WithBackfillBatchSize(rows int) Option {
+	return func(c *DriverConfig) { c.BackfillBatchSize = rows }
+}
+
+func WithSequenceAlignment(enabled bool) Option {
+	return func(c *DriverConfig) { c.AlignSequences = enabled }
+}
+
+func WithAnnotations(enabled bool) Option {
+	return func(c *DriverConfig) { c.Annotate = enabled }
+}
+
+// WithLockWarnings warns about ACCESS EXCLUSIVE locks on tables holding at
+// least largeRows rows, or DefaultLargeTableRows when zero, and on the hot
+// tables, in addition to the ones already named.
+func WithLockWarnings(largeRows int64, hot ...string) Option {
+	return func(c *DriverConfig) {
+		c.LargeTableRows = largeRows
+		c.HotTables = append(c.HotTables, hot...)
+	}
+}
+
+// WithIgnoredColumns leaves the differences of the ignored columns out of
+// diffs, in addition to the ones already set.
+func WithIgnoredColumns(ignored schema.IgnoredColumns) Option {
+	return func(c *DriverConfig) {
+		if c.IgnoredColumns == nil {
+			c.IgnoredColumns = schema.IgnoredColumns{}
+		}
+		for table, columns := range ignored {
+			c.IgnoredColumns[table] = append(c.IgnoredColumns[table], columns...)
+		}
+	}
+}
+
+// WithTableMappings compares source tables under the names mappings give
+// them, in addition to the ones already set, see schema.Schema.MapTableNames.
+func WithTableMappings(mappings ...schema.NameMapping) Option {
+	return func(c *DriverConfig) { c.TableMappings = append(c.TableMappings, mappings...) }
+}
+
+// WithRedactedNames replaces the names of tables, columns and other objects
+// with stable pseudonyms in generated statements, such as table_1f0c93a2,
+// so that plans can be shared without revealing the data model. Pseudonyms
+// are derived from the names and key, which keeps them from being guessed
+// by hashing common names.
+func WithRedactedNames(key string) Option {
+	return func(c *DriverConfig) { c.RedactNames, c.RedactKey = true, key }
+}
+
+// WithTemplateVars renders statements with placeholders for vars, in
+// addition to the ones already set, see TemplateVar.
+func WithTemplateVars(vars ...TemplateVar) Option {
+	return func(c *DriverConfig) { c.TemplateVars = append(c.TemplateVars, vars...) }
+}
+
+func WithMaxRewriteRows(rows int64) Option {
+	return func(c *DriverConfig) { c.MaxRewriteRows = rows }
+}
+
+// WithTables restricts introspection to the named tables, in addition to the
+// ones already selected.
+func WithTables(names ...string) Option {
+	return func(c *DriverConfig) { c.Tables = append(c.Tables, names...) }
+}
+
+// WithSkippedTypes leaves changes to the given types of objects out of plans,
+// in addition to the ones already skipped.
+func WithSkippedTypes(types ...schema.ObjectType) Option {
+	return func(c *DriverConfig) { c.SkippedTypes = append(c.SkippedTypes, types...) }
+}
+
+func WithDeterministicOrder(enabled bool) Option {
+	return func(c *DriverConfig) { c.DeterministicOrder = enabled }
+}
+
+func WithDeferredDestructive(enabled bool) Option {
+	return func(c *DriverConfig) { c.DeferDestructive = enabled }
+}
+
+func WithExpandContract(enabled bool) Option {
+	return func(c *DriverConfig) { c.ExpandContract = enabled }
+}
+
+// WithSourceSnapshot compares s instead of introspecting the source database.
+func WithSourceSnapshot(s *schema.Schema) Option {
+	return func(c *DriverConfig) { c.Snapshots.Source = s }
+}
+
+// WithTargetSnapshot compares s instead of introspecting the target database.
+func WithTargetSnapshot(s *schema.Schema) Option {
+	return func(c *DriverConfig) { c.Snapshots.Target = s }
+}
+
+// WithDiffCheck calls check with every diff before it gets rendered. Checks
+// of several WithDiffCheck options run in order, until one fails.
+func WithDiffCheck(check DiffCheckFunc) Option {
+	return func(c *DriverConfig) {
+		previous := c.DiffCheck
+		if previous == nil {
+			c.DiffCheck = check
+			return
+		}
+
+		c.DiffCheck = func(diff *schema.Diff) error {
+			err := previous(diff)
+			if err != nil {
+				return err
+			}
+			return check(diff)
+		}
+	}
+}
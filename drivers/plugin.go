@@ -0,0 +1,562 @@
+package drivers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// PluginPrefix starts the name of the executables providing external
+// drivers: dbdiff-driver-mysql provides the mysql driver.
+const PluginPrefix = "dbdiff-driver-"
+
+// PluginProtocolVersion is the version of the protocol spoken with plugins.
+// Plugins reject opening requests for another version.
+const PluginProtocolVersion = 1
+
+// Plugins speak a line-based JSON protocol over their standard input and
+// output: dbdiff writes a pluginRequest per line and the plugin answers each
+// with a pluginResponse, in order. The first request opens the databases,
+// the last one closes them. Anything written to the standard error is
+// passed through, e.g. for logs.
+//
+// Methods and their params and results:
+//
+//	open        PluginConfig       {"dialect": string}
+//	introspect  {"side": string}   schema.Schema
+//	render      schema.Diff        {"objects": [ObjectStatements]}
+//	exec        {"side": string, "statement": string}
+//	close
+type pluginRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type pluginResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// Error fails the request when not empty.
+	Error string `json:"error,omitempty"`
+}
+
+// PluginConfig is the part of DriverConfig sent to plugins. Options holding
+// functions, such as loggers or dialers, cannot cross process boundaries and
+// ignore rules are applied by dbdiff itself.
+type PluginConfig struct {
+	ProtocolVersion int `json:"protocolVersion"`
+
+	SourceDSN    string `json:"sourceDSN"`
+	TargetDSN    string `json:"targetDSN"`
+	SchemaFilter string `json:"schemaFilter,omitempty"`
+	SourceSchema string `json:"sourceSchema,omitempty"`
+	ReadOnly     bool   `json:"readOnly,omitempty"`
+
+	StrictDefinitions bool `json:"strictDefinitions,omitempty"`
+	Annotate          bool `json:"annotate,omitempty"`
+	Online            bool `json:"online,omitempty"`
+
+	Tables []string `json:"tables,omitempty"`
+
+	Concurrency      int           `json:"concurrency,omitempty"`
+	MaxOpenConns     int           `json:"maxOpenConns,omitempty"`
+	MaxIdleConns     int           `json:"maxIdleConns,omitempty"`
+	QueryTimeout     time.Duration `json:"queryTimeout,omitempty"`
+	ConnectTimeout   time.Duration `json:"connectTimeout,omitempty"`
+	LockTimeout      time.Duration `json:"lockTimeout,omitempty"`
+	StatementTimeout time.Duration `json:"statementTimeout,omitempty"`
+}
+
+func newPluginConfig(config *DriverConfig) PluginConfig {
+	return PluginConfig{
+		ProtocolVersion:   PluginProtocolVersion,
+		SourceDSN:         config.SourceDSN,
+		TargetDSN:         config.TargetDSN,
+		SchemaFilter:      config.SchemaFilter,
+		SourceSchema:      config.SourceSchema,
+		ReadOnly:          config.ReadOnly,
+		StrictDefinitions: config.StrictDefinitions,
+		Annotate:          config.Annotate,
+		Online:            config.Online,
+		Tables:            config.Tables,
+		Concurrency:       config.Concurrency,
+		MaxOpenConns:      config.MaxOpenConns,
+		MaxIdleConns:      config.MaxIdleConns,
+		QueryTimeout:      config.QueryTimeout,
+		ConnectTimeout:    config.ConnectTimeout,
+		LockTimeout:       config.LockTimeout,
+		StatementTimeout:  config.StatementTimeout,
+	}
+}
+
+// Options turns the configuration back into driver options, on the plugin
+// side.
+func (c PluginConfig) Options() []Option {
+	return []Option{
+		WithSourceDSN(c.SourceDSN),
+		WithTargetDSN(c.TargetDSN),
+		WithSchemaFilter(c.SchemaFilter),
+		WithSourceSchema(c.SourceSchema),
+		WithReadOnly(c.ReadOnly),
+		WithStrictDefinitions(c.StrictDefinitions),
+		WithAnnotations(c.Annotate),
+		WithOnlineDDL(c.Online),
+		WithTables(c.Tables...),
+		WithConcurrency(c.Concurrency),
+		WithMaxOpenConns(c.MaxOpenConns),
+		WithMaxIdleConns(c.MaxIdleConns),
+		WithQueryTimeout(c.QueryTimeout),
+		WithConnectTimeout(c.ConnectTimeout),
+		WithLockTimeout(c.LockTimeout),
+		WithStatementTimeout(c.StatementTimeout),
+	}
+}
+
+type pluginSide struct {
+	Side      Side   `json:"side"`
+	Statement string `json:"statement,omitempty"`
+}
+
+type pluginOpened struct {
+	Dialect string `json:"dialect"`
+}
+
+type pluginObjects struct {
+	Objects []*ObjectStatements `json:"objects"`
+}
+
+// PluginDriver is a driver running in another process, see ServePlugin.
+// Introspection and rendering happen in the plugin, comparison in dbdiff.
+type PluginDriver struct {
+	// Dialect is the dialect reported by the plugin.
+	Dialect string
+
+	Logger *slog.Logger
+	Ignore schema.IgnoreRules
+	Tables []string
+
+	TypeEquivalences     []schema.TypeEquivalence
+	CaseInsensitiveNames bool
+	SkippedTypes         []schema.ObjectType
+	DeterministicOrder   bool
+	DeferDestructive     bool
+	ExpandContract       bool
+	Snapshots            Snapshots
+	DiffCheck            DiffCheckFunc
+	TemplateVars         []TemplateVar
+	RedactNames          bool
+	RedactKey            string
+	TableMappings        []schema.NameMapping
+	IgnoredColumns       schema.IgnoredColumns
+
+	mu       sync.Mutex
+	encoder  *json.Encoder
+	decoder  *json.Decoder
+	closer   io.Closer
+	wait     func() error
+	progress *progressReporter
+}
+
+// OpenPlugin starts the plugin executable at path and opens the databases
+// described by opts through it.
+func OpenPlugin(path string, opts ...Option) (*PluginDriver, error) {
+	cmd := exec.Command(path)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start plugin: %w", err)
+	}
+
+	driver, err := newPluginDriver(stdout, stdin, cmd.Wait, opts...)
+	if err != nil {
+		stdin.Close()
+		cmd.Wait()
+		return nil, err
+	}
+
+	return driver, nil
+}
+
+// NewPluginDriver speaks to a plugin served over r and w, rather than
+// through the standard streams of a process. Close closes w.
+func NewPluginDriver(r io.Reader, w io.WriteCloser, opts ...Option) (*PluginDriver, error) {
+	return newPluginDriver(r, w, nil, opts...)
+}
+
+func newPluginDriver(r io.Reader, w io.WriteCloser, wait func() error, opts ...Option) (*PluginDriver, error) {
+	config := NewDriverConfig(opts...)
+
+	driver := &PluginDriver{
+		Logger:               config.Logger,
+		Ignore:               config.Ignore,
+		Tables:               config.Tables,
+		TypeEquivalences:     config.TypeEquivalences,
+		CaseInsensitiveNames: config.CaseInsensitiveNames,
+		SkippedTypes:         config.SkippedTypes,
+		DeterministicOrder:   config.DeterministicOrder,
+		DeferDestructive:     config.DeferDestructive,
+		ExpandContract:       config.ExpandContract,
+		Snapshots:            config.Snapshots,
+		DiffCheck:            config.DiffCheck,
+		TemplateVars:         config.TemplateVars,
+		RedactNames:          config.RedactNames,
+		RedactKey:            config.RedactKey,
+		TableMappings:        config.TableMappings,
+		IgnoredColumns:       config.IgnoredColumns,
+		encoder:              json.NewEncoder(w),
+		decoder:              json.NewDecoder(bufio.NewReader(r)),
+		closer:               w,
+		wait:                 wait,
+		progress:             newProgressReporter(config.Progress),
+	}
+
+	var opened pluginOpened
+	err := driver.call("open", newPluginConfig(config), &opened)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin: %w", err)
+	}
+	driver.Dialect = opened.Dialect
+
+	err = config.Snapshots.check(opened.Dialect)
+	if err != nil {
+		driver.Close()
+		return nil, err
+	}
+
+	return driver, nil
+}
+
+// call sends a request and decodes its result into result, unless nil.
+// Requests are serialized since the plugin answers them in order.
+func (d *PluginDriver) call(method string, params any, result any) error {
+	request := pluginRequest{Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		request.Params = data
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	err := d.encoder.Encode(request)
+	if err != nil {
+		return fmt.Errorf("failed to send %s request to plugin: %w", method, err)
+	}
+
+	var response pluginResponse
+	err = d.decoder.Decode(&response)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response from plugin: %w", method, err)
+	}
+
+	if response.Error != "" {
+		return errors.New(response.Error)
+	}
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(response.Result, result)
+}
+
+func (d *PluginDriver) Close() error {
+	err := d.call("close", nil, nil)
+	err = errors.Join(err, d.closer.Close())
+	if d.wait != nil {
+		err = errors.Join(err, d.wait())
+	}
+	return err
+}
+
+func (d *PluginDriver) Diff(ctx context.Context) (string, error) {
+	return collectStatements(d.Statements(ctx))
+}
+
+func (d *PluginDriver) Statements(ctx context.Context) iter.Seq2[string, error] {
+	return planStatements(ctx, d, d, d.planOptions())
+}
+
+func (d *PluginDriver) ObjectStatements(ctx context.Context) iter.Seq2[*ObjectStatements, error] {
+	return planObjects(ctx, d, d, d.planOptions())
+}
+
+func (d *PluginDriver) planOptions() planOptions {
+	return planOptions{
+		progress:         d.progress,
+		check:            d.DiffCheck,
+		deferDestructive: d.DeferDestructive || d.ExpandContract,
+		deferNotNull:     d.ExpandContract,
+		templateVars:     d.TemplateVars,
+		redactNames:      d.RedactNames,
+		redactKey:        d.RedactKey,
+		tableMappings:    d.TableMappings,
+		compare:          d.compareOptions(),
+	}
+}
+
+func (d *PluginDriver) compareOptions() []schema.CompareOption {
+	return []schema.CompareOption{
+		schema.WithTypeEquivalence(d.TypeEquivalences...),
+		schema.WithCaseInsensitiveNames(d.CaseInsensitiveNames),
+		schema.WithSkippedTypes(d.SkippedTypes...),
+		schema.WithIgnoredColumns(d.IgnoredColumns),
+		schema.WithDeterministicOrder(d.DeterministicOrder),
+		schema.WithLogger(d.Logger),
+	}
+}
+
+func (d *PluginDriver) Introspect(ctx context.Context, side Side) (*schema.Schema, error) {
+	s := d.Snapshots.Side(side)
+	if s == nil {
+		err := ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+
+		err = d.call("introspect", pluginSide{Side: side}, &s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	d.progress.report(IntrospectionPhase, side, len(s.Tables), len(s.Tables))
+
+	if len(d.Tables) > 0 {
+		s = s.OnlyTables(d.Tables)
+	}
+
+	return s.Filter(d.Ignore, func(objectType schema.ObjectType, name string) {
+		d.Logger.DebugContext(ctx, "ignoring object", "side", side, "type", objectType, "name", name)
+	}), nil
+}
+
+func (d *PluginDriver) Render(diff *schema.Diff) iter.Seq2[string, error] {
+	return flattenObjects(d.RenderObjects(diff))
+}
+
+// RenderObjects groups statements by object when the plugin's renderer
+// does, see ObjectRenderer. Otherwise the whole plan comes as a single
+// object without type nor name.
+func (d *PluginDriver) RenderObjects(diff *schema.Diff) iter.Seq2[*ObjectStatements, error] {
+	return func(yield func(*ObjectStatements, error) bool) {
+		var result pluginObjects
+		err := d.call("render", diff, &result)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		for _, object := range result.Objects {
+			if !yield(object, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (d *PluginDriver) Exec(ctx context.Context, side Side, statement string) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	return d.call("exec", pluginSide{Side: side, Statement: statement}, nil)
+}
+
+// ServePlugin answers the requests of dbdiff read from r on w, with drivers
+// created by constructor introspecting schemas of dialect, until the driver
+// gets closed. Plugin executables serve their standard streams:
+//
+//	func main() {
+//		err := drivers.ServePlugin(context.Background(), os.Stdin, os.Stdout, "mysql", NewMyDriver)
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+func ServePlugin(ctx context.Context, r io.Reader, w io.Writer, dialect string, constructor Constructor) error {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	encoder := json.NewEncoder(w)
+
+	var driver Driver
+	defer func() {
+		if driver != nil {
+			driver.Close()
+		}
+	}()
+
+	for {
+		var request pluginRequest
+		err := decoder.Decode(&request)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid request: %w", err)
+		}
+
+		if request.Method != "open" && driver == nil {
+			return fmt.Errorf("%s request before open", request.Method)
+		}
+
+		var result any
+		switch request.Method {
+		case "open":
+			var config PluginConfig
+			err = json.Unmarshal(request.Params, &config)
+			if err == nil && config.ProtocolVersion != PluginProtocolVersion {
+				err = fmt.Errorf("unsupported plugin protocol version %d, expected %d", config.ProtocolVersion, PluginProtocolVersion)
+			}
+			if err == nil {
+				driver, err = constructor(config.Options()...)
+			}
+			if err == nil {
+				result = pluginOpened{Dialect: dialect}
+			}
+
+		case "introspect":
+			var params pluginSide
+			err = json.Unmarshal(request.Params, &params)
+			if err == nil {
+				result, err = driver.Introspect(ctx, params.Side)
+			}
+
+		case "render":
+			var diff schema.Diff
+			err = json.Unmarshal(request.Params, &diff)
+			if err == nil {
+				result, err = renderPluginObjects(driver, &diff)
+			}
+
+		case "exec":
+			var params pluginSide
+			err = json.Unmarshal(request.Params, &params)
+			if err == nil {
+				executor, ok := driver.(Executor)
+				if !ok {
+					err = errors.New("driver cannot execute statements")
+				} else {
+					err = executor.Exec(ctx, params.Side, params.Statement)
+				}
+			}
+
+		case "close":
+			err = driver.Close()
+			driver = nil
+
+		default:
+			err = fmt.Errorf("unknown method %q", request.Method)
+		}
+
+		response := pluginResponse{}
+		if err != nil {
+			response.Error = err.Error()
+		} else if result != nil {
+			response.Result, err = json.Marshal(result)
+			if err != nil {
+				response.Error = err.Error()
+			}
+		}
+
+		err = encoder.Encode(response)
+		if err != nil {
+			return err
+		}
+
+		if request.Method == "close" {
+			return nil
+		}
+	}
+}
+
+// renderPluginObjects renders diff with the driver's ObjectRenderer, or else
+// as a single object.
+func renderPluginObjects(driver Driver, diff *schema.Diff) (*pluginObjects, error) {
+	objects := &pluginObjects{Objects: []*ObjectStatements{}}
+
+	renderer, ok := driver.(ObjectRenderer)
+	if !ok {
+		var statements []string
+		for statement, err := range driver.Render(diff) {
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, statement)
+		}
+
+		if len(statements) > 0 {
+			objects.Objects = append(objects.Objects, &ObjectStatements{Statements: statements})
+		}
+		return objects, nil
+	}
+
+	for object, err := range renderer.RenderObjects(diff) {
+		if err != nil {
+			return nil, err
+		}
+		objects.Objects = append(objects.Objects, object)
+	}
+
+	return objects, nil
+}
+
+// RegisterPlugins registers the plugin executables found in the PATH
+// directories under the name following PluginPrefix, unless a driver is
+// already registered under that name. The first executable found wins, like
+// with exec.LookPath.
+func RegisterPlugins() {
+	for _, directory := range filepath.SplitList(os.Getenv("PATH")) {
+		if directory == "" {
+			directory = "."
+		}
+
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name, found := strings.CutPrefix(entry.Name(), PluginPrefix)
+			if !found || name == "" || entry.IsDir() {
+				continue
+			}
+
+			path := filepath.Join(directory, entry.Name())
+			if _, err := exec.LookPath(path); err != nil {
+				continue
+			}
+
+			registryMu.Lock()
+			if _, registered := registry[name]; !registered {
+				registry[name] = func(opts ...Option) (Driver, error) {
+					return OpenPlugin(path, opts...)
+				}
+			}
+			registryMu.Unlock()
+		}
+	}
+}
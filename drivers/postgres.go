@@ -1,44 +1,326 @@
 package drivers
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"fmt"
+	"iter"
+	"log/slog"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/quantumsheep/dbdiff/schema"
 	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
 )
 
-type PostgresDriverConfig struct {
-	SourceConnectionString string
-	TargetConnectionString string
+func init() {
+	Register("postgres", func(opts ...Option) (Driver, error) {
+		driver, err := NewPostgresDriver(opts...)
+		if err != nil {
+			return nil, err
+		}
+		return driver, nil
+	})
 }
 
 type PostgresDriver struct {
+	PostgresRenderer
+
 	SourceDatabaseConnection *sql.DB
 	TargetDatabaseConnection *sql.DB
+
+	Concurrency  int
+	QueryTimeout time.Duration
+	Cache        *IntrospectionCache
+	Logger       *slog.Logger
+	Ignore       schema.IgnoreRules
+	Tables       []string
+
+	TypeEquivalences     []schema.TypeEquivalence
+	CaseInsensitiveNames bool
+	SkippedTypes         []schema.ObjectType
+	DeterministicOrder   bool
+	DeferDestructive     bool
+	ExpandContract       bool
+	MaxRewriteRows       int64
+	Snapshots            Snapshots
+	DiffCheck            DiffCheckFunc
+	TemplateVars         []TemplateVar
+	RedactNames          bool
+	RedactKey            string
+	TableMappings        []schema.NameMapping
+	IgnoredColumns       schema.IgnoredColumns
+	SchemaFilter         string
+	SourceSchema         string
+	Introspection        IntrospectionMode
+
+	// LargeTableRows and HotTables select the tables on which statements
+	// taking ACCESS EXCLUSIVE locks get a warning, see WithLockWarnings.
+	LargeTableRows int64
+	HotTables      []string
+
+	progress *progressReporter
+
+	// connectionStrings and passwords hold the connection string and
+	// password function of each side, for pg_dump to connect with.
+	connectionStrings map[Side]string
+	passwords         map[Side]PasswordFunc
+
+	sourcePool *pgxpool.Pool
+	targetPool *pgxpool.Pool
 }
 
-func NewPostgresDriver(config *PostgresDriverConfig) (*PostgresDriver, error) {
-	sourceDatabaseConnection, err := sql.Open("pgx", config.SourceConnectionString)
+// NewPostgresDriver connects to the source and target databases described
+// by opts.
+func NewPostgresDriver(opts ...Option) (*PostgresDriver, error) {
+	config := NewDriverConfig(opts...)
+
+	err := config.Snapshots.check("postgres")
 	if err != nil {
 		return nil, err
 	}
 
-	targetDatabaseConnection, err := sql.Open("pgx", config.TargetConnectionString)
+	driver := &PostgresDriver{
+		PostgresRenderer: PostgresRenderer{
+			LockTimeout:       config.LockTimeout,
+			StatementTimeout:  config.StatementTimeout,
+			Annotate:          config.Annotate,
+			Online:            config.Online,
+			DeferValidation:   config.DeferValidation,
+			BackfillBatchSize: config.BackfillBatchSize,
+			AlignSequences:    config.AlignSequences,
+		},
+		Concurrency:          config.Concurrency,
+		QueryTimeout:         config.QueryTimeout,
+		Cache:                config.Cache,
+		Logger:               config.Logger,
+		Ignore:               config.Ignore,
+		Tables:               config.Tables,
+		TypeEquivalences:     config.TypeEquivalences,
+		CaseInsensitiveNames: config.CaseInsensitiveNames,
+		SkippedTypes:         config.SkippedTypes,
+		DeterministicOrder:   config.DeterministicOrder,
+		DeferDestructive:     config.DeferDestructive,
+		ExpandContract:       config.ExpandContract,
+		MaxRewriteRows:       config.MaxRewriteRows,
+		Snapshots:            config.Snapshots,
+		DiffCheck:            config.DiffCheck,
+		TemplateVars:         config.TemplateVars,
+		RedactNames:          config.RedactNames,
+		RedactKey:            config.RedactKey,
+		TableMappings:        config.TableMappings,
+		IgnoredColumns:       config.IgnoredColumns,
+		SchemaFilter:         config.SchemaFilter,
+		SourceSchema:         config.SourceSchema,
+		Introspection:        cmp.Or(config.Introspection, CatalogIntrospection),
+		LargeTableRows:       config.LargeTableRows,
+		HotTables:            config.HotTables,
+		progress:             newProgressReporter(config.Progress),
+	}
+
+	if driver.Introspection == PgDumpIntrospection && (config.SourceDialFunc != nil || config.TargetDialFunc != nil) {
+		return nil, fmt.Errorf("pg_dump introspection cannot go through tunnels")
+	}
+
+	driver.connectionStrings = map[Side]string{
+		SourceSide: withTLSParams(withSocketHost(config.SourceDSN), config.SourceTLS),
+		TargetSide: withTLSParams(withSocketHost(config.TargetDSN), config.TargetTLS),
+	}
+	driver.passwords = map[Side]PasswordFunc{
+		SourceSide: config.SourcePasswordFunc,
+		TargetSide: config.TargetPasswordFunc,
+	}
+
+	driver.SourceDatabaseConnection, driver.sourcePool, err = openPostgres(driver.connectionStrings[SourceSide], config.SourceDialFunc, config.SourcePasswordFunc, config)
 	if err != nil {
 		return nil, err
 	}
 
-	driver := &PostgresDriver{
-		SourceDatabaseConnection: sourceDatabaseConnection,
-		TargetDatabaseConnection: targetDatabaseConnection,
+	driver.TargetDatabaseConnection, driver.targetPool, err = openPostgres(driver.connectionStrings[TargetSide], config.TargetDialFunc, config.TargetPasswordFunc, config)
+	if err != nil {
+		driver.SourceDatabaseConnection.Close()
+		if driver.sourcePool != nil {
+			driver.sourcePool.Close()
+		}
+		return nil, err
 	}
 
 	return driver, nil
 }
 
+func openPostgres(connectionString string, dial DialFunc, password PasswordFunc, config *DriverConfig) (*sql.DB, *pgxpool.Pool, error) {
+	if config.UsePgxPool {
+		poolConfig, err := pgxpool.ParseConfig(connectionString)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		poolConfig.MaxConns = int32(config.MaxOpenConns)
+		if config.ConnectTimeout > 0 {
+			poolConfig.ConnConfig.ConnectTimeout = config.ConnectTimeout
+		}
+		setDialFunc(poolConfig.ConnConfig, dial)
+		setReadOnly(poolConfig.ConnConfig, config.ReadOnly)
+		if password != nil {
+			poolConfig.BeforeConnect = beforeConnect(password)
+		}
+
+		pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return stdlib.OpenDBFromPool(pool), pool, nil
+	}
+
+	connConfig, err := pgx.ParseConfig(connectionString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if config.ConnectTimeout > 0 {
+		connConfig.ConnectTimeout = config.ConnectTimeout
+	}
+	setDialFunc(connConfig, dial)
+	setReadOnly(connConfig, config.ReadOnly)
+
+	var opts []stdlib.OptionOpenDB
+	if password != nil {
+		opts = append(opts, stdlib.OptionBeforeConnect(beforeConnect(password)))
+	}
+
+	db := stdlib.OpenDB(*connConfig, opts...)
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+
+	return db, nil, nil
+}
+
+// setDialFunc routes connections through dial, leaving host names for it to
+// resolve since they may only be known on the other side of a tunnel.
+func setDialFunc(connConfig *pgx.ConnConfig, dial DialFunc) {
+	if dial == nil {
+		return
+	}
+
+	connConfig.DialFunc = pgconn.DialFunc(dial)
+	connConfig.LookupFunc = func(ctx context.Context, host string) ([]string, error) {
+		return []string{host}, nil
+	}
+}
+
+// beforeConnect sets the password of each new connection, so that short-lived
+// tokens are fetched again once expired.
+func beforeConnect(password PasswordFunc) func(context.Context, *pgx.ConnConfig) error {
+	return func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		token, err := password(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get database password: %w", err)
+		}
+		connConfig.Password = token
+		return nil
+	}
+}
+
+func setReadOnly(connConfig *pgx.ConnConfig, readOnly bool) {
+	if readOnly {
+		connConfig.RuntimeParams["default_transaction_read_only"] = "on"
+	}
+}
+
+// socketHostPattern matches connection URLs whose host is a percent-encoded
+// Unix socket directory, as libpq accepts, such as
+// postgres://app@%2Fvar%2Frun%2Fpostgresql/app.
+var socketHostPattern = regexp.MustCompile(`^(postgres(?:ql)?://(?:[^@/]*@)?)(%2[Ff][^/?:]*)(?::(\d+))?([^?]*)(?:\?(.*))?$`)
+
+// withSocketHost moves percent-encoded socket directories from the host of
+// connection URLs to their host parameter, which pgx understands. Other
+// connection strings are returned as they are.
+func withSocketHost(connectionString string) string {
+	match := socketHostPattern.FindStringSubmatch(connectionString)
+	if match == nil {
+		return connectionString
+	}
+
+	params := []string{"host=" + match[2]}
+	if match[3] != "" {
+		params = append(params, "port="+match[3])
+	}
+	if match[5] != "" {
+		params = append(params, match[5])
+	}
+
+	return match[1] + match[4] + "?" + strings.Join(params, "&")
+}
+
+// withTLSParams sets the TLS parameters on a connection string, in either
+// URL or keyword/value form, overriding the ones it already has.
+func withTLSParams(connectionString string, tls TLSConfig) string {
+	params := tls.params()
+	if len(params) == 0 {
+		return connectionString
+	}
+
+	if strings.HasPrefix(connectionString, "postgres://") || strings.HasPrefix(connectionString, "postgresql://") {
+		u, err := url.Parse(connectionString)
+		if err != nil {
+			// Leave it to pgx to report
+			return connectionString
+		}
+
+		query := u.Query()
+		for name, value := range params {
+			query.Set(name, value)
+		}
+		u.RawQuery = query.Encode()
+
+		return u.String()
+	}
+
+	// Later keywords take precedence over earlier ones
+	names := slices.Sorted(maps.Keys(params))
+	for _, name := range names {
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(params[name])
+		connectionString += fmt.Sprintf(" %s='%s'", name, value)
+	}
+
+	return strings.TrimSpace(connectionString)
+}
+
+func (d *PostgresDriver) sideOf(db *sql.DB) Side {
+	if db == d.TargetDatabaseConnection {
+		return TargetSide
+	}
+	return SourceSide
+}
+
+// schemaFilter returns the schema introspected in db, empty for its current
+// schema.
+func (d *PostgresDriver) schemaFilter(db *sql.DB) string {
+	if d.sideOf(db) == SourceSide && d.SourceSchema != "" {
+		return d.SourceSchema
+	}
+	return d.SchemaFilter
+}
+
+func (d *PostgresDriver) query(ctx context.Context, db *sql.DB, query string, args ...any) (*queryRows, error) {
+	return logQuery(ctx, d.Logger.With("side", d.sideOf(db)), d.QueryTimeout, db, query, args...)
+}
+
+func (d *PostgresDriver) queryRow(ctx context.Context, db *sql.DB, query string, args ...any) *queryRow {
+	return logQueryRow(ctx, d.Logger.With("side", d.sideOf(db)), d.QueryTimeout, db, query, args...)
+}
+
 func (d *PostgresDriver) Close() error {
 	var err error
 
@@ -52,132 +334,324 @@ func (d *PostgresDriver) Close() error {
 		return err
 	}
 
+	// Closing a database/sql handle does not close the pool behind it
+	if d.sourcePool != nil {
+		d.sourcePool.Close()
+	}
+	if d.targetPool != nil {
+		d.targetPool.Close()
+	}
+
 	return nil
 }
 
 func (d *PostgresDriver) Diff(ctx context.Context) (string, error) {
-	var diff strings.Builder
+	return collectStatements(d.Statements(ctx))
+}
 
-	subDiff, err := d.DiffTables(ctx)
-	if err != nil {
-		return "", err
+// DiffTableByName computes the statements migrating a single table, only
+// introspecting that table.
+func (d *PostgresDriver) DiffTableByName(ctx context.Context, name string) (string, error) {
+	return d.DiffTables(ctx, name)
+}
+
+// DiffTables computes the statements migrating the given tables, leaving the
+// rest of the databases, views included, alone.
+func (d *PostgresDriver) DiffTables(ctx context.Context, names ...string) (string, error) {
+	restricted := *d
+	restricted.Tables = names
+	return restricted.Diff(ctx)
+}
+
+func (d *PostgresDriver) Statements(ctx context.Context) iter.Seq2[string, error] {
+	return planStatements(ctx, d, d, d.planOptions())
+}
+
+func (d *PostgresDriver) ObjectStatements(ctx context.Context) iter.Seq2[*ObjectStatements, error] {
+	return planObjects(ctx, d, d, d.planOptions())
+}
+
+func (d *PostgresDriver) planOptions() planOptions {
+	return planOptions{
+		progress:         d.progress,
+		check:            d.DiffCheck,
+		deferDestructive: d.DeferDestructive || d.ExpandContract,
+		deferNotNull:     d.ExpandContract,
+		templateVars:     d.TemplateVars,
+		redactNames:      d.RedactNames,
+		redactKey:        d.RedactKey,
+		tableMappings:    d.TableMappings,
+		compare:          d.compareOptions(),
+		classify:         postgresImpact,
+		annotate:         d.Annotate,
+		lockWarnings:     d.lockWarnings(),
+		maxRewriteRows:   d.MaxRewriteRows,
 	}
-	fmt.Fprintln(&diff, subDiff)
+}
 
-	return strings.TrimSpace(diff.String()), nil
+func (d *PostgresDriver) compareOptions() []schema.CompareOption {
+	return []schema.CompareOption{
+		schema.WithTypeEquivalence(d.TypeEquivalences...),
+		schema.WithTypeEquivalence(postgresCategoryTypes),
+		schema.WithCaseInsensitiveNames(d.CaseInsensitiveNames),
+		schema.WithSkippedTypes(d.SkippedTypes...),
+		schema.WithIgnoredColumns(d.IgnoredColumns),
+		schema.WithDeterministicOrder(d.DeterministicOrder),
+		schema.WithLogger(d.Logger),
+	}
 }
 
-func (d *PostgresDriver) DiffTables(ctx context.Context) (string, error) {
-	var diff strings.Builder
+func (d *PostgresDriver) Exec(ctx context.Context, side Side, statement string) error {
+	_, err := d.ExecRows(ctx, side, statement)
+	return err
+}
 
-	sourceTables, err := d.GetTables(ctx, d.SourceDatabaseConnection)
-	if err != nil {
-		return "", err
+// ExecRows is Exec returning the number of rows the statement affected, see
+// RowsExecutor.
+func (d *PostgresDriver) ExecRows(ctx context.Context, side Side, statement string) (int64, error) {
+	return logExec(ctx, d.Logger.With("side", side), d.connection(side), statement)
+}
+
+// EstimateImpact tells which statements rewrite or scan existing tables of
+// the target database, see ImpactEstimator.
+func (d *PostgresDriver) EstimateImpact(ctx context.Context, statements []string) ([]*StatementImpact, error) {
+	return estimateImpact(ctx, d, postgresImpact, d.lockWarnings(), statements)
+}
+
+func (d *PostgresDriver) lockWarnings() lockWarnings {
+	return lockWarnings{largeRows: d.LargeTableRows, hot: d.HotTables}
+}
+
+// EstimateRows returns the row counts the planner estimates from the last
+// VACUUM or ANALYZE, -1 for tables never analyzed. Sides read from a
+// snapshot have no row counts.
+func (d *PostgresDriver) EstimateRows(ctx context.Context, side Side) (map[string]int64, error) {
+	if d.Snapshots.Side(side) != nil {
+		return nil, nil
 	}
 
-	targetTables, err := d.GetTables(ctx, d.TargetDatabaseConnection)
+	rows, err := d.query(ctx, d.connection(side), `
+		SELECT c.relname, c.reltuples::bigint
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = coalesce(nullif($1::text, ''), current_schema())
+		AND c.relkind IN ('r', 'p')
+	`, d.schemaFilter(d.connection(side)))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer rows.Close()
 
-	// Added or modified tables
-	for _, sourceTable := range sourceTables {
-		targetTable, found := lo.Find(targetTables, func(t *PostgresTable) bool {
-			return t.Name == sourceTable.Name
-		})
-
-		// Table not found in target database
-		if !found {
-			fmt.Fprintf(&diff, "%s\n", sourceTable.String())
-			continue
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		var count int64
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, err
 		}
+		counts[name] = max(count, -1)
+	}
 
-		subDiff, err := sourceTable.DiffTable(targetTable)
+	return counts, rows.Err()
+}
+
+func (d *PostgresDriver) connection(side Side) *sql.DB {
+	if side == TargetSide {
+		return d.TargetDatabaseConnection
+	}
+	return d.SourceDatabaseConnection
+}
+
+// Introspect reads the schema of one of the databases, skipping it entirely
+// when the cache holds an entry for its current catalog state.
+func (d *PostgresDriver) Introspect(ctx context.Context, side Side) (*schema.Schema, error) {
+	start := time.Now()
+	db := d.connection(side)
+
+	var s *schema.Schema
+	var err error
+
+	loaded := true
+	if snapshot := d.Snapshots.Side(side); snapshot != nil {
+		s, loaded = snapshot, false
+	} else if d.Cache == nil {
+		s, err = d.GetSchema(ctx, db)
+	} else {
+		var fingerprint string
+		fingerprint, err = d.Fingerprint(ctx, db)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		fmt.Fprintln(&diff, subDiff)
-	}
 
-	// Removed tables
-	for _, targetTable := range targetTables {
-		_, found := lo.Find(sourceTables, func(t *PostgresTable) bool {
-			return t.Name == targetTable.Name
+		loaded = false
+		s, err = cached(d.Cache, fingerprint, d.cacheKind(), func() (*schema.Schema, error) {
+			loaded = true
+			return d.GetSchema(ctx, db)
 		})
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		// Table not found in source database
-		if !found {
-			fmt.Fprintf(&diff, "DROP TABLE \"%s\";\n", targetTable.Name)
+	// Cache hits skip GetTables and its progress updates
+	if !loaded {
+		d.progress.report(IntrospectionPhase, side, len(s.Tables), len(s.Tables))
+	}
+
+	d.Logger.DebugContext(ctx, "introspected schema",
+		"side", side,
+		"tables", len(s.Tables),
+		"views", len(s.Views),
+		"cached", !loaded,
+		"duration", time.Since(start),
+	)
+
+	if side == SourceSide && d.SourceSchema != "" {
+		targetSchema := d.SchemaFilter
+		if targetSchema == "" && d.Snapshots.Side(TargetSide) != nil {
+			return nil, fmt.Errorf("the target schema must be named to map %s onto a snapshot", d.SourceSchema)
+		}
+		if targetSchema == "" {
+			err := d.queryRow(ctx, d.TargetDatabaseConnection, "SELECT current_schema()").Scan(&targetSchema)
+			if err != nil {
+				return nil, err
+			}
 		}
+		s = remapPostgresSchema(s, d.SourceSchema, targetSchema)
 	}
 
-	subDiff, err := d.DiffViews(ctx)
-	if err != nil {
-		return "", err
+	if len(d.Tables) > 0 {
+		s = s.OnlyTables(d.Tables)
 	}
-	fmt.Fprintln(&diff, subDiff)
 
-	return strings.TrimSpace(diff.String()), nil
+	return s.Filter(d.Ignore, func(objectType schema.ObjectType, name string) {
+		d.Logger.DebugContext(ctx, "ignoring object", "side", side, "type", objectType, "name", name)
+	}), nil
 }
 
-func (d *PostgresDriver) DiffViews(ctx context.Context) (string, error) {
-	var diff strings.Builder
-
-	sourceViews, err := d.GetViews(ctx, d.SourceDatabaseConnection)
-	if err != nil {
-		return "", err
+// cacheKind keeps schemas read by pg_dump apart from the ones read from the
+// catalogs, as they spell column types differently.
+func (d *PostgresDriver) cacheKind() string {
+	if d.Introspection == PgDumpIntrospection {
+		return tablesCacheKind(d.Tables) + "/pg_dump"
 	}
+	return tablesCacheKind(d.Tables)
+}
 
-	targetViews, err := d.GetViews(ctx, d.TargetDatabaseConnection)
+// Fingerprint identifies the server, database and schema along with the
+// transaction ids that last touched the schema's catalog rows, which change
+// on every DDL statement affecting it.
+func (d *PostgresDriver) Fingerprint(ctx context.Context, db *sql.DB) (string, error) {
+	var fingerprint string
+	err := d.queryRow(ctx, db, `
+		SELECT concat_ws(':',
+			coalesce(inet_server_addr()::text, 'local'),
+			current_setting('port'),
+			current_database(),
+			coalesce(nullif($1::text, ''), current_schema()),
+			md5(coalesce(string_agg(version, ',' ORDER BY version), ''))
+		)
+		FROM (
+			SELECT 'c' || c.oid::text || ':' || c.xmin::text
+			FROM pg_class c
+			WHERE c.relnamespace = (SELECT oid FROM pg_namespace WHERE nspname = coalesce(nullif($1::text, ''), current_schema()))
+			UNION ALL
+			SELECT 'a' || a.attrelid::text || ':' || a.attnum::text || ':' || a.xmin::text
+			FROM pg_attribute a
+			JOIN pg_class c ON c.oid = a.attrelid
+			WHERE c.relnamespace = (SELECT oid FROM pg_namespace WHERE nspname = coalesce(nullif($1::text, ''), current_schema()))
+			UNION ALL
+			SELECT 'd' || ad.oid::text || ':' || ad.xmin::text
+			FROM pg_attrdef ad
+			JOIN pg_class c ON c.oid = ad.adrelid
+			WHERE c.relnamespace = (SELECT oid FROM pg_namespace WHERE nspname = coalesce(nullif($1::text, ''), current_schema()))
+			UNION ALL
+			SELECT 'k' || con.oid::text || ':' || con.xmin::text
+			FROM pg_constraint con
+			WHERE con.connamespace = (SELECT oid FROM pg_namespace WHERE nspname = coalesce(nullif($1::text, ''), current_schema()))
+			UNION ALL
+			SELECT 't' || tg.oid::text || ':' || tg.xmin::text
+			FROM pg_trigger tg
+			JOIN pg_class c ON c.oid = tg.tgrelid
+			WHERE c.relnamespace = (SELECT oid FROM pg_namespace WHERE nspname = coalesce(nullif($1::text, ''), current_schema()))
+			UNION ALL
+			SELECT 'r' || rw.oid::text || ':' || rw.xmin::text
+			FROM pg_rewrite rw
+			JOIN pg_class c ON c.oid = rw.ev_class
+			WHERE c.relnamespace = (SELECT oid FROM pg_namespace WHERE nspname = coalesce(nullif($1::text, ''), current_schema()))
+			UNION ALL
+			SELECT 'p' || p.oid::text || ':' || p.xmin::text
+			FROM pg_proc p
+			WHERE p.pronamespace = (SELECT oid FROM pg_namespace WHERE nspname = coalesce(nullif($1::text, ''), current_schema()))
+			UNION ALL
+			SELECT 'o' || o.oid::text || ':' || o.xmin::text
+			FROM pg_operator o
+			WHERE o.oprnamespace = (SELECT oid FROM pg_namespace WHERE nspname = coalesce(nullif($1::text, ''), current_schema()))
+			UNION ALL
+			SELECT 'x' || ca.oid::text || ':' || ca.xmin::text
+			FROM pg_cast ca
+			WHERE ca.oid >= 16384
+		) AS versions(version)
+	`, d.schemaFilter(db)).Scan(&fingerprint)
 	if err != nil {
 		return "", err
 	}
 
-	// Added or modified views
-	for _, sourceView := range sourceViews {
-		targetView, found := lo.Find(targetViews, func(v *PostgresView) bool {
-			return v.Name == sourceView.Name
-		})
+	return "postgres:" + fingerprint, nil
+}
 
-		if !found {
-			fmt.Fprintf(&diff, "%s\n", sourceView.String())
-			continue
-		}
+func (d *PostgresDriver) GetSchema(ctx context.Context, db *sql.DB) (*schema.Schema, error) {
+	if d.Introspection == PgDumpIntrospection {
+		return d.GetDumpedSchema(ctx, db)
+	}
 
-		if sourceView.Def != targetView.Def {
-			fmt.Fprintf(&diff, "DROP VIEW \"%s\";\n", targetView.Name)
-			fmt.Fprintf(&diff, "%s\n", sourceView.String())
-		}
+	tables, err := d.GetTables(ctx, db)
+	if err != nil {
+		return nil, err
 	}
 
-	// Removed views
-	for _, targetView := range targetViews {
-		_, found := lo.Find(sourceViews, func(v *PostgresView) bool {
-			return v.Name == targetView.Name
-		})
+	s := &schema.Schema{
+		Dialect: "postgres",
+		Tables:  tables,
+	}
+	if len(d.Tables) > 0 {
+		return s, nil
+	}
 
-		if !found {
-			fmt.Fprintf(&diff, "DROP VIEW \"%s\";\n", targetView.Name)
-		}
+	s.Views, err = d.GetViews(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	s.Aggregates, err = d.GetAggregates(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	s.Operators, err = d.GetOperators(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	s.Casts, err = d.GetCasts(ctx, db)
+	if err != nil {
+		return nil, err
 	}
 
-	return strings.TrimSpace(diff.String()), nil
+	return s, nil
 }
 
-func (d *PostgresDriver) GetViews(ctx context.Context, db *sql.DB) ([]*PostgresView, error) {
-	viewRows, err := db.QueryContext(ctx, `
+func (d *PostgresDriver) GetViews(ctx context.Context, db *sql.DB) ([]*schema.View, error) {
+	viewRows, err := d.query(ctx, db, `
 		SELECT table_name, view_definition
 		FROM information_schema.views
-		WHERE table_schema = current_schema()
-	`)
+		WHERE table_schema = coalesce(nullif($1::text, ''), current_schema())
+	`, d.schemaFilter(db))
 	if err != nil {
 		return nil, err
 	}
 	defer viewRows.Close()
 
-	var views []*PostgresView
+	var views []*schema.View
 	for viewRows.Next() {
-		view := &PostgresView{}
+		view := &schema.View{}
 
 		err := viewRows.Scan(&view.Name, &view.Def)
 		if err != nil {
@@ -186,138 +660,272 @@ func (d *PostgresDriver) GetViews(ctx context.Context, db *sql.DB) ([]*PostgresV
 
 		views = append(views, view)
 	}
+	if err := viewRows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = d.GetViewTriggers(ctx, db, lo.KeyBy(views, func(v *schema.View) string { return v.Name }))
+	if err != nil {
+		return nil, err
+	}
 	return views, nil
 }
 
-func (d *PostgresDriver) GetTables(ctx context.Context, db *sql.DB) ([]*PostgresTable, error) {
-	tableRows, err := db.QueryContext(ctx, `
+// GetViewTriggers reads the triggers defined on views, such as INSTEAD OF
+// triggers making them writable.
+func (d *PostgresDriver) GetViewTriggers(ctx context.Context, db *sql.DB, viewsByName map[string]*schema.View) error {
+	triggerRows, err := d.query(ctx, db, `
+		SELECT cl.relname, tg.tgname, pg_get_triggerdef(tg.oid)
+		FROM pg_trigger tg
+		JOIN pg_class cl ON cl.oid = tg.tgrelid
+		JOIN pg_namespace n ON n.oid = cl.relnamespace
+		WHERE n.nspname = coalesce(nullif($1::text, ''), current_schema()) AND cl.relkind = 'v' AND tg.tgisinternal = false
+		ORDER BY cl.relname, tg.tgname
+	`, d.schemaFilter(db))
+	if err != nil {
+		return err
+	}
+	defer triggerRows.Close()
+
+	for triggerRows.Next() {
+		var viewName string
+		trigger := &schema.Trigger{}
+
+		err := triggerRows.Scan(&viewName, &trigger.Name, &trigger.Def)
+		if err != nil {
+			return err
+		}
+
+		view, ok := viewsByName[viewName]
+		if !ok {
+			continue
+		}
+
+		view.Triggers = append(view.Triggers, trigger)
+	}
+
+	return triggerRows.Err()
+}
+
+func (d *PostgresDriver) GetTables(ctx context.Context, db *sql.DB) ([]*schema.Table, error) {
+	tableRows, err := d.query(ctx, db, `
 		SELECT table_name 
 		FROM information_schema.tables 
-		WHERE table_schema = current_schema() 
+		WHERE table_schema = coalesce(nullif($1::text, ''), current_schema()) 
 		AND table_type = 'BASE TABLE'
-	`)
+		AND ($2::text[] IS NULL OR table_name = ANY($2::text[]))
+	`, d.schemaFilter(db), d.Tables)
 	if err != nil {
 		return nil, err
 	}
 	defer tableRows.Close()
 
-	var tables []*PostgresTable
+	var tables []*schema.Table
+	tablesByName := make(map[string]*schema.Table)
 	for tableRows.Next() {
 		var tableName string
 		if err := tableRows.Scan(&tableName); err != nil {
 			return nil, err
 		}
 
-		table, err := d.GetTable(ctx, db, tableName)
-		if err != nil {
-			return nil, err
-		}
-
+		table := &schema.Table{Name: tableName}
 		tables = append(tables, table)
+		tablesByName[tableName] = table
+	}
+	if err := tableRows.Err(); err != nil {
+		return nil, err
+	}
+
+	side := d.sideOf(db)
+	d.progress.report(IntrospectionPhase, side, 0, len(tables))
+
+	// Each object kind is fetched with a single query over the whole schema
+	// and grouped by table, instead of issuing one query per table.
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(d.Concurrency)
+	g.Go(func() error {
+		return d.GetColumns(gctx, db, tablesByName)
+	})
+	g.Go(func() error {
+		return d.GetConstraints(gctx, db, tablesByName)
+	})
+	g.Go(func() error {
+		return d.GetIndexes(gctx, db, tablesByName)
+	})
+	g.Go(func() error {
+		return d.GetTriggers(gctx, db, tablesByName)
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
 	}
 
+	d.progress.report(IntrospectionPhase, side, len(tables), len(tables))
+
 	return tables, nil
 }
 
-func (d *PostgresDriver) GetTable(ctx context.Context, db *sql.DB, tableName string) (*PostgresTable, error) {
-	table := &PostgresTable{Name: tableName}
-
-	// Get columns
-	columnRows, err := db.QueryContext(ctx, `
-			SELECT column_name, data_type, is_nullable, column_default
-			FROM information_schema.columns
-			WHERE table_schema = current_schema() AND table_name = $1
-			ORDER BY ordinal_position
-		`, tableName)
+func (d *PostgresDriver) GetColumns(ctx context.Context, db *sql.DB, tablesByName map[string]*schema.Table) error {
+	// Sequences owned by a column depend on it automatically, as opposed to
+	// the internal dependency of identity columns on theirs
+	columnRows, err := d.query(ctx, db, `
+		SELECT c.table_name, c.column_name, format_type(a.atttypid, a.atttypmod), c.is_nullable, c.column_default, (
+			SELECT s.relname
+			FROM pg_depend dep
+			JOIN pg_class s ON s.oid = dep.objid AND s.relkind = 'S'
+			WHERE dep.classid = 'pg_class'::regclass
+			AND dep.refclassid = 'pg_class'::regclass
+			AND dep.deptype = 'a'
+			AND dep.refobjid = t.oid
+			AND dep.refobjsubid = a.attnum
+			ORDER BY s.relname
+			LIMIT 1
+		)
+		FROM information_schema.columns c
+		JOIN pg_namespace n ON n.nspname = c.table_schema
+		JOIN pg_class t ON t.relnamespace = n.oid AND t.relname = c.table_name
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attname = c.column_name
+		WHERE c.table_schema = coalesce(nullif($1::text, ''), current_schema())
+		ORDER BY c.table_name, c.ordinal_position
+	`, d.schemaFilter(db))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer columnRows.Close()
 
 	for columnRows.Next() {
-		var colName, dataType, isNullable string
-		var colDefault sql.NullString
-		if err := columnRows.Scan(&colName, &dataType, &isNullable, &colDefault); err != nil {
-			return nil, err
+		var tableName, colName, dataType, isNullable string
+		var colDefault, sequence sql.NullString
+		if err := columnRows.Scan(&tableName, &colName, &dataType, &isNullable, &colDefault, &sequence); err != nil {
+			return err
+		}
+
+		// Columns of views and other relations are not part of any table
+		table, ok := tablesByName[tableName]
+		if !ok {
+			continue
 		}
 
-		column := &PostgresColumn{
-			Name:    colName,
-			Type:    dataType,
-			NotNull: isNullable == "NO",
-			Default: colDefault,
+		column := &schema.Column{
+			Name:     colName,
+			Type:     dataType,
+			NotNull:  isNullable == "NO",
+			Default:  colDefault,
+			Sequence: sequence.String,
 		}
 		table.Columns = append(table.Columns, column)
 	}
 
-	// Get constraints
-	constraintRows, err := db.QueryContext(ctx, `
-			SELECT conname, contype, pg_get_constraintdef(oid)
-			FROM pg_constraint
-			WHERE conrelid = $1::regclass
-		`, tableName)
+	return columnRows.Err()
+}
+
+func (d *PostgresDriver) GetConstraints(ctx context.Context, db *sql.DB, tablesByName map[string]*schema.Table) error {
+	constraintRows, err := d.query(ctx, db, `
+		SELECT cl.relname, con.conname, con.contype, pg_get_constraintdef(con.oid)
+		FROM pg_constraint con
+		JOIN pg_class cl ON cl.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = cl.relnamespace
+		WHERE n.nspname = coalesce(nullif($1::text, ''), current_schema())
+		ORDER BY cl.relname, con.oid
+	`, d.schemaFilter(db))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer constraintRows.Close()
 
 	for constraintRows.Next() {
-		constraint := &PostgresConstraint{}
+		var tableName string
+		constraint := &schema.Constraint{}
 
-		err := constraintRows.Scan(&constraint.Name, &constraint.Type, &constraint.Def)
+		err := constraintRows.Scan(&tableName, &constraint.Name, &constraint.Type, &constraint.Def)
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		table, ok := tablesByName[tableName]
+		if !ok {
+			continue
 		}
 
 		table.Constraints = append(table.Constraints, constraint)
 	}
 
-	// Get indexes
-	indexRows, err := db.QueryContext(ctx, `
-			SELECT indexname, indexdef
-			FROM pg_indexes
-			WHERE schemaname = current_schema() AND tablename = $1
-			AND indexname NOT IN (
-				SELECT conname FROM pg_constraint WHERE conrelid = $1::regclass
-			)
-		`, tableName)
+	return constraintRows.Err()
+}
+
+func (d *PostgresDriver) GetIndexes(ctx context.Context, db *sql.DB, tablesByName map[string]*schema.Table) error {
+	// Indexes backing a constraint are created along with the constraint
+	indexRows, err := d.query(ctx, db, `
+		SELECT i.tablename, i.indexname, i.indexdef
+		FROM pg_indexes i
+		WHERE i.schemaname = coalesce(nullif($1::text, ''), current_schema())
+		AND NOT EXISTS (
+			SELECT 1
+			FROM pg_constraint con
+			JOIN pg_class cl ON cl.oid = con.conrelid
+			JOIN pg_namespace n ON n.oid = cl.relnamespace
+			WHERE n.nspname = i.schemaname
+			AND cl.relname = i.tablename
+			AND con.conname = i.indexname
+		)
+		ORDER BY i.tablename, i.indexname
+	`, d.schemaFilter(db))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer indexRows.Close()
 
 	for indexRows.Next() {
-		index := &PostgresIndex{}
+		var tableName string
+		index := &schema.Index{}
 
-		err := indexRows.Scan(&index.Name, &index.Def)
+		err := indexRows.Scan(&tableName, &index.Name, &index.Def)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
+		table, ok := tablesByName[tableName]
+		if !ok {
+			continue
+		}
+
+		index.Table = tableName
+		parsePostgresIndex(index)
 		table.Indexes = append(table.Indexes, index)
 	}
 
-	// Get triggers
-	triggerRows, err := db.QueryContext(ctx, `
-			SELECT tgname, pg_get_triggerdef(oid)
-			FROM pg_trigger
-			WHERE tgrelid = $1::regclass AND tgisinternal = false
-		`, tableName)
+	return indexRows.Err()
+}
+
+func (d *PostgresDriver) GetTriggers(ctx context.Context, db *sql.DB, tablesByName map[string]*schema.Table) error {
+	triggerRows, err := d.query(ctx, db, `
+		SELECT cl.relname, tg.tgname, pg_get_triggerdef(tg.oid)
+		FROM pg_trigger tg
+		JOIN pg_class cl ON cl.oid = tg.tgrelid
+		JOIN pg_namespace n ON n.oid = cl.relnamespace
+		WHERE n.nspname = coalesce(nullif($1::text, ''), current_schema()) AND tg.tgisinternal = false
+		ORDER BY cl.relname, tg.tgname
+	`, d.schemaFilter(db))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer triggerRows.Close()
 
 	for triggerRows.Next() {
-		trigger := &PostgresTrigger{}
+		var tableName string
+		trigger := &schema.Trigger{}
 
-		err := triggerRows.Scan(&trigger.Name, &trigger.Def)
+		err := triggerRows.Scan(&tableName, &trigger.Name, &trigger.Def)
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		table, ok := tablesByName[tableName]
+		if !ok {
+			continue
 		}
 
 		table.Triggers = append(table.Triggers, trigger)
 	}
 
-	return table, nil
+	return triggerRows.Err()
 }
@@ -1,36 +1,144 @@
 package drivers
 
 import (
-	"database/sql"
 	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
 )
 
-type PostgresColumn struct {
-	Name       string
-	Type       string
-	NotNull    bool
-	Default    sql.NullString
+func (r *PostgresRenderer) ColumnDefinition(c *schema.Column) string {
+	value := fmt.Sprintf("%s %s", postgresQuoted(c.Name), c.Type)
+	if c.NotNull {
+		value += " NOT NULL"
+	}
+	if c.Default.Valid {
+		value += fmt.Sprintf(" DEFAULT %s", c.Default.String)
+	}
+	return value
 }
 
-func (c *PostgresColumn) Copy() *PostgresColumn {
-	new := *c
-	return &new
+// AlterColumn returns the statements turning the target column into the
+// source one. Types are only altered when retyped is set, so that equivalent
+// spellings of a type are left alone.
+func (r *PostgresRenderer) AlterColumn(table string, sourceColumn *schema.Column, targetColumn *schema.Column, retyped bool) []string {
+	var statements []string
+
+	// Type change
+	if retyped {
+		// Arrays of another element type have no assignment cast, their
+		// elements are converted by an explicit one
+		using := ""
+		if strings.HasSuffix(sourceColumn.Type, "]") || strings.HasSuffix(targetColumn.Type, "]") {
+			using = fmt.Sprintf(" USING %s::%s", postgresQuoted(sourceColumn.Name), sourceColumn.Type)
+		}
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s%s;", postgresQuoted(table), postgresQuoted(sourceColumn.Name), sourceColumn.Type, using))
+	}
+
+	// Not Null change
+	if sourceColumn.NotNull != targetColumn.NotNull {
+		if sourceColumn.NotNull {
+			statements = append(statements, r.SetNotNull(table, sourceColumn)...)
+		} else {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL;", postgresQuoted(table), postgresQuoted(sourceColumn.Name)))
+		}
+	}
+
+	// Default change, the sequence it may draw from being created first
+	if sourceColumn.Default != targetColumn.Default {
+		if sourceColumn.Default.Valid {
+			statements = append(statements, r.CreateSequence(sourceColumn, targetColumn)...)
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;", postgresQuoted(table), postgresQuoted(sourceColumn.Name), sourceColumn.Default.String))
+			statements = append(statements, r.AlignSequence(table, sourceColumn)...)
+		} else {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT;", postgresQuoted(table), postgresQuoted(sourceColumn.Name)))
+		}
+	}
+
+	return append(statements, r.OwnSequence(table, sourceColumn, targetColumn)...)
 }
 
-func (c *PostgresColumn) HasEqualAttributes(other *PostgresColumn) bool {
-	copy := c.Copy()
-	copy.Name = other.Name
+// postgresCategoryTypes makes the categories information_schema reports
+// column types by, which snapshots taken before types were read with
+// format_type hold, equivalent to the types they stand for: ARRAY to any
+// array type, USER-DEFINED to any other one.
+func postgresCategoryTypes(sourceType, targetType string) bool {
+	return postgresTypeInCategory(sourceType, targetType) || postgresTypeInCategory(targetType, sourceType)
+}
 
-	return *copy == *other
+func postgresTypeInCategory(columnType string, category string) bool {
+	switch category {
+	case "ARRAY":
+		return strings.HasSuffix(columnType, "]")
+	case "USER-DEFINED":
+		return !strings.HasSuffix(columnType, "]")
+	}
+	return false
 }
 
-func (c *PostgresColumn) String() string {
-	value := fmt.Sprintf("\"%s\" %s", c.Name, c.Type)
-	if c.NotNull {
-		value += " NOT NULL"
+// postgresSequenceTypes are the column types sequences can be declared as,
+// bigint being the default.
+var postgresSequenceTypes = map[string]bool{"smallint": true, "integer": true}
+
+// CreateSequence returns the statement creating the sequence column owns,
+// for columns added to the plan or starting to own one. The sequence exists
+// already when the target column, if any, draws from it.
+func (r *PostgresRenderer) CreateSequence(column *schema.Column, target *schema.Column) []string {
+	if column.Sequence == "" || (target != nil && drawsFrom(target, column.Sequence)) {
+		return nil
 	}
-	if c.Default.Valid {
-		value += fmt.Sprintf(" DEFAULT %s", c.Default.String)
+
+	create := fmt.Sprintf("CREATE SEQUENCE %s", postgresQuoted(column.Sequence))
+	if postgresSequenceTypes[column.Type] {
+		create += " AS " + column.Type
 	}
-	return value
+	return []string{create + ";"}
+}
+
+// OwnSequence returns the statements attaching the sequence column owns to
+// it, once the column exists, so that it gets dropped along with it. The
+// sequence the target column owned instead is dropped, as dropping the
+// column would, unless column still draws from it.
+func (r *PostgresRenderer) OwnSequence(table string, column *schema.Column, target *schema.Column) []string {
+	var statements []string
+	if target != nil && target.Sequence != "" && target.Sequence != column.Sequence && !drawsFrom(column, target.Sequence) {
+		statements = append(statements, fmt.Sprintf("DROP SEQUENCE %s;", postgresQuoted(target.Sequence)))
+	}
+	if column.Sequence != "" && (target == nil || target.Sequence != column.Sequence) {
+		statements = append(statements, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.%s;", postgresQuoted(column.Sequence), postgresQuoted(table), postgresQuoted(column.Name)))
+	}
+	return statements
+}
+
+// drawsFrom reports whether column owns sequence or takes its default from
+// it.
+func drawsFrom(column *schema.Column, sequence string) bool {
+	if column.Sequence == sequence {
+		return true
+	}
+
+	match := nextvalPattern.FindStringSubmatch(column.Default.String)
+	if !column.Default.Valid || match == nil {
+		return false
+	}
+	name := strings.ReplaceAll(match[1][1:len(match[1])-1], "''", "'")
+	return unqualifiedName(name) == sequence
+}
+
+// nextvalPattern matches column defaults drawing values from a sequence, as
+// reported for serial columns, and captures the quoted sequence name.
+var nextvalPattern = regexp.MustCompile(`(?i)^nextval\(('(?:[^']|'')+')(?:::regclass)?\)$`)
+
+// AlignSequence returns the statement moving the sequence column now draws
+// its default from past the values the table already holds, so that new
+// rows do not collide with existing ones. Columns whose default is not a
+// sequence, or renderers not aligning sequences, need none.
+func (r *PostgresRenderer) AlignSequence(table string, column *schema.Column) []string {
+	match := nextvalPattern.FindStringSubmatch(column.Default.String)
+	if !r.AlignSequences || !column.Default.Valid || match == nil {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("SELECT setval(%s, coalesce(max(%s), 0) + 1, false) FROM %s;", match[1], postgresQuoted(column.Name), postgresQuoted(table))}
 }
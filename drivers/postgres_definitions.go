@@ -0,0 +1,326 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// GetAggregates reads the user-defined aggregates of the schema, leaving
+// out the ones created by extensions, which the extension manages.
+func (d *PostgresDriver) GetAggregates(ctx context.Context, db *sql.DB) ([]*schema.Aggregate, error) {
+	rows, err := d.query(ctx, db, `
+		SELECT quote_ident(p.proname), pg_get_function_identity_arguments(p.oid), a.aggkind,
+			a.aggtransfn::text, format_type(a.aggtranstype, NULL), a.aggtransspace,
+			a.aggfinalfn::text, a.aggfinalextra, a.aggcombinefn::text, a.aggserialfn::text, a.aggdeserialfn::text,
+			quote_literal(a.agginitval), a.aggmtransfn::text, a.aggminvtransfn::text, coalesce(format_type(nullif(a.aggmtranstype, 0), NULL), ''),
+			a.aggmtransspace, a.aggmfinalfn::text, a.aggmfinalextra, quote_literal(a.aggminitval),
+			coalesce(o.oprname, ''), p.proparallel
+		FROM pg_aggregate a
+		JOIN pg_proc p ON p.oid = a.aggfnoid
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		LEFT JOIN pg_operator o ON o.oid = a.aggsortop
+		WHERE n.nspname = coalesce(nullif($1::text, ''), current_schema())
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_depend dep
+			WHERE dep.classid = 'pg_proc'::regclass AND dep.objid = p.oid AND dep.deptype = 'e'
+		)
+		ORDER BY p.proname, p.oid
+	`, d.schemaFilter(db))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aggregates []*schema.Aggregate
+	for rows.Next() {
+		var name, arguments, kind, transition, stateType, final, combine, serial, deserial string
+		var movingTransition, movingInverse, movingStateType, movingFinal, sortOperator, parallel string
+		var stateSpace, movingStateSpace int64
+		var finalExtra, movingFinalExtra bool
+		var initial, movingInitial sql.NullString
+
+		err := rows.Scan(
+			&name, &arguments, &kind,
+			&transition, &stateType, &stateSpace,
+			&final, &finalExtra, &combine, &serial, &deserial,
+			&initial, &movingTransition, &movingInverse, &movingStateType,
+			&movingStateSpace, &movingFinal, &movingFinalExtra, &movingInitial,
+			&sortOperator, &parallel,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Aggregates without arguments, such as count(*), are spelled with a
+		// star
+		if arguments == "" {
+			arguments = "*"
+		}
+
+		options := []string{"SFUNC = " + transition, "STYPE = " + stateType}
+		if stateSpace > 0 {
+			options = append(options, fmt.Sprintf("SSPACE = %d", stateSpace))
+		}
+		options = appendRegproc(options, "FINALFUNC", final)
+		if finalExtra {
+			options = append(options, "FINALFUNC_EXTRA")
+		}
+		options = appendRegproc(options, "COMBINEFUNC", combine)
+		options = appendRegproc(options, "SERIALFUNC", serial)
+		options = appendRegproc(options, "DESERIALFUNC", deserial)
+		if initial.Valid {
+			options = append(options, "INITCOND = "+initial.String)
+		}
+		options = appendRegproc(options, "MSFUNC", movingTransition)
+		options = appendRegproc(options, "MINVFUNC", movingInverse)
+		if movingStateType != "" {
+			options = append(options, "MSTYPE = "+movingStateType)
+		}
+		if movingStateSpace > 0 {
+			options = append(options, fmt.Sprintf("MSSPACE = %d", movingStateSpace))
+		}
+		options = appendRegproc(options, "MFINALFUNC", movingFinal)
+		if movingFinalExtra {
+			options = append(options, "MFINALFUNC_EXTRA")
+		}
+		if movingInitial.Valid {
+			options = append(options, "MINITCOND = "+movingInitial.String)
+		}
+		if sortOperator != "" {
+			options = append(options, "SORTOP = "+sortOperator)
+		}
+		switch parallel {
+		case "s":
+			options = append(options, "PARALLEL = SAFE")
+		case "r":
+			options = append(options, "PARALLEL = RESTRICTED")
+		}
+		if kind == "h" {
+			options = append(options, "HYPOTHETICAL")
+		}
+
+		signature := name + "(" + arguments + ")"
+		aggregates = append(aggregates, &schema.Aggregate{
+			Name: signature,
+			Def:  "CREATE AGGREGATE " + name + " (" + arguments + ") (" + strings.Join(options, ", ") + ")",
+		})
+	}
+
+	return aggregates, rows.Err()
+}
+
+// GetOperators reads the user-defined operators of the schema, leaving out
+// the ones created by extensions and the shells standing for operators only
+// referenced as the commutator or negator of others so far.
+func (d *PostgresDriver) GetOperators(ctx context.Context, db *sql.DB) ([]*schema.Operator, error) {
+	rows, err := d.query(ctx, db, `
+		SELECT o.oprname, coalesce(format_type(nullif(o.oprleft, 0), NULL), 'NONE'), format_type(o.oprright, NULL),
+			o.oprcode::text, coalesce(com.oprname, ''), coalesce(neg.oprname, ''),
+			o.oprrest::text, o.oprjoin::text, o.oprcanhash, o.oprcanmerge
+		FROM pg_operator o
+		JOIN pg_namespace n ON n.oid = o.oprnamespace
+		LEFT JOIN pg_operator com ON com.oid = o.oprcom
+		LEFT JOIN pg_operator neg ON neg.oid = o.oprnegate
+		WHERE n.nspname = coalesce(nullif($1::text, ''), current_schema())
+		AND o.oprcode <> 0
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_depend dep
+			WHERE dep.classid = 'pg_operator'::regclass AND dep.objid = o.oid AND dep.deptype = 'e'
+		)
+		ORDER BY o.oprname, o.oid
+	`, d.schemaFilter(db))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var operators []*schema.Operator
+	for rows.Next() {
+		var name, left, right, function, commutator, negator, restrict, join string
+		var hashes, merges bool
+
+		err := rows.Scan(&name, &left, &right, &function, &commutator, &negator, &restrict, &join, &hashes, &merges)
+		if err != nil {
+			return nil, err
+		}
+
+		options := []string{"FUNCTION = " + function}
+		if left != "NONE" {
+			options = append(options, "LEFTARG = "+left)
+		}
+		options = append(options, "RIGHTARG = "+right)
+		if commutator != "" {
+			options = append(options, "COMMUTATOR = "+commutator)
+		}
+		if negator != "" {
+			options = append(options, "NEGATOR = "+negator)
+		}
+		options = appendRegproc(options, "RESTRICT", restrict)
+		options = appendRegproc(options, "JOIN", join)
+		if hashes {
+			options = append(options, "HASHES")
+		}
+		if merges {
+			options = append(options, "MERGES")
+		}
+
+		operators = append(operators, &schema.Operator{
+			Name: name + "(" + left + ", " + right + ")",
+			Def:  "CREATE OPERATOR " + name + " (" + strings.Join(options, ", ") + ")",
+		})
+	}
+
+	return operators, rows.Err()
+}
+
+// GetCasts reads the user-defined casts involving the schema, through their
+// source type, target type or function, leaving out the ones created by
+// extensions. Casts belong to the whole database rather than to a schema.
+func (d *PostgresDriver) GetCasts(ctx context.Context, db *sql.DB) ([]*schema.Cast, error) {
+	rows, err := d.query(ctx, db, `
+		SELECT format_type(c.castsource, NULL), format_type(c.casttarget, NULL), c.castmethod, c.castcontext,
+			CASE WHEN c.castfunc = 0 THEN '' ELSE c.castfunc::regprocedure::text END
+		FROM pg_cast c
+		JOIN pg_type s ON s.oid = c.castsource
+		JOIN pg_type t ON t.oid = c.casttarget
+		LEFT JOIN pg_proc p ON p.oid = c.castfunc
+		WHERE c.oid >= 16384
+		AND (SELECT oid FROM pg_namespace WHERE nspname = coalesce(nullif($1::text, ''), current_schema())) IN (s.typnamespace, t.typnamespace, p.pronamespace)
+		AND NOT EXISTS (
+			SELECT 1 FROM pg_depend dep
+			WHERE dep.classid = 'pg_cast'::regclass AND dep.objid = c.oid AND dep.deptype = 'e'
+		)
+		ORDER BY 1, 2
+	`, d.schemaFilter(db))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var casts []*schema.Cast
+	for rows.Next() {
+		var source, target, method, castContext, function string
+
+		err := rows.Scan(&source, &target, &method, &castContext, &function)
+		if err != nil {
+			return nil, err
+		}
+
+		name := "(" + source + " AS " + target + ")"
+		def := "CREATE CAST " + name
+		switch method {
+		case "f":
+			def += " WITH FUNCTION " + function
+		case "i":
+			def += " WITH INOUT"
+		default:
+			def += " WITHOUT FUNCTION"
+		}
+		switch castContext {
+		case "a":
+			def += " AS ASSIGNMENT"
+		case "i":
+			def += " AS IMPLICIT"
+		}
+
+		casts = append(casts, &schema.Cast{Name: name, Def: def})
+	}
+
+	return casts, rows.Err()
+}
+
+// appendRegproc appends option set to the function named value, as printed
+// by a regproc column, unless it is - for none.
+func appendRegproc(options []string, option string, value string) []string {
+	if value == "" || value == "-" {
+		return options
+	}
+	return append(options, option+" = "+value)
+}
+
+// RenderAggregates returns the statements migrating aggregates. Modified
+// ones are dropped and created again, as CREATE OR REPLACE AGGREGATE cannot
+// change their state type.
+func (r *PostgresRenderer) RenderAggregates(changes []*schema.Change[*schema.Aggregate]) []string {
+	return renderDefinitions(changes, "AGGREGATE", func(a *schema.Aggregate) (string, string) { return a.Name, a.Def })
+}
+
+// RenderOperators returns the statements migrating operators, dropping and
+// creating again the modified ones.
+func (r *PostgresRenderer) RenderOperators(changes []*schema.Change[*schema.Operator]) []string {
+	return renderDefinitions(changes, "OPERATOR", func(o *schema.Operator) (string, string) { return o.Name, o.Def })
+}
+
+// RenderCasts returns the statements migrating casts, dropping and creating
+// again the modified ones.
+func (r *PostgresRenderer) RenderCasts(changes []*schema.Change[*schema.Cast]) []string {
+	return renderDefinitions(changes, "CAST", func(c *schema.Cast) (string, string) { return c.Name, c.Def })
+}
+
+// renderDefinitions returns the statements migrating objects compared by
+// their definition alone, keyword being the one DROP statements name their
+// kind with.
+func renderDefinitions[T any](changes []*schema.Change[T], keyword string, definition func(T) (name string, def string)) []string {
+	var statements []string
+
+	for _, change := range changes {
+		if change.Kind != schema.Added {
+			name, _ := definition(change.Target)
+			statements = append(statements, fmt.Sprintf("DROP %s %s;", keyword, name))
+		}
+		if change.Kind != schema.Removed {
+			_, def := definition(change.Source)
+			statements = append(statements, def+";")
+		}
+	}
+
+	return statements
+}
+
+// emitDefinitions emits the aggregates, operators and casts of diff that are
+// removed, or the ones that are not: operators first, as aggregates may sort
+// with them, and in reverse order when removed.
+func (r *PostgresRenderer) emitDefinitions(emit ObjectEmitFunc, diff *schema.Diff, removed bool) error {
+	emits := []func() error{
+		func() error {
+			return emitDefinitionChanges(emit, schema.OperatorObject, diff.Operators, removed, func(o *schema.Operator) string { return o.Name }, r.RenderOperators, schema.DescribeOperator, r.Annotate)
+		},
+		func() error {
+			return emitDefinitionChanges(emit, schema.AggregateObject, diff.Aggregates, removed, func(a *schema.Aggregate) string { return a.Name }, r.RenderAggregates, schema.DescribeAggregate, r.Annotate)
+		},
+		func() error {
+			return emitDefinitionChanges(emit, schema.CastObject, diff.Casts, removed, func(c *schema.Cast) string { return c.Name }, r.RenderCasts, schema.DescribeCast, r.Annotate)
+		},
+	}
+	if removed {
+		slices.Reverse(emits)
+	}
+
+	for _, emitChanges := range emits {
+		err := emitChanges()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func emitDefinitionChanges[T any](emit ObjectEmitFunc, objectType schema.ObjectType, changes []*schema.Change[T], removed bool, name func(T) string, render func([]*schema.Change[T]) []string, describe func(*schema.Change[T]) string, annotated bool) error {
+	for _, change := range changes {
+		if (change.Kind == schema.Removed) != removed {
+			continue
+		}
+
+		statements := annotate(annotated, render([]*schema.Change[T]{change}), describe(change))
+		err := emit(objectType, changeName(change, name), changeClass(change), statements...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
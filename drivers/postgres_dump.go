@@ -0,0 +1,394 @@
+package drivers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// IntrospectionMode selects how the Postgres driver reads schemas.
+type IntrospectionMode string
+
+const (
+	// CatalogIntrospection queries information_schema and the system
+	// catalogs. It is the default.
+	CatalogIntrospection IntrospectionMode = "catalog"
+
+	// PgDumpIntrospection parses the output of pg_dump --schema-only
+	// instead of querying the catalogs.
+	PgDumpIntrospection IntrospectionMode = "pg_dump"
+)
+
+// ParseIntrospectionMode validates an introspection mode name, empty meaning
+// CatalogIntrospection.
+func ParseIntrospectionMode(name string) (IntrospectionMode, error) {
+	switch mode := IntrospectionMode(name); mode {
+	case "":
+		return CatalogIntrospection, nil
+	case CatalogIntrospection, PgDumpIntrospection:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown introspection mode %q, expected catalog or pg_dump", name)
+}
+
+// GetDumpedSchema reads the schema of db from the output of pg_dump, found
+// in PATH, connecting with the connection string db was opened with.
+func (d *PostgresDriver) GetDumpedSchema(ctx context.Context, db *sql.DB) (*schema.Schema, error) {
+	schemaName := d.schemaFilter(db)
+	if schemaName == "" {
+		err := d.queryRow(ctx, db, "SELECT current_schema()").Scan(&schemaName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	side := d.sideOf(db)
+	d.progress.report(IntrospectionPhase, side, 0, 0)
+
+	dump, err := d.dump(ctx, side, schemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := parsePostgresDump(dump)
+	if err != nil {
+		return nil, err
+	}
+
+	d.progress.report(IntrospectionPhase, side, len(s.Tables), len(s.Tables))
+	return s, nil
+}
+
+// dump runs pg_dump against the database of side, restricted to schemaName
+// or to the tables of d.Tables. The password goes through PGPASSWORD rather
+// than pg_dump's command line.
+func (d *PostgresDriver) dump(ctx context.Context, side Side, schemaName string) (string, error) {
+	connectionString, password, err := withoutPassword(d.connectionStrings[side])
+	if err != nil {
+		return "", err
+	}
+	if passwordFunc := d.passwords[side]; passwordFunc != nil {
+		password, err = passwordFunc(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to get database password: %w", err)
+		}
+	}
+
+	args := []string{"--schema-only", "--no-owner", "--no-privileges", "--dbname=" + connectionString}
+	if len(d.Tables) == 0 {
+		args = append(args, "--schema="+dumpPattern(schemaName))
+	}
+	for _, table := range d.Tables {
+		args = append(args, "--table="+dumpPattern(schemaName)+"."+dumpPattern(table))
+	}
+
+	cmd := exec.CommandContext(ctx, "pg_dump", args...)
+	cmd.Env = os.Environ()
+	if password != "" {
+		cmd.Env = append(cmd.Env, "PGPASSWORD="+password)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	d.Logger.DebugContext(ctx, "running pg_dump", "side", side, "schema", schemaName)
+	err = cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("pg_dump failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// dumpPattern quotes a name for pg_dump's --schema and --table options,
+// which otherwise treat it as a case-folded pattern.
+func dumpPattern(name string) string {
+	return postgresQuoted(name)
+}
+
+// passwordParam matches the password of keyword/value connection strings.
+var passwordParam = regexp.MustCompile(`(?:^|\s)password\s*=\s*(?:'(?:[^'\\]|\\.)*'|\S+)`)
+
+// withoutPassword removes the password from a connection string, in either
+// URL or keyword/value form, and returns it separately.
+func withoutPassword(connectionString string) (string, string, error) {
+	config, err := pgconn.ParseConfig(connectionString)
+	if err != nil {
+		return "", "", err
+	}
+	if config.Password == "" {
+		return connectionString, "", nil
+	}
+
+	if strings.HasPrefix(connectionString, "postgres://") || strings.HasPrefix(connectionString, "postgresql://") {
+		u, err := url.Parse(connectionString)
+		if err != nil {
+			return "", "", err
+		}
+
+		query := u.Query()
+		query.Del("password")
+		u.RawQuery = query.Encode()
+		if u.User != nil {
+			u.User = url.User(u.User.Username())
+		}
+		return u.String(), config.Password, nil
+	}
+
+	return strings.TrimSpace(passwordParam.ReplaceAllString(connectionString, "")), config.Password, nil
+}
+
+var (
+	dumpCreateTable     = regexp.MustCompile(`(?is)^CREATE\s+(?:UNLOGGED\s+)?TABLE\s+` + statementIdentifier + `\s*\((.*)\)`)
+	dumpAddConstraint   = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:ONLY\s+)?` + statementIdentifier + `\s+ADD\s+CONSTRAINT\s+(` + postgresName + `)\s+(.*)$`)
+	dumpSetDefault      = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:ONLY\s+)?` + statementIdentifier + `\s+ALTER\s+COLUMN\s+(` + postgresName + `)\s+SET\s+DEFAULT\s+(.*)$`)
+	dumpSequenceOwner   = regexp.MustCompile(`(?is)^ALTER\s+SEQUENCE\s+` + statementIdentifier + `\s+OWNED\s+BY\s+(.+)$`)
+	dumpCreateIndex     = regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(` + postgresName + `)\s+ON\s+(?:ONLY\s+)?` + statementIdentifier)
+	dumpCreateTrigger   = regexp.MustCompile(`(?is)^CREATE\s+(?:CONSTRAINT\s+)?TRIGGER\s+(` + postgresName + `)\s.*?\sON\s+` + statementIdentifier)
+	dumpCreateView      = regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?VIEW\s+` + statementIdentifier + `(?:\s+WITH\s*\([^)]*\))?\s+AS\s+(.*)$`)
+	dumpConstraintName  = regexp.MustCompile(`(?is)^CONSTRAINT\s+(` + postgresName + `)\s+(.*)$`)
+	dumpColumnName      = regexp.MustCompile(`^` + postgresName)
+	dumpColumnNotNull   = regexp.MustCompile(`(?i)\s+NOT\s+NULL$`)
+	dumpColumnGenerated = regexp.MustCompile(`(?is)\s+GENERATED\s+.*$`)
+	dumpColumnDefault   = regexp.MustCompile(`(?is)\s+DEFAULT\s+(.*)$`)
+	dumpColumnCollate   = regexp.MustCompile(`(?is)\s+COLLATE\s+\S+$`)
+)
+
+// parsePostgresDump builds a schema from the statements of a pg_dump
+// --schema-only output. Objects it cannot model, such as functions, types
+// and sequences, are ignored as they are by catalog introspection.
+func parsePostgresDump(dump string) (*schema.Schema, error) {
+	s := &schema.Schema{Dialect: "postgres"}
+
+	for _, statement := range splitDumpStatements(dump) {
+		switch {
+		case dumpCreateTable.MatchString(statement):
+			match := dumpCreateTable.FindStringSubmatch(statement)
+			table := &schema.Table{Name: unqualifiedName(match[1])}
+			for _, element := range splitTopLevel(match[2], ',') {
+				if constraint := dumpConstraintName.FindStringSubmatch(element); constraint != nil {
+					table.Constraints = append(table.Constraints, dumpConstraint(constraint[1], constraint[2]))
+					continue
+				}
+				table.Columns = append(table.Columns, dumpColumn(element))
+			}
+			s.Tables = append(s.Tables, table)
+
+		case dumpAddConstraint.MatchString(statement):
+			match := dumpAddConstraint.FindStringSubmatch(statement)
+			table, found := s.TableByName(unqualifiedName(match[1]))
+			if !found {
+				continue
+			}
+			table.Constraints = append(table.Constraints, dumpConstraint(match[2], match[3]))
+
+		case dumpSetDefault.MatchString(statement):
+			match := dumpSetDefault.FindStringSubmatch(statement)
+			table, found := s.TableByName(unqualifiedName(match[1]))
+			if !found {
+				continue
+			}
+			if column, found := table.ColumnByName(unqualifiedName(match[2])); found {
+				column.Default = sql.NullString{String: strings.TrimSpace(match[3]), Valid: true}
+			}
+
+		case dumpSequenceOwner.MatchString(statement):
+			match := dumpSequenceOwner.FindStringSubmatch(statement)
+			// Owners are spelled schema.table.column, NONE for no owner
+			parts := namePart.FindAllString(strings.TrimSpace(match[2]), -1)
+			if len(parts) < 2 {
+				continue
+			}
+			table, found := s.TableByName(unqualifiedName(parts[len(parts)-2]))
+			if !found {
+				continue
+			}
+			if column, found := table.ColumnByName(unqualifiedName(parts[len(parts)-1])); found {
+				column.Sequence = unqualifiedName(match[1])
+			}
+
+		case dumpCreateIndex.MatchString(statement):
+			match := dumpCreateIndex.FindStringSubmatch(statement)
+			table, found := s.TableByName(unqualifiedName(match[2]))
+			if !found {
+				continue
+			}
+			index := &schema.Index{Table: table.Name, Name: unqualifiedName(match[1]), Def: statement}
+			parsePostgresIndex(index)
+			table.Indexes = append(table.Indexes, index)
+
+		case dumpCreateTrigger.MatchString(statement):
+			match := dumpCreateTrigger.FindStringSubmatch(statement)
+			trigger := &schema.Trigger{Name: unqualifiedName(match[1]), Def: statement}
+			if table, found := s.TableByName(unqualifiedName(match[2])); found {
+				table.Triggers = append(table.Triggers, trigger)
+			} else if view, found := s.ViewByName(unqualifiedName(match[2])); found {
+				view.Triggers = append(view.Triggers, trigger)
+			}
+
+		case dumpCreateView.MatchString(statement):
+			match := dumpCreateView.FindStringSubmatch(statement)
+			s.Views = append(s.Views, &schema.View{Name: unqualifiedName(match[1]), Def: " " + strings.TrimSpace(match[2]) + ";"})
+		}
+	}
+
+	return s, nil
+}
+
+// dumpColumn parses a column definition of a CREATE TABLE statement, such as
+// `name character varying(255) DEFAULT 'x'::character varying NOT NULL`.
+// Collations and generation expressions are left out, as they are by
+// catalog introspection.
+func dumpColumn(definition string) *schema.Column {
+	name := dumpColumnName.FindString(definition)
+	column := &schema.Column{Name: unqualifiedName(name)}
+	rest := strings.TrimSpace(definition[len(name):])
+
+	if match := dumpColumnNotNull.FindStringIndex(rest); match != nil {
+		column.NotNull = true
+		rest = rest[:match[0]]
+	}
+
+	rest = dumpColumnGenerated.ReplaceAllString(rest, "")
+
+	if match := dumpColumnDefault.FindStringSubmatchIndex(rest); match != nil {
+		column.Default = sql.NullString{String: rest[match[2]:match[3]], Valid: true}
+		rest = rest[:match[0]]
+	}
+
+	column.Type = strings.TrimSpace(dumpColumnCollate.ReplaceAllString(rest, ""))
+	return column
+}
+
+// dumpConstraint builds a constraint from its name and definition, telling
+// its type from the definition as pg_constraint.contype would.
+func dumpConstraint(name string, definition string) *schema.Constraint {
+	definition = strings.TrimSpace(definition)
+
+	constraintType := "c"
+	upper := strings.ToUpper(definition)
+	switch {
+	case strings.HasPrefix(upper, "PRIMARY KEY"):
+		constraintType = "p"
+	case strings.HasPrefix(upper, "UNIQUE"):
+		constraintType = "u"
+	case strings.HasPrefix(upper, "FOREIGN KEY"):
+		constraintType = "f"
+	case strings.HasPrefix(upper, "EXCLUDE"):
+		constraintType = "x"
+	}
+
+	return &schema.Constraint{Name: unqualifiedName(name), Type: constraintType, Def: definition}
+}
+
+// splitDumpStatements splits SQL into statements, without their trailing
+// semicolon, leaving out comments and semicolons within quotes and dollar
+// quoted bodies.
+func splitDumpStatements(dump string) []string {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(dump); i++ {
+		switch c := dump[i]; {
+		case c == '-' && strings.HasPrefix(dump[i:], "--"):
+			end := strings.IndexByte(dump[i:], '\n')
+			if end < 0 {
+				i = len(dump)
+			} else {
+				i += end
+				current.WriteByte('\n')
+			}
+
+		case c == '\'' || c == '"':
+			end := quotedEnd(dump, i, c)
+			current.WriteString(dump[i:end])
+			i = end - 1
+
+		case c == '$':
+			tag := dollarTag.FindString(dump[i:])
+			if tag == "" {
+				current.WriteByte(c)
+				continue
+			}
+			end := strings.Index(dump[i+len(tag):], tag)
+			if end < 0 {
+				end = len(dump) - i - len(tag)
+			} else {
+				end += len(tag)
+			}
+			current.WriteString(dump[i : i+len(tag)+end])
+			i += len(tag) + end - 1
+
+		case c == ';':
+			flush()
+
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	return statements
+}
+
+var dollarTag = regexp.MustCompile(`^\$(?:[A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// quotedEnd returns the index following the quoted string or identifier
+// starting at start, quotes being escaped by doubling them.
+func quotedEnd(s string, start int, quote byte) int {
+	for i := start + 1; i < len(s); i++ {
+		if s[i] != quote {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(s)
+}
+
+// splitTopLevel splits s on sep outside of parentheses and quotes, trimming
+// each part.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'', '"':
+			i = quotedEnd(s, i, c) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if part := strings.TrimSpace(s[start:]); part != "" {
+		parts = append(parts, part)
+	}
+
+	return parts
+}
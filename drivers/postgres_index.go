@@ -1,10 +1,194 @@
 package drivers
 
-type PostgresIndex struct {
-	Name string
-	Def  string
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// postgresIndexHead matches index definitions, as written by
+// pg_get_indexdef, up to the parenthesis opening their keys.
+var postgresIndexHead = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?` + postgresName + `\s+ON\s+(?:ONLY\s+)?` + statementIdentifier + `\s+(?:USING\s+(` + postgresName + `)\s*)?\(`)
+
+// postgresKeyOrdering matches the words ending index keys, after their
+// operator class.
+var postgresKeyOrdering = regexp.MustCompile(`(?i)^(?:ASC|DESC|NULLS|FIRST|LAST)$`)
+
+// postgresNullsNotDistinct matches the clause of unique indexes treating
+// NULL values as equal.
+var postgresNullsNotDistinct = regexp.MustCompile(`(?i)^NULLS\s+NOT\s+DISTINCT\b`)
+
+// parsePostgresIndex fills the structure of index from its definition: the
+// access method, the keys and their operator classes, the INCLUDE columns,
+// the storage parameters and the predicate. Definitions it cannot parse
+// leave index as is, to be compared by definition.
+func parsePostgresIndex(index *schema.Index) {
+	head := postgresIndexHead.FindStringSubmatch(index.Def)
+	if head == nil {
+		return
+	}
+
+	keys, rest, ok := parenthesized(index.Def, len(head[0])-1)
+	if !ok {
+		return
+	}
+
+	index.Unique = head[1] != ""
+	index.Method = "btree"
+	if head[3] != "" {
+		index.Method = strings.ToLower(unqualifiedName(head[3]))
+	}
+	index.Columns, index.OperatorClasses = nil, nil
+
+	hasOperatorClass := false
+	for _, key := range splitTopLevel(keys, ',') {
+		column, operatorClass := splitIndexKey(key)
+		index.Columns = append(index.Columns, column)
+		index.OperatorClasses = append(index.OperatorClasses, operatorClass)
+		hasOperatorClass = hasOperatorClass || operatorClass != ""
+	}
+	if !hasOperatorClass {
+		index.OperatorClasses = nil
+	}
+
+	index.Include, index.Options, index.NullsNotDistinct, index.Where = nil, nil, false, ""
+	for rest = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), ";")); rest != ""; rest = strings.TrimSpace(rest) {
+		word, after, _ := strings.Cut(rest, " ")
+		switch strings.ToUpper(word) {
+		case "INCLUDE", "WITH":
+			list, remaining, ok := parenthesized(after, strings.IndexByte(after, '('))
+			if !ok {
+				return
+			}
+			if strings.EqualFold(word, "INCLUDE") {
+				index.Include = splitTopLevel(list, ',')
+			} else {
+				for _, option := range splitTopLevel(list, ',') {
+					name, value, _ := strings.Cut(option, "=")
+					index.Options = append(index.Options, strings.ToLower(strings.TrimSpace(name))+"="+strings.Trim(strings.TrimSpace(value), "'"))
+				}
+				slices.Sort(index.Options)
+			}
+			rest = remaining
+		case "NULLS":
+			match := postgresNullsNotDistinct.FindString(rest)
+			if match == "" {
+				return
+			}
+			index.NullsNotDistinct = true
+			rest = rest[len(match):]
+		case "TABLESPACE":
+			// Where the index is stored does not change what it holds
+			_, rest, _ = strings.Cut(strings.TrimSpace(after), " ")
+		case "WHERE":
+			index.Where = strings.TrimSpace(after)
+			rest = ""
+		default:
+			return
+		}
+	}
+}
+
+// splitIndexKey splits an index key into the key itself, a column or an
+// expression with its collation and ordering, and its operator class.
+func splitIndexKey(key string) (column string, operatorClass string) {
+	var words []string
+	for _, word := range splitTopLevel(key, ' ') {
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+
+	column = words[0]
+	i := 1
+	if i+1 < len(words) && strings.EqualFold(words[i], "COLLATE") {
+		column += " COLLATE " + words[i+1]
+		i += 2
+	}
+	if i < len(words) && !postgresKeyOrdering.MatchString(words[i]) {
+		operatorClass = words[i]
+		i++
+	}
+	for _, word := range words[i:] {
+		column += " " + strings.ToUpper(word)
+	}
+	return column, operatorClass
+}
+
+// parenthesized returns the text between the parenthesis at open and the
+// one closing it, along with the text following it.
+func parenthesized(s string, open int) (inside string, rest string, ok bool) {
+	if open < 0 || open >= len(s) || s[open] != '(' {
+		return "", "", false
+	}
+
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'', '"':
+			i = quotedEnd(s, i, c) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s[open+1 : i], s[i+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// IndexDefinition returns the statement creating index, without its
+// trailing semicolon. Indexes only described by their structure, such as in
+// hand-written snapshots, get one written from it.
+func (r *PostgresRenderer) IndexDefinition(index *schema.Index) string {
+	if index.Def != "" {
+		return index.Def
+	}
+
+	keys := make([]string, len(index.Columns))
+	for i, column := range index.Columns {
+		keys[i] = column
+		if i < len(index.OperatorClasses) && index.OperatorClasses[i] != "" {
+			keys[i] = withOperatorClass(column, index.OperatorClasses[i])
+		}
+	}
+
+	def := "CREATE "
+	if index.Unique {
+		def += "UNIQUE "
+	}
+	def += fmt.Sprintf("INDEX %s ON %s", postgresQuoted(index.Name), postgresQuoted(index.Table))
+	if index.Method != "" {
+		def += " USING " + index.Method
+	}
+	def += " (" + strings.Join(keys, ", ") + ")"
+	if len(index.Include) > 0 {
+		def += " INCLUDE (" + strings.Join(index.Include, ", ") + ")"
+	}
+	if index.NullsNotDistinct {
+		def += " NULLS NOT DISTINCT"
+	}
+	if len(index.Options) > 0 {
+		def += " WITH (" + strings.Join(index.Options, ", ") + ")"
+	}
+	if index.Where != "" {
+		def += " WHERE " + index.Where
+	}
+	return def
 }
 
-func (i *PostgresIndex) String() string {
-	return i.Def + ";"
+// withOperatorClass inserts operatorClass in an index key, after its
+// collation and before its ordering.
+func withOperatorClass(key string, operatorClass string) string {
+	words := splitTopLevel(key, ' ')
+	i := 1
+	if i+1 < len(words) && strings.EqualFold(words[i], "COLLATE") {
+		i += 2
+	}
+	return strings.Join(slices.Concat(words[:i], []string{operatorClass}, words[i:]), " ")
 }
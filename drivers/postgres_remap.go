@@ -0,0 +1,108 @@
+package drivers
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+var postgresBareIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_$]*$`)
+
+// postgresIdentifier spells name the way Postgres prints it in definitions:
+// as is when it needs no quoting, quoted otherwise.
+func postgresIdentifier(name string) string {
+	if postgresBareIdentifier.MatchString(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// remapPostgresSchema returns a copy of s in which the references qualified
+// with the from schema, in definitions and defaults, are qualified with the
+// to schema instead. Postgres qualifies the tables of index definitions, and
+// any reference outside the search path, so that the same objects in two
+// schemas would otherwise never compare equal.
+func remapPostgresSchema(s *schema.Schema, from, to string) *schema.Schema {
+	qualifier := regexp.MustCompile(`(^|[^\w"$.])` + regexp.QuoteMeta(postgresIdentifier(from)) + `\.`)
+	replacement := "${1}" + strings.ReplaceAll(postgresIdentifier(to), "$", "$$") + "."
+	remap := func(def string) string {
+		return qualifier.ReplaceAllString(def, replacement)
+	}
+
+	remapped := &schema.Schema{Dialect: s.Dialect}
+
+	for _, table := range s.Tables {
+		table = table.Copy()
+
+		columns := table.Columns
+		table.Columns = nil
+		for _, column := range columns {
+			column = column.Copy()
+			column.Type = remap(column.Type)
+			if column.Default.Valid {
+				column.Default.String = remap(column.Default.String)
+			}
+			table.Columns = append(table.Columns, column)
+		}
+
+		constraints := table.Constraints
+		table.Constraints = nil
+		for _, constraint := range constraints {
+			constraint := *constraint
+			constraint.Def = remap(constraint.Def)
+			table.Constraints = append(table.Constraints, &constraint)
+		}
+
+		indexes := table.Indexes
+		table.Indexes = nil
+		for _, index := range indexes {
+			index := *index
+			index.Def = remap(index.Def)
+			index.Columns = slices.Clone(index.Columns)
+			for i, column := range index.Columns {
+				index.Columns[i] = remap(column)
+			}
+			index.Where = remap(index.Where)
+			table.Indexes = append(table.Indexes, &index)
+		}
+
+		triggers := table.Triggers
+		table.Triggers = nil
+		for _, trigger := range triggers {
+			trigger := *trigger
+			trigger.Def = remap(trigger.Def)
+			table.Triggers = append(table.Triggers, &trigger)
+		}
+
+		remapped.Tables = append(remapped.Tables, table)
+	}
+
+	for _, view := range s.Views {
+		view := *view
+		view.Def = remap(view.Def)
+
+		triggers := view.Triggers
+		view.Triggers = nil
+		for _, trigger := range triggers {
+			trigger := *trigger
+			trigger.Def = remap(trigger.Def)
+			view.Triggers = append(view.Triggers, &trigger)
+		}
+
+		remapped.Views = append(remapped.Views, &view)
+	}
+
+	for _, aggregate := range s.Aggregates {
+		remapped.Aggregates = append(remapped.Aggregates, &schema.Aggregate{Name: remap(aggregate.Name), Def: remap(aggregate.Def)})
+	}
+	for _, operator := range s.Operators {
+		remapped.Operators = append(remapped.Operators, &schema.Operator{Name: remap(operator.Name), Def: remap(operator.Def)})
+	}
+	for _, cast := range s.Casts {
+		remapped.Casts = append(remapped.Casts, &schema.Cast{Name: remap(cast.Name), Def: remap(cast.Def)})
+	}
+
+	return remapped
+}
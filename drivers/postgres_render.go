@@ -0,0 +1,236 @@
+package drivers
+
+import (
+	"fmt"
+	"iter"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// PostgresRenderer renders schema diffs as Postgres statements.
+type PostgresRenderer struct {
+	// LockTimeout and StatementTimeout, when set, are applied at the start
+	// of the plan so that DDL waiting on locks fails fast instead of
+	// blocking the queries queued behind it.
+	LockTimeout      time.Duration
+	StatementTimeout time.Duration
+
+	// Annotate prefixes statements with a comment explaining why they were
+	// generated.
+	Annotate bool
+
+	// Online migrates existing tables with statements avoiding long
+	// exclusive locks, see WithOnlineDDL.
+	Online bool
+
+	// DeferValidation adds foreign key and check constraints NOT VALID and
+	// validates them once the rest of the diff is rendered, see
+	// WithDeferredValidation.
+	DeferValidation bool
+
+	// BackfillBatchSize backfills NOT NULL columns added to existing tables
+	// in batches, see WithBackfillBatchSize.
+	BackfillBatchSize int
+
+	// AlignSequences moves sequences past the values of the columns they
+	// become the default of, see WithSequenceAlignment.
+	AlignSequences bool
+}
+
+func (r *PostgresRenderer) Render(diff *schema.Diff) iter.Seq2[string, error] {
+	return flattenObjects(r.RenderObjects(diff))
+}
+
+// RenderObjects renders the session settings, when any, as a settings
+// object preceding the others.
+func (r *PostgresRenderer) RenderObjects(diff *schema.Diff) iter.Seq2[*ObjectStatements, error] {
+	return emitObjects(func(emit ObjectEmitFunc) error {
+		if !diff.IsEmpty() {
+			err := emit(SettingsObject, "session", "", annotate(r.Annotate, r.SessionSettings(), "bound how long the migration waits on locks and runs")...)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Tables may use the operators, aggregates and casts in their
+		// defaults, checks and indexes, so they come first and go last
+		err := r.emitDefinitions(emit, diff, false)
+		if err != nil {
+			return err
+		}
+
+		for _, tableDiff := range diff.Tables {
+			for _, part := range splitTableDiff(tableDiff) {
+				err := emit(part.Type, part.Name, tableClass(part.TableDiff, false), annotate(r.Annotate, r.RenderTable(part.TableDiff), part.TableDiff.Describe()...)...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, change := range diff.Views {
+			statements := annotate(r.Annotate, r.RenderViews([]*schema.Change[*schema.View]{change}), schema.DescribeView(change))
+			err := emit(schema.ViewObject, changeName(change, viewName), changeClass(change), statements...)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = r.emitDefinitions(emit, diff, true)
+		if err != nil {
+			return err
+		}
+
+		if !r.DeferValidation {
+			return nil
+		}
+		for _, tableDiff := range diff.Tables {
+			statements := annotate(r.Annotate, r.ValidateConstraints(tableDiff), "validate the constraints added NOT VALID to "+tableDiff.Name())
+			if len(statements) == 0 {
+				continue
+			}
+			err := emit(schema.TableObject, tableDiff.Name(), AdditiveClass, statements...)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// SessionSettings returns the SET statements preceding the plan.
+func (r *PostgresRenderer) SessionSettings() []string {
+	var statements []string
+
+	if r.LockTimeout > 0 {
+		statements = append(statements, fmt.Sprintf("SET lock_timeout = '%s';", postgresDuration(r.LockTimeout)))
+	}
+	if r.StatementTimeout > 0 {
+		statements = append(statements, fmt.Sprintf("SET statement_timeout = '%s';", postgresDuration(r.StatementTimeout)))
+	}
+
+	return statements
+}
+
+// postgresDuration formats d in the largest unit Postgres accepts that
+// represents it exactly.
+func postgresDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dmin", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return fmt.Sprintf("%dms", max(d.Milliseconds(), 1))
+}
+
+func (r *PostgresRenderer) CreateView(v *schema.View) string {
+	return "CREATE VIEW " + postgresQuoted(v.Name) + " AS " + v.Def
+}
+
+func (r *PostgresRenderer) RenderViews(changes []*schema.Change[*schema.View]) []string {
+	var statements []string
+
+	for _, change := range changes {
+		switch change.Kind {
+		case schema.Added:
+			statements = append(statements, r.CreateView(change.Source))
+			statements = append(statements, createTriggers(change.Source.Triggers)...)
+		case schema.Modified:
+			// Views whose triggers alone changed are kept, the others are
+			// recreated along with their triggers
+			if change.Source.Def == change.Target.Def {
+				for _, trigger := range change.Target.Triggers {
+					statements = append(statements, fmt.Sprintf("DROP TRIGGER %s ON %s;", postgresQuoted(trigger.Name), postgresQuoted(change.Target.Name)))
+				}
+			} else {
+				statements = append(statements, fmt.Sprintf("DROP VIEW %s;", postgresQuoted(change.Target.Name)))
+				statements = append(statements, r.CreateView(change.Source))
+			}
+			statements = append(statements, createTriggers(change.Source.Triggers)...)
+		case schema.Removed:
+			statements = append(statements, fmt.Sprintf("DROP VIEW %s;", postgresQuoted(change.Target.Name)))
+		}
+	}
+
+	return statements
+}
+
+// postgresAlterTable matches the start of ALTER TABLE statements, up to the
+// altered table.
+const postgresAlterTable = `(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?` + statementIdentifier + `\s+`
+
+// postgresName matches a possibly quoted column or constraint name.
+const postgresName = `(?:"(?:[^"]|"")*"|[^\s"]+)`
+
+// postgresPatternImpact classifies Postgres statements by the locks they
+// take, as documented for each command, and how they go through tables.
+var postgresPatternImpact = classifyByPatterns([]impactPattern{
+	{pattern: regexp.MustCompile(postgresAlterTable + `ALTER\s+(?:COLUMN\s+)?` + postgresName + `\s+(?:SET\s+DATA\s+)?TYPE\s`), rewrite: true, lock: AccessExclusiveLock},
+	{pattern: updatePattern, rewrite: true, lock: "ROW EXCLUSIVE"},
+	{pattern: batchedUpdatePattern, rewrite: true, lock: "ROW EXCLUSIVE"},
+	{pattern: setNotNullPattern, scan: true, lock: AccessExclusiveLock},
+	{pattern: regexp.MustCompile(postgresAlterTable + `ADD\s+(?:CONSTRAINT\s+` + postgresName + `\s+)?FOREIGN\s+KEY`), scan: true, lock: "SHARE ROW EXCLUSIVE"},
+	{pattern: regexp.MustCompile(postgresAlterTable + `ADD\s+(?:CONSTRAINT|CHECK|UNIQUE|PRIMARY)\s`), scan: true, lock: AccessExclusiveLock},
+	{pattern: regexp.MustCompile(postgresAlterTable + `VALIDATE\s+CONSTRAINT\s`), scan: true, lock: "SHARE UPDATE EXCLUSIVE"},
+	{pattern: regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+CONCURRENTLY\s.*?\sON\s+(?:ONLY\s+)?` + statementIdentifier), scan: true, lock: "SHARE UPDATE EXCLUSIVE"},
+	{pattern: createIndexImpactPattern, scan: true, lock: "SHARE"},
+	{pattern: regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:CONSTRAINT\s+)?TRIGGER\s.*?\sON\s+` + statementIdentifier), lock: "SHARE ROW EXCLUSIVE"},
+	{pattern: regexp.MustCompile(`(?is)^DROP\s+TRIGGER\s+(?:IF\s+EXISTS\s+)?` + postgresName + `\s+ON\s+` + statementIdentifier), lock: AccessExclusiveLock},
+	{pattern: regexp.MustCompile(`(?is)^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?` + statementIdentifier), lock: AccessExclusiveLock},
+	{pattern: regexp.MustCompile(postgresAlterTable), lock: AccessExclusiveLock},
+})
+
+// batchedUpdatePattern matches the blocks updating rows in batches, see
+// PostgresRenderer.Backfill.
+var batchedUpdatePattern = regexp.MustCompile(`(?is)^DO\s+\$backfill\$.*?\sUPDATE\s+(?:ONLY\s+)?` + statementIdentifier)
+
+// notValidPattern matches constraints added without checking existing rows.
+var notValidPattern = regexp.MustCompile(`(?i)\sNOT\s+VALID\s*;?\s*$`)
+
+// postgresImpact classifies Postgres statements: changing column types and
+// updating rows rewrite tables, while adding NOT NULL and other constraints,
+// validating them and building indexes read them. Constraints added NOT
+// VALID read nothing, nor does SET NOT NULL once the plan validated a check
+// constraint proving it, as online plans do. Every statement on an existing
+// table reports the lock it takes.
+func postgresImpact(statements []string) []*StatementImpact {
+	impacts := postgresPatternImpact(statements)
+	for i, statement := range statements {
+		if impacts[i] == nil || !impacts[i].Scan {
+			continue
+		}
+
+		if notValidPattern.MatchString(statement) || (setNotNullPattern.MatchString(statement) && slices.ContainsFunc(statements[:i], validatesNotNull(statement))) {
+			impacts[i].Scan = false
+		}
+	}
+	return impacts
+}
+
+var setNotNullPattern = regexp.MustCompile(postgresAlterTable + `ALTER\s+(?:COLUMN\s+)?(` + postgresName + `)\s+SET\s+NOT\s+NULL`)
+
+// validatesNotNull returns a function reporting whether a statement
+// validates the check constraint online plans add before setNotNull, see
+// PostgresRenderer.SetNotNull.
+func validatesNotNull(setNotNull string) func(statement string) bool {
+	match := setNotNullPattern.FindStringSubmatch(setNotNull)
+	table, column := unqualifiedName(match[1]), unqualifiedName(match[2])
+	validate := fmt.Sprintf(`VALIDATE CONSTRAINT "%s_%s_not_null";`, table, column)
+
+	return func(statement string) bool {
+		return strings.HasSuffix(statement, validate)
+	}
+}
+
+// postgresQuoted quotes name as a Postgres identifier, keeping its case.
+func postgresQuoted(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
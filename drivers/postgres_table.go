@@ -2,185 +2,268 @@ package drivers
 
 import (
 	"fmt"
+	"maps"
+	"regexp"
+	"slices"
 	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
 )
 
-type PostgresTable struct {
-	Name        string
-	Columns     []*PostgresColumn
-	Indexes     []*PostgresIndex
-	Constraints []*PostgresConstraint
-	Triggers    []*PostgresTrigger
+func (r *PostgresRenderer) CreateTable(t *schema.Table) string {
+	var columnLines []string
+	for _, column := range t.Columns {
+		line := "\t" + r.ColumnDefinition(column)
+		columnLines = append(columnLines, line)
+	}
+
+	for _, constraint := range t.Constraints {
+		line := "\t" + r.ConstraintDefinition(constraint)
+		columnLines = append(columnLines, line)
+	}
+
+	createTableColumns := strings.Join(columnLines, ",\n")
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);", postgresQuoted(t.Name), createTableColumns)
 }
 
-func (t *PostgresTable) ColumnByName(name string) (*PostgresColumn, bool) {
+func (r *PostgresRenderer) ConstraintDefinition(c *schema.Constraint) string {
+	return fmt.Sprintf("CONSTRAINT %s %s", postgresQuoted(c.Name), c.Def)
+}
+
+// TableStatements returns the statements creating the table along with its
+// indexes and triggers, between the sequences its columns own and their
+// attachment to them.
+func (r *PostgresRenderer) TableStatements(t *schema.Table) []string {
+	var statements []string
 	for _, column := range t.Columns {
-		if column.Name == name {
-			return column, true
-		}
+		statements = append(statements, r.CreateSequence(column, nil)...)
 	}
-	return nil, false
+
+	statements = append(statements, r.CreateTable(t))
+	for _, column := range t.Columns {
+		statements = append(statements, r.OwnSequence(t.Name, column, nil)...)
+	}
+
+	for _, index := range t.Indexes {
+		statements = append(statements, r.IndexDefinition(index)+";")
+	}
+
+	for _, trigger := range t.Triggers {
+		statements = append(statements, trigger.Def+";")
+	}
+
+	return statements
 }
 
-func (t *PostgresTable) DiffTable(other *PostgresTable) (string, error) {
-	var diff strings.Builder
+func (r *PostgresRenderer) RenderTable(diff *schema.TableDiff) []string {
+	switch diff.Kind {
+	case schema.Added:
+		return r.TableStatements(diff.Source)
+	case schema.Removed:
+		return []string{fmt.Sprintf("DROP TABLE %s;", postgresQuoted(diff.Target.Name))}
+	}
+
+	t, other := diff.Source, diff.Target
+
+	var statements []string
+
+	// Renamed columns
+	for _, oldName := range slices.Sorted(maps.Keys(diff.Columns.Renamed)) {
+		newName := diff.Columns.Renamed[oldName]
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", postgresQuoted(t.Name), postgresQuoted(oldName), postgresQuoted(newName)))
+	}
 
 	// Added or modified columns
 	for _, sourceColumn := range t.Columns {
-		targetColumn, found := other.ColumnByName(sourceColumn.Name)
-		if !found {
-			fmt.Fprintf(&diff, "ALTER TABLE \"%s\" ADD COLUMN %s;\n", t.Name, sourceColumn.String())
+		if oldName, copied := diff.Columns.Copied[sourceColumn.Name]; copied {
+			statements = append(statements, r.CopyColumn(t.Name, sourceColumn, oldName)...)
+			continue
+		}
+		if slices.Contains(diff.Columns.Added, sourceColumn.Name) {
+			statements = append(statements, r.AddColumn(t.Name, sourceColumn)...)
 			continue
 		}
 
-		if !sourceColumn.HasEqualAttributes(targetColumn) {
-			// Type change
-			if sourceColumn.Type != targetColumn.Type {
-				// Using USING clause might be needed for some conversions, but keeping it simple as requested.
-				fmt.Fprintf(&diff, "ALTER TABLE \"%s\" ALTER COLUMN \"%s\" TYPE %s;\n", t.Name, sourceColumn.Name, sourceColumn.Type)
-			}
-
-			// Not Null change
-			if sourceColumn.NotNull != targetColumn.NotNull {
-				if sourceColumn.NotNull {
-					fmt.Fprintf(&diff, "ALTER TABLE \"%s\" ALTER COLUMN \"%s\" SET NOT NULL;\n", t.Name, sourceColumn.Name)
-				} else {
-					fmt.Fprintf(&diff, "ALTER TABLE \"%s\" ALTER COLUMN \"%s\" DROP NOT NULL;\n", t.Name, sourceColumn.Name)
-				}
-			}
-
-			// Default change
-			if sourceColumn.Default != targetColumn.Default {
-				if sourceColumn.Default.Valid {
-					fmt.Fprintf(&diff, "ALTER TABLE \"%s\" ALTER COLUMN \"%s\" SET DEFAULT %s;\n", t.Name, sourceColumn.Name, sourceColumn.Default.String)
-				} else {
-					fmt.Fprintf(&diff, "ALTER TABLE \"%s\" ALTER COLUMN \"%s\" DROP DEFAULT;\n", t.Name, sourceColumn.Name)
-				}
-			}
+		if slices.Contains(diff.Columns.Modified, sourceColumn.Name) {
+			targetColumn, _ := other.ColumnByName(sourceColumn.Name)
+			statements = append(statements, r.AlterColumn(t.Name, sourceColumn, targetColumn, slices.Contains(diff.Columns.Retyped, sourceColumn.Name))...)
 		}
 	}
 
-	// Removed columns
-	for _, targetColumn := range other.Columns {
-		_, found := t.ColumnByName(targetColumn.Name)
-		if !found {
-			fmt.Fprintf(&diff, "ALTER TABLE \"%s\" DROP COLUMN \"%s\";\n", t.Name, targetColumn.Name)
-		}
+	// Removed columns, along with the sequences they own
+	for _, columnName := range diff.Columns.Removed {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", postgresQuoted(t.Name), postgresQuoted(columnName)))
 	}
 
 	// Constraints
-	for _, sourceConstraint := range t.Constraints {
-		targetConstraint, found := other.ConstraintByName(sourceConstraint.Name)
-		if !found {
-			fmt.Fprintf(&diff, "ALTER TABLE \"%s\" ADD %s;\n", t.Name, sourceConstraint.String())
-			continue
+	for _, change := range diff.Constraints {
+		if change.Kind != schema.Added {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", postgresQuoted(t.Name), postgresQuoted(change.Target.Name)))
 		}
-		if sourceConstraint.Def != targetConstraint.Def {
-			fmt.Fprintf(&diff, "ALTER TABLE \"%s\" DROP CONSTRAINT \"%s\";\n", t.Name, targetConstraint.Name)
-			fmt.Fprintf(&diff, "ALTER TABLE \"%s\" ADD %s;\n", t.Name, sourceConstraint.String())
-		}
-	}
-	for _, targetConstraint := range other.Constraints {
-		_, found := t.ConstraintByName(targetConstraint.Name)
-		if !found {
-			fmt.Fprintf(&diff, "ALTER TABLE \"%s\" DROP CONSTRAINT \"%s\";\n", t.Name, targetConstraint.Name)
+		if change.Kind != schema.Removed {
+			statements = append(statements, r.AddConstraint(t.Name, change.Source)...)
 		}
 	}
 
 	// Indexes
-	for _, sourceIndex := range t.Indexes {
-		targetIndex, found := other.IndexByName(sourceIndex.Name)
-		if !found {
-			fmt.Fprintf(&diff, "%s\n", sourceIndex.String())
-			continue
-		}
-		if sourceIndex.Def != targetIndex.Def {
-			fmt.Fprintf(&diff, "DROP INDEX \"%s\";\n", targetIndex.Name)
-			fmt.Fprintf(&diff, "%s\n", sourceIndex.String())
+	for _, change := range diff.Indexes {
+		if change.Kind != schema.Added {
+			statements = append(statements, r.DropIndex(change.Target))
 		}
-	}
-	for _, targetIndex := range other.Indexes {
-		_, found := t.IndexByName(targetIndex.Name)
-		if !found {
-			fmt.Fprintf(&diff, "DROP INDEX \"%s\";\n", targetIndex.Name)
+		if change.Kind != schema.Removed {
+			statements = append(statements, r.CreateIndex(change.Source))
 		}
 	}
 
 	// Triggers
-	for _, sourceTrigger := range t.Triggers {
-		targetTrigger, found := other.TriggerByName(sourceTrigger.Name)
-		if !found {
-			fmt.Fprintf(&diff, "%s\n", sourceTrigger.String())
-			continue
+	for _, change := range diff.Triggers {
+		if change.Kind != schema.Added {
+			statements = append(statements, fmt.Sprintf("DROP TRIGGER %s ON %s;", postgresQuoted(change.Target.Name), postgresQuoted(t.Name)))
 		}
-		if sourceTrigger.Def != targetTrigger.Def {
-			fmt.Fprintf(&diff, "DROP TRIGGER \"%s\" ON \"%s\";\n", targetTrigger.Name, t.Name)
-			fmt.Fprintf(&diff, "%s\n", sourceTrigger.String())
+		if change.Kind != schema.Removed {
+			statements = append(statements, change.Source.Def+";")
 		}
 	}
-	for _, targetTrigger := range other.Triggers {
-		_, found := t.TriggerByName(targetTrigger.Name)
-		if !found {
-			fmt.Fprintf(&diff, "DROP TRIGGER \"%s\" ON \"%s\";\n", targetTrigger.Name, t.Name)
-		}
+
+	return statements
+}
+
+// AddColumn returns the statements adding column to an existing table. Online
+// or with BackfillBatchSize, NOT NULL columns are added as nullable,
+// backfilled with their default and only then constrained, see SetNotNull.
+func (r *PostgresRenderer) AddColumn(table string, column *schema.Column) []string {
+	statements := r.CreateSequence(column, nil)
+	if (!r.Online && r.BackfillBatchSize <= 0) || !column.NotNull {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", postgresQuoted(table), r.ColumnDefinition(column)))
+		return append(statements, r.OwnSequence(table, column, nil)...)
 	}
 
-	return strings.TrimSpace(diff.String()), nil
+	nullable := column.Copy()
+	nullable.NotNull = false
+	nullable.Default.Valid = false
+
+	statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", postgresQuoted(table), r.ColumnDefinition(nullable)))
+	statements = append(statements, r.OwnSequence(table, column, nil)...)
+	if column.Default.Valid {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;", postgresQuoted(table), postgresQuoted(column.Name), column.Default.String))
+	}
+	return append(statements, r.SetNotNull(table, column)...)
 }
 
-func (t *PostgresTable) ConstraintByName(name string) (*PostgresConstraint, bool) {
-	for _, c := range t.Constraints {
-		if c.Name == name {
-			return c, true
-		}
+// CopyColumn returns the statements adding column to an existing table,
+// filled with the values of its from column, such as the new name of a
+// renamed column during the expand phase. NOT NULL columns are constrained
+// once filled, see SetNotNull.
+func (r *PostgresRenderer) CopyColumn(table string, column *schema.Column, from string) []string {
+	nullable := column.Copy()
+	nullable.NotNull = false
+
+	statements := r.AddColumn(table, nullable)
+	statements = append(statements, fmt.Sprintf("UPDATE %s SET %s = %s;", postgresQuoted(table), postgresQuoted(column.Name), postgresQuoted(from)))
+	if column.NotNull {
+		statements = append(statements, r.SetNotNull(table, column)...)
 	}
-	return nil, false
+	return statements
 }
 
-func (t *PostgresTable) IndexByName(name string) (*PostgresIndex, bool) {
-	for _, i := range t.Indexes {
-		if i.Name == name {
-			return i, true
-		}
+// SetNotNull returns the statements making column NOT NULL. Online, rows are
+// first backfilled with the column default, when it has one, and the column
+// checked by a constraint validated without blocking writes, which SET NOT
+// NULL then relies on instead of scanning the table under an exclusive lock.
+// With BackfillBatchSize, SET NOT NULL goes the same way and rows are
+// backfilled in batches, see Backfill.
+func (r *PostgresRenderer) SetNotNull(table string, column *schema.Column) []string {
+	if !r.Online && r.BackfillBatchSize <= 0 {
+		return []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;", postgresQuoted(table), postgresQuoted(column.Name))}
 	}
-	return nil, false
+
+	var statements []string
+	if column.Default.Valid {
+		statements = append(statements, r.Backfill(table, column))
+	}
+
+	check := fmt.Sprintf("%s_%s_not_null", table, column.Name)
+	return append(statements,
+		fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s IS NOT NULL) NOT VALID;", postgresQuoted(table), postgresQuoted(check), postgresQuoted(column.Name)),
+		fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s;", postgresQuoted(table), postgresQuoted(check)),
+		fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;", postgresQuoted(table), postgresQuoted(column.Name)),
+		fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s;", postgresQuoted(table), postgresQuoted(check)),
+	)
 }
 
-func (t *PostgresTable) TriggerByName(name string) (*PostgresTrigger, bool) {
-	for _, tr := range t.Triggers {
-		if tr.Name == name {
-			return tr, true
-		}
+// Backfill returns the statement setting the NULL values of column to its
+// default. With BackfillBatchSize, rows are updated in batches of that many,
+// each committed before the next one, which only works outside of a
+// transaction block.
+func (r *PostgresRenderer) Backfill(table string, column *schema.Column) string {
+	if r.BackfillBatchSize <= 0 {
+		return fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s IS NULL;", postgresQuoted(table), postgresQuoted(column.Name), column.Default.String, postgresQuoted(column.Name))
 	}
-	return nil, false
+
+	update := fmt.Sprintf("UPDATE %s SET %s = %s WHERE ctid IN (SELECT ctid FROM %s WHERE %s IS NULL LIMIT %d);", postgresQuoted(table), postgresQuoted(column.Name), column.Default.String, postgresQuoted(table), postgresQuoted(column.Name), r.BackfillBatchSize)
+	return "DO $backfill$\nDECLARE\n\tupdated bigint;\nBEGIN\n\tLOOP\n\t\t" + update +
+		"\n\t\tGET DIAGNOSTICS updated = ROW_COUNT;\n\t\tEXIT WHEN updated = 0;\n\t\tCOMMIT;\n\tEND LOOP;\nEND\n$backfill$;"
 }
 
-func (t *PostgresTable) StringCreateTable() string {
-	var columnLines []string
-	for _, column := range t.Columns {
-		line := "\t" + column.String()
-		columnLines = append(columnLines, line)
+// AddConstraint returns the statements adding constraint to an existing
+// table. Online, foreign key and check constraints are added without
+// checking existing rows, then validated without blocking writes. With
+// DeferValidation, the validation is left to ValidateConstraints.
+func (r *PostgresRenderer) AddConstraint(table string, constraint *schema.Constraint) []string {
+	add := fmt.Sprintf("ALTER TABLE %s ADD %s", postgresQuoted(table), r.ConstraintDefinition(constraint))
+	if !r.validatesSeparately(constraint) {
+		return []string{add + ";"}
+	}
+	if r.DeferValidation {
+		return []string{add + " NOT VALID;"}
 	}
 
-	for _, constraint := range t.Constraints {
-		line := "\t" + constraint.String()
-		columnLines = append(columnLines, line)
+	return []string{
+		add + " NOT VALID;",
+		fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s;", postgresQuoted(table), postgresQuoted(constraint.Name)),
 	}
+}
 
-	createTableColumns := strings.Join(columnLines, ",\n")
-	return fmt.Sprintf("CREATE TABLE \"%s\" (\n%s\n);", t.Name, createTableColumns)
+// validatesSeparately reports whether constraint is added NOT VALID then
+// validated, rather than checked as it is added.
+func (r *PostgresRenderer) validatesSeparately(constraint *schema.Constraint) bool {
+	return (r.Online || r.DeferValidation) && (constraint.Type == "f" || constraint.Type == "c") && !strings.HasSuffix(constraint.Def, "NOT VALID")
 }
 
-func (t *PostgresTable) String() string {
-	str := t.StringCreateTable()
+// ValidateConstraints returns the statements validating the constraints
+// AddConstraint added NOT VALID to the table of diff, with DeferValidation.
+func (r *PostgresRenderer) ValidateConstraints(diff *schema.TableDiff) []string {
+	if !r.DeferValidation || diff.Kind != schema.Modified {
+		return nil
+	}
 
-	for _, index := range t.Indexes {
-		str += "\n" + index.String()
+	var statements []string
+	for _, change := range diff.Constraints {
+		if change.Kind != schema.Removed && r.validatesSeparately(change.Source) {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s;", postgresQuoted(diff.Source.Name), postgresQuoted(change.Source.Name)))
+		}
 	}
+	return statements
+}
 
-	for _, trigger := range t.Triggers {
-		str += "\n" + trigger.String()
+// createIndexPattern matches the start of index definitions, as reported by
+// pg_indexes.
+var createIndexPattern = regexp.MustCompile(`^CREATE (UNIQUE )?INDEX `)
+
+// CreateIndex returns the statement creating index on an existing table,
+// concurrently when online.
+func (r *PostgresRenderer) CreateIndex(index *schema.Index) string {
+	def := r.IndexDefinition(index)
+	if !r.Online {
+		return def + ";"
 	}
+	return createIndexPattern.ReplaceAllString(def, "CREATE ${1}INDEX CONCURRENTLY ") + ";"
+}
 
-	return str
+// DropIndex returns the statement dropping index, concurrently when online.
+func (r *PostgresRenderer) DropIndex(index *schema.Index) string {
+	if !r.Online {
+		return fmt.Sprintf("DROP INDEX %s;", postgresQuoted(index.Name))
+	}
+	return fmt.Sprintf("DROP INDEX CONCURRENTLY %s;", postgresQuoted(index.Name))
 }
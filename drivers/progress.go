@@ -0,0 +1,55 @@
+package drivers
+
+import "sync"
+
+type Side string
+
+const (
+	SourceSide Side = "source"
+	TargetSide Side = "target"
+)
+
+type ProgressPhase string
+
+const (
+	IntrospectionPhase ProgressPhase = "introspection"
+	ComparisonPhase    ProgressPhase = "comparison"
+)
+
+// Progress reports how many tables of a phase have been processed so far.
+// Side is empty during comparison, which involves both databases.
+type Progress struct {
+	Phase ProgressPhase
+	Side  Side
+	Done  int
+	Total int
+}
+
+// ProgressFunc receives progress updates. Calls are serialized, even though
+// both databases are introspected concurrently.
+type ProgressFunc func(progress Progress)
+
+type progressReporter struct {
+	mu sync.Mutex
+	fn ProgressFunc
+}
+
+func newProgressReporter(fn ProgressFunc) *progressReporter {
+	return &progressReporter{fn: fn}
+}
+
+func (r *progressReporter) report(phase ProgressPhase, side Side, done int, total int) {
+	if r == nil || r.fn == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fn(Progress{
+		Phase: phase,
+		Side:  side,
+		Done:  done,
+		Total: total,
+	})
+}
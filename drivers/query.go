@@ -0,0 +1,92 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// queryRows releases the query deadline along with the rows.
+type queryRows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+}
+
+func (r *queryRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+// queryRow releases the query deadline once scanned.
+type queryRow struct {
+	*sql.Row
+	cancel context.CancelFunc
+}
+
+func (r *queryRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return r.Row.Scan(dest...)
+}
+
+// withQueryTimeout bounds a single query by timeout, if positive.
+func withQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// logQuery runs a query bounded by timeout and logs it along with the time
+// it took to return its first rows at debug level. The deadline lasts until
+// the rows are closed.
+func logQuery(ctx context.Context, logger *slog.Logger, timeout time.Duration, db *sql.DB, query string, args ...any) (*queryRows, error) {
+	ctx, cancel := withQueryTimeout(ctx, timeout)
+
+	start := time.Now()
+	rows, err := db.QueryContext(ctx, query, args...)
+	logger.DebugContext(ctx, "query", "sql", compactSQL(query), "args", args, "duration", time.Since(start), "error", err)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &queryRows{Rows: rows, cancel: cancel}, nil
+}
+
+// logQueryRow is logQuery for queries returning a single row. Errors are
+// deferred to Scan and therefore not logged.
+func logQueryRow(ctx context.Context, logger *slog.Logger, timeout time.Duration, db *sql.DB, query string, args ...any) *queryRow {
+	ctx, cancel := withQueryTimeout(ctx, timeout)
+
+	start := time.Now()
+	row := db.QueryRowContext(ctx, query, args...)
+	logger.DebugContext(ctx, "query", "sql", compactSQL(query), "args", args, "duration", time.Since(start))
+
+	return &queryRow{Row: row, cancel: cancel}
+}
+
+// logExec runs a statement and logs it along with the time it took at debug
+// level. Statements are not bounded by the query timeout, which is meant for
+// introspection.
+func logExec(ctx context.Context, logger *slog.Logger, db *sql.DB, statement string) (int64, error) {
+	start := time.Now()
+	result, err := db.ExecContext(ctx, statement)
+	logger.DebugContext(ctx, "exec", "sql", compactSQL(statement), "duration", time.Since(start), "error", err)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return -1, nil
+	}
+	return rows, nil
+}
+
+// compactSQL collapses the indentation of multiline queries so that they fit
+// on a single log line.
+func compactSQL(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
@@ -0,0 +1,355 @@
+package drivers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// nameRedactor replaces the names of schema objects with pseudonyms, such as
+// table_1f0c93a2 for users, so that plans can be shared without revealing
+// the data model. Pseudonyms are derived from the name and a key, and are
+// therefore the same from one plan to the next.
+type nameRedactor struct {
+	pseudonyms map[string]string
+}
+
+// newNameRedactor gives a pseudonym to every object of schemas. Names shared
+// by objects of different kinds, such as a table and a column, take the
+// pseudonym of the first kind among tables, views, indexes, constraints,
+// triggers and columns.
+func newNameRedactor(key string, schemas ...*schema.Schema) *nameRedactor {
+	r := &nameRedactor{pseudonyms: make(map[string]string)}
+
+	add := func(kind string, name string) {
+		if _, found := r.pseudonyms[name]; found {
+			return
+		}
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(name))
+		r.pseudonyms[name] = kind + "_" + hex.EncodeToString(mac.Sum(nil))[:8]
+	}
+
+	for _, s := range schemas {
+		for _, table := range s.Tables {
+			add("table", table.Name)
+		}
+		for _, view := range s.Views {
+			add("view", view.Name)
+		}
+	}
+	for _, s := range schemas {
+		for _, table := range s.Tables {
+			for _, index := range table.Indexes {
+				add("index", index.Name)
+			}
+			for _, constraint := range table.Constraints {
+				add("constraint", constraint.Name)
+			}
+			for _, trigger := range table.Triggers {
+				add("trigger", trigger.Name)
+			}
+		}
+		for _, view := range s.Views {
+			for _, trigger := range view.Triggers {
+				add("trigger", trigger.Name)
+			}
+		}
+	}
+	for _, s := range schemas {
+		for _, table := range s.Tables {
+			for _, column := range table.Columns {
+				add("column", column.Name)
+			}
+		}
+	}
+
+	return r
+}
+
+func (r *nameRedactor) name(name string) string {
+	if pseudonym, found := r.pseudonyms[name]; found {
+		return pseudonym
+	}
+	return name
+}
+
+func (r *nameRedactor) names(names []string) []string {
+	if names == nil {
+		return nil
+	}
+
+	redacted := make([]string, len(names))
+	for i, name := range names {
+		redacted[i] = r.name(name)
+	}
+	return redacted
+}
+
+// nameMap redacts both the keys and values of names, such as the old and
+// new names of renamed columns.
+func (r *nameRedactor) nameMap(names map[string]string) map[string]string {
+	if names == nil {
+		return nil
+	}
+
+	redacted := make(map[string]string, len(names))
+	for from, to := range names {
+		redacted[r.name(from)] = r.name(to)
+	}
+	return redacted
+}
+
+// diff returns a copy of d naming every object by its pseudonym, for the
+// renderer to never see the actual names. Types and other values are kept.
+func (r *nameRedactor) diff(d *schema.Diff) *schema.Diff {
+	redacted := &schema.Diff{
+		Source:     r.schema(d.Source),
+		Target:     r.schema(d.Target),
+		Pragmas:    d.Pragmas,
+		Aggregates: d.Aggregates,
+		Operators:  d.Operators,
+		Casts:      d.Casts,
+	}
+
+	for _, table := range d.Tables {
+		copy := &schema.TableDiff{
+			Change:             *redactedChange(table.Change, r.table),
+			ForeignKeysChanged: table.ForeignKeysChanged,
+		}
+		if table.Columns != nil {
+			copy.Columns = &schema.ColumnsDiff{
+				Added:    r.names(table.Columns.Added),
+				Modified: r.names(table.Columns.Modified),
+				Removed:  r.names(table.Columns.Removed),
+				Renamed:  r.nameMap(table.Columns.Renamed),
+				Retyped:  r.names(table.Columns.Retyped),
+				Copied:   r.nameMap(table.Columns.Copied),
+			}
+		}
+		for _, change := range table.Constraints {
+			copy.Constraints = append(copy.Constraints, redactedChange(*change, r.constraint))
+		}
+		for _, change := range table.Indexes {
+			copy.Indexes = append(copy.Indexes, redactedChange(*change, r.index))
+		}
+		for _, change := range table.Triggers {
+			copy.Triggers = append(copy.Triggers, redactedChange(*change, r.trigger))
+		}
+		redacted.Tables = append(redacted.Tables, copy)
+	}
+
+	for _, change := range d.Views {
+		redacted.Views = append(redacted.Views, redactedChange(*change, r.view))
+	}
+
+	return redacted
+}
+
+// redactedChange returns a copy of change with its objects redacted by
+// redact, which must keep nil objects nil.
+func redactedChange[T any](change schema.Change[T], redact func(T) T) *schema.Change[T] {
+	return &schema.Change[T]{Kind: change.Kind, Source: redact(change.Source), Target: redact(change.Target)}
+}
+
+func (r *nameRedactor) schema(s *schema.Schema) *schema.Schema {
+	if s == nil {
+		return nil
+	}
+
+	redacted := &schema.Schema{Dialect: s.Dialect, Pragmas: s.Pragmas, Aggregates: s.Aggregates, Operators: s.Operators, Casts: s.Casts}
+	for _, table := range s.Tables {
+		redacted.Tables = append(redacted.Tables, r.table(table))
+	}
+	for _, view := range s.Views {
+		redacted.Views = append(redacted.Views, r.view(view))
+	}
+	return redacted
+}
+
+func (r *nameRedactor) table(t *schema.Table) *schema.Table {
+	if t == nil {
+		return nil
+	}
+
+	copy := t.Copy()
+	copy.Name = r.name(t.Name)
+
+	copy.Columns = make([]*schema.Column, len(t.Columns))
+	for i, column := range t.Columns {
+		copy.Columns[i] = column.Copy()
+		copy.Columns[i].Name = r.name(column.Name)
+		if column.Default.Valid {
+			copy.Columns[i].Default.String = r.definition(column.Default.String)
+		}
+	}
+
+	copy.Indexes = make([]*schema.Index, len(t.Indexes))
+	for i, index := range t.Indexes {
+		copy.Indexes[i] = r.index(index)
+	}
+
+	copy.Constraints = make([]*schema.Constraint, len(t.Constraints))
+	for i, constraint := range t.Constraints {
+		copy.Constraints[i] = r.constraint(constraint)
+	}
+
+	copy.ForeignKeys = make([]*schema.ForeignKey, len(t.ForeignKeys))
+	for i, fk := range t.ForeignKeys {
+		fkCopy := *fk
+		fkCopy.Table = r.name(fk.Table)
+		fkCopy.From = r.names(fk.From)
+		fkCopy.To = r.names(fk.To)
+		copy.ForeignKeys[i] = &fkCopy
+	}
+
+	copy.Triggers = make([]*schema.Trigger, len(t.Triggers))
+	for i, trigger := range t.Triggers {
+		copy.Triggers[i] = r.trigger(trigger)
+	}
+
+	return copy
+}
+
+func (r *nameRedactor) index(index *schema.Index) *schema.Index {
+	if index == nil {
+		return nil
+	}
+
+	copy := *index
+	copy.Table = r.name(index.Table)
+	copy.Name = r.name(index.Name)
+	copy.Def = r.definition(index.Def)
+	copy.Where = r.definition(index.Where)
+	copy.Include = r.names(index.Include)
+
+	// Keys are either column names or expressions
+	if index.Columns != nil {
+		copy.Columns = make([]string, len(index.Columns))
+		for i, key := range index.Columns {
+			copy.Columns[i] = r.definition(key)
+		}
+	}
+	return &copy
+}
+
+func (r *nameRedactor) constraint(constraint *schema.Constraint) *schema.Constraint {
+	if constraint == nil {
+		return nil
+	}
+
+	copy := *constraint
+	copy.Name = r.name(constraint.Name)
+	copy.Def = r.definition(constraint.Def)
+	return &copy
+}
+
+func (r *nameRedactor) trigger(trigger *schema.Trigger) *schema.Trigger {
+	if trigger == nil {
+		return nil
+	}
+
+	copy := *trigger
+	copy.Name = r.name(trigger.Name)
+	copy.Def = r.definition(trigger.Def)
+	return &copy
+}
+
+func (r *nameRedactor) view(view *schema.View) *schema.View {
+	if view == nil {
+		return nil
+	}
+
+	copy := *view
+	copy.Name = r.name(view.Name)
+	copy.Def = r.definition(view.Def)
+
+	copy.Triggers = make([]*schema.Trigger, len(view.Triggers))
+	for i, trigger := range view.Triggers {
+		copy.Triggers[i] = r.trigger(trigger)
+	}
+	return &copy
+}
+
+// definitionToken matches, at the start of what remains of a definition, a
+// string literal, a quoted identifier, the type of a cast or a bare word.
+var definitionToken = regexp.MustCompile(`^(?:'(?:[^']|'')*'|"(?:[^"]|"")*"|` + "`[^`]*`" + `|\[[^\]]*\]|::\s*(?:"(?:[^"]|"")*"|[A-Za-z_][A-Za-z0-9_]*(?:\s+(?:varying|precision)|\s+with(?:out)?\s+time\s+zone)?)|[A-Za-z_][A-Za-z0-9_$]*)`)
+
+// tableKeywords precede table names, which may be directly followed by the
+// parenthesized list of their columns, such as in REFERENCES users(id).
+var tableKeywords = []string{"on", "references", "table", "into", "from", "join", "update"}
+
+// definition replaces the names of objects in a definition read from the
+// database, such as the one of a view or an index, keeping their quoting.
+// String literals, the types of casts and called functions are left alone,
+// even when they spell an object name.
+func (r *nameRedactor) definition(def string) string {
+	var b strings.Builder
+	previous := ""
+
+	for i := 0; i < len(def); {
+		token := definitionToken.FindString(def[i:])
+		if token == "" {
+			b.WriteByte(def[i])
+			if def[i] != ' ' && def[i] != '\t' && def[i] != '\n' {
+				previous = ""
+			}
+			i++
+			continue
+		}
+		i += len(token)
+
+		switch token[0] {
+		case '\'', ':':
+			b.WriteString(token)
+		case '"':
+			name := strings.ReplaceAll(token[1:len(token)-1], `""`, `"`)
+			if pseudonym, found := r.pseudonyms[name]; found {
+				token = `"` + pseudonym + `"`
+			}
+			b.WriteString(token)
+		case '`', '[':
+			pseudonym, found := r.pseudonyms[token[1:len(token)-1]]
+			if token[0] == '[' && !found {
+				// Not a bracketed name, such as an array subscript, whose
+				// content still gets redacted
+				b.WriteByte('[')
+				i -= len(token) - 1
+				previous = ""
+				continue
+			}
+			if found {
+				token = token[:1] + pseudonym + token[len(token)-1:]
+			}
+			b.WriteString(token)
+		default:
+			call := i < len(def) && def[i] == '(' && !slices.Contains(tableKeywords, previous)
+			previous = strings.ToLower(token)
+			if !call {
+				token = r.name(token)
+			}
+			b.WriteString(token)
+			continue
+		}
+		previous = ""
+	}
+	return b.String()
+}
+
+// counts returns the row counts of tables keyed by their pseudonym.
+func (r *nameRedactor) counts(counts map[string]int64) map[string]int64 {
+	if counts == nil {
+		return nil
+	}
+
+	redacted := make(map[string]int64, len(counts))
+	for table, count := range counts {
+		redacted[r.name(table)] = count
+	}
+	return redacted
+}
@@ -0,0 +1,73 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/samber/lo"
+)
+
+// Constructor creates a driver from options.
+type Constructor func(opts ...Option) (Driver, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Constructor)
+)
+
+// Register makes a driver available to Open under name. Drivers register
+// themselves when compiled in; registering the same name twice panics.
+func Register(name string, constructor Constructor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, found := registry[name]; found {
+		panic("drivers: Register called twice for driver " + name)
+	}
+	registry[name] = constructor
+}
+
+// Open creates the driver registered under name.
+func Open(name string, opts ...Option) (Driver, error) {
+	registryMu.RLock()
+	constructor, found := registry[name]
+	registryMu.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("unsupported driver: %s", name)
+	}
+
+	return constructor(opts...)
+}
+
+// Introspect reads the schema of the database at dsn with the driver
+// registered under driverName, such as postgres or sqlite3, for tools that
+// only need the schema model and never diff it. opts configure the driver
+// as with Open, e.g. WithSchemaFilter.
+func Introspect(ctx context.Context, dsn string, driverName string, opts ...Option) (*schema.Schema, error) {
+	// The database is opened as the source. The target is left without a
+	// data source name and never connected to
+	opts = append(slices.Clone(opts), WithSourceDSN(dsn))
+
+	driver, err := Open(driverName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer driver.Close()
+
+	return driver.Introspect(ctx, SourceSide)
+}
+
+// Names returns the sorted names of the registered drivers.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := lo.Keys(registry)
+	slices.Sort(names)
+
+	return names
+}
@@ -0,0 +1,35 @@
+package drivers
+
+import (
+	"fmt"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// Snapshots are schemas standing in for the databases, such as the output of
+// `dbdiff inspect --format json`. Introspecting a side with a snapshot
+// returns it, after applying the ignore rules, without querying the
+// database.
+type Snapshots struct {
+	Source *schema.Schema
+	Target *schema.Schema
+}
+
+// Side returns the snapshot of side, or nil when the database is to be
+// introspected.
+func (s Snapshots) Side(side Side) *schema.Schema {
+	if side == TargetSide {
+		return s.Target
+	}
+	return s.Source
+}
+
+// check fails when a snapshot comes from another dialect than the driver's.
+func (s Snapshots) check(dialect string) error {
+	for _, snapshot := range []*schema.Schema{s.Source, s.Target} {
+		if snapshot != nil && snapshot.Dialect != "" && snapshot.Dialect != dialect {
+			return fmt.Errorf("%s snapshot cannot be compared by the %s driver", snapshot.Dialect, dialect)
+		}
+	}
+	return nil
+}
@@ -4,46 +4,175 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"iter"
+	"log/slog"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/quantumsheep/dbdiff/schema"
 	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
 )
 
-type SQLLiteDriverConfig struct {
-	SourceDatabasePath string
-	TargetDatabasePath string
+func init() {
+	Register("sqlite3", func(opts ...Option) (Driver, error) {
+		driver, err := NewSQLiteDriver(opts...)
+		if err != nil {
+			return nil, err
+		}
+		return driver, nil
+	})
 }
 
 type SQLiteDriver struct {
+	SQLiteRenderer
+
 	SourceDatabaseConnection *sql.DB
 	TargetDatabaseConnection *sql.DB
+
+	Concurrency  int
+	QueryTimeout time.Duration
+	Cache        *IntrospectionCache
+	Logger       *slog.Logger
+	Ignore       schema.IgnoreRules
+	Tables       []string
+
+	TypeEquivalences     []schema.TypeEquivalence
+	CaseInsensitiveNames bool
+	SkippedTypes         []schema.ObjectType
+	DeterministicOrder   bool
+	DeferDestructive     bool
+	ExpandContract       bool
+	MaxRewriteRows       int64
+	Snapshots            Snapshots
+	DiffCheck            DiffCheckFunc
+	TemplateVars         []TemplateVar
+	RedactNames          bool
+	RedactKey            string
+	TableMappings        []schema.NameMapping
+	IgnoredColumns       schema.IgnoredColumns
+
+	// StrictDefinitions compares view and trigger definitions verbatim
+	// instead of normalizing them with NormalizeSQLiteSQL.
+	StrictDefinitions bool
+
+	// Pragmas introspects the settings persisted in the database files,
+	// see GetPragmas.
+	Pragmas bool
+
+	// UserVersion ends plans with a user_version bump, see WithUserVersion.
+	UserVersion string
+
+	progress *progressReporter
 }
 
-func NewSQLiteDriver(config *SQLLiteDriverConfig) (*SQLiteDriver, error) {
-	sourceDatabasePath := strings.TrimPrefix(config.SourceDatabasePath, "sqlite://")
-	targetDatabasePath := strings.TrimPrefix(config.TargetDatabasePath, "sqlite://")
+// NewSQLiteDriver opens the source and target databases described by opts.
+func NewSQLiteDriver(opts ...Option) (*SQLiteDriver, error) {
+	config := NewDriverConfig(opts...)
 
-	sourceDatabaseConnection, err := sql.Open("sqlite3", sourceDatabasePath)
+	err := config.Snapshots.check("sqlite3")
 	if err != nil {
 		return nil, err
 	}
+	if config.UserVersion != "" && config.UserVersion != AutoUserVersion {
+		_, err := strconv.ParseInt(config.UserVersion, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user_version %q, expected %s or an integer", config.UserVersion, AutoUserVersion)
+		}
+	}
+
+	sourceDatabasePath, sourceKey := sqliteKey(sqliteDSN(config.SourceDSN, config.ReadOnly), config.SQLiteKey)
+	targetDatabasePath, targetKey := sqliteKey(sqliteDSN(config.TargetDSN, config.ReadOnly), config.SQLiteKey)
 
-	targetDatabaseConnection, err := sql.Open("sqlite3", targetDatabasePath)
+	sourceDatabaseConnection, err := openSQLite(config.SQLiteDriverName, sourceDatabasePath, sourceKey)
 	if err != nil {
 		return nil, err
 	}
 
+	targetDatabaseConnection, err := openSQLite(config.SQLiteDriverName, targetDatabasePath, targetKey)
+	if err != nil {
+		sourceDatabaseConnection.Close()
+		return nil, err
+	}
+
+	// Every new SQLite connection parses the whole schema again, so keep
+	// enough idle connections around for concurrent introspection queries
+	for _, db := range []*sql.DB{sourceDatabaseConnection, targetDatabaseConnection} {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+
 	driver := &SQLiteDriver{
+		SQLiteRenderer:           SQLiteRenderer{Annotate: config.Annotate, BumpsUserVersion: config.UserVersion != ""},
 		SourceDatabaseConnection: sourceDatabaseConnection,
 		TargetDatabaseConnection: targetDatabaseConnection,
+		Concurrency:              config.Concurrency,
+		QueryTimeout:             config.QueryTimeout,
+		Cache:                    config.Cache,
+		Logger:                   config.Logger,
+		Ignore:                   config.Ignore,
+		Tables:                   config.Tables,
+		TypeEquivalences:         config.TypeEquivalences,
+		CaseInsensitiveNames:     config.CaseInsensitiveNames,
+		SkippedTypes:             config.SkippedTypes,
+		DeterministicOrder:       config.DeterministicOrder,
+		DeferDestructive:         config.DeferDestructive,
+		ExpandContract:           config.ExpandContract,
+		MaxRewriteRows:           config.MaxRewriteRows,
+		Snapshots:                config.Snapshots,
+		DiffCheck:                config.DiffCheck,
+		TemplateVars:             config.TemplateVars,
+		RedactNames:              config.RedactNames,
+		RedactKey:                config.RedactKey,
+		TableMappings:            config.TableMappings,
+		IgnoredColumns:           config.IgnoredColumns,
+		StrictDefinitions:        config.StrictDefinitions,
+		Pragmas:                  config.Pragmas,
+		UserVersion:              config.UserVersion,
+		progress:                 newProgressReporter(config.Progress),
 	}
 
 	return driver, nil
 }
 
+// sqliteDSN turns a path, optionally prefixed with sqlite://, into a data
+// source name. Read-only files are opened as URIs with mode=ro, which fails
+// on missing files instead of creating them. In-memory databases cannot be
+// opened read-only and are left alone.
+func sqliteDSN(dsn string, readOnly bool) string {
+	dsn = strings.TrimPrefix(dsn, "sqlite://")
+	if !readOnly || dsn == "" || dsn == ":memory:" {
+		return dsn
+	}
+
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+
+	if strings.Contains(dsn, "?") {
+		return dsn + "&mode=ro"
+	}
+	return dsn + "?mode=ro"
+}
+
+func (d *SQLiteDriver) sideOf(db *sql.DB) Side {
+	if db == d.TargetDatabaseConnection {
+		return TargetSide
+	}
+	return SourceSide
+}
+
+func (d *SQLiteDriver) query(ctx context.Context, db *sql.DB, query string, args ...any) (*queryRows, error) {
+	return logQuery(ctx, d.Logger.With("side", d.sideOf(db)), d.QueryTimeout, db, query, args...)
+}
+
+func (d *SQLiteDriver) queryRow(ctx context.Context, db *sql.DB, query string, args ...any) *queryRow {
+	return logQueryRow(ctx, d.Logger.With("side", d.sideOf(db)), d.QueryTimeout, db, query, args...)
+}
+
 func (d *SQLiteDriver) Close() error {
 	var err error
 
@@ -61,207 +190,405 @@ func (d *SQLiteDriver) Close() error {
 }
 
 func (d *SQLiteDriver) Diff(ctx context.Context) (string, error) {
-	var diff strings.Builder
+	return collectStatements(d.Statements(ctx))
+}
 
-	var subDiff string
-	var err error
+// DiffTableByName computes the statements migrating a single table, only
+// introspecting that table.
+func (d *SQLiteDriver) DiffTableByName(ctx context.Context, name string) (string, error) {
+	return d.DiffTables(ctx, name)
+}
 
-	subDiff, err = d.DiffTables(ctx)
-	if err != nil {
-		return "", err
+// DiffTables computes the statements migrating the given tables, leaving the
+// rest of the databases, views included, alone.
+func (d *SQLiteDriver) DiffTables(ctx context.Context, names ...string) (string, error) {
+	restricted := *d
+	restricted.Tables = names
+	return restricted.Diff(ctx)
+}
+
+func (d *SQLiteDriver) Statements(ctx context.Context) iter.Seq2[string, error] {
+	return planStatements(ctx, d, d, d.planOptions())
+}
+
+func (d *SQLiteDriver) ObjectStatements(ctx context.Context) iter.Seq2[*ObjectStatements, error] {
+	return planObjects(ctx, d, d, d.planOptions())
+}
+
+func (d *SQLiteDriver) planOptions() planOptions {
+	return planOptions{
+		progress:         d.progress,
+		check:            d.DiffCheck,
+		deferDestructive: d.DeferDestructive || d.ExpandContract,
+		deferNotNull:     d.ExpandContract,
+		templateVars:     d.TemplateVars,
+		redactNames:      d.RedactNames,
+		redactKey:        d.RedactKey,
+		tableMappings:    d.TableMappings,
+		compare:          d.compareOptions(),
+		classify:         sqliteImpact,
+		annotate:         d.Annotate,
+		maxRewriteRows:   d.MaxRewriteRows,
+		trailer:          d.userVersionTrailer(),
 	}
-	fmt.Fprintln(&diff, subDiff)
+}
 
-	subDiff, err = d.DiffViews(ctx)
-	if err != nil {
-		return "", err
+// userVersionTrailer returns the trailer setting the user_version of the
+// target at the end of plans, if asked to.
+func (d *SQLiteDriver) userVersionTrailer() func(diff *schema.Diff) (*ObjectStatements, error) {
+	if d.UserVersion == "" {
+		return nil
+	}
+
+	return func(diff *schema.Diff) (*ObjectStatements, error) {
+		version := d.UserVersion
+		if version == AutoUserVersion {
+			current, found := lo.Find(diff.Target.Pragmas, func(pragma *schema.Pragma) bool { return pragma.Name == "user_version" })
+			if !found {
+				return nil, fmt.Errorf("cannot bump the user_version of a target read from a snapshot without pragmas, pass it explicitly")
+			}
+
+			n, err := strconv.ParseInt(current.Value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid user_version %q on the target: %w", current.Value, err)
+			}
+			version = strconv.FormatInt(n+1, 10)
+		}
+
+		statements := annotate(d.Annotate, []string{fmt.Sprintf("PRAGMA user_version = %s;", version)}, "record the migration in user_version")
+		return &ObjectStatements{Type: PragmaObject, Name: "user_version", Statements: statements, Class: AdditiveClass}, nil
 	}
-	fmt.Fprintln(&diff, subDiff)
+}
 
-	return strings.TrimSpace(diff.String()), nil
+func (d *SQLiteDriver) compareOptions() []schema.CompareOption {
+	opts := []schema.CompareOption{
+		schema.WithTypeEquivalence(d.TypeEquivalences...),
+		schema.WithCaseInsensitiveNames(d.CaseInsensitiveNames),
+		schema.WithSkippedTypes(d.SkippedTypes...),
+		schema.WithIgnoredColumns(d.IgnoredColumns),
+		schema.WithDeterministicOrder(d.DeterministicOrder),
+		schema.WithLogger(d.Logger),
+	}
+	if !d.StrictDefinitions {
+		opts = append(opts, schema.WithDefinitionNormalizer(NormalizeSQLiteSQL))
+	}
+
+	return opts
 }
 
-func (d *SQLiteDriver) DiffTables(ctx context.Context) (string, error) {
-	var diff strings.Builder
+func (d *SQLiteDriver) Exec(ctx context.Context, side Side, statement string) error {
+	_, err := d.ExecRows(ctx, side, statement)
+	return err
+}
 
-	sourceTables, err := d.GetTables(ctx, d.SourceDatabaseConnection)
-	if err != nil {
-		return "", err
+// ExecRows is Exec returning the number of rows the statement affected, see
+// RowsExecutor.
+func (d *SQLiteDriver) ExecRows(ctx context.Context, side Side, statement string) (int64, error) {
+	return logExec(ctx, d.Logger.With("side", side), d.connection(side), statement)
+}
+
+// EstimateImpact tells which statements rewrite or scan existing tables of
+// the target database, see ImpactEstimator.
+func (d *SQLiteDriver) EstimateImpact(ctx context.Context, statements []string) ([]*StatementImpact, error) {
+	return estimateImpact(ctx, d, sqliteImpact, lockWarnings{}, statements)
+}
+
+// StepChecks checks the table rebuilds of statements, see StepChecker.
+func (d *SQLiteDriver) StepChecks(statements []string) map[int][]StepCheck {
+	return sqliteStepChecks(statements)
+}
+
+// RunStepCheck runs check against one of the databases, see StepChecker.
+func (d *SQLiteDriver) RunStepCheck(ctx context.Context, side Side, check StepCheck) (bool, error) {
+	var passed bool
+	err := d.queryRow(ctx, d.connection(side), check.Query).Scan(&passed)
+	return passed, err
+}
+
+// EstimateRows returns the row counts ANALYZE recorded in sqlite_stat1,
+// counting the rows of tables it has no statistics for. Sides read from a
+// snapshot have no row counts.
+func (d *SQLiteDriver) EstimateRows(ctx context.Context, side Side) (map[string]int64, error) {
+	if d.Snapshots.Side(side) != nil {
+		return nil, nil
 	}
+	db := d.connection(side)
 
-	targetTables, err := d.GetTables(ctx, d.TargetDatabaseConnection)
+	var analyzed bool
+	err := d.queryRow(ctx, db, "SELECT count(*) > 0 FROM sqlite_master WHERE type='table' AND name='sqlite_stat1';").Scan(&analyzed)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Added or modified tables
-	for _, sourceTable := range sourceTables {
-		targetTable, found := lo.Find(targetTables, func(t *SQLiteTable) bool {
-			return t.Name == sourceTable.Name
-		})
+	// Statistics start with the number of rows of the table
+	query := "SELECT m.name, NULL FROM sqlite_master m WHERE m.type='table' AND m.name NOT LIKE 'sqlite_%';"
+	if analyzed {
+		query = "SELECT m.name, (SELECT max(CAST(s.stat AS INTEGER)) FROM sqlite_stat1 s WHERE s.tbl = m.name) FROM sqlite_master m WHERE m.type='table' AND m.name NOT LIKE 'sqlite_%';"
+	}
 
-		// Table not found in target database
-		if !found {
-			fmt.Fprintf(&diff, "%s\n", sourceTable.String())
-			continue
+	rows, err := d.query(ctx, db, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	var unknown []string
+	for rows.Next() {
+		var name string
+		var count sql.NullInt64
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, err
 		}
 
-		var subDiff string
+		if count.Valid {
+			counts[name] = count.Int64
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		subDiff, err = sourceTable.DiffTable(targetTable)
+	for _, name := range unknown {
+		var count int64
+		err := d.queryRow(ctx, db, fmt.Sprintf("SELECT count(*) FROM %s;", sqliteIdentifier(name))).Scan(&count)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		fmt.Fprintln(&diff, subDiff)
+		counts[name] = count
+	}
+
+	return counts, nil
+}
+
+func (d *SQLiteDriver) connection(side Side) *sql.DB {
+	if side == TargetSide {
+		return d.TargetDatabaseConnection
+	}
+	return d.SourceDatabaseConnection
+}
 
-		subDiff, err = sourceTable.DiffIndexes(targetTable)
+// Introspect reads the schema of one of the databases, skipping it entirely
+// when the cache holds an entry for its current schema version.
+func (d *SQLiteDriver) Introspect(ctx context.Context, side Side) (*schema.Schema, error) {
+	start := time.Now()
+	db := d.connection(side)
+
+	var s *schema.Schema
+	var err error
+
+	loaded := true
+	if snapshot := d.Snapshots.Side(side); snapshot != nil {
+		s, loaded = snapshot, false
+	} else if d.Cache == nil {
+		s, err = d.GetSchema(ctx, db)
+	} else {
+		var fingerprint string
+		fingerprint, err = d.Fingerprint(ctx, db)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		fmt.Fprintln(&diff, subDiff)
 
-		subDiff, err = sourceTable.DiffTriggers(targetTable)
+		loaded = false
+		s, err = cached(d.Cache, fingerprint, tablesCacheKind(d.Tables), func() (*schema.Schema, error) {
+			loaded = true
+			return d.GetSchema(ctx, db)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache hits skip GetTables and its progress updates
+	if !loaded {
+		d.progress.report(IntrospectionPhase, side, len(s.Tables), len(s.Tables))
+	}
+
+	// Pragmas do not bump the schema version fingerprints rely on, they are
+	// read again every time. Bumping the user_version needs the target's.
+	if (d.Pragmas || d.UserVersion == AutoUserVersion) && d.Snapshots.Side(side) == nil {
+		pragmas, err := d.GetPragmas(ctx, db)
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+		if !d.Pragmas {
+			pragmas = lo.Filter(pragmas, func(pragma *schema.Pragma, _ int) bool { return pragma.Name == "user_version" })
 		}
-		fmt.Fprintln(&diff, subDiff)
 
+		withPragmas := *s
+		withPragmas.Pragmas = pragmas
+		s = &withPragmas
 	}
 
-	// Removed tables
-	for _, targetTable := range targetTables {
-		_, found := lo.Find(sourceTables, func(t *SQLiteTable) bool {
-			return t.Name == targetTable.Name
-		})
+	d.Logger.DebugContext(ctx, "introspected schema",
+		"side", side,
+		"tables", len(s.Tables),
+		"views", len(s.Views),
+		"cached", !loaded,
+		"duration", time.Since(start),
+	)
 
-		// Table not found in source database
-		if !found {
-			fmt.Fprintf(&diff, "DROP TABLE \"%s\";\n", targetTable.Name)
-		}
+	if len(d.Tables) > 0 {
+		s = s.OnlyTables(d.Tables)
 	}
 
-	return strings.TrimSpace(diff.String()), nil
+	return s.Filter(d.Ignore, func(objectType schema.ObjectType, name string) {
+		d.Logger.DebugContext(ctx, "ignoring object", "side", side, "type", objectType, "name", name)
+	}), nil
 }
 
-func (d *SQLiteDriver) DiffViews(ctx context.Context) (string, error) {
-	var diff strings.Builder
-
-	sourceViews, err := d.GetViews(ctx, d.SourceDatabaseConnection)
+// Fingerprint identifies the database file and its schema version, which
+// SQLite bumps on every schema change. In-memory and temporary databases have
+// no stable identity and get an empty fingerprint.
+func (d *SQLiteDriver) Fingerprint(ctx context.Context, db *sql.DB) (string, error) {
+	var file string
+	err := d.queryRow(ctx, db, "SELECT file FROM pragma_database_list WHERE name = 'main';").Scan(&file)
 	if err != nil {
 		return "", err
 	}
+	if file == "" {
+		return "", nil
+	}
 
-	targetViews, err := d.GetViews(ctx, d.TargetDatabaseConnection)
+	var schemaVersion int
+	err = d.queryRow(ctx, db, "PRAGMA schema_version;").Scan(&schemaVersion)
 	if err != nil {
 		return "", err
 	}
 
-	for _, sourceView := range sourceViews {
-		targetView, found := lo.Find(targetViews, func(v *SQLiteView) bool {
-			return v.Name == sourceView.Name
-		})
-		if !found {
-			// New view
-			fmt.Fprintf(&diff, "%s;\n", sourceView.SQL)
-			continue
-		}
+	return fmt.Sprintf("sqlite:%s:%d", file, schemaVersion), nil
+}
 
-		subDiff, err := sourceView.Diff(targetView)
+func (d *SQLiteDriver) GetSchema(ctx context.Context, db *sql.DB) (*schema.Schema, error) {
+	tables, err := d.GetTables(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var views []*schema.View
+	if len(d.Tables) == 0 {
+		views, err = d.GetViews(ctx, db)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		fmt.Fprintln(&diff, subDiff)
 	}
 
-	for _, targetView := range targetViews {
-		_, found := lo.Find(sourceViews, func(v *SQLiteView) bool {
-			return v.Name == targetView.Name
-		})
-		if !found {
-			// Removed view
-			fmt.Fprintf(&diff, "DROP VIEW \"%s\";\n", targetView.Name)
+	return &schema.Schema{
+		Dialect: "sqlite3",
+		Tables:  tables,
+		Views:   views,
+	}, nil
+}
+
+// sqlitePragmas are the settings persisted in database files. The journal
+// mode only persists as WAL, the others being set per connection.
+var sqlitePragmas = []string{"application_id", "encoding", "journal_mode", "page_size", "user_version"}
+
+// GetPragmas reads the settings persisted in the database file, journal_mode
+// being either wal or delete.
+func (d *SQLiteDriver) GetPragmas(ctx context.Context, db *sql.DB) ([]*schema.Pragma, error) {
+	var pragmas []*schema.Pragma
+	for _, name := range sqlitePragmas {
+		pragma := &schema.Pragma{Name: name}
+		err := d.queryRow(ctx, db, fmt.Sprintf("PRAGMA %s;", name)).Scan(&pragma.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pragma %s: %w", name, err)
 		}
-	}
 
-	return strings.TrimSpace(diff.String()), nil
+		if name == "journal_mode" && !strings.EqualFold(pragma.Value, "wal") {
+			pragma.Value = "delete"
+		}
+		pragmas = append(pragmas, pragma)
+	}
+	return pragmas, nil
 }
 
-func (d *SQLiteDriver) GetTables(ctx context.Context, db *sql.DB) ([]*SQLiteTable, error) {
-	rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%';")
+func (d *SQLiteDriver) GetTables(ctx context.Context, db *sql.DB) ([]*schema.Table, error) {
+	filter, args := d.tableFilter("name")
+	rows, err := d.query(ctx, db, "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'"+filter+";", args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var tables []*SQLiteTable
+	var tables []*schema.Table
+	tablesByName := make(map[string]*schema.Table)
 	for rows.Next() {
 		var tableName string
 		if err := rows.Scan(&tableName); err != nil {
 			return nil, err
 		}
 
-		table, err := d.GetTable(ctx, db, tableName)
-		if err != nil {
-			return nil, err
-		}
-
+		table := &schema.Table{Name: tableName}
 		tables = append(tables, table)
+		tablesByName[tableName] = table
 	}
-
-	return tables, nil
-}
-
-func (d *SQLiteDriver) GetTable(ctx context.Context, db *sql.DB, tableName string) (*SQLiteTable, error) {
-	columns, err := d.GetTableColumns(ctx, db, tableName)
-	if err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	indexes, err := d.GetTableIndexes(ctx, db, tableName)
-	if err != nil {
-		return nil, err
-	}
+	side := d.sideOf(db)
+	d.progress.report(IntrospectionPhase, side, 0, len(tables))
 
-	triggers, err := d.GetTableTriggers(ctx, db, tableName)
-	if err != nil {
+	// Each object kind is read for all tables at once through the pragma
+	// table-valued functions, instead of issuing one PRAGMA per table
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(d.Concurrency)
+	g.Go(func() error {
+		return d.GetColumns(gctx, db, tablesByName)
+	})
+	g.Go(func() error {
+		return d.GetIndexes(gctx, db, tablesByName)
+	})
+	g.Go(func() error {
+		return d.GetForeignKeys(gctx, db, tablesByName)
+	})
+	g.Go(func() error {
+		return d.GetTriggers(gctx, db, tablesByName)
+	})
+	if err := g.Wait(); err != nil {
 		return nil, err
 	}
 
-	foreignKeys, err := d.GetTableForeignKeys(ctx, db, tableName)
-	if err != nil {
-		return nil, err
-	}
+	d.progress.report(IntrospectionPhase, side, len(tables), len(tables))
 
-	return &SQLiteTable{
-		Name:        tableName,
-		Columns:     columns,
-		Indexes:     indexes,
-		Triggers:    triggers,
-		ForeignKeys: foreignKeys,
-	}, nil
+	return tables, nil
 }
 
-func (d *SQLiteDriver) GetTableColumns(ctx context.Context, db *sql.DB, tableName string) ([]*SQLiteColumn, error) {
-	rows, err := db.QueryContext(ctx, "PRAGMA table_info("+tableName+");")
+func (d *SQLiteDriver) GetColumns(ctx context.Context, db *sql.DB, tablesByName map[string]*schema.Table) error {
+	filter, args := d.tableFilter("m.name")
+	rows, err := d.query(ctx, db, `
+		SELECT m.name, p.name, p.type, p."notnull", p.dflt_value, p.pk
+		FROM sqlite_master m
+		JOIN pragma_table_info(m.name) p
+		WHERE m.type = 'table'`+filter+`
+		ORDER BY m.name, p.cid
+	`, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var columns []*SQLiteColumn
 	for rows.Next() {
-		var cid int
+		var tableName string
 		var name string
 		var ctype string
 		var isNotNull int
 		var defaultValue sql.NullString
 		var isPrimaryKey int
 
-		if err := rows.Scan(&cid, &name, &ctype, &isNotNull, &defaultValue, &isPrimaryKey); err != nil {
-			return nil, err
+		if err := rows.Scan(&tableName, &name, &ctype, &isNotNull, &defaultValue, &isPrimaryKey); err != nil {
+			return err
+		}
+
+		table, ok := tablesByName[tableName]
+		if !ok {
+			continue
 		}
 
-		columns = append(columns, &SQLiteColumn{
+		table.Columns = append(table.Columns, &schema.Column{
 			Name:       name,
 			Type:       ctype,
 			NotNull:    isNotNull == 1,
@@ -270,147 +597,242 @@ func (d *SQLiteDriver) GetTableColumns(ctx context.Context, db *sql.DB, tableNam
 		})
 	}
 
-	return columns, nil
+	return rows.Err()
 }
 
-func (d *SQLiteDriver) GetTableIndexes(ctx context.Context, db *sql.DB, tableName string) ([]*SQLiteIndex, error) {
-	rows, err := db.QueryContext(ctx, "PRAGMA index_list("+tableName+");")
+func (d *SQLiteDriver) GetIndexes(ctx context.Context, db *sql.DB, tablesByName map[string]*schema.Table) error {
+	filter, args := d.tableFilter("m.name")
+	rows, err := d.query(ctx, db, `
+		SELECT m.name, il.name, il."unique", ii.seqno, ii.name, i.sql
+		FROM sqlite_master m
+		JOIN pragma_index_list(m.name) il
+		JOIN pragma_index_info(il.name) ii
+		LEFT JOIN sqlite_master i ON i.type = 'index' AND i.name = il.name
+		WHERE m.type = 'table'`+filter+`
+		ORDER BY m.name, il.seq, ii.seqno
+	`, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var indexes []*SQLiteIndex
+	var index *schema.Index
 	for rows.Next() {
-		var seq int
+		var tableName string
 		var name string
 		var isUnique int
-		var origin string
-		var partial int
+		var seqno int
+		var columnName sql.NullString
+		var def sql.NullString
 
-		err := rows.Scan(&seq, &name, &isUnique, &origin, &partial)
-		if err != nil {
-			return nil, err
+		if err := rows.Scan(&tableName, &name, &isUnique, &seqno, &columnName, &def); err != nil {
+			return err
 		}
 
-		columns, err := d.GetIndexColumns(ctx, db, name)
-		if err != nil {
-			return nil, err
+		table, ok := tablesByName[tableName]
+		if !ok {
+			continue
 		}
 
-		indexes = append(indexes, &SQLiteIndex{
-			Table:   tableName,
-			Name:    name,
-			Unique:  isUnique == 1,
-			Columns: columns,
-		})
+		// Rows are ordered by index, one row per indexed column
+		if index == nil || index.Table != tableName || index.Name != name {
+			index = &schema.Index{
+				Table:  tableName,
+				Name:   name,
+				Unique: isUnique == 1,
+			}
+			table.Indexes = append(table.Indexes, index)
+		}
+
+		if columnName.Valid {
+			index.Columns = append(index.Columns, columnName.String)
+			continue
+		}
+
+		// Expressions have no column name, only the statement creating the
+		// index spells them, which is then kept to create it again
+		keys, ok := sqliteIndexKeys(def.String)
+		if !ok || seqno >= len(keys) {
+			return fmt.Errorf("failed to read the expression indexed by %s", name)
+		}
+		index.Columns = append(index.Columns, keys[seqno])
+		index.Def = def.String
 	}
 
-	return indexes, nil
+	return rows.Err()
 }
 
-func (d *SQLiteDriver) GetIndexColumns(ctx context.Context, db *sql.DB, indexName string) ([]string, error) {
-	rows, err := db.QueryContext(ctx, "PRAGMA index_info("+indexName+");")
+func (d *SQLiteDriver) GetForeignKeys(ctx context.Context, db *sql.DB, tablesByName map[string]*schema.Table) error {
+	filter, args := d.tableFilter("m.name")
+	rows, err := d.query(ctx, db, `
+		SELECT m.name, f.id, f."table", f."from",
+			coalesce(f."to", (SELECT p.name FROM pragma_table_info(f."table") p WHERE p.pk = f.seq + 1)),
+			f.on_update, f.on_delete
+		FROM sqlite_master m
+		JOIN pragma_foreign_key_list(m.name) f
+		WHERE m.type = 'table'`+filter+`
+		ORDER BY m.name, f.id, f.seq
+	`, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var columns []string
+	foreignKeysMaps := make(map[string]map[int]*schema.ForeignKey)
+
 	for rows.Next() {
-		var seqno int
-		var cid int
-		var name string
+		var tableName string
+		var id int
+		var table, from, onUpdate, onDelete string
+		var to sql.NullString
+		if err := rows.Scan(&tableName, &id, &table, &from, &to, &onUpdate, &onDelete); err != nil {
+			return err
+		}
 
-		if err := rows.Scan(&seqno, &cid, &name); err != nil {
-			return nil, err
+		// Foreign keys naming no parent column reference the primary key of
+		// the parent, which must then have one
+		if !to.Valid {
+			return fmt.Errorf("foreign key of %s references the primary key of %s, which has none", tableName, table)
+		}
+
+		foreignKeysMap, exists := foreignKeysMaps[tableName]
+		if !exists {
+			foreignKeysMap = make(map[int]*schema.ForeignKey)
+			foreignKeysMaps[tableName] = foreignKeysMap
+		}
+
+		foreignKey, exists := foreignKeysMap[id]
+		if !exists {
+			foreignKey = &schema.ForeignKey{
+				Table:    table,
+				From:     []string{},
+				To:       []string{},
+				OnUpdate: onUpdate,
+				OnDelete: onDelete,
+			}
+			foreignKeysMap[id] = foreignKey
 		}
 
-		columns = append(columns, name)
+		foreignKey.From = append(foreignKey.From, from)
+		foreignKey.To = append(foreignKey.To, to.String)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for tableName, foreignKeysMap := range foreignKeysMaps {
+		table, ok := tablesByName[tableName]
+		if !ok {
+			continue
+		}
+
+		table.ForeignKeys = sortForeignKeys(lo.Values(foreignKeysMap))
 	}
 
-	return columns, nil
+	return nil
 }
 
-func (d *SQLiteDriver) GetTableTriggers(ctx context.Context, db *sql.DB, tableName string) ([]*SQLiteTrigger, error) {
-	rows, err := db.QueryContext(ctx, "SELECT name, sql FROM sqlite_master WHERE type = 'trigger' AND tbl_name = ?", tableName)
+func (d *SQLiteDriver) GetTriggers(ctx context.Context, db *sql.DB, tablesByName map[string]*schema.Table) error {
+	filter, args := d.tableFilter("tbl_name")
+	rows, err := d.query(ctx, db, "SELECT tbl_name, name, sql FROM sqlite_master WHERE type = 'trigger'"+filter, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var triggers []*SQLiteTrigger
 	for rows.Next() {
-		var name, sqlContent string
-		if err := rows.Scan(&name, &sqlContent); err != nil {
-			return nil, err
+		var tableName, name, sqlContent string
+		if err := rows.Scan(&tableName, &name, &sqlContent); err != nil {
+			return err
 		}
-		triggers = append(triggers, &SQLiteTrigger{
+
+		table, ok := tablesByName[tableName]
+		if !ok {
+			continue
+		}
+
+		table.Triggers = append(table.Triggers, &schema.Trigger{
 			Name: name,
-			SQL:  sqlContent,
+			Def:  sqlContent,
 		})
 	}
-	return triggers, nil
+	return rows.Err()
 }
 
-func (d *SQLiteDriver) GetViews(ctx context.Context, db *sql.DB) ([]*SQLiteView, error) {
-	rows, err := db.QueryContext(ctx, "SELECT name, sql FROM sqlite_master WHERE type = 'view' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+func (d *SQLiteDriver) GetViews(ctx context.Context, db *sql.DB) ([]*schema.View, error) {
+	rows, err := d.query(ctx, db, "SELECT name, sql FROM sqlite_master WHERE type = 'view' AND name NOT LIKE 'sqlite_%' ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var views []*SQLiteView
+	var views []*schema.View
 	for rows.Next() {
 		var name, sqlContent string
 		if err := rows.Scan(&name, &sqlContent); err != nil {
 			return nil, err
 		}
-		views = append(views, &SQLiteView{
+		views = append(views, &schema.View{
 			Name: name,
-			SQL:  sqlContent,
+			Def:  sqlContent,
 		})
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = d.GetViewTriggers(ctx, db, lo.KeyBy(views, func(v *schema.View) string { return v.Name }))
+	if err != nil {
+		return nil, err
+	}
 	return views, nil
 }
 
-func (d *SQLiteDriver) GetTableForeignKeys(ctx context.Context, db *sql.DB, tableName string) ([]*SQLiteForeignKey, error) {
-	rows, err := db.QueryContext(ctx, "PRAGMA foreign_key_list("+tableName+");")
+// GetViewTriggers reads the triggers defined on views, INSTEAD OF triggers
+// being the only ones SQLite allows on them.
+func (d *SQLiteDriver) GetViewTriggers(ctx context.Context, db *sql.DB, viewsByName map[string]*schema.View) error {
+	rows, err := d.query(ctx, db, "SELECT tbl_name, name, sql FROM sqlite_master WHERE type = 'trigger' ORDER BY name")
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	foreignKeysMap := make(map[int]*SQLiteForeignKey)
-
 	for rows.Next() {
-		var id, seq int
-		var table, from, to, onUpdate, onDelete, match string
-		if err := rows.Scan(&id, &seq, &table, &from, &to, &onUpdate, &onDelete, &match); err != nil {
-			return nil, err
+		var viewName, name, sqlContent string
+		if err := rows.Scan(&viewName, &name, &sqlContent); err != nil {
+			return err
 		}
 
-		foreignKey, exists := foreignKeysMap[id]
-		if !exists {
-			foreignKey = &SQLiteForeignKey{
-				Table:    table,
-				From:     []string{},
-				To:       []string{},
-				OnUpdate: onUpdate,
-				OnDelete: onDelete,
-			}
-			foreignKeysMap[id] = foreignKey
+		view, ok := viewsByName[viewName]
+		if !ok {
+			continue
 		}
 
-		foreignKey.From = append(foreignKey.From, from)
-		foreignKey.To = append(foreignKey.To, to)
+		view.Triggers = append(view.Triggers, &schema.Trigger{
+			Name: name,
+			Def:  sqlContent,
+		})
 	}
+	return rows.Err()
+}
 
-	foreignKeysSet := lo.Values(foreignKeysMap)
+// tableFilter returns the condition restricting a query to the tables set
+// with WithTables, column holding table names, along with its arguments.
+// It is empty when every table is read.
+func (d *SQLiteDriver) tableFilter(column string) (string, []any) {
+	if len(d.Tables) == 0 {
+		return "", nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(d.Tables)), ", ")
+	return " AND " + column + " IN (" + placeholders + ")", lo.ToAnySlice(d.Tables)
+}
 
-	sort.SliceStable(foreignKeysSet, func(i, j int) bool {
-		a := foreignKeysSet[i]
-		b := foreignKeysSet[j]
+// sortForeignKeys orders foreign keys deterministically, as SQLite does not
+// guarantee the order of their ids.
+func sortForeignKeys(foreignKeys []*schema.ForeignKey) []*schema.ForeignKey {
+	sort.SliceStable(foreignKeys, func(i, j int) bool {
+		a := foreignKeys[i]
+		b := foreignKeys[j]
 
 		if a.Table != b.Table {
 			return a.Table < b.Table
@@ -435,5 +857,5 @@ func (d *SQLiteDriver) GetTableForeignKeys(ctx context.Context, db *sql.DB, tabl
 		return false
 	})
 
-	return foreignKeysSet, nil
+	return foreignKeys
 }
@@ -0,0 +1,8 @@
+//go:build cgo
+
+package drivers
+
+import _ "github.com/mattn/go-sqlite3"
+
+// defaultSQLiteDriverName is go-sqlite3, which links SQLite through cgo.
+const defaultSQLiteDriverName = "sqlite3"
@@ -1,32 +1,13 @@
 package drivers
 
 import (
-	"database/sql"
 	"fmt"
-)
-
-type SQLiteColumn struct {
-	Name       string
-	Type       string
-	NotNull    bool
-	PrimaryKey bool
-	Default    sql.NullString
-}
-
-func (c *SQLiteColumn) Copy() *SQLiteColumn {
-	new := *c
-	return &new
-}
-
-func (c *SQLiteColumn) HasEqualAttributes(other *SQLiteColumn) bool {
-	copy := c.Copy()
-	copy.Name = other.Name
 
-	return *copy == *other
-}
+	"github.com/quantumsheep/dbdiff/schema"
+)
 
-func (c *SQLiteColumn) String() string {
-	value := fmt.Sprintf("\"%s\" %s", c.Name, c.Type)
+func (r *SQLiteRenderer) ColumnDefinition(c *schema.Column) string {
+	value := fmt.Sprintf("%s %s", sqliteIdentifier(c.Name), c.Type)
 	if c.NotNull {
 		value += " NOT NULL"
 	}
@@ -40,7 +21,7 @@ func (c *SQLiteColumn) String() string {
 	return value
 }
 
-func (c *SQLiteColumn) IsTypeChangeCompatible(other *SQLiteColumn) bool {
+func (r *SQLiteRenderer) IsTypeChangeCompatible(c *schema.Column, other *schema.Column) bool {
 	// In SQLite, most type changes are compatible due to dynamic typing,
 	// but changing between certain types may lead to data loss or unexpected behavior.
 	// Here we define a simple rule: changing between TEXT, INTEGER, REAL, BLOB is compatible,
@@ -4,29 +4,22 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/quantumsheep/dbdiff/schema"
 	"github.com/samber/lo"
 )
 
-type SQLiteForeignKey struct {
-	Table    string
-	From     []string
-	To       []string
-	OnUpdate string
-	OnDelete string
-}
-
-func (fk *SQLiteForeignKey) String() string {
+func (r *SQLiteRenderer) ForeignKeyDefinition(fk *schema.ForeignKey) string {
 	fromColumnsQuoted := lo.Map(fk.From, func(c string, _ int) string {
-		return fmt.Sprintf("\"%s\"", c)
+		return sqliteIdentifier(c)
 	})
 	toColumnsQuoted := lo.Map(fk.To, func(c string, _ int) string {
-		return fmt.Sprintf("\"%s\"", c)
+		return sqliteIdentifier(c)
 	})
 
 	fromColumns := strings.Join(fromColumnsQuoted, ", ")
 	toColumns := strings.Join(toColumnsQuoted, ", ")
 
-	s := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES \"%s\" (%s)", fromColumns, fk.Table, toColumns)
+	s := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", fromColumns, sqliteIdentifier(fk.Table), toColumns)
 	if fk.OnUpdate != "NO ACTION" && fk.OnUpdate != "" {
 		s += fmt.Sprintf(" ON UPDATE %s", fk.OnUpdate)
 	}
@@ -35,23 +28,3 @@ func (fk *SQLiteForeignKey) String() string {
 	}
 	return s
 }
-
-func (fk *SQLiteForeignKey) Equal(other *SQLiteForeignKey) bool {
-	if fk.Table != other.Table || fk.OnUpdate != other.OnUpdate || fk.OnDelete != other.OnDelete {
-		return false
-	}
-	if len(fk.From) != len(other.From) || len(fk.To) != len(other.To) {
-		return false
-	}
-	for i := range fk.From {
-		if fk.From[i] != other.From[i] {
-			return false
-		}
-	}
-	for i := range fk.To {
-		if fk.To[i] != other.To[i] {
-			return false
-		}
-	}
-	return true
-}
@@ -4,46 +4,82 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/quantumsheep/dbdiff/schema"
 	"github.com/samber/lo"
 )
 
-type SQLiteIndex struct {
-	Table   string
-	Name    string
-	Columns []string
-	Unique  bool
-}
-
-func (i *SQLiteIndex) Equal(other *SQLiteIndex) bool {
-	if i.Name != other.Name || i.Table != other.Table || i.Unique != other.Unique {
-		return false
-	}
-
-	if len(i.Columns) != len(other.Columns) {
-		return false
-	}
-
-	for idx, col := range i.Columns {
-		if col != other.Columns[idx] {
-			return false
-		}
+func (r *SQLiteRenderer) CreateIndex(i *schema.Index) string {
+	// Indexes on expressions keep the statement creating them, as their keys
+	// are not column names
+	if i.Def != "" {
+		return i.Def + ";"
 	}
 
-	return true
-}
-
-func (i *SQLiteIndex) String() string {
 	createIndex := "CREATE "
 	if i.Unique {
 		createIndex += "UNIQUE "
 	}
 
 	quotedColumns := lo.Map(i.Columns, func(c string, _ int) string {
-		return fmt.Sprintf("\"%s\"", c)
+		return sqliteIdentifier(c)
 	})
 	columns := strings.Join(quotedColumns, ", ")
 
-	createIndex += fmt.Sprintf("INDEX \"%s\" ON \"%s\" (%s);", i.Name, i.Table, columns)
+	createIndex += fmt.Sprintf("INDEX %s ON %s (%s);", sqliteIdentifier(i.Name), sqliteIdentifier(i.Table), columns)
 
 	return createIndex
 }
+
+// RenderIndexes drops the removed and modified indexes, then creates the
+// added and modified ones.
+func (r *SQLiteRenderer) RenderIndexes(changes []*schema.Change[*schema.Index]) []string {
+	return append(r.dropIndexes(changes), r.createIndexes(changes)...)
+}
+
+func (r *SQLiteRenderer) dropIndexes(changes []*schema.Change[*schema.Index]) []string {
+	var statements []string
+
+	for _, change := range changes {
+		if change.Kind != schema.Added {
+			statements = append(statements, fmt.Sprintf("DROP INDEX %s;", sqliteIdentifier(change.Target.Name)))
+		}
+	}
+
+	return statements
+}
+
+func (r *SQLiteRenderer) createIndexes(changes []*schema.Change[*schema.Index]) []string {
+	var statements []string
+
+	for _, change := range changes {
+		if change.Kind != schema.Removed {
+			statements = append(statements, r.CreateIndex(change.Source))
+		}
+	}
+
+	return statements
+}
+
+// sqliteIndexKeys returns the keys of the index created by def, the
+// columns or expressions between the parentheses following the table name.
+func sqliteIndexKeys(def string) ([]string, bool) {
+	for i := 0; i < len(def); i++ {
+		switch c := def[i]; c {
+		case '\'', '"', '`':
+			i = quotedEnd(def, i, c) - 1
+		case '[':
+			end := strings.IndexByte(def[i:], ']')
+			if end < 0 {
+				return nil, false
+			}
+			i += end
+		case '(':
+			keys, _, ok := parenthesized(def, i)
+			if !ok {
+				return nil, false
+			}
+			return splitTopLevel(keys, ','), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,110 @@
+package drivers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// sqliteKey removes the _key parameter from a SQLite data source name and
+// returns it, or fallback when the data source name has none.
+func sqliteKey(dsn string, fallback string) (string, string) {
+	path, rawQuery, found := strings.Cut(dsn, "?")
+	if !found {
+		return dsn, fallback
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil || !query.Has("_key") {
+		// Leave it to the driver to report
+		return dsn, fallback
+	}
+
+	key := query.Get("_key")
+	query.Del("_key")
+	if len(query) == 0 {
+		return path, key
+	}
+	return path + "?" + query.Encode(), key
+}
+
+// openSQLite opens the SQLite database at dsn with the database/sql driver
+// registered as driverName, unlocking every connection with key when set.
+func openSQLite(driverName string, dsn string, key string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil || key == "" {
+		return db, err
+	}
+
+	connector := &sqliteKeyConnector{driver: db.Driver(), dsn: dsn, key: key}
+	db.Close()
+
+	return sql.OpenDB(connector), nil
+}
+
+// ErrSQLCipherUnsupported is returned when opening encrypted SQLite
+// databases with a SQLite library lacking SQLCipher, see the sqlcipher build
+// tag.
+var ErrSQLCipherUnsupported = errors.New("SQLite library lacks SQLCipher support, build dbdiff with the sqlcipher tag to open encrypted databases")
+
+// sqliteKeyConnector runs PRAGMA key on each new connection, which must come
+// before any other statement for SQLCipher to decrypt the database.
+type sqliteKeyConnector struct {
+	driver driver.Driver
+	dsn    string
+	key    string
+}
+
+func (c *sqliteKeyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.unlock(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (c *sqliteKeyConnector) unlock(ctx context.Context, conn driver.Conn) error {
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return ErrSQLCipherUnsupported
+	}
+
+	_, err := execer.ExecContext(ctx, "PRAGMA key = '"+strings.ReplaceAll(c.key, "'", "''")+"'", nil)
+	if err != nil {
+		return err
+	}
+
+	// Plain SQLite silently ignores PRAGMA key and its unknown cipher_version
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return ErrSQLCipherUnsupported
+	}
+
+	rows, err := queryer.QueryContext(ctx, "PRAGMA cipher_version", nil)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := make([]driver.Value, len(rows.Columns()))
+	err = rows.Next(values)
+	if err == io.EOF {
+		return ErrSQLCipherUnsupported
+	}
+	return err
+}
+
+func (c *sqliteKeyConnector) Driver() driver.Driver {
+	return c.driver
+}
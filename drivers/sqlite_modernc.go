@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package drivers
+
+import _ "modernc.org/sqlite"
+
+// defaultSQLiteDriverName is modernc.org/sqlite on builds without cgo, such
+// as CGO_ENABLED=0 ones, SQLite being translated to Go there.
+const defaultSQLiteDriverName = "sqlite"
@@ -0,0 +1,92 @@
+package drivers
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeSQLiteSQL returns a canonical form of a statement, as stored
+// verbatim by SQLite for views and triggers, so that statements only
+// differing by comments, whitespace, keyword case or identifier quoting
+// compare equal. String literals and identifiers that need quoting are kept
+// as they are.
+func NormalizeSQLiteSQL(sql string) string {
+	var tokens []string
+
+	runes := []rune(sql)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 3
+			for i < len(runes) && !(runes[i-1] == '*' && runes[i] == '/') {
+				i++
+			}
+			i++
+		case c == '\'':
+			end := quoteEnd(runes, i, '\'')
+			tokens = append(tokens, string(runes[i:end]))
+			i = end
+		case c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			end := quoteEnd(runes, i, closing)
+			tokens = append(tokens, normalizeIdentifier(string(runes[i+1:end-1]), closing))
+			i = end
+		case isIdentifierRune(c):
+			start := i
+			for i < len(runes) && isIdentifierRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, strings.ToLower(string(runes[start:i])))
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+// quoteEnd returns the index following the quote closing the one at start,
+// where doubling the closing quote escapes it.
+func quoteEnd(runes []rune, start int, closing rune) int {
+	for i := start + 1; i < len(runes); i++ {
+		if runes[i] != closing {
+			continue
+		}
+		if closing != ']' && i+1 < len(runes) && runes[i+1] == closing {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(runes)
+}
+
+// normalizeIdentifier unquotes identifiers that do not need quoting. SQLite
+// identifiers are case-insensitive, so they are folded like keywords.
+func normalizeIdentifier(identifier string, closing rune) string {
+	if closing != ']' {
+		identifier = strings.ReplaceAll(identifier, string(closing)+string(closing), string(closing))
+	}
+
+	if identifier == "" || unicode.IsDigit([]rune(identifier)[0]) || strings.IndexFunc(identifier, func(c rune) bool { return !isIdentifierRune(c) }) >= 0 {
+		return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+	}
+
+	return strings.ToLower(identifier)
+}
+
+func isIdentifierRune(c rune) bool {
+	return c == '_' || c == '$' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
@@ -0,0 +1,192 @@
+package drivers
+
+import (
+	"fmt"
+	"iter"
+	"regexp"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/samber/lo"
+)
+
+// SQLiteRenderer renders schema diffs as SQLite statements.
+type SQLiteRenderer struct {
+	// Annotate prefixes statements with a comment explaining why they were
+	// generated.
+	Annotate bool
+
+	// BumpsUserVersion leaves user_version differences to the statement
+	// ending the plan, see WithUserVersion.
+	BumpsUserVersion bool
+}
+
+func (r *SQLiteRenderer) Render(diff *schema.Diff) iter.Seq2[string, error] {
+	return flattenObjects(r.RenderObjects(diff))
+}
+
+func (r *SQLiteRenderer) RenderObjects(diff *schema.Diff) iter.Seq2[*ObjectStatements, error] {
+	return emitObjects(func(emit ObjectEmitFunc) error {
+		// Renaming a recreated table into place fails while views refer to
+		// the table it replaces, so views are moved out of the way meanwhile
+		recreates := lo.SomeBy(diff.Tables, r.Recreates)
+		if recreates {
+			for _, view := range diff.Target.Views {
+				class := RebuildClass
+				if _, ok := diff.Source.ViewByName(view.Name); !ok {
+					class = DestructiveClass
+				}
+
+				statements := annotate(r.Annotate, r.DropViews([]*schema.View{view}), fmt.Sprintf("view %s dropped while tables are rebuilt", view.Name))
+				err := emit(schema.ViewObject, view.Name, class, statements...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, tableDiff := range diff.Tables {
+			for _, part := range splitTableDiff(tableDiff) {
+				reasons := part.TableDiff.Describe()
+				recreates, retyped := r.alterStrategy(part.TableDiff)
+				if recreates {
+					reasons = append(reasons, "table rebuild required")
+				}
+
+				// Retyped columns are dropped and added back, losing their
+				// values
+				class := tableClass(part.TableDiff, recreates)
+				if len(retyped) > 0 {
+					class = DestructiveClass
+				}
+
+				err := emit(part.Type, part.Name, class, annotate(r.Annotate, r.RenderTable(part.TableDiff), reasons...)...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if recreates {
+			for _, view := range diff.Source.Views {
+				class, reason := RebuildClass, fmt.Sprintf("view %s recreated after table rebuilds", view.Name)
+				if _, ok := diff.Target.ViewByName(view.Name); !ok {
+					class, reason = AdditiveClass, schema.DescribeView(&schema.Change[*schema.View]{Kind: schema.Added, Source: view})
+				}
+
+				statements := annotate(r.Annotate, r.CreateViews([]*schema.View{view}), reason)
+				err := emit(schema.ViewObject, view.Name, class, statements...)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			for _, change := range diff.Views {
+				statements := annotate(r.Annotate, r.RenderViews([]*schema.Change[*schema.View]{change}), schema.DescribeView(change))
+				err := emit(schema.ViewObject, changeName(change, viewName), changeClass(change), statements...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, change := range diff.Pragmas {
+			statements := r.RenderPragma(change)
+			if len(statements) == 0 {
+				continue
+			}
+			err := emit(PragmaObject, change.Source.Name, AdditiveClass, statements...)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// RenderPragma returns the statements bringing a pragma of the target to the
+// source value. The page size applies once the database is vacuumed, while
+// the encoding cannot change once the database holds tables, which is only
+// reported.
+func (r *SQLiteRenderer) RenderPragma(change *schema.Change[*schema.Pragma]) []string {
+	if change.Kind == schema.Removed || (r.BumpsUserVersion && change.Source.Name == "user_version") {
+		return nil
+	}
+
+	pragma := change.Source
+	switch pragma.Name {
+	case "encoding":
+		target := "none"
+		if change.Target != nil {
+			target = change.Target.Value
+		}
+		return []string{fmt.Sprintf("-- encoding is %s on the source and %s on the target, it cannot change once the database holds tables", pragma.Value, target)}
+	case "page_size":
+		return []string{fmt.Sprintf("PRAGMA page_size = %s;", pragma.Value), "VACUUM;"}
+	case "journal_mode":
+		return []string{fmt.Sprintf("PRAGMA journal_mode = %s;", strings.ToUpper(pragma.Value))}
+	}
+	return []string{fmt.Sprintf("PRAGMA %s = %s;", pragma.Name, pragma.Value)}
+}
+
+// sqliteImpact classifies SQLite statements: copying a table into its
+// rebuilt version and dropping columns rewrite it, building indexes reads it.
+var sqliteImpact = classifyByPatterns([]impactPattern{
+	{pattern: regexp.MustCompile(`(?is)^INSERT\s+INTO\s.*?\sSELECT\s.*\sFROM\s+` + statementIdentifier + `\s*;?\s*$`), rewrite: true},
+	{pattern: regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+` + statementIdentifier + `\s+DROP\s+(?:COLUMN\s+)?`), rewrite: true},
+	{pattern: updatePattern, rewrite: true},
+	{pattern: createIndexImpactPattern, scan: true},
+})
+
+var (
+	sqliteCopyPattern   = regexp.MustCompile(`(?is)^INSERT\s+INTO\s+` + statementIdentifier + `\s.*?\sSELECT\s.*\sFROM\s+` + statementIdentifier + `\s*;?\s*$`)
+	sqliteDropPattern   = regexp.MustCompile(`(?is)^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?` + statementIdentifier + `\s*;?\s*$`)
+	sqliteRenamePattern = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+` + statementIdentifier + `\s+RENAME\s+TO\s+` + statementIdentifier + `\s*;?\s*$`)
+)
+
+// sqliteStepChecks checks the table rebuilds of SQLite plans: before the
+// original table is dropped, its copy must hold as many rows, and once the
+// copy took its name, its foreign keys must hold.
+func sqliteStepChecks(statements []string) map[int][]StepCheck {
+	checks := map[int][]StepCheck{}
+
+	// copies maps the tables copied into another one to their copy
+	copies := map[string]string{}
+	for i, statement := range statements {
+		statement = statement[len(leadingComments.FindString(statement)):]
+
+		if match := sqliteCopyPattern.FindStringSubmatch(statement); match != nil {
+			copies[unqualifiedName(match[2])] = unqualifiedName(match[1])
+			continue
+		}
+
+		if match := sqliteDropPattern.FindStringSubmatch(statement); match != nil {
+			table := unqualifiedName(match[1])
+			if rebuilt, ok := copies[table]; ok {
+				checks[i] = append(checks[i], StepCheck{
+					Description: fmt.Sprintf("%s holds as many rows as %s", rebuilt, table),
+					Query:       fmt.Sprintf("SELECT (SELECT COUNT(*) FROM %s) = (SELECT COUNT(*) FROM %s)", sqliteIdentifier(rebuilt), sqliteIdentifier(table)),
+				})
+			}
+			continue
+		}
+
+		if match := sqliteRenamePattern.FindStringSubmatch(statement); match != nil {
+			from, to := unqualifiedName(match[1]), unqualifiedName(match[2])
+			if copies[to] == from {
+				checks[i+1] = append(checks[i+1], StepCheck{
+					Description: fmt.Sprintf("foreign keys of %s hold", to),
+					Query:       fmt.Sprintf("SELECT COUNT(*) = 0 FROM pragma_foreign_key_check('%s')", strings.ReplaceAll(to, "'", "''")),
+				})
+			}
+		}
+	}
+
+	return checks
+}
+
+// sqliteIdentifier quotes name as an SQLite identifier.
+func sqliteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,13 @@
+//go:build sqlcipher
+
+package drivers
+
+// Builds with the sqlcipher tag link against the system's SQLCipher library,
+// for go-sqlite3 to open encrypted databases. They need the libsqlite3 tag
+// too, so that go-sqlite3 leaves out its bundled SQLite, along with the path
+// to SQLCipher's headers:
+//
+//	CGO_CFLAGS=-I/usr/include/sqlcipher go build -tags "sqlcipher libsqlite3" ./cmd/dbdiff
+
+// #cgo LDFLAGS: -lsqlcipher
+import "C"
@@ -2,193 +2,148 @@ package drivers
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
+	"github.com/quantumsheep/dbdiff/schema"
 	"github.com/samber/lo"
 )
 
-type SQLiteTable struct {
-	Name        string
-	Columns     []*SQLiteColumn
-	Indexes     []*SQLiteIndex
-	Triggers    []*SQLiteTrigger
-	ForeignKeys []*SQLiteForeignKey
-}
-
-func (t *SQLiteTable) Copy() *SQLiteTable {
-	new := *t
-	return &new
-}
-
-func (t *SQLiteTable) ColumnByName(name string) (*SQLiteColumn, bool) {
-	for _, column := range t.Columns {
-		if column.Name == name {
-			return column, true
-		}
-	}
-	return nil, false
-}
-
-func (t *SQLiteTable) IndexByName(name string) (*SQLiteIndex, bool) {
-	for _, index := range t.Indexes {
-		if index.Name == name {
-			return index, true
-		}
-	}
-	return nil, false
-}
-
-func (t *SQLiteTable) TriggerByName(name string) (*SQLiteTrigger, bool) {
-	for _, trigger := range t.Triggers {
-		if trigger.Name == name {
-			return trigger, true
-		}
-	}
-	return nil, false
-}
-
-func (t *SQLiteTable) StringCreateTable() string {
+func (r *SQLiteRenderer) CreateTable(t *schema.Table) string {
 	var columnLines []string
 	for _, column := range t.Columns {
-		line := "\t" + column.String()
+		line := "\t" + r.ColumnDefinition(column)
 		columnLines = append(columnLines, line)
 	}
 
 	for _, fk := range t.ForeignKeys {
-		line := "\t" + fk.String()
+		line := "\t" + r.ForeignKeyDefinition(fk)
 		columnLines = append(columnLines, line)
 	}
 
 	createTableColumns := strings.Join(columnLines, ",\n")
-	return fmt.Sprintf("CREATE TABLE \"%s\" (\n%s\n);", t.Name, createTableColumns)
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);", sqliteIdentifier(t.Name), createTableColumns)
 }
 
-func (t *SQLiteTable) StringCreateIndexes() string {
-	var createIndexes []string
+// TableStatements returns the statements creating the table along with its
+// indexes and triggers.
+func (r *SQLiteRenderer) TableStatements(t *schema.Table) []string {
+	statements := []string{r.CreateTable(t)}
+
 	for _, index := range t.Indexes {
-		createIndexes = append(createIndexes, index.String())
+		statements = append(statements, r.CreateIndex(index))
 	}
 
-	return strings.Join(createIndexes, "\n")
-}
-
-func (t *SQLiteTable) StringCreateTriggers() string {
-	var createTriggers []string
 	for _, trigger := range t.Triggers {
-		createTriggers = append(createTriggers, trigger.SQL+";")
+		statements = append(statements, trigger.Def+";")
 	}
 
-	return strings.Join(createTriggers, "\n")
+	return statements
 }
 
-func (t *SQLiteTable) String() string {
-	str := t.StringCreateTable()
-
-	if indexes := t.StringCreateIndexes(); indexes != "" {
-		str += "\n" + indexes
+func (r *SQLiteRenderer) RenderTable(diff *schema.TableDiff) []string {
+	switch diff.Kind {
+	case schema.Added:
+		return r.TableStatements(diff.Source)
+	case schema.Removed:
+		return []string{fmt.Sprintf("DROP TABLE %s;", sqliteIdentifier(diff.Target.Name))}
 	}
 
-	if triggers := t.StringCreateTriggers(); triggers != "" {
-		str += "\n" + triggers
+	// A recreated table already comes with the source indexes and triggers
+	if r.Recreates(diff) {
+		return r.AlterTable(diff)
 	}
 
-	return str
-}
-
-type SQLiteTableColumnsDiff struct {
-	Added    []string
-	Modified []string
-	Removed  []string
-	Renamed  map[string]string // oldName -> newName
+	// Columns cannot be dropped while indexes refer to them
+	indexes := r.rebuiltIndexes(diff)
+	statements := r.dropIndexes(indexes)
+	statements = append(statements, r.AlterTable(diff)...)
+	statements = append(statements, r.createIndexes(indexes)...)
+	statements = append(statements, r.RenderTriggers(diff.Triggers)...)
 
-	ForeignKeysChanged bool
+	return statements
 }
 
-func (t *SQLiteTable) DiffColumns(other *SQLiteTable) *SQLiteTableColumnsDiff {
-	diff := &SQLiteTableColumnsDiff{
-		Added:              []string{},
-		Modified:           []string{},
-		Removed:            []string{},
-		Renamed:            make(map[string]string),
-		ForeignKeysChanged: false,
-	}
-
-	for _, sourceColumn := range t.Columns {
-		targetColumn, found := other.ColumnByName(sourceColumn.Name)
-
-		// New column
-		if !found {
-			// Maybe it's a renamed column?
-			renamedColumn, found := lo.Find(other.Columns, func(c *SQLiteColumn) bool {
-				_, existsInSourceTable := t.ColumnByName(c.Name)
-				return !existsInSourceTable && c.HasEqualAttributes(sourceColumn)
-			})
-			if found {
-				diff.Renamed[renamedColumn.Name] = sourceColumn.Name
-				continue
-			}
-
-			diff.Added = append(diff.Added, sourceColumn.Name)
+// rebuiltIndexes returns the index changes of a modified table, plus the
+// unchanged indexes on columns dropped and added back to change their type.
+func (r *SQLiteRenderer) rebuiltIndexes(diff *schema.TableDiff) []*schema.Change[*schema.Index] {
+	_, retyped := r.alterStrategy(diff)
+	indexes := diff.Indexes
+
+	for _, index := range diff.Source.Indexes {
+		changed := slices.ContainsFunc(diff.Indexes, func(change *schema.Change[*schema.Index]) bool {
+			return change.Kind != schema.Removed && change.Source.Name == index.Name
+		})
+		if changed || !slices.ContainsFunc(index.Columns, func(column string) bool { return slices.Contains(retyped, column) }) {
 			continue
 		}
 
-		if *sourceColumn == *targetColumn {
-			continue
-		}
+		target, _ := diff.Target.IndexByName(index.Name)
+		indexes = append(indexes, &schema.Change[*schema.Index]{Kind: schema.Modified, Source: index, Target: target})
+	}
 
-		if sourceColumn.Type != targetColumn.Type {
-			// Type change to compatible type should be done in table recreation
-			if sourceColumn.IsTypeChangeCompatible(targetColumn) {
-				diff.Modified = append(diff.Modified, sourceColumn.Name)
-				continue
-			}
+	return indexes
+}
 
-			diff.Removed = append(diff.Removed, targetColumn.Name)
-			diff.Added = append(diff.Added, sourceColumn.Name)
-			continue
-		}
+// Recreates reports whether migrating a modified table requires recreating
+// it, which drops and recreates its indexes and triggers.
+func (r *SQLiteRenderer) Recreates(diff *schema.TableDiff) bool {
+	recreate, _ := r.alterStrategy(diff)
+	return recreate
+}
 
-		diff.Modified = append(diff.Modified, sourceColumn.Name)
+// alterStrategy tells whether a modified table needs to be recreated and,
+// when it does not, which columns to drop and add back to change their type.
+func (r *SQLiteRenderer) alterStrategy(diff *schema.TableDiff) (recreate bool, retyped []string) {
+	if diff.Kind != schema.Modified {
+		return false, nil
 	}
 
-	// Removed columns
-	for _, targetColumn := range other.Columns {
-		_, found := t.ColumnByName(targetColumn.Name)
-		if !found && !lo.Contains(lo.Keys(diff.Renamed), targetColumn.Name) {
-			diff.Removed = append(diff.Removed, targetColumn.Name)
+	// Modified columns or Foreign Keys need to be handled via table
+	// recreation, except for type changes to incompatible types which drop
+	// and add the column back
+	recreate = diff.ForeignKeysChanged
+	for _, columnName := range diff.Columns.Modified {
+		sourceColumn, _ := diff.Source.ColumnByName(columnName)
+		targetColumn, _ := diff.Target.ColumnByName(columnName)
+
+		if slices.Contains(diff.Columns.Retyped, columnName) && !r.IsTypeChangeCompatible(sourceColumn, targetColumn) {
+			retyped = append(retyped, columnName)
+			continue
 		}
+
+		recreate = true
 	}
 
-	// Check if foreign keys changed
-	if len(t.ForeignKeys) != len(other.ForeignKeys) {
-		diff.ForeignKeysChanged = true
-	} else {
-		for _, sourceForeignKey := range t.ForeignKeys {
-			found := lo.SomeBy(other.ForeignKeys, func(fk *SQLiteForeignKey) bool {
-				return fk.Equal(sourceForeignKey)
-			})
-			if !found {
-				diff.ForeignKeysChanged = true
-				break
-			}
+	// Columns cannot be added NOT NULL without a default, even to be filled
+	// right away
+	for columnName := range diff.Columns.Copied {
+		column, _ := diff.Source.ColumnByName(columnName)
+		if column.NotNull && !column.Default.Valid {
+			recreate = true
 		}
 	}
 
-	return diff
+	return recreate, retyped
 }
 
-func (t *SQLiteTable) DiffTable(other *SQLiteTable) (string, error) {
-	columnsDiff := t.DiffColumns(other)
+// AlterTable returns the statements migrating the columns and foreign keys
+// of a modified table.
+func (r *SQLiteRenderer) AlterTable(diff *schema.TableDiff) []string {
+	t, other := diff.Source, diff.Target
+	columnsDiff := diff.Columns
 
-	var diff strings.Builder
+	recreate, retyped := r.alterStrategy(diff)
 
-	// Modified columns or Foreign Keys need to be handled via table recreation
-	if len(columnsDiff.Modified) > 0 || columnsDiff.ForeignKeysChanged {
+	var statements []string
+
+	if recreate {
 		tempTable := t.Copy()
 		tempTable.Name = "_" + t.Name + "_temp"
 
 		// Create temp table (table only; indexes recreated after rename)
-		fmt.Fprintf(&diff, "%s\n", tempTable.StringCreateTable())
+		statements = append(statements, r.CreateTable(tempTable))
 
 		// Reverse rename map: newName -> oldName
 		newToOld := lo.Invert(columnsDiff.Renamed)
@@ -198,17 +153,22 @@ func (t *SQLiteTable) DiffTable(other *SQLiteTable) (string, error) {
 		var selectColumns []string
 
 		for _, newCol := range t.Columns {
-			insertColumns = append(insertColumns, fmt.Sprintf("\"%s\"", newCol.Name))
+			insertColumns = append(insertColumns, sqliteIdentifier(newCol.Name))
 
 			// If the column existed before (same name), copy from old table
 			if _, ok := other.ColumnByName(newCol.Name); ok {
-				selectColumns = append(selectColumns, fmt.Sprintf("\"%s\"", newCol.Name))
+				selectColumns = append(selectColumns, sqliteIdentifier(newCol.Name))
 				continue
 			}
 
-			// If it was renamed, copy from old name
+			// If it was renamed or takes the values of another column, copy
+			// from that one
 			if oldName, ok := newToOld[newCol.Name]; ok {
-				selectColumns = append(selectColumns, fmt.Sprintf("\"%s\"", oldName))
+				selectColumns = append(selectColumns, sqliteIdentifier(oldName))
+				continue
+			}
+			if oldName, ok := columnsDiff.Copied[newCol.Name]; ok {
+				selectColumns = append(selectColumns, sqliteIdentifier(oldName))
 				continue
 			}
 
@@ -221,102 +181,68 @@ func (t *SQLiteTable) DiffTable(other *SQLiteTable) (string, error) {
 		}
 
 		// Copy data from old table to new temp table with explicit mapping
-		fmt.Fprintf(
-			&diff,
-			"INSERT INTO \"%s\" (%s) SELECT %s FROM \"%s\";\n",
-			tempTable.Name,
+		statements = append(statements, fmt.Sprintf(
+			"INSERT INTO %s (%s) SELECT %s FROM %s;",
+			sqliteIdentifier(tempTable.Name),
 			strings.Join(insertColumns, ", "),
 			strings.Join(selectColumns, ", "),
-			t.Name,
-		)
+			sqliteIdentifier(t.Name),
+		))
 
 		// Drop old table
-		fmt.Fprintf(&diff, "DROP TABLE \"%s\";\n", t.Name)
+		statements = append(statements, fmt.Sprintf("DROP TABLE %s;", sqliteIdentifier(t.Name)))
 
 		// Rename new table to old table's name
-		fmt.Fprintf(&diff, "ALTER TABLE \"%s\" RENAME TO \"%s\";\n", tempTable.Name, t.Name)
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", sqliteIdentifier(tempTable.Name), sqliteIdentifier(t.Name)))
 
-		// Recreate indexes (on final table name)
+		// Recreate indexes and triggers (on final table name), dropped
+		// along with the old table
 		for _, idx := range t.Indexes {
-			fmt.Fprintf(&diff, "%s\n", idx.String())
+			statements = append(statements, r.CreateIndex(idx))
+		}
+		for _, trigger := range t.Triggers {
+			statements = append(statements, trigger.Def+";")
 		}
 	} else {
-		for oldName, newName := range columnsDiff.Renamed {
-			fmt.Fprintf(&diff, "ALTER TABLE \"%s\" RENAME COLUMN \"%s\" TO \"%s\";\n", t.Name, oldName, newName)
+		for _, oldName := range slices.Sorted(maps.Keys(columnsDiff.Renamed)) {
+			newName := columnsDiff.Renamed[oldName]
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s;", sqliteIdentifier(t.Name), sqliteIdentifier(oldName), sqliteIdentifier(newName)))
 		}
 
-		for _, columnName := range columnsDiff.Removed {
-			fmt.Fprintf(&diff, "ALTER TABLE \"%s\" DROP COLUMN \"%s\";\n", t.Name, columnName)
+		for _, columnName := range slices.Concat(retyped, columnsDiff.Removed) {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", sqliteIdentifier(t.Name), sqliteIdentifier(columnName)))
 		}
 
-		for _, columnName := range columnsDiff.Added {
-			column, ok := t.ColumnByName(columnName)
-			if !ok {
-				return "", fmt.Errorf("internal error: added column %s not found in table %s", columnName, t.Name)
+		for _, column := range t.Columns {
+			if !slices.Contains(columnsDiff.Added, column.Name) && !slices.Contains(retyped, column.Name) {
+				continue
 			}
 
-			fmt.Fprintf(&diff, "ALTER TABLE \"%s\" ADD COLUMN %s;\n", t.Name, column.String())
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", sqliteIdentifier(t.Name), r.ColumnDefinition(column)))
+			if oldName, ok := columnsDiff.Copied[column.Name]; ok {
+				statements = append(statements, fmt.Sprintf("UPDATE %s SET %s = %s;", sqliteIdentifier(t.Name), sqliteIdentifier(column.Name), sqliteIdentifier(oldName)))
+			}
 		}
-
 	}
 
-	return strings.TrimSpace(diff.String()), nil
+	return statements
 }
 
-func (t *SQLiteTable) DiffTriggers(other *SQLiteTable) (string, error) {
-	var diff strings.Builder
-
-	for _, sourceTrigger := range t.Triggers {
-		targetTrigger, found := other.TriggerByName(sourceTrigger.Name)
-		if !found {
-			// New trigger
-			fmt.Fprintf(&diff, "%s;\n", sourceTrigger.SQL)
-			continue
-		}
+func (r *SQLiteRenderer) RenderTriggers(changes []*schema.Change[*schema.Trigger]) []string {
+	var statements []string
 
-		if sourceTrigger.SQL != targetTrigger.SQL {
+	for _, change := range changes {
+		switch change.Kind {
+		case schema.Added:
+			statements = append(statements, change.Source.Def+";")
+		case schema.Modified:
 			// Modified trigger: drop and recreate
-			fmt.Fprintf(&diff, "DROP TRIGGER \"%s\";\n", targetTrigger.Name)
-			fmt.Fprintf(&diff, "%s;\n", sourceTrigger.SQL)
-		}
-	}
-
-	for _, targetTrigger := range other.Triggers {
-		_, found := t.TriggerByName(targetTrigger.Name)
-		if !found {
-			// Removed trigger
-			fmt.Fprintf(&diff, "DROP TRIGGER \"%s\";\n", targetTrigger.Name)
-		}
-	}
-
-	return diff.String(), nil
-}
-
-func (t *SQLiteTable) DiffIndexes(other *SQLiteTable) (string, error) {
-	var diff strings.Builder
-
-	for _, sourceIndex := range t.Indexes {
-		targetIndex, found := other.IndexByName(sourceIndex.Name)
-		if !found {
-			// New index
-			fmt.Fprintf(&diff, "%s\n", sourceIndex.String())
-			continue
-		}
-
-		if !sourceIndex.Equal(targetIndex) {
-			// Modified index: drop and recreate
-			fmt.Fprintf(&diff, "DROP INDEX \"%s\";\n", targetIndex.Name)
-			fmt.Fprintf(&diff, "%s\n", sourceIndex.String())
-		}
-	}
-
-	for _, targetIndex := range other.Indexes {
-		_, found := t.IndexByName(targetIndex.Name)
-		if !found {
-			// Removed index
-			fmt.Fprintf(&diff, "DROP INDEX \"%s\";\n", targetIndex.Name)
+			statements = append(statements, fmt.Sprintf("DROP TRIGGER %s;", sqliteIdentifier(change.Target.Name)))
+			statements = append(statements, change.Source.Def+";")
+		case schema.Removed:
+			statements = append(statements, fmt.Sprintf("DROP TRIGGER %s;", sqliteIdentifier(change.Target.Name)))
 		}
 	}
 
-	return diff.String(), nil
+	return statements
 }
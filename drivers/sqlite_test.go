@@ -1,11 +1,19 @@
 package drivers
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
+	"github.com/quantumsheep/dbdiff/schema"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,14 +31,14 @@ func (d *TestingSQLiteDriver) Close() error {
 func (d *TestingSQLiteDriver) ExecOnSource(sqlStatements string) {
 	d.tb.Helper()
 
-	_, err := d.SourceDatabaseConnection.Exec(sqlStatements)
+	_, err := d.SourceDatabaseConnection.ExecContext(d.tb.Context(), sqlStatements)
 	require.NoError(d.tb, err)
 }
 
 func (d *TestingSQLiteDriver) ExecOnTarget(sqlStatements string) {
 	d.tb.Helper()
 
-	_, err := d.TargetDatabaseConnection.Exec(sqlStatements)
+	_, err := d.TargetDatabaseConnection.ExecContext(d.tb.Context(), sqlStatements)
 	require.NoError(d.tb, err)
 }
 
@@ -47,10 +55,12 @@ func (d *TestingSQLiteDriver) RequireDiff(expectedDiff string) string {
 func (d *TestingSQLiteDriver) FetchAllFromTarget(table string, additionalRules string) []map[string]any {
 	d.tb.Helper()
 
-	columns, err := d.GetTableColumns(d.tb.Context(), d.TargetDatabaseConnection, table)
+	tableSchema := &schema.Table{Name: table}
+	err := d.GetColumns(d.tb.Context(), d.TargetDatabaseConnection, map[string]*schema.Table{table: tableSchema})
 	require.NoError(d.tb, err)
+	columns := tableSchema.Columns
 
-	rows, err := d.TargetDatabaseConnection.Query(fmt.Sprintf("SELECT * FROM %q %s;", table, additionalRules))
+	rows, err := d.TargetDatabaseConnection.QueryContext(d.tb.Context(), fmt.Sprintf("SELECT * FROM %q %s;", table, additionalRules))
 	require.NoError(d.tb, err)
 
 	var results []map[string]any
@@ -83,10 +93,10 @@ func NewTestSQLiteDriver(tb testing.TB) *TestingSQLiteDriver {
 	sourceDatabasePath := filepath.Join(tb.TempDir(), "source.sqlite")
 	targetDatabasePath := filepath.Join(tb.TempDir(), "target.sqlite")
 
-	driver, err := NewSQLiteDriver(&SQLLiteDriverConfig{
-		SourceDatabasePath: sourceDatabasePath,
-		TargetDatabasePath: targetDatabasePath,
-	})
+	driver, err := NewSQLiteDriver(
+		WithSourceDSN(sourceDatabasePath),
+		WithTargetDSN(targetDatabasePath),
+	)
 	require.NoError(tb, err)
 	tb.Cleanup(func() {
 		require.NoError(tb, driver.Close())
@@ -105,6 +115,25 @@ func TestSQLiteDriver(t *testing.T) {
 		driver.RequireDiff(``)
 	})
 
+	t.Run("Introspect", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "database.sqlite")
+
+		driver, err := NewSQLiteDriver(WithSourceDSN(path), WithTargetDSN(path), WithReadOnly(false))
+		require.NoError(t, err)
+		require.NoError(t, driver.Exec(t.Context(), SourceSide, `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);`))
+		require.NoError(t, driver.Close())
+
+		s, err := Introspect(t.Context(), path, "sqlite3")
+		require.NoError(t, err)
+		require.Equal(t, "sqlite3", s.Dialect)
+		require.Len(t, s.Tables, 1)
+		require.Equal(t, "users", s.Tables[0].Name)
+		require.Len(t, s.Tables[0].Columns, 2)
+
+		_, err = Introspect(t.Context(), path, "oracle")
+		require.EqualError(t, err, "unsupported driver: oracle")
+	})
+
 	t.Run("CreateTables", func(t *testing.T) {
 		driver := NewTestSQLiteDriver(t)
 
@@ -392,6 +421,25 @@ ALTER TABLE "_users_temp" RENAME TO "users";`)
 		driver.ExecOnTarget(diff)
 	})
 
+	t.Run("ExpressionIndexes", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+			CREATE INDEX idx_lower ON users (lower(name), id);
+		`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);`)
+
+		diff := driver.RequireDiff(`CREATE INDEX idx_lower ON users (lower(name), id);`)
+
+		driver.ExecOnTarget(diff)
+		driver.RequireDiff(``)
+
+		s, err := driver.Introspect(t.Context(), SourceSide)
+		require.NoError(t, err)
+		require.Equal(t, []string{"lower(name)", "id"}, s.Tables[0].Indexes[0].Columns)
+	})
+
 	t.Run("ModifyIndexes", func(t *testing.T) {
 		driver := NewTestSQLiteDriver(t)
 
@@ -492,6 +540,91 @@ DROP VIEW "old_view";`
 		driver.ExecOnTarget(diff)
 	})
 
+	t.Run("ViewTriggers", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+			CREATE VIEW users_view AS SELECT id, name FROM users;
+			CREATE TRIGGER users_view_insert INSTEAD OF INSERT ON users_view BEGIN INSERT INTO users (name) VALUES (NEW.name); END;
+			CREATE VIEW admins_view AS SELECT name FROM users WHERE name = 'admin';
+			CREATE TRIGGER admins_view_delete INSTEAD OF DELETE ON admins_view BEGIN DELETE FROM users WHERE name = OLD.name; END;
+		`)
+
+		driver.ExecOnTarget(`
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+			CREATE VIEW users_view AS SELECT id, name FROM users;
+			CREATE TRIGGER users_view_insert INSTEAD OF INSERT ON users_view BEGIN SELECT 1; END;
+		`)
+
+		expected := `CREATE VIEW admins_view AS SELECT name FROM users WHERE name = 'admin';
+CREATE TRIGGER admins_view_delete INSTEAD OF DELETE ON admins_view BEGIN DELETE FROM users WHERE name = OLD.name; END;
+DROP TRIGGER "users_view_insert";
+CREATE TRIGGER users_view_insert INSTEAD OF INSERT ON users_view BEGIN INSERT INTO users (name) VALUES (NEW.name); END;`
+
+		diff := driver.RequireDiff(expected)
+
+		driver.ExecOnTarget(diff)
+		driver.RequireDiff(``)
+
+		// Rebuilding a table drops the views, their triggers are created
+		// again along with them
+		driver.ExecOnSource(`ALTER TABLE users RENAME TO old_users; CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL); DROP TABLE old_users;`)
+		diff, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.Contains(t, diff, "CREATE TRIGGER users_view_insert INSTEAD OF INSERT")
+
+		driver.ExecOnTarget(diff)
+		target, err := driver.Introspect(t.Context(), TargetSide)
+		require.NoError(t, err)
+		view, _ := target.ViewByName("users_view")
+		_, found := view.TriggerByName("users_view_insert")
+		require.True(t, found)
+	})
+
+	t.Run("Pragmas", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`PRAGMA user_version = 7; PRAGMA application_id = 1234; PRAGMA journal_mode = WAL; CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+		driver.ExecOnTarget(`PRAGMA user_version = 3; CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+
+		// Pragmas are left out unless asked for
+		driver.RequireDiff(``)
+
+		driver.Pragmas = true
+		diff := driver.RequireDiff(`PRAGMA application_id = 1234;
+PRAGMA journal_mode = WAL;
+PRAGMA user_version = 7;`)
+
+		driver.ExecOnTarget(diff)
+		driver.RequireDiff(``)
+	})
+
+	t.Run("UserVersion", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`PRAGMA user_version = 9; CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+		driver.ExecOnTarget(`PRAGMA user_version = 3;`)
+
+		driver.UserVersion = AutoUserVersion
+		driver.BumpsUserVersion = true
+		diff := driver.RequireDiff(`CREATE TABLE "users" (
+	"id" INTEGER PRIMARY KEY
+);
+PRAGMA user_version = 4;`)
+
+		// Plans changing nothing leave the user_version alone
+		driver.ExecOnTarget(diff)
+		driver.RequireDiff(``)
+
+		driver.ExecOnSource(`CREATE TABLE posts (id INTEGER PRIMARY KEY);`)
+		driver.UserVersion = "42"
+		driver.RequireDiff(`CREATE TABLE "posts" (
+	"id" INTEGER PRIMARY KEY
+);
+PRAGMA user_version = 42;`)
+	})
+
 	t.Run("ForeignKeys", func(t *testing.T) {
 		driver := NewTestSQLiteDriver(t)
 
@@ -538,4 +671,909 @@ ALTER TABLE "_posts_temp" RENAME TO "posts";`
 			{"id": int64(2), "user_id": int64(1), "title": "Second Post"},
 		}, rows)
 	})
+
+	t.Run("ForeignKeysToPrimaryKey", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+			CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users);
+		`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);`)
+
+		// The parent column is left out, so the primary key of users is read
+		diff := driver.RequireDiff(`CREATE TABLE "posts" (
+	"id" INTEGER PRIMARY KEY,
+	"user_id" INTEGER,
+	FOREIGN KEY ("user_id") REFERENCES "users" ("id")
+);`)
+
+		driver.ExecOnTarget(diff)
+		driver.RequireDiff(``)
+
+		// Parents without a primary key cannot be referenced that way
+		driver.ExecOnSource(`CREATE TABLE tags (name TEXT); CREATE TABLE post_tags (tag TEXT REFERENCES tags);`)
+		_, err := driver.Diff(t.Context())
+		require.ErrorContains(t, err, "foreign key of post_tags references the primary key of tags, which has none")
+	})
+
+	t.Run("ManyTables", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		var expected []string
+		for i := range 3 * DefaultConcurrency {
+			driver.ExecOnSource(fmt.Sprintf(`CREATE TABLE t%02d (id INTEGER PRIMARY KEY);`, i))
+			expected = append(expected, fmt.Sprintf("CREATE TABLE \"t%02d\" (\n\t\"id\" INTEGER PRIMARY KEY\n);", i))
+		}
+
+		// Tables are introspected in bulk but must keep their catalog order
+		driver.RequireDiff(strings.Join(expected, "\n"))
+	})
+
+	t.Run("Statements", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+			CREATE INDEX idx_users_name ON users (name);
+			CREATE VIEW users_view AS SELECT name FROM users;
+		`)
+
+		var statements []string
+		for statement, err := range driver.Statements(t.Context()) {
+			require.NoError(t, err)
+			statements = append(statements, statement)
+		}
+
+		require.Equal(t, []string{
+			"CREATE TABLE \"users\" (\n\t\"id\" INTEGER PRIMARY KEY,\n\t\"name\" TEXT\n);",
+			`CREATE INDEX "idx_users_name" ON "users" ("name");`,
+			`CREATE VIEW users_view AS SELECT name FROM users;`,
+		}, statements)
+
+		// Stopping early must not yield any further statement
+		count := 0
+		for _, err := range driver.Statements(t.Context()) {
+			require.NoError(t, err)
+			count++
+			break
+		}
+		require.Equal(t, 1, count)
+	})
+
+	t.Run("IntrospectCompareRender", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+
+		source, err := driver.Introspect(t.Context(), SourceSide)
+		require.NoError(t, err)
+		require.Equal(t, "sqlite3", source.Dialect)
+
+		target, err := driver.Introspect(t.Context(), TargetSide)
+		require.NoError(t, err)
+
+		diff := schema.Compare(source, target)
+		require.Len(t, diff.Tables, 1)
+		require.Equal(t, []string{"name"}, diff.Tables[0].Columns.Added)
+
+		statements, err := collectStatements(driver.Render(diff))
+		require.NoError(t, err)
+		require.Equal(t, `ALTER TABLE "users" ADD COLUMN "name" TEXT;`, statements)
+
+		// The same introspection can be compared against another schema
+		require.True(t, schema.Compare(source, source).IsEmpty())
+	})
+
+	t.Run("SchemaJSON", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT '', email TEXT);
+			CREATE UNIQUE INDEX idx_users_email ON users (email);
+		`)
+
+		source, err := driver.Introspect(t.Context(), SourceSide)
+		require.NoError(t, err)
+
+		data, err := json.Marshal(source)
+		require.NoError(t, err)
+		require.Contains(t, string(data), `{"name":"name","type":"TEXT","notNull":true,"default":"''"}`)
+		require.Contains(t, string(data), `{"name":"email","type":"TEXT","default":null}`)
+
+		var decoded schema.Schema
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.Equal(t, source, &decoded)
+	})
+
+	t.Run("Snapshots", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+		target, err := driver.Introspect(t.Context(), TargetSide)
+		require.NoError(t, err)
+
+		snapshotted, err := NewSQLiteDriver(
+			WithSourceSnapshot(&schema.Schema{Dialect: "sqlite3"}),
+			WithTargetSnapshot(target),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, snapshotted.Close())
+		})
+
+		diff, err := snapshotted.Diff(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, `DROP TABLE "users";`, diff)
+
+		_, err = NewSQLiteDriver(WithSourceSnapshot(&schema.Schema{Dialect: "postgres"}))
+		require.EqualError(t, err, "postgres snapshot cannot be compared by the sqlite3 driver")
+	})
+
+	t.Run("Annotate", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, age INTEGER); CREATE TABLE posts (id INTEGER PRIMARY KEY);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, age TEXT);`)
+
+		source, err := driver.Introspect(t.Context(), SourceSide)
+		require.NoError(t, err)
+		target, err := driver.Introspect(t.Context(), TargetSide)
+		require.NoError(t, err)
+
+		renderer := &SQLiteRenderer{Annotate: true}
+		statements, err := collectStatements(renderer.Render(schema.Compare(source, target)))
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(statements, "-- column users.age type changed TEXT → INTEGER; table rebuild required\nCREATE TABLE \"_users_temp\""), statements)
+		require.Contains(t, statements, "-- table posts created\nCREATE TABLE \"posts\"")
+		require.Equal(t, 2, strings.Count(statements, "-- "))
+	})
+
+	t.Run("AnnotateViewsAfterRebuild", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, age INTEGER); CREATE VIEW ages AS SELECT age FROM users; CREATE VIEW ids AS SELECT id FROM users;`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, age TEXT); CREATE VIEW ages AS SELECT age FROM users;`)
+
+		source, err := driver.Introspect(t.Context(), SourceSide)
+		require.NoError(t, err)
+		target, err := driver.Introspect(t.Context(), TargetSide)
+		require.NoError(t, err)
+
+		renderer := &SQLiteRenderer{Annotate: true}
+		statements, err := collectStatements(renderer.Render(schema.Compare(source, target)))
+		require.NoError(t, err)
+		require.Contains(t, statements, "-- view ages recreated after table rebuilds\nCREATE VIEW")
+		require.Contains(t, statements, "-- view ids created\nCREATE VIEW")
+		require.NotContains(t, statements, "view ids recreated")
+	})
+
+	t.Run("ObjectStatements", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT); CREATE INDEX users_name ON users (name); CREATE VIEW names AS SELECT name FROM users;`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT); CREATE INDEX users_id ON users (id);`)
+
+		var objects []string
+		for object, err := range driver.ObjectStatements(t.Context()) {
+			require.NoError(t, err)
+			objects = append(objects, fmt.Sprintf("%s %s: %s", object.Type, object.Name, strings.Join(object.Statements, " ")))
+		}
+		require.Equal(t, []string{
+			`index users_name: CREATE INDEX "users_name" ON "users" ("name");`,
+			`index users_id: DROP INDEX "users_id";`,
+			`view names: CREATE VIEW names AS SELECT name FROM users;`,
+		}, objects)
+	})
+
+	t.Run("StatementClass", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL); CREATE TABLE posts (id INTEGER PRIMARY KEY);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT); CREATE TABLE old (id INTEGER PRIMARY KEY);`)
+
+		plan, err := CollectPlan(t.Context(), driver)
+		require.NoError(t, err)
+
+		var classes []string
+		for _, object := range plan {
+			classes = append(classes, fmt.Sprintf("%s %s", object.Name, object.Class))
+		}
+		require.Equal(t, []string{"users rebuild", "posts additive", "old destructive"}, classes)
+		require.True(t, plan.HasDestructive())
+		require.True(t, plan.HasRebuild())
+
+		driver.ExecOnTarget(`DROP TABLE old;`)
+
+		plan, err = CollectPlan(t.Context(), driver)
+		require.NoError(t, err)
+		require.False(t, plan.HasDestructive())
+	})
+
+	t.Run("IgnoredColumns", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, age INTEGER, beta_opt_in INTEGER);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, legacy_flags TEXT, age TEXT); INSERT INTO users (legacy_flags, age) VALUES ('x', '1');`)
+
+		driver.IgnoredColumns = schema.IgnoredColumns{"users": {"legacy_flags", "beta_*"}}
+		statements, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.NotContains(t, statements, "beta_opt_in")
+
+		// The rebuild retyping age keeps the ignored column and its data
+		driver.ExecOnTarget(statements)
+		var flags string
+		require.NoError(t, driver.TargetDatabaseConnection.QueryRow(`SELECT legacy_flags FROM users`).Scan(&flags))
+		require.Equal(t, "x", flags)
+
+		statements, err = driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.Empty(t, statements)
+	})
+
+	t.Run("TableMapping", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE wp_users (id INTEGER PRIMARY KEY, email TEXT); CREATE INDEX wp_users_email ON wp_users (email); CREATE TABLE wp_posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES wp_users (id));`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT); CREATE INDEX users_email ON users (email);`)
+
+		mapping, err := schema.ParseNameMapping("wp_%=%")
+		require.NoError(t, err)
+
+		driver.TableMappings = []schema.NameMapping{mapping}
+		statements, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, `CREATE TABLE "posts" (
+	"id" INTEGER PRIMARY KEY,
+	"user_id" INTEGER,
+	FOREIGN KEY ("user_id") REFERENCES "users" ("id")
+);`, statements)
+
+		_, err = schema.ParseNameMapping("wp_=%")
+		require.Error(t, err)
+	})
+
+	t.Run("RedactedNames", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE patients (id INTEGER PRIMARY KEY, diagnosis TEXT); CREATE INDEX patients_diagnosis ON patients (diagnosis);`)
+		driver.ExecOnTarget(`CREATE TABLE patients (id INTEGER PRIMARY KEY);`)
+
+		driver.RedactNames, driver.RedactKey = true, "s3cret"
+		plan, err := CollectPlan(t.Context(), driver)
+		require.NoError(t, err)
+
+		statements := strings.Join(plan.Statements(), "\n")
+		require.NotContains(t, statements, "patients")
+		require.NotContains(t, statements, "diagnosis")
+		require.Regexp(t, `^ALTER TABLE "table_[0-9a-f]{8}" ADD COLUMN "column_[0-9a-f]{8}" TEXT;\nCREATE INDEX "index_[0-9a-f]{8}" ON "table_[0-9a-f]{8}" \("column_[0-9a-f]{8}"\);$`, statements)
+		require.Regexp(t, `^table_[0-9a-f]{8}$`, plan[0].Name)
+
+		// Pseudonyms only change along with the key
+		again, err := CollectPlan(t.Context(), driver)
+		require.NoError(t, err)
+		require.Equal(t, plan.Statements(), again.Statements())
+
+		driver.RedactKey = "other"
+		other, err := CollectPlan(t.Context(), driver)
+		require.NoError(t, err)
+		require.NotEqual(t, plan.Statements(), other.Statements())
+	})
+
+	t.Run("RedactedNamesKeepLiterals", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT DEFAULT 'status', date TEXT DEFAULT (date('now')));`)
+		driver.ExecOnTarget(`CREATE TABLE orders (id INTEGER PRIMARY KEY);`)
+
+		driver.RedactNames = true
+		statements, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.NotContains(t, statements, "orders")
+		require.NotContains(t, statements, `"status"`)
+		require.NotContains(t, statements, `"date"`)
+		require.Contains(t, statements, "TEXT DEFAULT 'status';")
+		require.Contains(t, statements, "TEXT DEFAULT date('now');")
+	})
+
+	t.Run("TemplateVars", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE acme_users (id INTEGER PRIMARY KEY, note TEXT); CREATE INDEX acme_users_note ON acme_users (note);`)
+		driver.ExecOnTarget(`CREATE TABLE acme_users (id INTEGER PRIMARY KEY);`)
+
+		driver.TemplateVars = []TemplateVar{{Name: "table_prefix", Value: "acme_"}}
+		statements, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.Contains(t, statements, `ALTER TABLE "{{table_prefix}}users" ADD COLUMN "note" TEXT;`)
+		require.Contains(t, statements, `CREATE INDEX "{{table_prefix}}users_note" ON "{{table_prefix}}users" ("note");`)
+
+		filled, err := FillTemplate(statements, map[string]string{"table_prefix": "globex_"})
+		require.NoError(t, err)
+		require.Contains(t, filled, `ALTER TABLE "globex_users" ADD COLUMN "note" TEXT;`)
+
+		_, err = FillTemplate(statements, nil)
+		require.EqualError(t, err, "no value for template variables: table_prefix")
+	})
+
+	t.Run("EstimateImpact", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, age INTEGER); CREATE INDEX users_age ON users (age); CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT); CREATE INDEX posts_title ON posts (title);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, age TEXT); INSERT INTO users (age) VALUES ('1'), ('2'), ('3');`)
+
+		driver.Annotate = true
+		statements, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.Contains(t, statements, "-- rewrites users, about 3 rows\nINSERT INTO \"_users_temp\"")
+		require.Contains(t, statements, "-- scans users, about 3 rows\nCREATE INDEX \"users_age\"")
+		require.NotContains(t, statements, "posts, about")
+
+		impacts, err := driver.EstimateImpact(t.Context(), []string{
+			`CREATE INDEX "posts_title" ON "posts" ("title");`,
+			`ALTER TABLE "users" DROP COLUMN "age";`,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []*StatementImpact{nil, {Table: "users", Rows: 3, Rewrite: true}}, impacts)
+
+		// Analyzed tables are estimated from their statistics
+		driver.ExecOnTarget(`CREATE INDEX users_age ON users (age); ANALYZE; INSERT INTO users (age) VALUES ('4');`)
+		rows, err := driver.EstimateRows(t.Context(), TargetSide)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int64{"users": 3}, rows)
+	})
+
+	t.Run("StepChecks", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY); CREATE TABLE posts (id INTEGER PRIMARY KEY, author INTEGER REFERENCES users (id));`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY); CREATE TABLE posts (id INTEGER PRIMARY KEY, author TEXT); INSERT INTO users (id) VALUES (1); INSERT INTO posts (author) VALUES ('1'), ('2');`)
+
+		driver.Annotate = true
+		var statements []string
+		for statement, err := range driver.Statements(t.Context()) {
+			require.NoError(t, err)
+			statements = append(statements, statement)
+		}
+		require.Len(t, statements, 4)
+
+		checks := driver.StepChecks(statements)
+		require.Equal(t, map[int][]StepCheck{
+			2: {{Description: "_posts_temp holds as many rows as posts", Query: `SELECT (SELECT COUNT(*) FROM "_posts_temp") = (SELECT COUNT(*) FROM "posts")`}},
+			4: {{Description: "foreign keys of posts hold", Query: `SELECT COUNT(*) = 0 FROM pragma_foreign_key_check('posts')`}},
+		}, checks)
+
+		for _, statement := range statements[:2] {
+			require.NoError(t, driver.Exec(t.Context(), TargetSide, statement))
+		}
+		driver.ExecOnTarget(`DELETE FROM _posts_temp WHERE id = 2;`)
+		passed, err := driver.RunStepCheck(t.Context(), TargetSide, checks[2][0])
+		require.NoError(t, err)
+		require.False(t, passed)
+
+		driver.ExecOnTarget(`INSERT INTO _posts_temp (id, author) VALUES (2, 2);`)
+		passed, err = driver.RunStepCheck(t.Context(), TargetSide, checks[2][0])
+		require.NoError(t, err)
+		require.True(t, passed)
+
+		for _, statement := range statements[2:] {
+			require.NoError(t, driver.Exec(t.Context(), TargetSide, statement))
+		}
+
+		// The post of the second author references no user
+		passed, err = driver.RunStepCheck(t.Context(), TargetSide, checks[4][0])
+		require.NoError(t, err)
+		require.False(t, passed)
+	})
+
+	t.Run("MaxRewriteRows", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, age INTEGER); CREATE TABLE posts (id INTEGER PRIMARY KEY);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, age TEXT); INSERT INTO users (age) VALUES ('1'), ('2'), ('3');`)
+
+		// Nothing is yielded before the whole plan got checked
+		driver.MaxRewriteRows = 2
+		var objects []*ObjectStatements
+		var limitErr *RewriteLimitError
+		for object, err := range driver.ObjectStatements(t.Context()) {
+			if err != nil {
+				require.ErrorAs(t, err, &limitErr)
+				break
+			}
+			objects = append(objects, object)
+		}
+		require.Empty(t, objects)
+		require.Equal(t, &RewriteLimitError{Table: "users", Rows: 3, Limit: 2}, limitErr)
+
+		driver.MaxRewriteRows = 3
+		statements, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.Contains(t, statements, `INSERT INTO "_users_temp"`)
+	})
+
+	t.Run("DiffTableByName", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT); CREATE INDEX users_name ON users (name); CREATE TABLE posts (id INTEGER PRIMARY KEY); CREATE VIEW names AS SELECT name FROM users;`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY); CREATE TABLE comments (id INTEGER PRIMARY KEY);`)
+
+		diff, err := driver.DiffTableByName(t.Context(), "users")
+		require.NoError(t, err)
+		require.Equal(t, "ALTER TABLE \"users\" ADD COLUMN \"name\" TEXT;\nCREATE INDEX \"users_name\" ON \"users\" (\"name\");", diff)
+
+		diff, err = driver.DiffTables(t.Context(), "posts", "comments", "missing")
+		require.NoError(t, err)
+		require.Equal(t, "CREATE TABLE \"posts\" (\n\t\"id\" INTEGER PRIMARY KEY\n);\nDROP TABLE \"comments\";", diff)
+	})
+
+	t.Run("DiffTableByNameExpressions", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		// Named tables are read by the same queries as whole schemas
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT); CREATE INDEX idx_lower ON users (lower(name)); CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT); CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER);`)
+
+		diff, err := driver.DiffTables(t.Context(), "users")
+		require.NoError(t, err)
+		require.Equal(t, "CREATE INDEX idx_lower ON users (lower(name));", diff)
+
+		diff, err = driver.DiffTables(t.Context(), "posts")
+		require.NoError(t, err)
+		require.Contains(t, diff, `FOREIGN KEY ("user_id") REFERENCES "users" ("id")`)
+	})
+
+	t.Run("SkippedTypes", func(t *testing.T) {
+		sourceDatabasePath := filepath.Join(t.TempDir(), "source.sqlite")
+		targetDatabasePath := filepath.Join(t.TempDir(), "target.sqlite")
+
+		open := func(types ...schema.ObjectType) *SQLiteDriver {
+			driver, err := NewSQLiteDriver(WithSourceDSN(sourceDatabasePath), WithTargetDSN(targetDatabasePath), WithSkippedTypes(types...))
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				require.NoError(t, driver.Close())
+			})
+			return driver
+		}
+
+		driver := open()
+		_, err := driver.SourceDatabaseConnection.ExecContext(t.Context(), `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT); CREATE INDEX users_name ON users (name); CREATE TABLE posts (id INTEGER PRIMARY KEY); CREATE INDEX posts_id ON posts (id); CREATE VIEW names AS SELECT name FROM users;`)
+		require.NoError(t, err)
+		_, err = driver.TargetDatabaseConnection.ExecContext(t.Context(), `CREATE TABLE users (id INTEGER PRIMARY KEY); CREATE INDEX users_id ON users (id);`)
+		require.NoError(t, err)
+
+		diff, err := open(schema.TableObject, schema.TriggerObject, schema.ViewObject).Diff(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, "CREATE INDEX \"users_name\" ON \"users\" (\"name\");\nDROP INDEX \"users_id\";", diff)
+
+		diff, err = open(schema.IndexObject, schema.ViewObject).Diff(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, "ALTER TABLE \"users\" ADD COLUMN \"name\" TEXT;\nCREATE TABLE \"posts\" (\n\t\"id\" INTEGER PRIMARY KEY\n);", diff)
+	})
+
+	t.Run("DeterministicOrder", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+		driver.DeterministicOrder = true
+
+		driver.ExecOnSource(`CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id)); CREATE TABLE users (id INTEGER PRIMARY KEY); CREATE INDEX users_b ON users (id); CREATE INDEX users_a ON users (id); CREATE TABLE archive (id INTEGER PRIMARY KEY);`)
+		driver.ExecOnTarget(`CREATE TABLE parents (id INTEGER PRIMARY KEY); CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parents (id));`)
+
+		diff, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, strings.Join([]string{
+			"CREATE TABLE \"archive\" (\n\t\"id\" INTEGER PRIMARY KEY\n);",
+			"CREATE TABLE \"users\" (\n\t\"id\" INTEGER PRIMARY KEY\n);",
+			"CREATE INDEX \"users_a\" ON \"users\" (\"id\");",
+			"CREATE INDEX \"users_b\" ON \"users\" (\"id\");",
+			"CREATE TABLE \"posts\" (\n\t\"id\" INTEGER PRIMARY KEY,\n\t\"user_id\" INTEGER,\n\tFOREIGN KEY (\"user_id\") REFERENCES \"users\" (\"id\")\n);",
+			"DROP TABLE \"children\";",
+			"DROP TABLE \"parents\";",
+		}, "\n"), diff)
+	})
+
+	t.Run("DeferDestructive", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+		driver.DeferDestructive = true
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT); CREATE INDEX users_email ON users (email);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, legacy INTEGER); CREATE INDEX users_legacy ON users (legacy); CREATE TABLE old (id INTEGER PRIMARY KEY);`)
+
+		var objects []string
+		for object, err := range driver.ObjectStatements(t.Context()) {
+			require.NoError(t, err)
+			objects = append(objects, fmt.Sprintf("%t %s", object.Contract, strings.Join(object.Statements, " ")))
+		}
+		require.Equal(t, []string{
+			`false ALTER TABLE "users" ADD COLUMN "email" TEXT; CREATE INDEX "users_email" ON "users" ("email");`,
+			`true DROP INDEX "users_legacy"; ALTER TABLE "users" DROP COLUMN "legacy";`,
+			`true DROP TABLE "old";`,
+		}, objects)
+	})
+
+	t.Run("ExpandContract", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+		driver.ExpandContract = true
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL, name TEXT);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, legacy INTEGER NOT NULL); INSERT INTO users VALUES (1, 'alice@example.com', 1);`)
+
+		var expand, contract []string
+		for object, err := range driver.ObjectStatements(t.Context()) {
+			require.NoError(t, err)
+			if object.Contract {
+				contract = append(contract, object.Statements...)
+			} else {
+				expand = append(expand, object.Statements...)
+			}
+		}
+
+		// Rows written by either version of the application are accepted
+		// between both phases
+		driver.ExecOnTarget(strings.Join(expand, "\n"))
+		driver.ExecOnTarget(`INSERT INTO users (id, legacy) VALUES (2, 2); INSERT INTO users (id, email, name) VALUES (3, 'bob@example.com', 'Bob');`)
+		driver.ExecOnTarget(`DELETE FROM users WHERE email IS NULL;`)
+
+		driver.ExecOnTarget(strings.Join(contract, "\n"))
+		driver.RequireDiff(``)
+		require.Equal(t, []map[string]any{
+			{"id": int64(1), "email": "alice@example.com", "name": nil},
+			{"id": int64(3), "email": "bob@example.com", "name": "Bob"},
+		}, driver.FetchAllFromTarget("users", "ORDER BY id"))
+	})
+
+	t.Run("DeferDestructiveRename", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+		driver.DeferDestructive = true
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, full_name TEXT NOT NULL, nickname TEXT DEFAULT 'none');`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, alias TEXT DEFAULT 'none'); INSERT INTO users VALUES (1, 'Alice', 'Al');`)
+
+		var expand, contract []string
+		for object, err := range driver.ObjectStatements(t.Context()) {
+			require.NoError(t, err)
+			if object.Contract {
+				contract = append(contract, object.Statements...)
+			} else {
+				expand = append(expand, object.Statements...)
+			}
+		}
+		require.NotEmpty(t, contract)
+
+		driver.ExecOnTarget(strings.Join(expand, "\n"))
+		driver.ExecOnTarget(strings.Join(contract, "\n"))
+		driver.RequireDiff(``)
+		require.Equal(t, []map[string]any{
+			{"id": int64(1), "full_name": "Alice", "nickname": "Al"},
+		}, driver.FetchAllFromTarget("users", "ORDER BY id"))
+	})
+
+	t.Run("ExpandContractRename", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+		driver.ExpandContract = true
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, full_name TEXT NOT NULL);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL); INSERT INTO users VALUES (1, 'Alice');`)
+
+		var expand, contract []string
+		for object, err := range driver.ObjectStatements(t.Context()) {
+			require.NoError(t, err)
+			if object.Contract {
+				contract = append(contract, object.Statements...)
+			} else {
+				expand = append(expand, object.Statements...)
+			}
+		}
+
+		// The running version keeps the old column until the contract phase,
+		// existing rows being copied to the new one
+		require.NotContains(t, strings.Join(expand, "\n"), "RENAME COLUMN")
+		driver.ExecOnTarget(strings.Join(expand, "\n"))
+		require.Equal(t, []map[string]any{
+			{"id": int64(1), "name": "Alice", "full_name": "Alice"},
+		}, driver.FetchAllFromTarget("users", "ORDER BY id"))
+		driver.ExecOnTarget(`INSERT INTO users (id, name, full_name) VALUES (2, 'Bob', 'Bob');`)
+
+		driver.ExecOnTarget(strings.Join(contract, "\n"))
+		driver.RequireDiff(``)
+		require.Equal(t, []map[string]any{
+			{"id": int64(1), "full_name": "Alice"},
+			{"id": int64(2), "full_name": "Bob"},
+		}, driver.FetchAllFromTarget("users", "ORDER BY id"))
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		sourceDatabasePath := filepath.Join(t.TempDir(), "source.sqlite")
+		targetDatabasePath := filepath.Join(t.TempDir(), "target.sqlite")
+
+		writable, err := NewSQLiteDriver(WithSourceDSN(sourceDatabasePath), WithTargetDSN(targetDatabasePath))
+		require.NoError(t, err)
+		_, err = writable.SourceDatabaseConnection.ExecContext(t.Context(), `CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+		require.NoError(t, err)
+		_, err = writable.TargetDatabaseConnection.ExecContext(t.Context(), `SELECT 1;`)
+		require.NoError(t, err)
+		require.NoError(t, writable.Close())
+
+		driver, err := NewSQLiteDriver(
+			WithSourceDSN("sqlite://"+sourceDatabasePath),
+			WithTargetDSN(targetDatabasePath),
+			WithReadOnly(true),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, driver.Close())
+		})
+
+		diff, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, "CREATE TABLE \"users\" (\n\t\"id\" INTEGER PRIMARY KEY\n);", diff)
+
+		_, err = driver.TargetDatabaseConnection.ExecContext(t.Context(), `CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+		require.ErrorContains(t, err, "readonly database")
+	})
+
+	t.Run("ExecRows", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT); INSERT INTO users (name) VALUES ('a'), ('b');`)
+
+		rows, err := driver.ExecRows(t.Context(), TargetSide, `UPDATE users SET name = 'c';`)
+		require.NoError(t, err)
+		require.EqualValues(t, 2, rows)
+
+		_, err = driver.ExecRows(t.Context(), TargetSide, `UPDATE missing SET name = 'c';`)
+		require.ErrorContains(t, err, "no such table")
+	})
+
+	t.Run("SQLiteKey", func(t *testing.T) {
+		dsn, key := sqliteKey("file:app.db?_key=s3cret&mode=ro", "fallback")
+		require.Equal(t, "file:app.db?mode=ro", dsn)
+		require.Equal(t, "s3cret", key)
+
+		dsn, key = sqliteKey("app.db", "fallback")
+		require.Equal(t, "app.db", dsn)
+		require.Equal(t, "fallback", key)
+
+		// go-sqlite3 bundles SQLite without SQLCipher
+		driver, err := NewSQLiteDriver(
+			WithSourceDSN(filepath.Join(t.TempDir(), "source.sqlite")+"?_key=s3cret"),
+			WithTargetDSN(":memory:"),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, driver.Close())
+		})
+
+		_, err = driver.Diff(t.Context())
+		require.ErrorIs(t, err, ErrSQLCipherUnsupported)
+	})
+
+	t.Run("SQLiteDriverName", func(t *testing.T) {
+		sql.Register("sqlite3-renamed", &sqlite3.SQLiteDriver{})
+
+		driver, err := NewSQLiteDriver(
+			WithSQLiteDriverName("sqlite3-renamed"),
+			WithSourceDSN(":memory:"),
+			WithTargetDSN(":memory:"),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, driver.Close())
+		})
+		require.IsType(t, &sqlite3.SQLiteDriver{}, driver.SourceDatabaseConnection.Driver())
+
+		_, err = NewSQLiteDriver(WithSQLiteDriverName("missing"))
+		require.ErrorContains(t, err, `unknown driver "missing"`)
+	})
+
+	t.Run("IgnoreRules", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		tableRule, err := schema.IgnoreGlob(schema.TableObject, "schema_*")
+		require.NoError(t, err)
+		indexRule, err := schema.IgnoreRegexp(schema.IndexObject, `_tmp$`)
+		require.NoError(t, err)
+		driver.Ignore = schema.IgnoreRules{tableRule, indexRule}
+
+		driver.ExecOnSource(`
+			CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY);
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+			CREATE INDEX idx_users_name ON users (name);
+			CREATE INDEX idx_users_name_tmp ON users (name);
+		`)
+		driver.ExecOnTarget(`CREATE TABLE schema_migrations (version TEXT);`)
+
+		driver.RequireDiff(`CREATE TABLE "users" (
+	"id" INTEGER PRIMARY KEY,
+	"name" TEXT
+);
+CREATE INDEX "idx_users_name" ON "users" ("name");`)
+	})
+
+	t.Run("TypeEquivalence", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+		driver.TypeEquivalences = []schema.TypeEquivalence{
+			schema.EquivalentTypes([2]string{"TEXT", "varchar(255)"}),
+		}
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, email TEXT);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(255), email VARCHAR(100));`)
+
+		driver.RequireDiff(`ALTER TABLE "users" DROP COLUMN "email";
+ALTER TABLE "users" ADD COLUMN "email" TEXT;`)
+	})
+
+	t.Run("TypeNormalization", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, count INT, active BOOL, name varchar( 255 ));`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, count INTEGER, active BOOLEAN, name VARCHAR(255));`)
+
+		driver.RequireDiff(``)
+
+		// Strict types keep the declared types as they are
+		driver.TypeEquivalences = NewDriverConfig(WithStrictTypes(true)).TypeEquivalences
+		diff, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.NotEmpty(t, diff)
+	})
+
+	t.Run("CaseInsensitiveNames", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+		driver.CaseInsensitiveNames = true
+
+		driver.ExecOnSource(`
+			CREATE TABLE Users (ID INTEGER PRIMARY KEY, Name TEXT NOT NULL, Email TEXT);
+			CREATE INDEX IDX_Users_Name ON Users (Name);
+		`)
+		driver.ExecOnTarget(`
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+			CREATE INDEX idx_users_name ON users (name);
+		`)
+
+		driver.RequireDiff(`ALTER TABLE "Users" ADD COLUMN "Email" TEXT;`)
+	})
+
+	t.Run("NormalizedDefinitions", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+			CREATE VIEW named_users AS SELECT id, name FROM users WHERE name <> 'a  b';
+			CREATE TRIGGER users_name AFTER UPDATE ON users BEGIN SELECT 1; END;
+		`)
+		driver.ExecOnTarget(`
+			CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+			create view "named_users" as
+				-- Only users with a name
+				select [id], ` + "`name`" + ` from "users" where name <> 'a  b';
+			CREATE TRIGGER users_name AFTER UPDATE ON users
+			BEGIN
+				/* nothing yet */ SELECT 1;
+			END;
+		`)
+
+		driver.RequireDiff(``)
+
+		driver.StrictDefinitions = true
+		diff, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+		require.Contains(t, diff, `DROP VIEW "named_users";`)
+
+		// String literals are compared as they are
+		require.NotEqual(t, NormalizeSQLiteSQL(`SELECT 'a  b'`), NormalizeSQLiteSQL(`SELECT 'a b'`))
+		require.NotEqual(t, NormalizeSQLiteSQL(`SELECT "first name"`), NormalizeSQLiteSQL(`SELECT first_name`))
+	})
+
+	t.Run("Logging", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		var logs bytes.Buffer
+		driver.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY, full_name TEXT);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);`)
+
+		driver.RequireDiff(`ALTER TABLE "users" RENAME COLUMN "name" TO "full_name";`)
+
+		require.Contains(t, logs.String(), `msg=query`)
+		require.Contains(t, logs.String(), `msg="introspected schema" side=source tables=1`)
+		require.Contains(t, logs.String(), `msg="detected column rename" table=users from=name to=full_name`)
+	})
+
+	t.Run("Cancellation", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		_, err := driver.Diff(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+
+		driver.QueryTimeout = time.Nanosecond
+		_, err = driver.Diff(t.Context())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("IntrospectionCache", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		cacheDir := t.TempDir()
+		driver.Cache = NewIntrospectionCache(cacheDir)
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+		driver.RequireDiff("CREATE TABLE \"users\" (\n\t\"id\" INTEGER PRIMARY KEY\n);")
+
+		entries, err := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+		require.NoError(t, err)
+		require.NotEmpty(t, entries)
+
+		// Persisted entries are reused by a fresh cache
+		driver.Cache = NewIntrospectionCache(cacheDir)
+		driver.RequireDiff("CREATE TABLE \"users\" (\n\t\"id\" INTEGER PRIMARY KEY\n);")
+
+		// Schema changes bump schema_version and invalidate the cached entries
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+		driver.RequireDiff(``)
+	})
+
+	t.Run("Progress", func(t *testing.T) {
+		driver := NewTestSQLiteDriver(t)
+
+		var updates []Progress
+		driver.progress = newProgressReporter(func(progress Progress) {
+			updates = append(updates, progress)
+		})
+
+		driver.ExecOnSource(`CREATE TABLE users (id INTEGER PRIMARY KEY); CREATE TABLE posts (id INTEGER PRIMARY KEY);`)
+		driver.ExecOnTarget(`CREATE TABLE users (id INTEGER PRIMARY KEY);`)
+
+		_, err := driver.Diff(t.Context())
+		require.NoError(t, err)
+
+		require.Contains(t, updates, Progress{Phase: IntrospectionPhase, Side: SourceSide, Done: 2, Total: 2})
+		require.Contains(t, updates, Progress{Phase: IntrospectionPhase, Side: TargetSide, Done: 1, Total: 1})
+		require.Equal(t, Progress{Phase: ComparisonPhase, Done: 2, Total: 2}, updates[len(updates)-1])
+	})
+}
+
+// syntheticSchema generates tableCount tables, each with an index and a
+// foreign key to the previous table. Every tenth table gets an extra column
+// when variant is non-zero, so that comparing variants yields changes.
+func syntheticSchema(tableCount int, variant int) string {
+	var schema strings.Builder
+
+	schema.WriteString("BEGIN;\n")
+	for i := range tableCount {
+		fmt.Fprintf(&schema, "CREATE TABLE t%d (id INTEGER PRIMARY KEY, name TEXT NOT NULL, parent_id INTEGER", i)
+		if variant != 0 && i%10 == 0 {
+			schema.WriteString(", extra TEXT")
+		}
+		if i > 0 {
+			fmt.Fprintf(&schema, ", FOREIGN KEY (parent_id) REFERENCES t%d (id)", i-1)
+		}
+		schema.WriteString(");\n")
+		fmt.Fprintf(&schema, "CREATE INDEX idx_t%d_name ON t%d (name);\n", i, i)
+	}
+	schema.WriteString("COMMIT;\n")
+
+	return schema.String()
+}
+
+func BenchmarkSQLiteDriver(b *testing.B) {
+	for _, tableCount := range []int{100, 1_000, 10_000} {
+		b.Run(fmt.Sprintf("Tables=%d", tableCount), func(b *testing.B) {
+			driver := NewTestSQLiteDriver(b)
+
+			driver.ExecOnSource(syntheticSchema(tableCount, 1))
+			driver.ExecOnTarget(syntheticSchema(tableCount, 0))
+
+			for b.Loop() {
+				_, err := driver.Diff(b.Context())
+				require.NoError(b, err)
+			}
+		})
+	}
 }
@@ -1,6 +0,0 @@
-package drivers
-
-type SQLiteTrigger struct {
-	Name string
-	SQL  string
-}
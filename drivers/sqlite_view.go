@@ -2,22 +2,50 @@ package drivers
 
 import (
 	"fmt"
-	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/samber/lo"
 )
 
-type SQLiteView struct {
-	Name string
-	SQL  string
-}
+func (r *SQLiteRenderer) RenderViews(changes []*schema.Change[*schema.View]) []string {
+	var statements []string
+
+	for _, change := range changes {
+		switch change.Kind {
+		case schema.Added:
+			statements = append(statements, change.Source.Def+";")
+			statements = append(statements, createTriggers(change.Source.Triggers)...)
+		case schema.Modified:
+			// Views whose triggers alone changed are kept, the others are
+			// recreated along with their triggers
+			if change.Source.Def == change.Target.Def {
+				for _, trigger := range change.Target.Triggers {
+					statements = append(statements, fmt.Sprintf("DROP TRIGGER %s;", sqliteIdentifier(trigger.Name)))
+				}
+			} else {
+				statements = append(statements, fmt.Sprintf("DROP VIEW %s;", sqliteIdentifier(change.Target.Name)))
+				statements = append(statements, change.Source.Def+";")
+			}
+			statements = append(statements, createTriggers(change.Source.Triggers)...)
+		case schema.Removed:
+			statements = append(statements, fmt.Sprintf("DROP VIEW %s;", sqliteIdentifier(change.Target.Name)))
+		}
+	}
 
-func (v *SQLiteView) Diff(other *SQLiteView) (string, error) {
-	var diff strings.Builder
+	return statements
+}
 
-	if v.SQL != other.SQL {
-		// Modified view
-		fmt.Fprintf(&diff, "DROP VIEW \"%s\";\n", other.Name)
-		fmt.Fprintf(&diff, "%s;\n", v.SQL)
+func (r *SQLiteRenderer) CreateViews(views []*schema.View) []string {
+	var statements []string
+	for _, view := range views {
+		statements = append(statements, view.Def+";")
+		statements = append(statements, createTriggers(view.Triggers)...)
 	}
+	return statements
+}
 
-	return strings.TrimSpace(diff.String()), nil
+func (r *SQLiteRenderer) DropViews(views []*schema.View) []string {
+	return lo.Map(views, func(view *schema.View, _ int) string {
+		return fmt.Sprintf("DROP VIEW %s;", sqliteIdentifier(view.Name))
+	})
 }
@@ -0,0 +1,84 @@
+package drivers
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// TemplateVar turns Value into a {{Name}} placeholder wherever it occurs in
+// the quoted identifiers of generated statements, such as a schema name or a
+// tenant table prefix, so that one plan can be filled in for other schemas
+// or tenants with FillTemplate.
+type TemplateVar struct {
+	Name  string
+	Value string
+}
+
+var templateVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseTemplateVar parses a template variable written as name=value, e.g.
+// table_prefix=acme_.
+func ParseTemplateVar(s string) (TemplateVar, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || value == "" {
+		return TemplateVar{}, fmt.Errorf("invalid template variable %q, expected name=value", s)
+	}
+	if !templateVarName.MatchString(name) {
+		return TemplateVar{}, fmt.Errorf("invalid template variable name %q", name)
+	}
+	return TemplateVar{Name: name, Value: value}, nil
+}
+
+// quotedIdentifier matches double-quoted identifiers, doubled quotes
+// included.
+var quotedIdentifier = regexp.MustCompile(`"(?:[^"]|"")*"`)
+
+// templatize replaces the values of vars in the quoted identifiers of
+// statements with their placeholder. Longer values are replaced first, so
+// that a value containing another one wins.
+func templatize(statements []string, vars []TemplateVar) []string {
+	vars = slices.Clone(vars)
+	slices.SortStableFunc(vars, func(a, b TemplateVar) int {
+		return cmp.Compare(len(b.Value), len(a.Value))
+	})
+
+	var pairs []string
+	for _, v := range vars {
+		pairs = append(pairs, v.Value, "{{"+v.Name+"}}")
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	templated := make([]string, len(statements))
+	for i, statement := range statements {
+		templated[i] = quotedIdentifier.ReplaceAllStringFunc(statement, replacer.Replace)
+	}
+	return templated
+}
+
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// FillTemplate replaces the {{name}} placeholders of a templated plan with
+// values. Placeholders without a value are reported rather than left in the
+// plan.
+func FillTemplate(plan string, values map[string]string) (string, error) {
+	var missing []string
+	filled := templatePlaceholder.ReplaceAllStringFunc(plan, func(placeholder string) string {
+		name := templatePlaceholder.FindStringSubmatch(placeholder)[1]
+		value, ok := values[name]
+		if !ok {
+			if !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+			return placeholder
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no value for template variables: %s", strings.Join(missing, ", "))
+	}
+	return filled, nil
+}
@@ -0,0 +1,34 @@
+package drivers
+
+// TLSConfig secures connections to the databases, using the libpq parameter
+// names and semantics. Empty fields keep whatever the connection string
+// specifies. SQLite has no network connections and ignores it.
+type TLSConfig struct {
+	// Mode is one of disable, allow, prefer, require, verify-ca or
+	// verify-full.
+	Mode string
+
+	// RootCert is the path of the certificate authorities used to verify the
+	// server certificate.
+	RootCert string
+
+	// Cert and Key are the paths of the client certificate and its private
+	// key, for servers authenticating clients by certificate.
+	Cert string
+	Key  string
+}
+
+func (c TLSConfig) params() map[string]string {
+	params := make(map[string]string)
+	for name, value := range map[string]string{
+		"sslmode":     c.Mode,
+		"sslrootcert": c.RootCert,
+		"sslcert":     c.Cert,
+		"sslkey":      c.Key,
+	} {
+		if value != "" {
+			params[name] = value
+		}
+	}
+	return params
+}
@@ -0,0 +1,183 @@
+package drivertest
+
+const usersTable = `CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(100) NOT NULL)`
+
+// postgresTouch is the function called by the Postgres trigger cases.
+// Functions are not compared, so both databases define it.
+const postgresTouch = `CREATE FUNCTION touch() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN RETURN NEW; END $$`
+
+// Cases are the behaviors every driver is expected to handle.
+var Cases = []Case{
+	{
+		Name:   "NoChanges",
+		Source: []string{usersTable},
+		Target: []string{usersTable},
+	},
+	{
+		Name:   "CreateTable",
+		Source: []string{usersTable},
+	},
+	{
+		Name:   "DropTable",
+		Target: []string{usersTable},
+	},
+	{
+		Name:   "AddColumn",
+		Source: []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(100) NOT NULL, email VARCHAR(100))`},
+		Target: []string{usersTable},
+	},
+	{
+		Name:   "RemoveColumn",
+		Source: []string{usersTable},
+		Target: []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(100) NOT NULL, email VARCHAR(100))`},
+	},
+	{
+		Name:   "RenameColumn",
+		Source: []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, full_name VARCHAR(100) NOT NULL)`},
+		Target: []string{usersTable},
+	},
+	{
+		Name:   "ModifyColumnType",
+		Source: []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`},
+		Target: []string{usersTable},
+	},
+	{
+		Name:   "SetNotNull",
+		Source: []string{usersTable},
+		Target: []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(100))`},
+	},
+	{
+		Name:   "DropNotNull",
+		Source: []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(100))`},
+		Target: []string{usersTable},
+	},
+	{
+		Name:   "SetDefault",
+		Source: []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(100) NOT NULL DEFAULT 'anonymous')`},
+		Target: []string{usersTable},
+	},
+	{
+		Name:   "CreateIndex",
+		Source: []string{usersTable, `CREATE INDEX users_name ON users (name)`},
+		Target: []string{usersTable},
+	},
+	{
+		Name:   "DropIndex",
+		Source: []string{usersTable},
+		Target: []string{usersTable, `CREATE INDEX users_name ON users (name)`},
+	},
+	{
+		Name:   "ModifyIndex",
+		Source: []string{usersTable, `CREATE UNIQUE INDEX users_name ON users (name, id)`},
+		Target: []string{usersTable, `CREATE INDEX users_name ON users (name)`},
+	},
+	{
+		Name:   "RemoveIndexedColumn",
+		Source: []string{usersTable, `CREATE INDEX users_name ON users (name)`},
+		Target: []string{
+			`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(100) NOT NULL, email VARCHAR(100))`,
+			`CREATE INDEX users_name ON users (name, email)`,
+		},
+	},
+	{
+		Name:   "ModifyIndexedColumnType",
+		Source: []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, age INTEGER)`, `CREATE INDEX users_age ON users (age)`},
+		Target: []string{`CREATE TABLE users (id INTEGER PRIMARY KEY, age VARCHAR(3))`, `CREATE INDEX users_age ON users (age)`},
+	},
+	{
+		Name: "ModifyTableUsedByView",
+		Source: []string{
+			`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(100) NOT NULL, parent_id INTEGER REFERENCES users (id))`,
+			`CREATE VIEW user_names AS SELECT name FROM users`,
+		},
+		Target: []string{
+			`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(100) NOT NULL, parent_id INTEGER)`,
+			`CREATE VIEW user_names AS SELECT name FROM users`,
+		},
+	},
+	{
+		Name: "AddForeignKey",
+		Source: []string{
+			usersTable,
+			`CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id) ON DELETE CASCADE)`,
+		},
+		Target: []string{
+			usersTable,
+			`CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER)`,
+		},
+	},
+	{
+		Name: "DropForeignKey",
+		Source: []string{
+			usersTable,
+			`CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER)`,
+		},
+		Target: []string{
+			usersTable,
+			`CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id))`,
+		},
+	},
+	{
+		Name:   "CreateView",
+		Source: []string{usersTable, `CREATE VIEW user_names AS SELECT name FROM users`},
+		Target: []string{usersTable},
+	},
+	{
+		Name:   "ModifyView",
+		Source: []string{usersTable, `CREATE VIEW user_names AS SELECT name FROM users`},
+		Target: []string{usersTable, `CREATE VIEW user_names AS SELECT id, name FROM users`},
+	},
+	{
+		Name:   "DropView",
+		Source: []string{usersTable},
+		Target: []string{usersTable, `CREATE VIEW user_names AS SELECT name FROM users`},
+	},
+	{
+		Name:     "CreateSQLiteTrigger",
+		Dialects: []string{"sqlite3"},
+		Source:   []string{usersTable, `CREATE TRIGGER users_touch AFTER UPDATE ON users BEGIN SELECT 1; END`},
+		Target:   []string{usersTable},
+	},
+	{
+		Name:     "ModifySQLiteTrigger",
+		Dialects: []string{"sqlite3"},
+		Source:   []string{usersTable, `CREATE TRIGGER users_touch AFTER UPDATE ON users BEGIN SELECT 1; END`},
+		Target:   []string{usersTable, `CREATE TRIGGER users_touch AFTER INSERT ON users BEGIN SELECT 1; END`},
+	},
+	{
+		Name:     "ModifySQLiteTableWithTrigger",
+		Dialects: []string{"sqlite3"},
+		Source: []string{
+			`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(100) NOT NULL, parent_id INTEGER REFERENCES users (id))`,
+			`CREATE TRIGGER users_touch AFTER UPDATE ON users BEGIN SELECT 1; END`,
+		},
+		Target: []string{
+			`CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR(100) NOT NULL, parent_id INTEGER)`,
+			`CREATE TRIGGER users_touch AFTER UPDATE ON users BEGIN SELECT 1; END`,
+		},
+	},
+	{
+		Name:     "CreatePostgresTrigger",
+		Dialects: []string{"postgres"},
+		Source: []string{
+			usersTable,
+			postgresTouch,
+			`CREATE TRIGGER users_touch BEFORE UPDATE ON users FOR EACH ROW EXECUTE FUNCTION touch()`,
+		},
+		Target: []string{usersTable, postgresTouch},
+	},
+	{
+		Name:     "ModifyPostgresTrigger",
+		Dialects: []string{"postgres"},
+		Source: []string{
+			usersTable,
+			postgresTouch,
+			`CREATE TRIGGER users_touch BEFORE UPDATE ON users FOR EACH ROW EXECUTE FUNCTION touch()`,
+		},
+		Target: []string{
+			usersTable,
+			postgresTouch,
+			`CREATE TRIGGER users_touch BEFORE INSERT ON users FOR EACH ROW EXECUTE FUNCTION touch()`,
+		},
+	},
+}
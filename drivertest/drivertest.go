@@ -0,0 +1,112 @@
+// Package drivertest checks that a driver behaves like the built-in ones, so
+// that third-party drivers can prove their conformance with a single call:
+//
+//	func TestConformance(t *testing.T) {
+//		drivertest.Run(t, func(tb testing.TB) drivers.Driver {
+//			driver, err := NewMyDriver(
+//				drivers.WithSourceDSN(emptyDatabase(tb)),
+//				drivers.WithTargetDSN(emptyDatabase(tb)),
+//			)
+//			require.NoError(tb, err)
+//			return driver
+//		})
+//	}
+//
+// Each Case sets both databases up, then checks that the driver detects the
+// difference and that applying its plan to the target leaves nothing to
+// migrate.
+package drivertest
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory opens a driver comparing two new, empty databases. The driver must
+// be writable and implement drivers.Executor. Run closes it.
+type Factory func(tb testing.TB) drivers.Driver
+
+// Case is a single behavioral test.
+type Case struct {
+	Name string
+
+	// Dialects restricts the case to drivers introspecting one of them, for
+	// statements that cannot be written portably. Empty runs it with every
+	// driver.
+	Dialects []string
+
+	// Source and Target are executed on each database before comparing
+	// them. A case with the same statements on both sides expects an empty
+	// plan.
+	Source []string
+	Target []string
+}
+
+// Run runs cases, or Cases when none are given, against drivers opened with
+// open.
+func Run(t *testing.T, open Factory, cases ...Case) {
+	if len(cases) == 0 {
+		cases = Cases
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			runCase(t, open, c)
+		})
+	}
+}
+
+func runCase(t *testing.T, open Factory, c Case) {
+	driver := open(t)
+	t.Cleanup(func() {
+		driver.Close()
+	})
+
+	executor, ok := driver.(drivers.Executor)
+	require.True(t, ok, "driver must implement drivers.Executor")
+
+	if len(c.Dialects) > 0 {
+		s, err := driver.Introspect(t.Context(), drivers.SourceSide)
+		require.NoError(t, err)
+
+		if !slices.Contains(c.Dialects, s.Dialect) {
+			t.Skipf("not applicable to the %s dialect", s.Dialect)
+		}
+	}
+
+	exec(t, executor, drivers.SourceSide, c.Source)
+	exec(t, executor, drivers.TargetSide, c.Target)
+
+	plan := statements(t, driver)
+	if slices.Equal(c.Source, c.Target) {
+		require.Empty(t, plan, "identical databases must not differ")
+		return
+	}
+	require.NotEmpty(t, plan, "the difference was not detected")
+
+	exec(t, executor, drivers.TargetSide, plan)
+	require.Empty(t, statements(t, driver), "the plan did not migrate the target, it was:\n%s", plan)
+}
+
+func exec(t *testing.T, executor drivers.Executor, side drivers.Side, statements []string) {
+	t.Helper()
+
+	for _, statement := range statements {
+		err := executor.Exec(t.Context(), side, statement)
+		require.NoError(t, err, "executing on %s:\n%s", side, statement)
+	}
+}
+
+func statements(t *testing.T, driver drivers.Driver) []string {
+	t.Helper()
+
+	var plan []string
+	for statement, err := range driver.Statements(t.Context()) {
+		require.NoError(t, err)
+		plan = append(plan, statement)
+	}
+	return plan
+}
@@ -0,0 +1,138 @@
+package drivertest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HostileIdentifiers are names that break statements and introspection
+// queries interpolating them unquoted, or quoting them the wrong way.
+var HostileIdentifiers = []string{
+	`order`,
+	`select`,
+	`MixedCase`,
+	`first name`,
+	`it's`,
+	`say "hi"`,
+	`a.b`,
+	`semi;colon`,
+	`naïve`,
+	`テーブル`,
+}
+
+// IdentifierCases returns cases creating, modifying and dropping tables,
+// columns, indexes, foreign keys, views and triggers named after each of
+// names, so that every statement a driver generates and every query it
+// introspects with has to quote them correctly.
+func IdentifierCases(names ...string) []Case {
+	var cases []Case
+	for _, name := range names {
+		cases = append(cases, identifierCases(name)...)
+	}
+	return cases
+}
+
+func identifierCases(name string) []Case {
+	table := quoteIdentifier(name)
+	column := quoteIdentifier(name)
+	index := quoteIdentifier(name + "_idx")
+	view := quoteIdentifier(name + "_view")
+	trigger := quoteIdentifier(name + "_trigger")
+
+	create := fmt.Sprintf(`CREATE TABLE %s (id INTEGER PRIMARY KEY, %s VARCHAR(100) NOT NULL)`, table, column)
+	withoutColumn := fmt.Sprintf(`CREATE TABLE %s (id INTEGER PRIMARY KEY)`, table)
+	withParent := fmt.Sprintf(`CREATE TABLE %s (id INTEGER PRIMARY KEY, %s VARCHAR(100) NOT NULL, parent_id INTEGER REFERENCES %s (id))`, table, column, table)
+	createIndex := fmt.Sprintf(`CREATE INDEX %s ON %s (%s)`, index, table, column)
+	createView := fmt.Sprintf(`CREATE VIEW %s AS SELECT %s FROM %s`, view, column, table)
+	sqliteTrigger := fmt.Sprintf(`CREATE TRIGGER %s AFTER UPDATE ON %s BEGIN SELECT 1; END`, trigger, table)
+	postgresTrigger := fmt.Sprintf(`CREATE TRIGGER %s BEFORE UPDATE ON %s FOR EACH ROW EXECUTE FUNCTION touch()`, trigger, table)
+
+	cases := []Case{
+		{
+			Name:   "NoChanges",
+			Source: []string{create, createIndex, createView},
+			Target: []string{create, createIndex, createView},
+		},
+		{
+			Name:   "CreateTable",
+			Source: []string{create, createIndex},
+		},
+		{
+			Name:   "DropTable",
+			Target: []string{create, createIndex},
+		},
+		{
+			Name:   "AddColumn",
+			Source: []string{create},
+			Target: []string{withoutColumn},
+		},
+		{
+			Name:   "RemoveColumn",
+			Source: []string{withoutColumn},
+			Target: []string{create},
+		},
+		{
+			Name:   "ModifyColumnType",
+			Source: []string{fmt.Sprintf(`CREATE TABLE %s (id INTEGER PRIMARY KEY, %s TEXT NOT NULL)`, table, column)},
+			Target: []string{create},
+		},
+		{
+			Name:   "SetDefault",
+			Source: []string{fmt.Sprintf(`CREATE TABLE %s (id INTEGER PRIMARY KEY, %s VARCHAR(100) NOT NULL DEFAULT 'it''s')`, table, column)},
+			Target: []string{create},
+		},
+		{
+			Name:   "CreateIndex",
+			Source: []string{create, createIndex},
+			Target: []string{create},
+		},
+		{
+			Name:   "ModifyIndex",
+			Source: []string{create, fmt.Sprintf(`CREATE UNIQUE INDEX %s ON %s (%s, id)`, index, table, column)},
+			Target: []string{create, createIndex},
+		},
+		{
+			Name:   "AddForeignKey",
+			Source: []string{withParent, createIndex},
+			Target: []string{fmt.Sprintf(`CREATE TABLE %s (id INTEGER PRIMARY KEY, %s VARCHAR(100) NOT NULL, parent_id INTEGER)`, table, column), createIndex},
+		},
+		{
+			Name:   "DropForeignKey",
+			Source: []string{create},
+			Target: []string{withParent},
+		},
+		{
+			Name:   "CreateView",
+			Source: []string{create, createView},
+			Target: []string{create},
+		},
+		{
+			Name:   "ModifyTableUsedByView",
+			Source: []string{withParent, createView},
+			Target: []string{create, createView},
+		},
+		{
+			Name:     "ModifySQLiteTableWithTrigger",
+			Dialects: []string{"sqlite3"},
+			Source:   []string{withParent, sqliteTrigger},
+			Target:   []string{create, sqliteTrigger},
+		},
+		{
+			Name:     "CreatePostgresTrigger",
+			Dialects: []string{"postgres"},
+			Source:   []string{create, postgresTouch, postgresTrigger},
+			Target:   []string{create, postgresTouch},
+		},
+	}
+
+	for i := range cases {
+		cases[i].Name = "Identifier/" + name + "/" + cases[i].Name
+	}
+	return cases
+}
+
+// quoteIdentifier quotes name the standard SQL way, which every built-in
+// dialect accepts.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,45 @@
+package drivertest_test
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/drivertest"
+	"github.com/stretchr/testify/require"
+)
+
+// openPlugin serves the SQLite driver as a plugin over pipes, exercising the
+// plugin protocol without building an executable.
+func openPlugin(tb testing.TB) drivers.Driver {
+	directory := tb.TempDir()
+
+	requests, requestsWriter := io.Pipe()
+	responses, responsesWriter := io.Pipe()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- drivers.ServePlugin(context.Background(), requests, responsesWriter, "sqlite3", func(opts ...drivers.Option) (drivers.Driver, error) {
+			return drivers.NewSQLiteDriver(opts...)
+		})
+		responsesWriter.Close()
+	}()
+	tb.Cleanup(func() {
+		require.NoError(tb, <-served)
+	})
+
+	driver, err := drivers.NewPluginDriver(responses, requestsWriter,
+		drivers.WithSourceDSN(filepath.Join(directory, "source.sqlite")),
+		drivers.WithTargetDSN(filepath.Join(directory, "target.sqlite")),
+	)
+	require.NoError(tb, err)
+	require.Equal(tb, "sqlite3", driver.Dialect)
+
+	return driver
+}
+
+func TestPlugin(t *testing.T) {
+	drivertest.Run(t, openPlugin)
+}
@@ -0,0 +1,72 @@
+package drivertest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/drivertest"
+	"github.com/quantumsheep/dbdiff/internal/containers"
+	"github.com/stretchr/testify/require"
+)
+
+// postgresServer is shared by every case, each one working in schemas of
+// its own. Left running until the process exits, see containers.Postgres.
+var postgresServer = sync.OnceValues(func() (string, error) {
+	dsn, _, err := containers.Postgres(context.Background())
+	return dsn, err
+})
+
+func openPostgres(tb testing.TB) drivers.Driver {
+	dsn, err := postgresServer()
+	if errors.Is(err, containers.ErrUnsupported) {
+		tb.Skip("Postgres conformance runs with the testcontainers build tag")
+	}
+	require.NoError(tb, err)
+
+	conn, err := sql.Open("pgx", dsn)
+	require.NoError(tb, err)
+	tb.Cleanup(func() {
+		conn.Close()
+	})
+
+	id := time.Now().UnixNano()
+	sourceSchema := fmt.Sprintf("source_%d", id)
+	targetSchema := fmt.Sprintf("target_%d", id)
+
+	for _, name := range []string{sourceSchema, targetSchema} {
+		_, err = conn.ExecContext(tb.Context(), "CREATE SCHEMA "+name)
+		require.NoError(tb, err)
+
+		tb.Cleanup(func() {
+			// tb.Context is already canceled by the time cleanups run
+			conn.ExecContext(context.Background(), "DROP SCHEMA "+name+" CASCADE")
+		})
+	}
+
+	driver, err := drivers.NewPostgresDriver(
+		drivers.WithSourceDSN(dsn+"&search_path="+sourceSchema),
+		drivers.WithTargetDSN(dsn+"&search_path="+targetSchema),
+	)
+	require.NoError(tb, err)
+
+	return driver
+}
+
+func TestPostgres(t *testing.T) {
+	drivertest.Run(t, openPostgres)
+}
+
+func TestPostgresIdentifiers(t *testing.T) {
+	drivertest.Run(t, openPostgres, drivertest.IdentifierCases(drivertest.HostileIdentifiers...)...)
+}
+
+func FuzzPostgres(f *testing.F) {
+	drivertest.Fuzz(f, openPostgres)
+}
@@ -0,0 +1,34 @@
+package drivertest_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/quantumsheep/dbdiff/drivers"
+	"github.com/quantumsheep/dbdiff/drivertest"
+	"github.com/stretchr/testify/require"
+)
+
+func openSQLite(tb testing.TB) drivers.Driver {
+	directory := tb.TempDir()
+
+	driver, err := drivers.NewSQLiteDriver(
+		drivers.WithSourceDSN(filepath.Join(directory, "source.sqlite")),
+		drivers.WithTargetDSN(filepath.Join(directory, "target.sqlite")),
+	)
+	require.NoError(tb, err)
+
+	return driver
+}
+
+func TestSQLite(t *testing.T) {
+	drivertest.Run(t, openSQLite)
+}
+
+func TestSQLiteIdentifiers(t *testing.T) {
+	drivertest.Run(t, openSQLite, drivertest.IdentifierCases(drivertest.HostileIdentifiers...)...)
+}
+
+func FuzzSQLite(f *testing.F) {
+	drivertest.Fuzz(f, openSQLite)
+}
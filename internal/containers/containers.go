@@ -0,0 +1,18 @@
+// Package containers starts throwaway databases in Docker, for tests and
+// scratch databases. Docker support is only compiled in with the
+// testcontainers build tag, which pulls in a large dependency tree:
+//
+//	go test -tags testcontainers ./...
+package containers
+
+import "errors"
+
+// ErrUnsupported is returned when the binary was built without the
+// testcontainers build tag.
+var ErrUnsupported = errors.New("built without the testcontainers build tag")
+
+// PostgresImage is the image Postgres starts, matching docker-compose.yml.
+const PostgresImage = "postgres:17"
+
+// TerminateFunc stops and removes a container.
+type TerminateFunc func() error
@@ -0,0 +1,10 @@
+//go:build !testcontainers
+
+package containers
+
+import "context"
+
+// Postgres returns ErrUnsupported, see the testcontainers build.
+func Postgres(ctx context.Context) (string, TerminateFunc, error) {
+	return "", nil, ErrUnsupported
+}
@@ -0,0 +1,36 @@
+//go:build testcontainers
+
+package containers
+
+import (
+	"context"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+// Postgres starts an empty Postgres server and returns its connection string.
+// Containers left running are removed once the process exits.
+func Postgres(ctx context.Context) (string, TerminateFunc, error) {
+	container, err := postgres.Run(ctx, PostgresImage,
+		postgres.WithDatabase("dbdiff"),
+		postgres.WithUsername("user"),
+		postgres.WithPassword("password"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		return "", nil, err
+	}
+
+	terminate := func() error {
+		return testcontainers.TerminateContainer(container)
+	}
+
+	dsn, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		terminate()
+		return "", nil, err
+	}
+
+	return dsn, terminate, nil
+}
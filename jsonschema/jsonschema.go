@@ -0,0 +1,19 @@
+// Package jsonschema holds the JSON Schema documents describing dbdiff's
+// machine-readable output, for downstream tools to validate it:
+//
+//   - snapshot.schema.json for schemas, as written by `dbdiff inspect
+//     --format json`, versioned by schema.FormatVersion
+//   - diff.schema.json for plans written by `dbdiff --format json`
+//   - plan.schema.json for plan files, versioned by plan.FileVersion
+//
+// Each document describes a single version, given by the const of its
+// format_version or version property. Versions are only bumped on changes
+// breaking existing readers, new optional fields keep the version.
+package jsonschema
+
+import "embed"
+
+// FS holds the documents, named as listed above.
+//
+//go:embed *.schema.json
+var FS embed.FS
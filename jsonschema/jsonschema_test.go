@@ -0,0 +1,34 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/quantumsheep/dbdiff/plan"
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocuments(t *testing.T) {
+	for name, version := range map[string]struct {
+		property string
+		value    int
+	}{
+		"snapshot.schema.json": {"format_version", schema.FormatVersion},
+		"diff.schema.json":     {"format_version", 1},
+		"plan.schema.json":     {"version", plan.FileVersion},
+	} {
+		data, err := FS.ReadFile(name)
+		require.NoError(t, err, name)
+
+		var document struct {
+			ID         string `json:"$id"`
+			Properties map[string]struct {
+				Const int `json:"const"`
+			} `json:"properties"`
+		}
+		require.NoError(t, json.Unmarshal(data, &document), name)
+		require.Equal(t, "https://github.com/quantumsheep/dbdiff/jsonschema/"+name, document.ID)
+		require.Equal(t, version.value, document.Properties[version.property].Const, name)
+	}
+}
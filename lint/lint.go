@@ -0,0 +1,178 @@
+// Package lint flags risky patterns in migration plans, such as statements
+// losing data or locking tables for long periods.
+package lint
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Severity ranks findings. Off disables a rule.
+type Severity string
+
+const (
+	Off     Severity = "off"
+	Info    Severity = "info"
+	Warning Severity = "warning"
+	Error   Severity = "error"
+)
+
+func ParseSeverity(s string) (Severity, error) {
+	switch severity := Severity(strings.ToLower(s)); severity {
+	case Off, Info, Warning, Error:
+		return severity, nil
+	}
+	return "", fmt.Errorf("unknown severity: %s", s)
+}
+
+// Finding is a risky pattern found in a plan.
+type Finding struct {
+	Rule     string
+	Severity Severity
+
+	// Statement is the index of the offending statement in the plan.
+	Statement int
+	Message   string
+}
+
+func (f *Finding) String() string {
+	return fmt.Sprintf("%s [%s] %s", f.Severity, f.Rule, f.Message)
+}
+
+// Rule detects a single risky pattern.
+type Rule struct {
+	Name        string
+	Description string
+
+	// Severity is used unless overridden with WithSeverity.
+	Severity Severity
+
+	// Dialects restricts the rule to plans for these drivers. Empty applies
+	// to every dialect.
+	Dialects []string
+
+	check func(plan []*statement, report func(index int, format string, args ...any))
+}
+
+// Rules lists every rule, in the order their findings are reported.
+var Rules = []*Rule{
+	{
+		Name:        "drop-table",
+		Description: "Dropping a table loses its data",
+		Severity:    Warning,
+		check:       checkDropTable,
+	},
+	{
+		Name:        "drop-column",
+		Description: "Dropping a column loses its data",
+		Severity:    Warning,
+		check:       checkDropColumn,
+	},
+	{
+		Name:        "rename-as-drop-add",
+		Description: "A column dropped and another added to the same table may be a rename losing data",
+		Severity:    Warning,
+		check:       checkRenameAsDropAdd,
+	},
+	{
+		Name:        "not-null-without-default",
+		Description: "Adding a NOT NULL column without default fails on tables holding rows",
+		Severity:    Error,
+		check:       checkNotNullWithoutDefault,
+	},
+	{
+		Name:        "set-not-null",
+		Description: "Setting NOT NULL scans the whole table under an exclusive lock",
+		Severity:    Warning,
+		Dialects:    []string{"postgres"},
+		check:       checkSetNotNull,
+	},
+	{
+		Name:        "column-type-change",
+		Description: "Changing a column type may rewrite the whole table under an exclusive lock",
+		Severity:    Warning,
+		Dialects:    []string{"postgres"},
+		check:       checkColumnTypeChange,
+	},
+	{
+		Name:        "non-concurrent-index",
+		Description: "Building an index without CONCURRENTLY blocks writes to the table",
+		Severity:    Warning,
+		Dialects:    []string{"postgres"},
+		check:       checkNonConcurrentIndex,
+	},
+}
+
+func RuleByName(name string) (*Rule, bool) {
+	for _, rule := range Rules {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return nil, false
+}
+
+type Option func(*linter)
+
+// WithDialect only applies the rules relevant to plans for the named
+// driver, such as sqlite3 or postgres.
+func WithDialect(dialect string) Option {
+	return func(l *linter) { l.dialect = dialect }
+}
+
+// WithSeverity overrides the severity of a rule. Off disables it.
+func WithSeverity(rule string, severity Severity) Option {
+	return func(l *linter) { l.severities[rule] = severity }
+}
+
+type linter struct {
+	dialect    string
+	severities map[string]Severity
+}
+
+// Lint checks the statements of a plan, returning findings ordered by
+// statement.
+func Lint(statements []string, opts ...Option) []*Finding {
+	l := &linter{
+		severities: make(map[string]Severity),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	plan := make([]*statement, len(statements))
+	for i, sql := range statements {
+		plan[i] = parseStatement(sql)
+	}
+
+	var findings []*Finding
+	for _, rule := range Rules {
+		if len(rule.Dialects) > 0 && !slices.Contains(rule.Dialects, l.dialect) {
+			continue
+		}
+
+		severity, found := l.severities[rule.Name]
+		if !found {
+			severity = rule.Severity
+		}
+		if severity == Off {
+			continue
+		}
+
+		rule.check(plan, func(index int, format string, args ...any) {
+			findings = append(findings, &Finding{
+				Rule:      rule.Name,
+				Severity:  severity,
+				Statement: index,
+				Message:   fmt.Sprintf(format, args...),
+			})
+		})
+	}
+
+	slices.SortStableFunc(findings, func(a, b *Finding) int {
+		return a.Statement - b.Statement
+	})
+
+	return findings
+}
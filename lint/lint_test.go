@@ -0,0 +1,88 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	rules := func(findings []*Finding) []string {
+		var names []string
+		for _, finding := range findings {
+			names = append(names, finding.Rule)
+		}
+		return names
+	}
+
+	t.Run("DropTable", func(t *testing.T) {
+		findings := Lint([]string{`DROP TABLE "users";`})
+		require.Equal(t, []string{"drop-table"}, rules(findings))
+		require.Equal(t, `warning [drop-table] dropping table "users" loses its data`, findings[0].String())
+	})
+
+	t.Run("RecreatedTable", func(t *testing.T) {
+		findings := Lint([]string{
+			"CREATE TABLE \"_users_temp\" (\n\t\"id\" INTEGER\n);",
+			`INSERT INTO "_users_temp" ("id") SELECT "id" FROM "users";`,
+			`DROP TABLE "users";`,
+			`ALTER TABLE "_users_temp" RENAME TO "users";`,
+		})
+		require.Empty(t, findings)
+	})
+
+	t.Run("RenameAsDropAdd", func(t *testing.T) {
+		findings := Lint([]string{
+			`ALTER TABLE "users" DROP COLUMN "name";`,
+			`ALTER TABLE "users" ADD COLUMN "full_name" TEXT;`,
+		})
+		require.Equal(t, []string{"drop-column", "rename-as-drop-add"}, rules(findings))
+		require.Equal(t, 1, findings[1].Statement)
+	})
+
+	t.Run("RetypedColumn", func(t *testing.T) {
+		findings := Lint([]string{
+			`ALTER TABLE "users" DROP COLUMN "age";`,
+			`ALTER TABLE "users" ADD COLUMN "age" INTEGER;`,
+		})
+		require.Equal(t, []string{"drop-column"}, rules(findings))
+	})
+
+	t.Run("NotNullWithoutDefault", func(t *testing.T) {
+		findings := Lint([]string{
+			`ALTER TABLE "users" ADD COLUMN "email" TEXT NOT NULL;`,
+			`ALTER TABLE "users" ADD COLUMN "name" TEXT NOT NULL DEFAULT '';`,
+		})
+		require.Equal(t, []string{"not-null-without-default"}, rules(findings))
+		require.Equal(t, Error, findings[0].Severity)
+	})
+
+	t.Run("Dialects", func(t *testing.T) {
+		statements := []string{
+			`CREATE INDEX "idx_users_name" ON "users" ("name");`,
+			`ALTER TABLE "users" ALTER COLUMN "name" SET NOT NULL;`,
+			`ALTER TABLE "users" ALTER COLUMN "age" TYPE bigint;`,
+			`CREATE INDEX CONCURRENTLY idx_users_email ON public.users USING btree (email);`,
+		}
+
+		require.Empty(t, Lint(statements, WithDialect("sqlite3")))
+		require.Equal(t, []string{"non-concurrent-index", "set-not-null", "column-type-change"}, rules(Lint(statements, WithDialect("postgres"))))
+	})
+
+	t.Run("CreatedTable", func(t *testing.T) {
+		findings := Lint([]string{
+			"CREATE TABLE \"users\" (\n\t\"id\" integer\n);",
+			`CREATE INDEX "idx_users_id" ON "users" ("id");`,
+		}, WithDialect("postgres"))
+		require.Empty(t, findings)
+	})
+
+	t.Run("Severities", func(t *testing.T) {
+		statements := []string{`ALTER TABLE "users" DROP COLUMN "name";`}
+
+		findings := Lint(statements, WithSeverity("drop-column", Error))
+		require.Equal(t, Error, findings[0].Severity)
+
+		require.Empty(t, Lint(statements, WithSeverity("drop-column", Off)))
+	})
+}
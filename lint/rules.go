@@ -0,0 +1,216 @@
+package lint
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type statementKind int
+
+const (
+	otherStatement statementKind = iota
+	createTable
+	dropTable
+	renameTable
+	addColumn
+	dropColumn
+	setNotNull
+	alterColumnType
+	createIndex
+)
+
+// statement is what the rules need to know about a plan statement.
+type statement struct {
+	kind   statementKind
+	table  string
+	column string
+
+	// definition is the column definition of added columns, or the new name
+	// of renamed tables.
+	definition string
+
+	concurrently bool
+}
+
+const identifier = `("(?:[^"]|"")*"|[^\s"(;,]+)`
+
+var statementPatterns = []struct {
+	kind    statementKind
+	pattern *regexp.Regexp
+}{
+	{createTable, regexp.MustCompile(`(?is)^CREATE\s+(?:TEMP(?:ORARY)?\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?` + identifier)},
+	{dropTable, regexp.MustCompile(`(?is)^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?` + identifier)},
+	{renameTable, regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+` + identifier + `\s+RENAME\s+TO\s+` + identifier)},
+	{addColumn, regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+` + identifier + `\s+ADD\s+COLUMN\s+(?:IF\s+NOT\s+EXISTS\s+)?` + identifier + `(.*?);?\s*$`)},
+	{dropColumn, regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+` + identifier + `\s+DROP\s+COLUMN\s+(?:IF\s+EXISTS\s+)?` + identifier)},
+	{setNotNull, regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+` + identifier + `\s+ALTER\s+COLUMN\s+` + identifier + `\s+SET\s+NOT\s+NULL`)},
+	{alterColumnType, regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+` + identifier + `\s+ALTER\s+COLUMN\s+` + identifier + `\s+(?:SET\s+DATA\s+)?TYPE\s`)},
+	{createIndex, regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(CONCURRENTLY\s+)?.*?\sON\s+(?:ONLY\s+)?` + identifier)},
+}
+
+var leadingComments = regexp.MustCompile(`^(?:\s*--[^\n]*\n)*\s*`)
+
+func parseStatement(sql string) *statement {
+	sql = leadingComments.ReplaceAllString(sql, "")
+
+	for _, p := range statementPatterns {
+		match := p.pattern.FindStringSubmatch(sql)
+		if match == nil {
+			continue
+		}
+
+		s := &statement{kind: p.kind}
+		switch p.kind {
+		case createTable, dropTable:
+			s.table = objectName(match[1])
+		case renameTable:
+			s.table = objectName(match[1])
+			s.definition = objectName(match[2])
+		case addColumn:
+			s.table = objectName(match[1])
+			s.column = objectName(match[2])
+			s.definition = strings.TrimSpace(match[3])
+		case dropColumn, setNotNull, alterColumnType:
+			s.table = objectName(match[1])
+			s.column = objectName(match[2])
+		case createIndex:
+			s.concurrently = match[1] != ""
+			s.table = objectName(match[2])
+		}
+		return s
+	}
+
+	return &statement{kind: otherStatement}
+}
+
+// objectName unquotes an identifier and strips its schema, if any.
+func objectName(identifier string) string {
+	if strings.HasPrefix(identifier, `"`) {
+		return strings.ReplaceAll(strings.Trim(identifier, `"`), `""`, `"`)
+	}
+
+	if i := strings.LastIndex(identifier, "."); i >= 0 {
+		identifier = identifier[i+1:]
+	}
+	return strings.Trim(identifier, `"`)
+}
+
+// createdTables lists the tables created by the plan, which hold no rows
+// yet and can be altered without risk.
+func createdTables(plan []*statement) map[string]bool {
+	created := make(map[string]bool)
+	for _, s := range plan {
+		if s.kind == createTable {
+			created[s.table] = true
+		}
+	}
+	return created
+}
+
+func checkDropTable(plan []*statement, report func(int, string, ...any)) {
+	for i, s := range plan {
+		if s.kind != dropTable {
+			continue
+		}
+
+		// Tables recreated by renaming a copy over them keep their data
+		recreated := false
+		for _, later := range plan[i+1:] {
+			if later.kind == renameTable && later.definition == s.table {
+				recreated = true
+				break
+			}
+		}
+
+		if !recreated {
+			report(i, "dropping table %q loses its data", s.table)
+		}
+	}
+}
+
+func checkDropColumn(plan []*statement, report func(int, string, ...any)) {
+	for i, s := range plan {
+		if s.kind == dropColumn {
+			report(i, "dropping column %q.%q loses its data", s.table, s.column)
+		}
+	}
+}
+
+func checkRenameAsDropAdd(plan []*statement, report func(int, string, ...any)) {
+	dropped := make(map[string][]string)
+	for _, s := range plan {
+		if s.kind == dropColumn {
+			dropped[s.table] = append(dropped[s.table], s.column)
+		}
+	}
+
+	for i, s := range plan {
+		if s.kind != addColumn || len(dropped[s.table]) == 0 {
+			continue
+		}
+
+		// Columns dropped and added back under the same name are type
+		// changes, not renames
+		var candidates []string
+		for _, column := range dropped[s.table] {
+			if column != s.column {
+				candidates = append(candidates, strconv.Quote(column))
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		report(i, "column %q.%q is added while %s is dropped, if it is a rename its data is lost", s.table, s.column, strings.Join(candidates, ", "))
+	}
+}
+
+var (
+	notNullPattern = regexp.MustCompile(`(?i)\bNOT\s+NULL\b`)
+	defaultPattern = regexp.MustCompile(`(?i)\bDEFAULT\b`)
+)
+
+func checkNotNullWithoutDefault(plan []*statement, report func(int, string, ...any)) {
+	created := createdTables(plan)
+
+	for i, s := range plan {
+		if s.kind != addColumn || created[s.table] {
+			continue
+		}
+
+		if notNullPattern.MatchString(s.definition) && !defaultPattern.MatchString(s.definition) {
+			report(i, "column %q.%q is added as NOT NULL without a default", s.table, s.column)
+		}
+	}
+}
+
+func checkSetNotNull(plan []*statement, report func(int, string, ...any)) {
+	created := createdTables(plan)
+
+	for i, s := range plan {
+		if s.kind == setNotNull && !created[s.table] {
+			report(i, "setting column %q.%q NOT NULL scans %q under an exclusive lock", s.table, s.column, s.table)
+		}
+	}
+}
+
+func checkColumnTypeChange(plan []*statement, report func(int, string, ...any)) {
+	created := createdTables(plan)
+
+	for i, s := range plan {
+		if s.kind == alterColumnType && !created[s.table] {
+			report(i, "changing the type of column %q.%q may rewrite %q under an exclusive lock", s.table, s.column, s.table)
+		}
+	}
+}
+
+func checkNonConcurrentIndex(plan []*statement, report func(int, string, ...any)) {
+	created := createdTables(plan)
+
+	for i, s := range plan {
+		if s.kind == createIndex && !s.concurrently && !created[s.table] {
+			report(i, "index on %q is built without CONCURRENTLY, blocking writes until it completes", s.table)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package plan
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// approvalPrefix sets approval tokens apart from plan signatures, so that the
+// signature of the author of a plan never passes for an approval.
+const approvalPrefix = "dbdiff approval "
+
+// Approve returns the token with which the owner of key confirms having
+// reviewed the plan. The plan must be sealed, the token only approving this
+// very content.
+func (f *File) Approve(key ed25519.PrivateKey) (string, error) {
+	if f.Checksum == "" {
+		return "", errors.New("plan file is not sealed")
+	}
+
+	err := f.checkChecksum()
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(approvalPrefix+f.Checksum))), nil
+}
+
+// CheckApproval checks that token was returned by Approve for this plan with
+// the private key of key.
+func (f *File) CheckApproval(token string, key ed25519.PublicKey) error {
+	if f.Checksum == "" {
+		return errors.New("plan file is not sealed")
+	}
+
+	err := f.checkChecksum()
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return fmt.Errorf("invalid approval token: %w", err)
+	}
+	if !ed25519.Verify(key, []byte(approvalPrefix+f.Checksum), signature) {
+		return errors.New("approval token does not match the plan and key")
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// FileVersion is the version of the plan file format written by Write.
+const FileVersion = 1
+
+// File is a plan saved for later, along with the state of the databases it
+// was generated from, so that it is only applied to the target it was made
+// for.
+type File struct {
+	Version   int       `json:"version"`
+	Dialect   string    `json:"dialect"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Source Database `json:"source"`
+	Target Database `json:"target"`
+
+	// Statements migrate the target to the source, in order.
+	Statements []string `json:"statements"`
+
+	// RequiresApproval makes apply refuse the plan without the approval
+	// token of a second operator, see Approve. Anyone holding the plan can
+	// drop it along with the checksum, so that it only ever adds to the
+	// approval the applying side requires on its own.
+	RequiresApproval bool `json:"requiresApproval,omitempty"`
+
+	// Checksum, when set, is the Digest of the plan, see Seal. Signature is
+	// the Ed25519 signature of Checksum, see Sign.
+	Checksum  string `json:"checksum,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Database identifies one side of a plan.
+type Database struct {
+	// URL is the database URL or environment alias, without its password.
+	URL string `json:"url"`
+
+	// Hash is the schema.Schema Hash of the database when the plan was made.
+	Hash string `json:"hash"`
+}
+
+// Write encodes f as indented JSON.
+func (f *File) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(f)
+}
+
+// ReadFile decodes a plan file, rejecting versions it does not know and
+// plans modified since they were sealed.
+func ReadFile(r io.Reader) (*File, error) {
+	var f File
+	err := json.NewDecoder(r).Decode(&f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plan file: %w", err)
+	}
+
+	if f.Version != FileVersion {
+		return nil, fmt.Errorf("unsupported plan file version %d", f.Version)
+	}
+
+	err = f.checkChecksum()
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
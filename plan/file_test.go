@@ -0,0 +1,93 @@
+package plan
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		file := &File{
+			Version:    FileVersion,
+			Dialect:    "sqlite3",
+			CreatedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Source:     Database{URL: "source.db", Hash: "sha256:a"},
+			Target:     Database{URL: "target.db", Hash: "sha256:b"},
+			Statements: []string{`DROP TABLE "posts";`},
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, file.Write(&buf))
+
+		read, err := ReadFile(&buf)
+		require.NoError(t, err)
+		require.Equal(t, file, read)
+	})
+
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		_, err := ReadFile(strings.NewReader(`{"version": 2}`))
+		require.EqualError(t, err, "unsupported plan file version 2")
+	})
+
+	t.Run("Checksum", func(t *testing.T) {
+		file := &File{Version: FileVersion, Dialect: "sqlite3", Statements: []string{`DROP TABLE "posts";`}}
+		require.NoError(t, file.Seal())
+		require.Regexp(t, `^sha256:[0-9a-f]{64}$`, file.Checksum)
+
+		var buf bytes.Buffer
+		require.NoError(t, file.Write(&buf))
+
+		read, err := ReadFile(strings.NewReader(buf.String()))
+		require.NoError(t, err)
+		require.Equal(t, file, read)
+
+		edited := strings.Replace(buf.String(), `DROP TABLE`, `DROP TABLE IF EXISTS`, 1)
+		_, err = ReadFile(strings.NewReader(edited))
+		require.ErrorIs(t, err, ErrModified)
+	})
+
+	t.Run("Signature", func(t *testing.T) {
+		publicKey, privateKey, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		otherKey, _, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+
+		file := &File{Version: FileVersion, Dialect: "sqlite3", Statements: []string{`DROP TABLE "posts";`}}
+		require.EqualError(t, file.Verify(publicKey), "plan file is not signed")
+
+		require.NoError(t, file.Sign(privateKey))
+		require.NoError(t, file.Verify(publicKey))
+		require.EqualError(t, file.Verify(otherKey), "plan file signature does not match the key")
+
+		file.Statements = nil
+		require.ErrorIs(t, file.Verify(publicKey), ErrModified)
+	})
+
+	t.Run("Approval", func(t *testing.T) {
+		authorPublicKey, authorKey, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		approverPublicKey, approverKey, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+
+		file := &File{Version: FileVersion, Dialect: "sqlite3", Statements: []string{`DROP TABLE "posts";`}, RequiresApproval: true}
+		_, err = file.Approve(approverKey)
+		require.EqualError(t, err, "plan file is not sealed")
+
+		require.NoError(t, file.Sign(authorKey))
+		token, err := file.Approve(approverKey)
+		require.NoError(t, err)
+		require.NoError(t, file.CheckApproval(token, approverPublicKey))
+
+		// The signature of the author is no approval
+		require.EqualError(t, file.CheckApproval(file.Signature, authorPublicKey), "approval token does not match the plan and key")
+		require.EqualError(t, file.CheckApproval(token, authorPublicKey), "approval token does not match the plan and key")
+
+		file.RequiresApproval = false
+		require.ErrorIs(t, file.CheckApproval(token, approverPublicKey), ErrModified)
+	})
+}
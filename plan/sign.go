@@ -0,0 +1,139 @@
+package plan
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ErrModified is returned for plan files whose content no longer matches
+// their checksum, i.e. that were edited after being written.
+var ErrModified = errors.New("plan file was modified after it was written")
+
+// Digest returns the SHA-256 of the plan, its checksum and signature left
+// out, as sha256:<hex>.
+func (f *File) Digest() (string, error) {
+	unsigned := *f
+	unsigned.Checksum = ""
+	unsigned.Signature = ""
+
+	content, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Seal sets the checksum of the plan, which ReadFile checks so that plans
+// edited between review and execution are refused.
+func (f *File) Seal() error {
+	digest, err := f.Digest()
+	if err != nil {
+		return err
+	}
+
+	f.Checksum = digest
+	return nil
+}
+
+// Sign seals the plan and signs its checksum with key, for Verify to prove
+// who wrote it.
+func (f *File) Sign(key ed25519.PrivateKey) error {
+	err := f.Seal()
+	if err != nil {
+		return err
+	}
+
+	f.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(f.Checksum)))
+	return nil
+}
+
+// checkChecksum rejects plans whose checksum, when they have one, does not
+// match their content.
+func (f *File) checkChecksum() error {
+	if f.Checksum == "" {
+		return nil
+	}
+
+	digest, err := f.Digest()
+	if err != nil {
+		return err
+	}
+	if digest != f.Checksum {
+		return ErrModified
+	}
+	return nil
+}
+
+// Verify checks that the plan was signed with the private key of key and
+// not modified since.
+func (f *File) Verify(key ed25519.PublicKey) error {
+	if f.Signature == "" {
+		return errors.New("plan file is not signed")
+	}
+
+	err := f.checkChecksum()
+	if err != nil {
+		return err
+	}
+	if f.Checksum == "" {
+		return errors.New("plan file has a signature but no checksum")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(f.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid plan file signature: %w", err)
+	}
+	if !ed25519.Verify(key, []byte(f.Checksum), signature) {
+		return errors.New("plan file signature does not match the key")
+	}
+	return nil
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS #8 Ed25519 private key, as
+// generated by `openssl genpkey -algorithm ed25519`.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported %T key, expected Ed25519", key)
+	}
+	return privateKey, nil
+}
+
+// ParsePublicKey parses a PEM encoded PKIX Ed25519 public key, as extracted
+// by `openssl pkey -pubout`.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported %T key, expected Ed25519", key)
+	}
+	return publicKey, nil
+}
@@ -0,0 +1,295 @@
+// Package plan reads migration plans, as generated by dbdiff or written by
+// hand.
+package plan
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+	"unicode"
+)
+
+// Statement is a single statement of a plan.
+type Statement struct {
+	// SQL is the statement text, including its terminating semicolon when it
+	// has one. Comments preceding it are left out.
+	SQL string
+
+	// Line is the line the statement starts on, counting from 1.
+	Line int
+}
+
+// Split yields the statements of a plan as they are read. Semicolons only end
+// statements outside of quotes, comments, Postgres dollar-quoted strings and
+// BEGIN ... END blocks of trigger, function and procedure bodies, so that
+// SQLite triggers come out whole.
+func Split(r io.Reader) iter.Seq2[Statement, error] {
+	return func(yield func(Statement, error) bool) {
+		s := &splitter{r: bufio.NewReader(r), line: 1}
+
+		for {
+			statement, err := s.next()
+			if errors.Is(err, io.EOF) {
+				if statement.SQL != "" {
+					yield(statement, nil)
+				}
+				return
+			}
+			if err != nil {
+				yield(Statement{}, fmt.Errorf("statement at line %d: %w", statement.Line, err))
+				return
+			}
+
+			if !yield(statement, nil) {
+				return
+			}
+		}
+	}
+}
+
+// SplitString returns the statements of a plan held in memory.
+func SplitString(plan string) []Statement {
+	var statements []Statement
+	for statement := range Split(strings.NewReader(plan)) {
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+type splitter struct {
+	r    *bufio.Reader
+	line int
+
+	sql       strings.Builder
+	startLine int
+
+	// words holds the first keywords of the statement, telling whether it
+	// has a body whose BEGIN ... END blocks are tracked in depth.
+	words []string
+	depth int
+}
+
+// next reads up to the end of the next statement. At the end of the input,
+// it returns io.EOF along with the unterminated statement, if any.
+func (s *splitter) next() (Statement, error) {
+	s.sql.Reset()
+	s.words = s.words[:0]
+	s.depth = 0
+
+	for {
+		c, err := s.read()
+		if err != nil {
+			return s.statement(), err
+		}
+
+		switch {
+		case c == '\n' || unicode.IsSpace(c):
+			s.write(c)
+		case c == '-' && s.peek() == '-':
+			err = s.comment(c, "\n")
+		case c == '/' && s.peek() == '*':
+			err = s.comment(c, "*/")
+		case c == '\'' || c == '"' || c == '`':
+			s.write(c)
+			err = s.quoted(c)
+		case c == '[':
+			s.write(c)
+			err = s.until("]")
+		case c == '$':
+			s.write(c)
+			err = s.dollarQuoted()
+		case isWordRune(c):
+			s.word(c)
+		case c == ';':
+			s.write(c)
+			if s.depth == 0 {
+				return s.statement(), nil
+			}
+		default:
+			s.write(c)
+		}
+
+		if err != nil {
+			return s.statement(), err
+		}
+	}
+}
+
+func (s *splitter) statement() Statement {
+	return Statement{SQL: strings.TrimSpace(s.sql.String()), Line: s.startLine}
+}
+
+func (s *splitter) read() (rune, error) {
+	c, _, err := s.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if c == '\n' {
+		s.line++
+	}
+	return c, nil
+}
+
+func (s *splitter) peek() rune {
+	c, _, err := s.r.ReadRune()
+	if err != nil {
+		return 0
+	}
+	s.r.UnreadRune()
+	return c
+}
+
+// write appends to the statement, which starts at its first character that
+// is neither blank nor part of a comment.
+func (s *splitter) write(c rune) {
+	if s.sql.Len() == 0 {
+		if unicode.IsSpace(c) {
+			return
+		}
+		s.startLine = s.line
+	}
+	s.sql.WriteRune(c)
+}
+
+// comment consumes a comment starting with first and ending with end.
+// Comments before a statement are dropped, the ones inside it are kept.
+func (s *splitter) comment(first rune, end string) error {
+	if s.sql.Len() > 0 {
+		s.sql.WriteRune(first)
+	}
+	return s.until(end)
+}
+
+// until consumes the input up to and including end, keeping it in the
+// statement once it started. A missing newline at the end of the input ends
+// line comments all the same.
+func (s *splitter) until(end string) error {
+	var consumed strings.Builder
+	for !strings.HasSuffix(consumed.String(), end) {
+		c, err := s.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) && end == "\n" {
+				break
+			}
+			return unexpectedEOF(err)
+		}
+		consumed.WriteRune(c)
+	}
+
+	if s.sql.Len() > 0 {
+		s.sql.WriteString(consumed.String())
+	}
+
+	return nil
+}
+
+// quoted consumes a quoted string or identifier, where doubling the quote
+// escapes it.
+func (s *splitter) quoted(quote rune) error {
+	for {
+		c, err := s.read()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		s.sql.WriteRune(c)
+
+		if c == quote {
+			if s.peek() != quote {
+				return nil
+			}
+
+			c, _ = s.read()
+			s.sql.WriteRune(c)
+		}
+	}
+}
+
+// dollarQuoted consumes a Postgres dollar-quoted string, once its opening $
+// has been written. Positional parameters such as $1 are left alone.
+func (s *splitter) dollarQuoted() error {
+	var tag strings.Builder
+	tag.WriteRune('$')
+
+	for {
+		c := s.peek()
+		if c == '$' {
+			break
+		}
+		if !isWordRune(c) || (tag.Len() == 1 && unicode.IsDigit(c)) {
+			return nil
+		}
+
+		c, _ = s.read()
+		s.sql.WriteRune(c)
+		tag.WriteRune(c)
+	}
+
+	c, _ := s.read()
+	s.sql.WriteRune(c)
+	tag.WriteRune(c)
+
+	return s.until(tag.String())
+}
+
+func (s *splitter) word(first rune) {
+	s.write(first)
+
+	var word strings.Builder
+	word.WriteRune(first)
+	for isWordRune(s.peek()) {
+		c, _ := s.read()
+		s.sql.WriteRune(c)
+		word.WriteRune(c)
+	}
+
+	keyword := strings.ToUpper(word.String())
+	if len(s.words) < 6 {
+		s.words = append(s.words, keyword)
+	}
+
+	if !s.hasBody() {
+		return
+	}
+
+	switch keyword {
+	case "BEGIN", "CASE":
+		s.depth++
+	case "END":
+		s.depth = max(s.depth-1, 0)
+	}
+}
+
+// hasBody reports whether the statement creates a trigger, function or
+// procedure, whose body may hold semicolons.
+func (s *splitter) hasBody() bool {
+	if len(s.words) == 0 || s.words[0] != "CREATE" {
+		return false
+	}
+
+	for _, word := range s.words[1:] {
+		switch word {
+		case "TRIGGER", "FUNCTION", "PROCEDURE":
+			return true
+		case "OR", "REPLACE", "TEMP", "TEMPORARY", "CONSTRAINT":
+			continue
+		}
+		return false
+	}
+
+	return false
+}
+
+// unexpectedEOF reports the end of the input within quotes or a comment.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func isWordRune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
@@ -0,0 +1,73 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	sqls := func(statements []Statement) []string {
+		var sqls []string
+		for _, statement := range statements {
+			sqls = append(sqls, statement.SQL)
+		}
+		return sqls
+	}
+
+	t.Run("Statements", func(t *testing.T) {
+		statements := SplitString("-- Plan\nCREATE TABLE \"users\" (\n\t\"id\" INTEGER\n);\n\nDROP TABLE \"posts\";")
+		require.Equal(t, []Statement{
+			{SQL: "CREATE TABLE \"users\" (\n\t\"id\" INTEGER\n);", Line: 2},
+			{SQL: `DROP TABLE "posts";`, Line: 6},
+		}, statements)
+	})
+
+	t.Run("Quotes", func(t *testing.T) {
+		statements := SplitString(`INSERT INTO "a;b" VALUES ('it''s; fine', "x"";y", ` + "`c;d`" + `, [e;f]); SELECT 1`)
+		require.Equal(t, []string{
+			`INSERT INTO "a;b" VALUES ('it''s; fine', "x"";y", ` + "`c;d`" + `, [e;f]);`,
+			`SELECT 1`,
+		}, sqls(statements))
+	})
+
+	t.Run("Comments", func(t *testing.T) {
+		statements := SplitString("SELECT 1 -- not; the end\n;\n/* a; b */ SELECT /* c; d */ 2;")
+		require.Equal(t, []string{
+			"SELECT 1 -- not; the end\n;",
+			"SELECT /* c; d */ 2;",
+		}, sqls(statements))
+	})
+
+	t.Run("SQLiteTrigger", func(t *testing.T) {
+		trigger := `CREATE TRIGGER "audit" AFTER UPDATE ON "users"
+WHEN CASE WHEN NEW.name IS NULL THEN 0 ELSE 1 END
+BEGIN
+	INSERT INTO "log" VALUES (NEW.id);
+	UPDATE "users" SET "updated" = 1 WHERE "id" = NEW.id;
+END;`
+		statements := SplitString(trigger + "\nBEGIN;\nSELECT 1;\nEND;")
+		require.Equal(t, []string{trigger, "BEGIN;", "SELECT 1;", "END;"}, sqls(statements))
+	})
+
+	t.Run("DollarQuotes", func(t *testing.T) {
+		function := `CREATE OR REPLACE FUNCTION touch() RETURNS trigger AS $body$
+BEGIN
+	NEW.updated_at := now();
+	RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;`
+		statements := SplitString(function + "\nPREPARE q AS SELECT $1;\nSELECT $$a;b$$;")
+		require.Equal(t, []string{function, "PREPARE q AS SELECT $1;", "SELECT $$a;b$$;"}, sqls(statements))
+	})
+
+	t.Run("Unterminated", func(t *testing.T) {
+		var errs []error
+		for _, err := range Split(strings.NewReader(`SELECT 'open;`)) {
+			errs = append(errs, err)
+		}
+		require.Len(t, errs, 1)
+		require.Error(t, errs[0])
+	})
+}
@@ -0,0 +1,191 @@
+// Package policy blocks diffs making disallowed changes, such as dropping
+// audit tables or narrowing column types, before any statement is rendered.
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// Rule names a kind of disallowed change.
+type Rule string
+
+const (
+	// NoDropTable forbids dropping tables.
+	NoDropTable Rule = "no-drop-table"
+
+	// NoDropColumn forbids dropping columns. Renamed columns are not
+	// dropped.
+	NoDropColumn Rule = "no-drop-column"
+
+	// RequirePrimaryKey forbids creating tables without a primary key.
+	RequirePrimaryKey Rule = "require-primary-key"
+
+	// NoTypeNarrowing forbids changing column types to ones holding less,
+	// see Narrows.
+	NoTypeNarrowing Rule = "no-type-narrowing"
+)
+
+// Rules lists every rule.
+var Rules = []Rule{NoDropTable, NoDropColumn, RequirePrimaryKey, NoTypeNarrowing}
+
+// Policy applies a rule to some tables.
+type Policy struct {
+	// Name identifies the policy in violations. Defaults to the rule.
+	Name string `yaml:"name"`
+
+	Rule Rule `yaml:"rule"`
+
+	// Tables are globs, using the path.Match syntax, restricting the policy
+	// to the tables they match. Empty matches every table.
+	Tables []string `yaml:"tables"`
+}
+
+// Validate reports unknown rules and malformed table patterns.
+func (p Policy) Validate() error {
+	known := false
+	for _, rule := range Rules {
+		known = known || p.Rule == rule
+	}
+	if !known {
+		return fmt.Errorf("unknown policy rule: %q", p.Rule)
+	}
+
+	for _, pattern := range p.Tables {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("invalid table pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+func (p Policy) name() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return string(p.Rule)
+}
+
+func (p Policy) appliesTo(table string) bool {
+	if len(p.Tables) == 0 {
+		return true
+	}
+
+	for _, pattern := range p.Tables {
+		if matched, _ := path.Match(pattern, table); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Violation is a change disallowed by a policy.
+type Violation struct {
+	Policy  string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("[%s] %s", v.Policy, v.Message)
+}
+
+// ViolationsError fails diffs violating policies, listing every violation.
+type ViolationsError []Violation
+
+func (e ViolationsError) Error() string {
+	var message strings.Builder
+	fmt.Fprintf(&message, "%d policy violation(s):", len(e))
+	for _, violation := range e {
+		message.WriteString("\n  ")
+		message.WriteString(violation.String())
+	}
+	return message.String()
+}
+
+// Evaluate returns the violations of policies found in diff, in diff order.
+func Evaluate(diff *schema.Diff, policies ...Policy) []Violation {
+	var violations []Violation
+
+	for _, table := range diff.Tables {
+		for _, policy := range policies {
+			if !policy.appliesTo(table.Name()) {
+				continue
+			}
+
+			for _, message := range check(policy.Rule, table) {
+				violations = append(violations, Violation{Policy: policy.name(), Message: message})
+			}
+		}
+	}
+
+	return violations
+}
+
+// Check returns a ViolationsError when diff violates any of policies, for
+// drivers.WithDiffCheck.
+func Check(policies ...Policy) func(diff *schema.Diff) error {
+	return func(diff *schema.Diff) error {
+		violations := Evaluate(diff, policies...)
+		if len(violations) > 0 {
+			return ViolationsError(violations)
+		}
+		return nil
+	}
+}
+
+func check(rule Rule, table *schema.TableDiff) []string {
+	var messages []string
+
+	switch rule {
+	case NoDropTable:
+		if table.Kind == schema.Removed {
+			messages = append(messages, fmt.Sprintf("table %s is dropped", table.Name()))
+		}
+
+	case NoDropColumn:
+		if table.Kind == schema.Modified {
+			for _, column := range table.Columns.Removed {
+				messages = append(messages, fmt.Sprintf("column %s.%s is dropped", table.Name(), column))
+			}
+		}
+
+	case RequirePrimaryKey:
+		if table.Kind == schema.Added && !hasPrimaryKey(table.Source) {
+			messages = append(messages, fmt.Sprintf("table %s is created without a primary key", table.Name()))
+		}
+
+	case NoTypeNarrowing:
+		if table.Kind != schema.Modified {
+			break
+		}
+
+		for _, name := range table.Columns.Retyped {
+			source, _ := table.Source.ColumnByName(name)
+			target, found := table.Target.ColumnByName(name)
+			if found && Narrows(target.Type, source.Type) {
+				messages = append(messages, fmt.Sprintf("column %s.%s is narrowed from %s to %s", table.Name(), name, target.Type, source.Type))
+			}
+		}
+	}
+
+	return messages
+}
+
+func hasPrimaryKey(table *schema.Table) bool {
+	for _, column := range table.Columns {
+		if column.PrimaryKey {
+			return true
+		}
+	}
+	for _, constraint := range table.Constraints {
+		if constraint.Type == "p" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/quantumsheep/dbdiff/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	column := func(name string, columnType string, primaryKey bool) *schema.Column {
+		return &schema.Column{Name: name, Type: columnType, PrimaryKey: primaryKey}
+	}
+
+	target := &schema.Schema{Tables: []*schema.Table{
+		{Name: "audit_log", Columns: []*schema.Column{column("id", "INTEGER", true)}},
+		{Name: "sessions", Columns: []*schema.Column{column("id", "INTEGER", true)}},
+		{Name: "users", Columns: []*schema.Column{
+			column("id", "BIGINT", true),
+			column("name", "VARCHAR(255)", false),
+			column("bio", "TEXT", false),
+		}},
+	}}
+	source := &schema.Schema{Tables: []*schema.Table{
+		{Name: "users", Columns: []*schema.Column{
+			column("id", "INTEGER", true),
+			column("name", "VARCHAR(500)", false),
+		}},
+		{Name: "events", Columns: []*schema.Column{column("payload", "TEXT", false)}},
+	}}
+	diff := schema.Compare(source, target)
+
+	messages := func(violations []Violation) []string {
+		var messages []string
+		for _, violation := range violations {
+			messages = append(messages, violation.String())
+		}
+		return messages
+	}
+
+	t.Run("Rules", func(t *testing.T) {
+		violations := Evaluate(diff,
+			Policy{Rule: NoDropColumn},
+			Policy{Rule: NoTypeNarrowing},
+			Policy{Rule: RequirePrimaryKey},
+		)
+		require.Equal(t, []string{
+			"[no-drop-column] column users.bio is dropped",
+			"[no-type-narrowing] column users.id is narrowed from BIGINT to INTEGER",
+			"[require-primary-key] table events is created without a primary key",
+		}, messages(violations))
+	})
+
+	t.Run("Tables", func(t *testing.T) {
+		violations := Evaluate(diff, Policy{Name: "keep-audit", Rule: NoDropTable, Tables: []string{"audit_*"}})
+		require.Equal(t, []string{"[keep-audit] table audit_log is dropped"}, messages(violations))
+	})
+
+	t.Run("Check", func(t *testing.T) {
+		err := Check(Policy{Rule: NoDropTable})(diff)
+		require.EqualError(t, err, "2 policy violation(s):\n  [no-drop-table] table audit_log is dropped\n  [no-drop-table] table sessions is dropped")
+
+		require.NoError(t, Check(Policy{Rule: NoDropTable, Tables: []string{"users"}})(diff))
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		require.EqualError(t, Policy{Rule: "no-fun"}.Validate(), `unknown policy rule: "no-fun"`)
+		require.Error(t, Policy{Rule: NoDropTable, Tables: []string{"["}}.Validate())
+	})
+}
+
+func TestNarrows(t *testing.T) {
+	for _, c := range []struct {
+		from, to string
+		narrows  bool
+	}{
+		{"BIGINT", "INT", true},
+		{"int4", "int8", false},
+		{"DOUBLE PRECISION", "REAL", true},
+		{"REAL", "INTEGER", true},
+		{"NUMERIC(10,2)", "NUMERIC(8,2)", true},
+		{"NUMERIC(10,2)", "NUMERIC(10,0)", true},
+		{"NUMERIC", "NUMERIC(10,2)", true},
+		{"NUMERIC(10,2)", "NUMERIC(12,4)", false},
+		{"NUMERIC(10,2)", "BIGINT", true},
+		{"VARCHAR(255)", "VARCHAR(100)", true},
+		{"VARCHAR(100)", "CHAR(200)", false},
+		{"TEXT", "VARCHAR(100)", true},
+		{"VARCHAR(100)", "TEXT", false},
+		{"VARCHAR", "VARCHAR(100)", true},
+		{"TEXT", "INTEGER", false},
+	} {
+		require.Equal(t, c.narrows, Narrows(c.from, c.to), "%s to %s", c.from, c.to)
+	}
+}
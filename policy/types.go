@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/quantumsheep/dbdiff/schema"
+)
+
+// integerRanks orders integer types by size.
+var integerRanks = map[string]int{
+	"SMALLINT":    1,
+	"SMALLSERIAL": 1,
+	"INTEGER":     2,
+	"SERIAL":      2,
+	"BIGINT":      3,
+	"BIGSERIAL":   3,
+}
+
+// floatRanks orders floating point types by precision.
+var floatRanks = map[string]int{
+	"REAL":             1,
+	"DOUBLE PRECISION": 2,
+}
+
+// lengthTypes are limited by their first modifier, and unlimited without.
+var lengthTypes = map[string]bool{
+	"CHARACTER VARYING": true,
+	"CHARACTER":         true,
+	"BIT VARYING":       true,
+	"BIT":               true,
+}
+
+// Narrows reports whether changing a column from one type to another may
+// lose values: smaller integer or floating point types, shorter strings,
+// lower numeric precision or scale, and numbers losing their fractional
+// part. Types it does not know never narrow.
+func Narrows(from string, to string) bool {
+	fromName, fromModifiers := splitType(schema.NormalizeType(from))
+	toName, toModifiers := splitType(schema.NormalizeType(to))
+
+	if fromRank, found := integerRanks[fromName]; found {
+		toRank, found := integerRanks[toName]
+		return found && toRank < fromRank
+	}
+
+	if fromRank, found := floatRanks[fromName]; found {
+		if toRank, found := floatRanks[toName]; found {
+			return toRank < fromRank
+		}
+		_, toInteger := integerRanks[toName]
+		return toInteger
+	}
+
+	switch {
+	case fromName == "NUMERIC" && toName == "NUMERIC":
+		return modifierNarrows(fromModifiers, toModifiers, 0) || modifierNarrows(fromModifiers, toModifiers, 1)
+	case fromName == "NUMERIC":
+		_, toInteger := integerRanks[toName]
+		return toInteger
+	case fromName == "TEXT" || lengthTypes[fromName]:
+		return lengthTypes[toName] && modifierNarrows(fromModifiers, toModifiers, 0)
+	}
+
+	return false
+}
+
+// modifierNarrows reports whether the i-th modifier of a type shrinks. A
+// missing modifier is unlimited.
+func modifierNarrows(from []int, to []int, i int) bool {
+	if i >= len(to) {
+		return false
+	}
+	if i >= len(from) {
+		return true
+	}
+	return to[i] < from[i]
+}
+
+// splitType separates a normalized type from its numeric modifiers, e.g.
+// NUMERIC(10,2) into NUMERIC and [10 2].
+func splitType(normalized string) (string, []int) {
+	name, rest, found := strings.Cut(normalized, "(")
+	if !found {
+		return normalized, nil
+	}
+
+	rest, suffix, _ := strings.Cut(rest, ")")
+	if suffix != "" {
+		// Such as TIME(3) WITH TIME ZONE
+		name += " " + strings.TrimPrefix(suffix, " ")
+	}
+
+	var modifiers []int
+	for _, modifier := range strings.Split(rest, ",") {
+		n, err := strconv.Atoi(modifier)
+		if err != nil {
+			return name, nil
+		}
+		modifiers = append(modifiers, n)
+	}
+
+	return name, modifiers
+}
@@ -0,0 +1,128 @@
+package schema
+
+import "strings"
+
+// WithCaseInsensitiveNames matches objects whose names only differ by case,
+// such as Users and users, as the same object. Postgres folds unquoted
+// identifiers to lower case and SQLite ignores case in most names, so
+// schemas written by different tools often disagree on case alone.
+func WithCaseInsensitiveNames(enabled bool) CompareOption {
+	return func(c *comparer) { c.caseInsensitive = enabled }
+}
+
+// alignNameCase returns a copy of target where names matching a source name
+// regardless of case are spelled as in source, so that comparing by exact
+// name then matches them. Names matching exactly are left alone.
+func alignNameCase(source *Schema, target *Schema) *Schema {
+	aligned := &Schema{
+		Dialect:    target.Dialect,
+		Views:      make([]*View, len(target.Views)),
+		Pragmas:    target.Pragmas,
+		Aggregates: target.Aggregates,
+		Operators:  target.Operators,
+		Casts:      target.Casts,
+	}
+
+	tableNames := names(source.Tables, tableName)
+
+	for _, targetTable := range target.Tables {
+		table := targetTable.Copy()
+		table.Name = alignName(tableNames, table.Name)
+
+		sourceTable, found := source.TableByName(table.Name)
+		if !found {
+			aligned.Tables = append(aligned.Tables, table)
+			continue
+		}
+
+		columnNames := names(sourceTable.Columns, func(c *Column) string { return c.Name })
+		alignColumns := func(columns []string) []string {
+			alignedColumns := make([]string, len(columns))
+			for i, column := range columns {
+				alignedColumns[i] = alignName(columnNames, column)
+			}
+			return alignedColumns
+		}
+
+		table.Columns = make([]*Column, len(targetTable.Columns))
+		for i, column := range targetTable.Columns {
+			table.Columns[i] = column.Copy()
+			table.Columns[i].Name = alignName(columnNames, column.Name)
+		}
+
+		indexNames := names(sourceTable.Indexes, func(i *Index) string { return i.Name })
+		table.Indexes = make([]*Index, len(targetTable.Indexes))
+		for i, index := range targetTable.Indexes {
+			copy := *index
+			copy.Name = alignName(indexNames, index.Name)
+			copy.Table = table.Name
+			copy.Columns = alignColumns(index.Columns)
+			table.Indexes[i] = &copy
+		}
+
+		constraintNames := names(sourceTable.Constraints, func(c *Constraint) string { return c.Name })
+		table.Constraints = make([]*Constraint, len(targetTable.Constraints))
+		for i, constraint := range targetTable.Constraints {
+			copy := *constraint
+			copy.Name = alignName(constraintNames, constraint.Name)
+			table.Constraints[i] = &copy
+		}
+
+		table.ForeignKeys = make([]*ForeignKey, len(targetTable.ForeignKeys))
+		for i, fk := range targetTable.ForeignKeys {
+			copy := *fk
+			copy.Table = alignName(tableNames, fk.Table)
+			copy.From = alignColumns(fk.From)
+			if referenced, found := source.TableByName(copy.Table); found {
+				referencedNames := names(referenced.Columns, func(c *Column) string { return c.Name })
+				copy.To = make([]string, len(fk.To))
+				for j, column := range fk.To {
+					copy.To[j] = alignName(referencedNames, column)
+				}
+			}
+			table.ForeignKeys[i] = &copy
+		}
+
+		triggerNames := names(sourceTable.Triggers, func(t *Trigger) string { return t.Name })
+		table.Triggers = make([]*Trigger, len(targetTable.Triggers))
+		for i, trigger := range targetTable.Triggers {
+			copy := *trigger
+			copy.Name = alignName(triggerNames, trigger.Name)
+			table.Triggers[i] = &copy
+		}
+
+		aligned.Tables = append(aligned.Tables, table)
+	}
+
+	viewNames := names(source.Views, func(v *View) string { return v.Name })
+	for i, view := range target.Views {
+		copy := *view
+		copy.Name = alignName(viewNames, view.Name)
+		aligned.Views[i] = &copy
+	}
+
+	return aligned
+}
+
+func names[T any](objects []T, name func(T) string) []string {
+	names := make([]string, len(objects))
+	for i, object := range objects {
+		names[i] = name(object)
+	}
+	return names
+}
+
+// alignName returns the spelling of name found in candidates, preferring an
+// exact match over one ignoring case.
+func alignName(candidates []string, name string) string {
+	match := name
+	for _, candidate := range candidates {
+		if candidate == name {
+			return name
+		}
+		if match == name && strings.EqualFold(candidate, name) {
+			match = candidate
+		}
+	}
+	return match
+}
@@ -0,0 +1,422 @@
+package schema
+
+import (
+	"log/slog"
+
+	"github.com/samber/lo"
+)
+
+type ChangeKind string
+
+const (
+	Added    ChangeKind = "added"
+	Removed  ChangeKind = "removed"
+	Modified ChangeKind = "modified"
+)
+
+// Change describes how a single object differs between the source and the
+// target schema. Source is nil for removed objects, Target for added ones.
+type Change[T any] struct {
+	Kind   ChangeKind
+	Source T
+	Target T
+}
+
+// Diff lists the changes needed to turn the target schema into the source
+// one. Added and modified objects come in source order, followed by removed
+// objects in target order.
+type Diff struct {
+	Source *Schema
+	Target *Schema
+
+	Tables []*TableDiff
+	Views  []*Change[*View]
+
+	// Pragmas are only compared when both sides hold theirs.
+	Pragmas []*Change[*Pragma]
+
+	Aggregates []*Change[*Aggregate]
+	Operators  []*Change[*Operator]
+	Casts      []*Change[*Cast]
+}
+
+func (d *Diff) IsEmpty() bool {
+	return len(d.Tables) == 0 && len(d.Views) == 0 && len(d.Pragmas) == 0 &&
+		len(d.Aggregates) == 0 && len(d.Operators) == 0 && len(d.Casts) == 0
+}
+
+type TableDiff struct {
+	Change[*Table]
+
+	// The fields below are only set for modified tables.
+	Columns            *ColumnsDiff
+	ForeignKeysChanged bool
+	Constraints        []*Change[*Constraint]
+	Indexes            []*Change[*Index]
+	Triggers           []*Change[*Trigger]
+}
+
+// IsDestructive reports whether applying the diff drops tables, views,
+// columns, constraints, indexes, triggers, aggregates, operators or casts,
+// losing data or definitions that only exist in the target.
+func (d *Diff) IsDestructive() bool {
+	for _, table := range d.Tables {
+		if table.IsDestructive() {
+			return true
+		}
+	}
+	return removes(d.Views) || removes(d.Aggregates) || removes(d.Operators) || removes(d.Casts)
+}
+
+func (d *TableDiff) Name() string {
+	if d.Source != nil {
+		return d.Source.Name
+	}
+	return d.Target.Name
+}
+
+func (d *TableDiff) IsEmpty() bool {
+	return d.Kind == Modified &&
+		d.Columns.IsEmpty() &&
+		!d.ForeignKeysChanged &&
+		len(d.Constraints) == 0 &&
+		len(d.Indexes) == 0 &&
+		len(d.Triggers) == 0
+}
+
+// IsDestructive reports whether the table, or any of its columns,
+// constraints, indexes or triggers, is dropped. Renamed columns are not.
+func (d *TableDiff) IsDestructive() bool {
+	if d.Kind != Modified {
+		return d.Kind == Removed
+	}
+
+	return len(d.Columns.Removed) > 0 ||
+		removes(d.Constraints) ||
+		removes(d.Indexes) ||
+		removes(d.Triggers)
+}
+
+func removes[T any](changes []*Change[T]) bool {
+	for _, change := range changes {
+		if change.Kind == Removed {
+			return true
+		}
+	}
+	return false
+}
+
+// ColumnsDiff lists column names. Added and Modified name source columns,
+// Removed names target columns.
+type ColumnsDiff struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+	Renamed  map[string]string // oldName -> newName
+
+	// Retyped lists the modified columns whose type changed to one that is
+	// not equivalent.
+	Retyped []string
+
+	// Copied maps added columns to the column of the target table whose
+	// values they take, such as the new names of renamed columns during the
+	// expand phase, see Diff.Phases.
+	Copied map[string]string // newName -> oldName
+}
+
+func (d *ColumnsDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Modified) == 0 && len(d.Removed) == 0 && len(d.Renamed) == 0
+}
+
+type CompareOption func(*comparer)
+
+// WithTypeEquivalence makes columns whose types are declared equivalent by
+// any of equivalences compare equal.
+func WithTypeEquivalence(equivalences ...TypeEquivalence) CompareOption {
+	return func(c *comparer) {
+		for _, equivalence := range equivalences {
+			if equivalence != nil {
+				c.typeEquivalences = append(c.typeEquivalences, equivalence)
+			}
+		}
+	}
+}
+
+// WithLogger logs comparison decisions, such as detected renames, at debug
+// level.
+func WithLogger(logger *slog.Logger) CompareOption {
+	return func(c *comparer) {
+		if logger != nil {
+			c.logger = logger
+		}
+	}
+}
+
+// WithDefinitionNormalizer compares view and trigger definitions once
+// normalized, so that formatting-only differences are not reported.
+func WithDefinitionNormalizer(normalize func(def string) string) CompareOption {
+	return func(c *comparer) { c.normalizeDefinition = normalize }
+}
+
+type comparer struct {
+	logger              *slog.Logger
+	typeEquivalences    []TypeEquivalence
+	caseInsensitive     bool
+	normalizeDefinition func(def string) string
+	skippedTypes        []ObjectType
+	deterministic       bool
+	ignoredColumns      IgnoredColumns
+}
+
+func (c *comparer) definitionsEqual(source string, target string) bool {
+	if source == target {
+		return true
+	}
+	if c.normalizeDefinition == nil {
+		return false
+	}
+	return c.normalizeDefinition(source) == c.normalizeDefinition(target)
+}
+
+// triggersEqual reports whether both sides have the same triggers, in any
+// order.
+func (c *comparer) triggersEqual(source []*Trigger, target []*Trigger) bool {
+	changes := compareByName(source, target, func(t *Trigger) string {
+		return t.Name
+	}, func(a, b *Trigger) bool {
+		return c.definitionsEqual(a.Def, b.Def)
+	})
+	return len(changes) == 0
+}
+
+func (c *comparer) typesEqual(sourceType string, targetType string) bool {
+	if sourceType == targetType {
+		return true
+	}
+
+	for _, equivalence := range c.typeEquivalences {
+		if equivalence(sourceType, targetType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// columnAttributesEqual reports whether both columns only differ by name.
+// Owned sequences are only compared when both sides report one, so that
+// snapshots predating them do not differ from every serial column.
+func (c *comparer) columnAttributesEqual(source *Column, target *Column) bool {
+	return c.typesEqual(source.Type, target.Type) &&
+		source.NotNull == target.NotNull &&
+		source.PrimaryKey == target.PrimaryKey &&
+		source.Default == target.Default &&
+		(source.Sequence == target.Sequence || source.Sequence == "" || target.Sequence == "")
+}
+
+// Compare computes the changes turning target into source. With
+// WithCaseInsensitiveNames, the diff's Target is a copy of target whose names
+// are spelled as in source. With WithSkippedTypes, the diff's Source is a
+// copy of source holding the target's objects of the skipped types, and
+// with WithIgnoredColumns the target's ignored columns. With
+// WithDeterministicOrder, both are sorted copies, see Schema.Sorted.
+func Compare(source *Schema, target *Schema, opts ...CompareOption) *Diff {
+	c := &comparer{
+		logger: slog.New(slog.DiscardHandler),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.caseInsensitive {
+		target = alignNameCase(source, target)
+	}
+	source = alignSkippedTypes(source, target, c.skippedTypes)
+	source = alignIgnoredColumns(source, target, c.ignoredColumns)
+	if c.deterministic {
+		source, target = source.Sorted(), target.Sorted()
+	}
+
+	diff := &Diff{
+		Source: source,
+		Target: target,
+	}
+
+	for _, change := range compareByName(source.Tables, target.Tables, tableName, nil) {
+		tableDiff := &TableDiff{Change: *change}
+		if change.Kind == Modified {
+			c.compareTables(tableDiff)
+		}
+
+		if !tableDiff.IsEmpty() {
+			diff.Tables = append(diff.Tables, tableDiff)
+		}
+	}
+
+	diff.Views = compareByName(source.Views, target.Views, func(v *View) string {
+		return v.Name
+	}, func(a, b *View) bool {
+		return c.definitionsEqual(a.Def, b.Def) && c.triggersEqual(a.Triggers, b.Triggers)
+	})
+
+	if len(source.Pragmas) > 0 && len(target.Pragmas) > 0 {
+		diff.Pragmas = compareByName(source.Pragmas, target.Pragmas, func(p *Pragma) string {
+			return p.Name
+		}, func(a, b *Pragma) bool {
+			return a.Value == b.Value
+		})
+	}
+
+	diff.Aggregates = compareByName(source.Aggregates, target.Aggregates, func(a *Aggregate) string {
+		return a.Name
+	}, func(a, b *Aggregate) bool {
+		return a.Def == b.Def
+	})
+	diff.Operators = compareByName(source.Operators, target.Operators, func(o *Operator) string {
+		return o.Name
+	}, func(a, b *Operator) bool {
+		return a.Def == b.Def
+	})
+	diff.Casts = compareByName(source.Casts, target.Casts, func(c *Cast) string {
+		return c.Name
+	}, func(a, b *Cast) bool {
+		return a.Def == b.Def
+	})
+
+	if c.deterministic {
+		sortRemoved(diff.Tables, func(d *TableDiff) bool {
+			return d.Kind == Removed
+		}, (*TableDiff).Name, target.tableRanks())
+		sortRemoved(diff.Views, func(change *Change[*View]) bool {
+			return change.Kind == Removed
+		}, func(change *Change[*View]) string {
+			return change.Target.Name
+		}, target.viewRanks())
+	}
+
+	return diff
+}
+
+func tableName(t *Table) string {
+	return t.Name
+}
+
+// compareByName matches objects by name. Objects present on both sides are
+// reported as modified unless equal says otherwise; a nil equal reports them
+// all.
+func compareByName[T any](source []T, target []T, name func(T) string, equal func(a, b T) bool) []*Change[T] {
+	var changes []*Change[T]
+
+	targetByName := lo.KeyBy(target, name)
+	for _, sourceObject := range source {
+		targetObject, found := targetByName[name(sourceObject)]
+		if !found {
+			changes = append(changes, &Change[T]{Kind: Added, Source: sourceObject})
+			continue
+		}
+
+		if equal == nil || !equal(sourceObject, targetObject) {
+			changes = append(changes, &Change[T]{Kind: Modified, Source: sourceObject, Target: targetObject})
+		}
+	}
+
+	sourceByName := lo.KeyBy(source, name)
+	for _, targetObject := range target {
+		if _, found := sourceByName[name(targetObject)]; !found {
+			changes = append(changes, &Change[T]{Kind: Removed, Target: targetObject})
+		}
+	}
+
+	return changes
+}
+
+func (c *comparer) compareTables(diff *TableDiff) {
+	source, target := diff.Source, diff.Target
+
+	diff.Columns = c.compareColumns(source, target)
+	diff.ForeignKeysChanged = !foreignKeysEqual(source.ForeignKeys, target.ForeignKeys)
+
+	diff.Constraints = compareByName(source.Constraints, target.Constraints, func(constraint *Constraint) string {
+		return constraint.Name
+	}, func(a, b *Constraint) bool {
+		return a.Def == b.Def
+	})
+
+	diff.Indexes = compareByName(source.Indexes, target.Indexes, func(i *Index) string {
+		return i.Name
+	}, (*Index).Equal)
+
+	diff.Triggers = compareByName(source.Triggers, target.Triggers, func(t *Trigger) string {
+		return t.Name
+	}, func(a, b *Trigger) bool {
+		return c.definitionsEqual(a.Def, b.Def)
+	})
+}
+
+func (c *comparer) compareColumns(source *Table, target *Table) *ColumnsDiff {
+	diff := &ColumnsDiff{
+		Added:    []string{},
+		Modified: []string{},
+		Removed:  []string{},
+		Renamed:  make(map[string]string),
+	}
+
+	for _, sourceColumn := range source.Columns {
+		targetColumn, found := target.ColumnByName(sourceColumn.Name)
+
+		// New column
+		if !found {
+			// Maybe it's a renamed column?
+			renamedColumn, found := lo.Find(target.Columns, func(column *Column) bool {
+				_, existsInSourceTable := source.ColumnByName(column.Name)
+				_, alreadyRenamed := diff.Renamed[column.Name]
+				return !existsInSourceTable && !alreadyRenamed && c.columnAttributesEqual(sourceColumn, column)
+			})
+			if found {
+				c.logger.Debug("detected column rename", "table", source.Name, "from", renamedColumn.Name, "to", sourceColumn.Name)
+				diff.Renamed[renamedColumn.Name] = sourceColumn.Name
+				continue
+			}
+
+			diff.Added = append(diff.Added, sourceColumn.Name)
+			continue
+		}
+
+		if c.columnAttributesEqual(sourceColumn, targetColumn) {
+			continue
+		}
+
+		diff.Modified = append(diff.Modified, sourceColumn.Name)
+		if !c.typesEqual(sourceColumn.Type, targetColumn.Type) {
+			diff.Retyped = append(diff.Retyped, sourceColumn.Name)
+		}
+	}
+
+	// Removed columns
+	for _, targetColumn := range target.Columns {
+		_, found := source.ColumnByName(targetColumn.Name)
+		_, renamed := diff.Renamed[targetColumn.Name]
+		if !found && !renamed {
+			diff.Removed = append(diff.Removed, targetColumn.Name)
+		}
+	}
+
+	return diff
+}
+
+func foreignKeysEqual(source []*ForeignKey, target []*ForeignKey) bool {
+	if len(source) != len(target) {
+		return false
+	}
+
+	for _, sourceForeignKey := range source {
+		found := lo.SomeBy(target, func(fk *ForeignKey) bool {
+			return fk.Equal(sourceForeignKey)
+		})
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
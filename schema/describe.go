@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/samber/lo"
+)
+
+// Describe explains the changes of a table diff in plain words, one per
+// change, such as "column users.age type changed TEXT → INTEGER".
+func (d *TableDiff) Describe() []string {
+	switch d.Kind {
+	case Added:
+		return []string{fmt.Sprintf("table %s created", d.Name())}
+	case Removed:
+		return []string{fmt.Sprintf("table %s dropped", d.Name())}
+	}
+
+	table := d.Name()
+	var reasons []string
+
+	renamed := lo.Keys(d.Columns.Renamed)
+	slices.Sort(renamed)
+	for _, oldName := range renamed {
+		reasons = append(reasons, fmt.Sprintf("column %s.%s renamed to %s", table, oldName, d.Columns.Renamed[oldName]))
+	}
+
+	for _, name := range d.Columns.Added {
+		reasons = append(reasons, fmt.Sprintf("column %s.%s added", table, name))
+	}
+
+	for _, name := range d.Columns.Modified {
+		source, _ := d.Source.ColumnByName(name)
+		target, _ := d.Target.ColumnByName(name)
+		reasons = append(reasons, describeColumn(table, source, target, slices.Contains(d.Columns.Retyped, name))...)
+	}
+
+	for _, name := range d.Columns.Removed {
+		reasons = append(reasons, fmt.Sprintf("column %s.%s dropped", table, name))
+	}
+
+	if d.ForeignKeysChanged {
+		reasons = append(reasons, fmt.Sprintf("foreign keys of %s changed", table))
+	}
+
+	reasons = append(reasons, describeChanges("constraint", d.Constraints, func(c *Constraint) string { return c.Name })...)
+	reasons = append(reasons, describeChanges("index", d.Indexes, func(i *Index) string { return i.Name })...)
+	reasons = append(reasons, describeChanges("trigger", d.Triggers, func(t *Trigger) string { return t.Name })...)
+
+	return reasons
+}
+
+// DescribeView explains a view change in plain words.
+func DescribeView(change *Change[*View]) string {
+	return describeChanges("view", []*Change[*View]{change}, func(v *View) string { return v.Name })[0]
+}
+
+// DescribeAggregate explains an aggregate change in plain words.
+func DescribeAggregate(change *Change[*Aggregate]) string {
+	return describeChanges("aggregate", []*Change[*Aggregate]{change}, func(a *Aggregate) string { return a.Name })[0]
+}
+
+// DescribeOperator explains an operator change in plain words.
+func DescribeOperator(change *Change[*Operator]) string {
+	return describeChanges("operator", []*Change[*Operator]{change}, func(o *Operator) string { return o.Name })[0]
+}
+
+// DescribeCast explains a cast change in plain words.
+func DescribeCast(change *Change[*Cast]) string {
+	return describeChanges("cast", []*Change[*Cast]{change}, func(c *Cast) string { return c.Name })[0]
+}
+
+func describeColumn(table string, source *Column, target *Column, retyped bool) []string {
+	column := table + "." + source.Name
+	var reasons []string
+
+	if retyped {
+		reasons = append(reasons, fmt.Sprintf("column %s type changed %s → %s", column, target.Type, source.Type))
+	}
+	if source.NotNull != target.NotNull {
+		if source.NotNull {
+			reasons = append(reasons, fmt.Sprintf("column %s made NOT NULL", column))
+		} else {
+			reasons = append(reasons, fmt.Sprintf("column %s made nullable", column))
+		}
+	}
+	if source.PrimaryKey != target.PrimaryKey {
+		reasons = append(reasons, fmt.Sprintf("column %s primary key changed", column))
+	}
+	if source.Default != target.Default {
+		switch {
+		case !source.Default.Valid:
+			reasons = append(reasons, fmt.Sprintf("column %s default dropped", column))
+		case !target.Default.Valid:
+			reasons = append(reasons, fmt.Sprintf("column %s default set to %s", column, source.Default.String))
+		default:
+			reasons = append(reasons, fmt.Sprintf("column %s default changed %s → %s", column, target.Default.String, source.Default.String))
+		}
+	}
+
+	// Types spelled differently yet equivalent are not retyped, the column
+	// still changed
+	if len(reasons) == 0 {
+		reasons = append(reasons, fmt.Sprintf("column %s type changed %s → %s", column, target.Type, source.Type))
+	}
+
+	return reasons
+}
+
+func describeChanges[T any](kind string, changes []*Change[T], name func(T) string) []string {
+	var reasons []string
+
+	for _, change := range changes {
+		switch change.Kind {
+		case Added:
+			reasons = append(reasons, fmt.Sprintf("%s %s created", kind, name(change.Source)))
+		case Modified:
+			reasons = append(reasons, fmt.Sprintf("%s %s definition changed", kind, name(change.Source)))
+		case Removed:
+			reasons = append(reasons, fmt.Sprintf("%s %s dropped", kind, name(change.Target)))
+		}
+	}
+
+	return reasons
+}
@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/samber/lo"
+)
+
+// Expanded returns the schema holding the objects of both sides of the diff:
+// the source schema plus every table, column, constraint, foreign key, index,
+// trigger, view, aggregate, operator and cast the diff removes from the
+// target. Renamed columns are kept under both names, the old one being
+// removed last like the others. Migrating the target to the expanded schema
+// first, then the expanded schema to the source, defers removals to the end.
+//
+// With deferNotNull, columns of modified tables that the diff adds, makes NOT
+// NULL, renames or removes are nullable in the expanded schema, so that both
+// the current and the next version of the application can write rows until
+// the NOT NULL constraints are added or the columns removed last. Columns
+// with a default value are left alone.
+func (d *Diff) Expanded(deferNotNull bool) *Schema {
+	expanded := &Schema{
+		Dialect:    d.Source.Dialect,
+		Views:      slices.Clone(d.Source.Views),
+		Pragmas:    d.Source.Pragmas,
+		Aggregates: slices.Concat(d.Source.Aggregates, removed(d.Aggregates)),
+		Operators:  slices.Concat(d.Source.Operators, removed(d.Operators)),
+		Casts:      slices.Concat(d.Source.Casts, removed(d.Casts)),
+	}
+
+	tableDiffs := lo.KeyBy(d.Tables, (*TableDiff).Name)
+	for _, table := range d.Source.Tables {
+		if tableDiff, found := tableDiffs[table.Name]; found && tableDiff.Kind == Modified {
+			table = tableDiff.expanded()
+			if deferNotNull {
+				table = tableDiff.relaxNotNull(table)
+			}
+		}
+		expanded.Tables = append(expanded.Tables, table)
+	}
+
+	for _, tableDiff := range d.Tables {
+		if tableDiff.Kind == Removed {
+			expanded.Tables = append(expanded.Tables, tableDiff.Target)
+		}
+	}
+
+	for _, change := range d.Views {
+		if change.Kind == Removed {
+			expanded.Views = append(expanded.Views, change.Target)
+		}
+	}
+
+	return expanded
+}
+
+// Phases returns the diffs deferring removals to the end: expand migrates the
+// target to the expanded schema, see Expanded, and contract the expanded
+// schema to the source. Renamed columns are added under their new name by
+// expand, which copies the values of their old name into them, and their old
+// name dropped by contract.
+func (d *Diff) Phases(deferNotNull bool, opts ...CompareOption) (expand, contract *Diff) {
+	expanded := d.Expanded(deferNotNull)
+	expand = Compare(expanded, d.Target, opts...)
+	contract = Compare(d.Source, expanded, opts...)
+
+	expandDiffs := lo.KeyBy(expand.Tables, (*TableDiff).Name)
+	for _, tableDiff := range d.Tables {
+		expandDiff, found := expandDiffs[tableDiff.Name()]
+		if tableDiff.Kind != Modified || !found || expandDiff.Kind != Modified {
+			continue
+		}
+
+		for oldName, newName := range tableDiff.Columns.Renamed {
+			if !slices.Contains(expandDiff.Columns.Added, newName) {
+				continue
+			}
+			if expandDiff.Columns.Copied == nil {
+				expandDiff.Columns.Copied = make(map[string]string)
+			}
+			expandDiff.Columns.Copied[newName] = oldName
+		}
+	}
+
+	return expand, contract
+}
+
+// removed returns the target objects of the removed changes.
+func removed[T any](changes []*Change[T]) []T {
+	var objects []T
+	for _, change := range changes {
+		if change.Kind == Removed {
+			objects = append(objects, change.Target)
+		}
+	}
+	return objects
+}
+
+// expanded returns the source table plus the objects the diff removes from
+// the target one, renamed columns included under their old name.
+func (d *TableDiff) expanded() *Table {
+	table := d.Source.Copy()
+
+	table.Columns = slices.Clone(table.Columns)
+	for _, name := range slices.Concat(d.Columns.Removed, slices.Sorted(maps.Keys(d.Columns.Renamed))) {
+		column, _ := d.Target.ColumnByName(name)
+		table.Columns = append(table.Columns, column)
+	}
+
+	if d.ForeignKeysChanged {
+		table.ForeignKeys = slices.Clone(table.ForeignKeys)
+		for _, foreignKey := range d.Target.ForeignKeys {
+			if !slices.ContainsFunc(table.ForeignKeys, foreignKey.Equal) {
+				table.ForeignKeys = append(table.ForeignKeys, foreignKey)
+			}
+		}
+	}
+
+	table.Constraints = appendRemoved(table.Constraints, d.Constraints)
+	table.Indexes = appendRemoved(table.Indexes, d.Indexes)
+	table.Triggers = appendRemoved(table.Triggers, d.Triggers)
+
+	return table
+}
+
+func appendRemoved[T any](objects []T, changes []*Change[T]) []T {
+	objects = slices.Clone(objects)
+	for _, change := range changes {
+		if change.Kind == Removed {
+			objects = append(objects, change.Target)
+		}
+	}
+	return objects
+}
+
+// relaxNotNull returns a copy of the expanded table where NOT NULL columns
+// without default are nullable, unless they are already NOT NULL in the target
+// and stay so.
+func (d *TableDiff) relaxNotNull(table *Table) *Table {
+	relaxed := table.Copy()
+	relaxed.Columns = make([]*Column, len(table.Columns))
+	for i, column := range table.Columns {
+		relaxed.Columns[i] = column
+		if !column.NotNull || column.PrimaryKey || column.Default.Valid {
+			continue
+		}
+
+		// Removed and renamed columns are left out by the next version of
+		// the application, added ones and the new names of renamed ones by
+		// the current one
+		_, renamed := d.Columns.Renamed[column.Name]
+		if !slices.Contains(d.Columns.Removed, column.Name) && !renamed {
+			targetColumn, found := d.Target.ColumnByName(column.Name)
+			if found && targetColumn.NotNull {
+				continue
+			}
+		}
+
+		relaxed.Columns[i] = column.Copy()
+		relaxed.Columns[i].NotNull = false
+	}
+	return relaxed
+}
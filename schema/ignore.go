@@ -0,0 +1,229 @@
+package schema
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+)
+
+type ObjectType string
+
+const (
+	TableObject   ObjectType = "table"
+	IndexObject   ObjectType = "index"
+	TriggerObject ObjectType = "trigger"
+	ViewObject    ObjectType = "view"
+
+	AggregateObject ObjectType = "aggregate"
+	OperatorObject  ObjectType = "operator"
+	CastObject      ObjectType = "cast"
+)
+
+// IgnoreRule hides objects whose name matches a pattern from comparisons.
+type IgnoreRule struct {
+	// Type restricts the rule to one kind of object. Empty matches every
+	// kind.
+	Type ObjectType
+
+	match func(name string) bool
+}
+
+// IgnoreGlob ignores objects whose whole name matches a glob pattern, using
+// the path.Match syntax.
+func IgnoreGlob(objectType ObjectType, pattern string) (IgnoreRule, error) {
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return IgnoreRule{}, err
+	}
+
+	return IgnoreRule{
+		Type: objectType,
+		match: func(name string) bool {
+			matched, _ := path.Match(pattern, name)
+			return matched
+		},
+	}, nil
+}
+
+// IgnoreRegexp ignores objects whose name matches a regular expression.
+// Anchor the expression to match whole names.
+func IgnoreRegexp(objectType ObjectType, expr string) (IgnoreRule, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return IgnoreRule{}, err
+	}
+
+	return IgnoreRule{
+		Type:  objectType,
+		match: re.MatchString,
+	}, nil
+}
+
+func (r IgnoreRule) Matches(objectType ObjectType, name string) bool {
+	if r.Type != "" && r.Type != objectType {
+		return false
+	}
+	return r.match != nil && r.match(name)
+}
+
+type IgnoreRules []IgnoreRule
+
+func (rules IgnoreRules) Matches(objectType ObjectType, name string) bool {
+	for _, rule := range rules {
+		if rule.Matches(objectType, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns a copy of the schema without the objects matched by rules,
+// calling ignored, when set, for each of them. The schema itself is left
+// untouched.
+func (s *Schema) Filter(rules IgnoreRules, ignored func(objectType ObjectType, name string)) *Schema {
+	if len(rules) == 0 {
+		return s
+	}
+
+	filtered := &Schema{Dialect: s.Dialect, Pragmas: s.Pragmas}
+
+	for _, table := range s.Tables {
+		if rules.Matches(TableObject, table.Name) {
+			if ignored != nil {
+				ignored(TableObject, table.Name)
+			}
+			continue
+		}
+
+		table = table.Copy()
+		table.Indexes = filterByName(table.Indexes, rules, ignored, IndexObject, func(i *Index) string {
+			return i.Name
+		})
+		table.Triggers = filterByName(table.Triggers, rules, ignored, TriggerObject, func(t *Trigger) string {
+			return t.Name
+		})
+		filtered.Tables = append(filtered.Tables, table)
+	}
+
+	filtered.Views = filterByName(s.Views, rules, ignored, ViewObject, func(v *View) string {
+		return v.Name
+	})
+	filtered.Aggregates = filterByName(s.Aggregates, rules, ignored, AggregateObject, func(a *Aggregate) string {
+		return a.Name
+	})
+	filtered.Operators = filterByName(s.Operators, rules, ignored, OperatorObject, func(o *Operator) string {
+		return o.Name
+	})
+	filtered.Casts = filterByName(s.Casts, rules, ignored, CastObject, func(c *Cast) string {
+		return c.Name
+	})
+
+	return filtered
+}
+
+func filterByName[T any](objects []T, rules IgnoreRules, ignored func(ObjectType, string), objectType ObjectType, name func(T) string) []T {
+	var kept []T
+	for _, object := range objects {
+		if !rules.Matches(objectType, name(object)) {
+			kept = append(kept, object)
+			continue
+		}
+
+		if ignored != nil {
+			ignored(objectType, name(object))
+		}
+	}
+	return kept
+}
+
+// OnlyTables returns a copy of the schema only holding the named tables,
+// without views, aggregates, operators or casts. The schema itself is left untouched.
+func (s *Schema) OnlyTables(names []string) *Schema {
+	restricted := &Schema{Dialect: s.Dialect, Pragmas: s.Pragmas}
+	for _, table := range s.Tables {
+		if slices.Contains(names, table.Name) {
+			restricted.Tables = append(restricted.Tables, table)
+		}
+	}
+	return restricted
+}
+
+// IgnoredColumns lists, by table, the columns whose differences are left
+// out of diffs, e.g. {"users": {"legacy_flags"}}. Both table and column
+// names are glob patterns using the path.Match syntax.
+type IgnoredColumns map[string][]string
+
+// Validate reports the first malformed pattern.
+func (ignored IgnoredColumns) Validate() error {
+	for table, columns := range ignored {
+		for _, pattern := range append([]string{table}, columns...) {
+			_, err := path.Match(pattern, "")
+			if err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (ignored IgnoredColumns) Matches(table string, column string) bool {
+	for tablePattern, columnPatterns := range ignored {
+		if matched, _ := path.Match(tablePattern, table); !matched {
+			continue
+		}
+		for _, pattern := range columnPatterns {
+			if matched, _ := path.Match(pattern, column); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WithIgnoredColumns leaves the differences of the ignored columns out of
+// the diff, as if the source had them exactly as the target does, so that
+// known divergent columns are neither added, altered nor dropped without
+// ignoring their whole table. The diff's Source is then a copy of source.
+func WithIgnoredColumns(ignored IgnoredColumns) CompareOption {
+	return func(c *comparer) { c.ignoredColumns = ignored }
+}
+
+// alignIgnoredColumns returns a copy of source where the ignored columns of
+// tables found on both sides are replaced by their target counterparts, at
+// their target position. Table rebuilds therefore keep them.
+func alignIgnoredColumns(source *Schema, target *Schema, ignored IgnoredColumns) *Schema {
+	if len(ignored) == 0 {
+		return source
+	}
+
+	aligned := &Schema{
+		Dialect:    source.Dialect,
+		Views:      source.Views,
+		Pragmas:    source.Pragmas,
+		Aggregates: source.Aggregates,
+		Operators:  source.Operators,
+		Casts:      source.Casts,
+	}
+
+	for _, sourceTable := range source.Tables {
+		targetTable, found := target.TableByName(sourceTable.Name)
+		if !found {
+			aligned.Tables = append(aligned.Tables, sourceTable)
+			continue
+		}
+
+		table := sourceTable.Copy()
+		table.Columns = slices.DeleteFunc(slices.Clone(sourceTable.Columns), func(c *Column) bool {
+			return ignored.Matches(table.Name, c.Name)
+		})
+		for i, column := range targetTable.Columns {
+			if ignored.Matches(table.Name, column.Name) {
+				table.Columns = slices.Insert(table.Columns, min(i, len(table.Columns)), column)
+			}
+		}
+		aligned.Tables = append(aligned.Tables, table)
+	}
+
+	return aligned
+}
@@ -0,0 +1,107 @@
+package schema
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// FormatVersion is the version of the JSON encoding of schemas, written as
+// their format_version field. It is bumped on changes breaking readers of the
+// previous versions, which are described by the JSON Schema documents of the
+// jsonschema package.
+const FormatVersion = 1
+
+// plainSchema is Schema without its JSON methods.
+type plainSchema Schema
+
+// versionedSchema is the JSON encoding of a Schema.
+type versionedSchema struct {
+	FormatVersion int `json:"format_version"`
+	plainSchema
+}
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(versionedSchema{FormatVersion: FormatVersion, plainSchema: plainSchema(*s)})
+}
+
+// UnmarshalJSON decodes schemas of this format version or older, including
+// the ones written before schemas had a version.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	var decoded versionedSchema
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	if decoded.FormatVersion > FormatVersion {
+		return fmt.Errorf("unsupported schema format version %d, expected up to %d", decoded.FormatVersion, FormatVersion)
+	}
+
+	*s = Schema(decoded.plainSchema)
+	return nil
+}
+
+// columnJSON is the JSON encoding of a Column, with its default as a plain
+// string that is null when the column has none.
+type columnJSON struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	NotNull    bool    `json:"notNull,omitempty"`
+	PrimaryKey bool    `json:"primaryKey,omitempty"`
+	Default    *string `json:"default"`
+	Sequence   string  `json:"sequence,omitempty"`
+}
+
+func (c *Column) MarshalJSON() ([]byte, error) {
+	encoded := columnJSON{
+		Name:       c.Name,
+		Type:       c.Type,
+		NotNull:    c.NotNull,
+		PrimaryKey: c.PrimaryKey,
+		Sequence:   c.Sequence,
+	}
+	if c.Default.Valid {
+		encoded.Default = &c.Default.String
+	}
+
+	return json.Marshal(encoded)
+}
+
+func (c *Column) UnmarshalJSON(data []byte) error {
+	var decoded columnJSON
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	*c = Column{
+		Name:       decoded.Name,
+		Type:       decoded.Type,
+		NotNull:    decoded.NotNull,
+		PrimaryKey: decoded.PrimaryKey,
+		Sequence:   decoded.Sequence,
+	}
+	if decoded.Default != nil {
+		c.Default = sql.NullString{String: *decoded.Default, Valid: true}
+	}
+
+	return nil
+}
+
+// Hash identifies the schema by the SHA-256 of its JSON encoding, so that two
+// introspections of an unchanged database hash the same.
+func (s *Schema) Hash() string {
+	// The format version is left out, for schemas to keep their hash when
+	// it is bumped
+	data, err := json.Marshal((*plainSchema)(s))
+	if err != nil {
+		// Schemas only hold strings, booleans and slices of them
+		panic(err)
+	}
+
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,196 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NameMapping maps names sharing a pattern on the source side to their
+// counterparts on the target side, such as wp_users to users for wp_%=%.
+type NameMapping struct {
+	sourcePrefix, sourceSuffix string
+	targetPrefix, targetSuffix string
+}
+
+// ParseNameMapping parses a mapping written as SOURCE=TARGET, where both
+// patterns hold a single % standing for the common part of the names, e.g.
+// wp_%=% or %_v2=%.
+func ParseNameMapping(s string) (NameMapping, error) {
+	source, target, ok := strings.Cut(s, "=")
+	if !ok || strings.Count(source, "%") != 1 || strings.Count(target, "%") != 1 {
+		return NameMapping{}, fmt.Errorf("invalid name mapping %q, expected SOURCE=TARGET with a single %% on each side", s)
+	}
+
+	var m NameMapping
+	m.sourcePrefix, m.sourceSuffix, _ = strings.Cut(source, "%")
+	m.targetPrefix, m.targetSuffix, _ = strings.Cut(target, "%")
+	return m, nil
+}
+
+// Map returns the target name of a source name, and whether the mapping
+// matches it at all.
+func (m NameMapping) Map(name string) (string, bool) {
+	if len(name) <= len(m.sourcePrefix)+len(m.sourceSuffix) {
+		return name, false
+	}
+
+	common, ok := strings.CutPrefix(name, m.sourcePrefix)
+	if !ok {
+		return name, false
+	}
+	common, ok = strings.CutSuffix(common, m.sourceSuffix)
+	if !ok {
+		return name, false
+	}
+	return m.targetPrefix + common + m.targetSuffix, true
+}
+
+// MapTableNames returns a copy of the schema whose tables are renamed by the
+// first of mappings matching them, so that schemas only differing by a table
+// prefix or suffix compare equal once the source is mapped. The indexes,
+// triggers, constraints and views following the same pattern are renamed
+// too, along with the references to renamed objects in definitions. The
+// schema itself is left untouched.
+func (s *Schema) MapTableNames(mappings ...NameMapping) *Schema {
+	if len(mappings) == 0 {
+		return s
+	}
+
+	// Every renamed object is collected first, as definitions may refer to
+	// objects listed after them
+	renamed := make(map[string]string)
+	collect := func(name string) {
+		for _, mapping := range mappings {
+			if mapped, ok := mapping.Map(name); ok {
+				renamed[name] = mapped
+				return
+			}
+		}
+	}
+	for _, table := range s.Tables {
+		collect(table.Name)
+		for _, index := range table.Indexes {
+			collect(index.Name)
+		}
+		for _, constraint := range table.Constraints {
+			collect(constraint.Name)
+		}
+		for _, trigger := range table.Triggers {
+			collect(trigger.Name)
+		}
+	}
+	for _, view := range s.Views {
+		collect(view.Name)
+		for _, trigger := range view.Triggers {
+			collect(trigger.Name)
+		}
+	}
+
+	rename := func(name string) string {
+		if mapped, ok := renamed[name]; ok {
+			return mapped
+		}
+		return name
+	}
+
+	mapped := &Schema{Dialect: s.Dialect, Pragmas: s.Pragmas, Aggregates: s.Aggregates, Operators: s.Operators, Casts: s.Casts}
+
+	for _, sourceTable := range s.Tables {
+		table := sourceTable.Copy()
+		table.Name = rename(table.Name)
+
+		table.Columns = make([]*Column, len(sourceTable.Columns))
+		for i, column := range sourceTable.Columns {
+			table.Columns[i] = column.Copy()
+			if column.Default.Valid {
+				table.Columns[i].Default.String = renameIdentifiers(column.Default.String, renamed)
+			}
+		}
+
+		table.Indexes = make([]*Index, len(sourceTable.Indexes))
+		for i, index := range sourceTable.Indexes {
+			copy := *index
+			copy.Name = rename(index.Name)
+			copy.Table = table.Name
+			copy.Def = renameIdentifiers(index.Def, renamed)
+			table.Indexes[i] = &copy
+		}
+
+		table.Constraints = make([]*Constraint, len(sourceTable.Constraints))
+		for i, constraint := range sourceTable.Constraints {
+			copy := *constraint
+			copy.Name = rename(constraint.Name)
+			copy.Def = renameIdentifiers(constraint.Def, renamed)
+			table.Constraints[i] = &copy
+		}
+
+		table.ForeignKeys = make([]*ForeignKey, len(sourceTable.ForeignKeys))
+		for i, fk := range sourceTable.ForeignKeys {
+			copy := *fk
+			copy.Table = rename(fk.Table)
+			table.ForeignKeys[i] = &copy
+		}
+
+		table.Triggers = make([]*Trigger, len(sourceTable.Triggers))
+		for i, trigger := range sourceTable.Triggers {
+			copy := *trigger
+			copy.Name = rename(trigger.Name)
+			copy.Def = renameIdentifiers(trigger.Def, renamed)
+			table.Triggers[i] = &copy
+		}
+
+		mapped.Tables = append(mapped.Tables, table)
+	}
+
+	for _, sourceView := range s.Views {
+		view := *sourceView
+		view.Name = rename(view.Name)
+		view.Def = renameIdentifiers(view.Def, renamed)
+
+		view.Triggers = make([]*Trigger, len(sourceView.Triggers))
+		for i, trigger := range sourceView.Triggers {
+			copy := *trigger
+			copy.Name = rename(trigger.Name)
+			copy.Def = renameIdentifiers(trigger.Def, renamed)
+			view.Triggers[i] = &copy
+		}
+
+		mapped.Views = append(mapped.Views, &view)
+	}
+
+	return mapped
+}
+
+// identifierToken matches the string literals, quoted identifiers and bare
+// words of a definition.
+var identifierToken = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"]|"")*"|` + "`[^`]*`" + `|\[[^\]]*\]|[A-Za-z_][A-Za-z0-9_$]*`)
+
+// renameIdentifiers replaces the identifiers of def naming a key of renamed
+// with its value, keeping their quoting. String literals are left alone.
+func renameIdentifiers(def string, renamed map[string]string) string {
+	if len(renamed) == 0 {
+		return def
+	}
+
+	return identifierToken.ReplaceAllStringFunc(def, func(token string) string {
+		switch token[0] {
+		case '\'':
+			return token
+		case '"':
+			name := strings.ReplaceAll(token[1:len(token)-1], `""`, `"`)
+			if mapped, ok := renamed[name]; ok {
+				return `"` + strings.ReplaceAll(mapped, `"`, `""`) + `"`
+			}
+		case '`', '[':
+			if mapped, ok := renamed[token[1:len(token)-1]]; ok {
+				return token[:1] + mapped + token[len(token)-1:]
+			}
+		default:
+			if mapped, ok := renamed[token]; ok {
+				return mapped
+			}
+		}
+		return token
+	})
+}
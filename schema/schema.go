@@ -0,0 +1,265 @@
+// Package schema holds a dialect-agnostic model of a database schema, as
+// produced by the drivers' introspection, and compares two of them.
+package schema
+
+import (
+	"database/sql"
+	"slices"
+)
+
+// Schema is everything introspected from a single database.
+type Schema struct {
+	// Dialect names the driver that introspected the schema, such as sqlite3
+	// or postgres.
+	Dialect string `json:"dialect"`
+
+	Tables []*Table `json:"tables"`
+	Views  []*View  `json:"views"`
+
+	// Pragmas are the durable database-level settings, when asked for
+	// (SQLite).
+	Pragmas []*Pragma `json:"pragmas,omitempty"`
+
+	// Aggregates, Operators and Casts are the user-defined ones (Postgres).
+	Aggregates []*Aggregate `json:"aggregates,omitempty"`
+	Operators  []*Operator  `json:"operators,omitempty"`
+	Casts      []*Cast      `json:"casts,omitempty"`
+}
+
+func (s *Schema) TableByName(name string) (*Table, bool) {
+	for _, table := range s.Tables {
+		if table.Name == name {
+			return table, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Schema) ViewByName(name string) (*View, bool) {
+	for _, view := range s.Views {
+		if view.Name == name {
+			return view, true
+		}
+	}
+	return nil, false
+}
+
+// Pragma is a database-level setting persisted in the database file, such
+// as SQLite's user_version.
+type Pragma struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Aggregate is a user-defined aggregate function.
+type Aggregate struct {
+	// Name is the name of the aggregate followed by its argument types, such
+	// as sum_squares(integer), aggregates being overloadable.
+	Name string `json:"name"`
+
+	// Def is the whole CREATE AGGREGATE statement, without its trailing
+	// semicolon.
+	Def string `json:"def"`
+}
+
+// Operator is a user-defined operator.
+type Operator struct {
+	// Name is the operator followed by its operand types, NONE for the
+	// missing left operand of prefix operators, such as ===(integer, integer).
+	Name string `json:"name"`
+
+	// Def is the whole CREATE OPERATOR statement, without its trailing
+	// semicolon.
+	Def string `json:"def"`
+}
+
+// Cast is a user-defined conversion between two types.
+type Cast struct {
+	// Name is the source and target types, such as (text AS email).
+	Name string `json:"name"`
+
+	// Def is the whole CREATE CAST statement, without its trailing
+	// semicolon.
+	Def string `json:"def"`
+}
+
+type Table struct {
+	Name    string    `json:"name"`
+	Columns []*Column `json:"columns"`
+	Indexes []*Index  `json:"indexes,omitempty"`
+
+	// Constraints are the named table constraints, for dialects reporting
+	// them as such (Postgres).
+	Constraints []*Constraint `json:"constraints,omitempty"`
+
+	// ForeignKeys are the unnamed foreign keys, for dialects that do not
+	// report them as constraints (SQLite).
+	ForeignKeys []*ForeignKey `json:"foreignKeys,omitempty"`
+
+	Triggers []*Trigger `json:"triggers,omitempty"`
+}
+
+func (t *Table) Copy() *Table {
+	new := *t
+	return &new
+}
+
+func (t *Table) ColumnByName(name string) (*Column, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return nil, false
+}
+
+func (t *Table) IndexByName(name string) (*Index, bool) {
+	for _, index := range t.Indexes {
+		if index.Name == name {
+			return index, true
+		}
+	}
+	return nil, false
+}
+
+func (t *Table) ConstraintByName(name string) (*Constraint, bool) {
+	for _, constraint := range t.Constraints {
+		if constraint.Name == name {
+			return constraint, true
+		}
+	}
+	return nil, false
+}
+
+func (t *Table) TriggerByName(name string) (*Trigger, bool) {
+	for _, trigger := range t.Triggers {
+		if trigger.Name == name {
+			return trigger, true
+		}
+	}
+	return nil, false
+}
+
+type Column struct {
+	Name       string
+	Type       string
+	NotNull    bool
+	PrimaryKey bool
+	Default    sql.NullString
+
+	// Sequence names the sequence owned by the column, such as the one of
+	// serial columns, which is dropped along with it (Postgres).
+	Sequence string
+}
+
+func (c *Column) Copy() *Column {
+	new := *c
+	return &new
+}
+
+// HasEqualAttributes reports whether both columns only differ by name.
+func (c *Column) HasEqualAttributes(other *Column) bool {
+	copy := c.Copy()
+	copy.Name = other.Name
+
+	return *copy == *other
+}
+
+type Index struct {
+	Table   string   `json:"table"`
+	Name    string   `json:"name"`
+	Columns []string `json:"columns,omitempty"`
+	Unique  bool     `json:"unique,omitempty"`
+
+	// Def is the whole CREATE INDEX statement, for dialects reporting it
+	// (Postgres).
+	Def string `json:"def,omitempty"`
+
+	// The fields below describe the index structurally, for dialects
+	// reporting them (Postgres), in which case Columns lists its keys: column
+	// names or expressions, along with their collation and ordering.
+	Method string `json:"method,omitempty"`
+	// OperatorClasses holds the operator class of each key, empty for the
+	// default one.
+	OperatorClasses  []string `json:"operatorClasses,omitempty"`
+	Include          []string `json:"include,omitempty"`
+	NullsNotDistinct bool     `json:"nullsNotDistinct,omitempty"`
+	// Options are the storage parameters, such as fillfactor=90, sorted.
+	Options []string `json:"options,omitempty"`
+	Where   string   `json:"where,omitempty"`
+}
+
+// Equal compares indexes by structure when both describe theirs, so that
+// definitions only differing by formatting or the schema qualifying the
+// table compare equal.
+func (i *Index) Equal(other *Index) bool {
+	if i.Name != other.Name || i.Table != other.Table {
+		return false
+	}
+	if (i.Method == "") != (other.Method == "") {
+		// A single side describes its structure, such as against a snapshot
+		// predating it
+		return i.Def == other.Def
+	}
+
+	return i.Unique == other.Unique &&
+		slices.Equal(i.Columns, other.Columns) &&
+		i.Method == other.Method &&
+		slices.Equal(i.OperatorClasses, other.OperatorClasses) &&
+		slices.Equal(i.Include, other.Include) &&
+		i.NullsNotDistinct == other.NullsNotDistinct &&
+		slices.Equal(i.Options, other.Options) &&
+		i.Where == other.Where &&
+		(i.Method != "" || i.Def == other.Def)
+}
+
+type Constraint struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // p (primary), u (unique), c (check), f (foreign)
+	Def  string `json:"def"`
+}
+
+type ForeignKey struct {
+	Table    string   `json:"table"`
+	From     []string `json:"from"`
+	To       []string `json:"to"`
+	OnUpdate string   `json:"onUpdate"`
+	OnDelete string   `json:"onDelete"`
+}
+
+func (fk *ForeignKey) Equal(other *ForeignKey) bool {
+	return fk.Table == other.Table &&
+		fk.OnUpdate == other.OnUpdate &&
+		fk.OnDelete == other.OnDelete &&
+		slices.Equal(fk.From, other.From) &&
+		slices.Equal(fk.To, other.To)
+}
+
+type Trigger struct {
+	Name string `json:"name"`
+
+	// Def is the whole CREATE TRIGGER statement, without its trailing
+	// semicolon.
+	Def string `json:"def"`
+}
+
+type View struct {
+	Name string `json:"name"`
+
+	// Def is the view definition as reported by the database: the whole
+	// CREATE VIEW statement for SQLite, only its query for Postgres.
+	Def string `json:"def"`
+
+	// Triggers are the triggers defined on the view, such as INSTEAD OF
+	// triggers making it writable. Dropping the view drops them.
+	Triggers []*Trigger `json:"triggers,omitempty"`
+}
+
+func (v *View) TriggerByName(name string) (*Trigger, bool) {
+	for _, trigger := range v.Triggers {
+		if trigger.Name == name {
+			return trigger, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,120 @@
+package schema
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ObjectTypes lists every type of object a schema holds.
+var ObjectTypes = []ObjectType{TableObject, IndexObject, TriggerObject, ViewObject, AggregateObject, OperatorObject, CastObject}
+
+// ParseObjectTypes parses a comma-separated list of object types, spelled in
+// the singular or the plural such as "tables,indexes".
+func ParseObjectTypes(list string) ([]ObjectType, error) {
+	var types []ObjectType
+	for name := range strings.SplitSeq(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		objectType, found := objectTypeNames[name]
+		if !found {
+			return nil, fmt.Errorf("unknown object type %q, expected tables, indexes, triggers, views, aggregates, operators or casts", name)
+		}
+		types = append(types, objectType)
+	}
+	return types, nil
+}
+
+var objectTypeNames = map[string]ObjectType{
+	"table":    TableObject,
+	"tables":   TableObject,
+	"index":    IndexObject,
+	"indexes":  IndexObject,
+	"trigger":  TriggerObject,
+	"triggers": TriggerObject,
+	"view":     ViewObject,
+	"views":    ViewObject,
+
+	"aggregate":  AggregateObject,
+	"aggregates": AggregateObject,
+	"operator":   OperatorObject,
+	"operators":  OperatorObject,
+	"cast":       CastObject,
+	"casts":      CastObject,
+}
+
+// WithSkippedTypes leaves changes to the given types of objects out of the
+// diff, as if the source had them exactly as the target does. Skipping
+// tables still reports changes to the indexes and triggers of tables present
+// on both sides. The diff's Source is then a copy of source.
+func WithSkippedTypes(types ...ObjectType) CompareOption {
+	return func(c *comparer) { c.skippedTypes = append(c.skippedTypes, types...) }
+}
+
+// alignSkippedTypes returns a copy of source where objects of the skipped
+// types are replaced by their target counterparts.
+func alignSkippedTypes(source *Schema, target *Schema, skipped []ObjectType) *Schema {
+	if len(skipped) == 0 {
+		return source
+	}
+
+	aligned := &Schema{
+		Dialect:    source.Dialect,
+		Views:      source.Views,
+		Pragmas:    source.Pragmas,
+		Aggregates: source.Aggregates,
+		Operators:  source.Operators,
+		Casts:      source.Casts,
+	}
+	if slices.Contains(skipped, ViewObject) {
+		aligned.Views = target.Views
+	}
+	if slices.Contains(skipped, AggregateObject) {
+		aligned.Aggregates = target.Aggregates
+	}
+	if slices.Contains(skipped, OperatorObject) {
+		aligned.Operators = target.Operators
+	}
+	if slices.Contains(skipped, CastObject) {
+		aligned.Casts = target.Casts
+	}
+
+	skipTables := slices.Contains(skipped, TableObject)
+	for _, sourceTable := range source.Tables {
+		targetTable, found := target.TableByName(sourceTable.Name)
+		if !found && skipTables {
+			continue
+		}
+		if !found {
+			targetTable = &Table{Name: sourceTable.Name}
+		}
+
+		table := sourceTable.Copy()
+		if skipTables {
+			table.Columns = targetTable.Columns
+			table.Constraints = targetTable.Constraints
+			table.ForeignKeys = targetTable.ForeignKeys
+		}
+		if slices.Contains(skipped, IndexObject) {
+			table.Indexes = targetTable.Indexes
+		}
+		if slices.Contains(skipped, TriggerObject) {
+			table.Triggers = targetTable.Triggers
+		}
+		aligned.Tables = append(aligned.Tables, table)
+	}
+
+	// Tables only found in the target are kept rather than dropped
+	if skipTables {
+		for _, targetTable := range target.Tables {
+			if _, found := source.TableByName(targetTable.Name); !found {
+				aligned.Tables = append(aligned.Tables, targetTable)
+			}
+		}
+	}
+
+	return aligned
+}
@@ -0,0 +1,183 @@
+package schema
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// WithDeterministicOrder orders the diff regardless of the order objects were
+// created in, so that logically identical databases always give the same
+// diff. Tables and views come after the ones they depend on, then by name,
+// and are removed in the reverse order. Indexes, constraints and triggers
+// are ordered by name. The diff's Source and Target are then sorted copies.
+func WithDeterministicOrder(enabled bool) CompareOption {
+	return func(c *comparer) { c.deterministic = enabled }
+}
+
+// Sorted returns a copy of the schema where tables and views come after the
+// ones they depend on, then by name, and the indexes, constraints and
+// triggers of each table, as well as aggregates, operators and casts, are
+// ordered by name. The schema itself is left
+// untouched.
+func (s *Schema) Sorted() *Schema {
+	sorted := &Schema{
+		Dialect:    s.Dialect,
+		Tables:     make([]*Table, len(s.Tables)),
+		Views:      make([]*View, len(s.Views)),
+		Pragmas:    s.Pragmas,
+		Aggregates: sortedByName(s.Aggregates, func(a *Aggregate) string { return a.Name }),
+		Operators:  sortedByName(s.Operators, func(o *Operator) string { return o.Name }),
+		Casts:      sortedByName(s.Casts, func(c *Cast) string { return c.Name }),
+	}
+
+	for i, table := range s.Tables {
+		table = table.Copy()
+		table.Indexes = sortedByName(table.Indexes, func(i *Index) string { return i.Name })
+		table.Constraints = sortedByName(table.Constraints, func(c *Constraint) string { return c.Name })
+		table.Triggers = sortedByName(table.Triggers, func(t *Trigger) string { return t.Name })
+		sorted.Tables[i] = table
+	}
+
+	for i, view := range s.Views {
+		view := *view
+		view.Triggers = sortedByName(view.Triggers, func(t *Trigger) string { return t.Name })
+		sorted.Views[i] = &view
+	}
+
+	tableRanks := s.tableRanks()
+	slices.SortFunc(sorted.Tables, func(a, b *Table) int {
+		return cmp.Or(cmp.Compare(tableRanks[a.Name], tableRanks[b.Name]), cmp.Compare(a.Name, b.Name))
+	})
+
+	viewRanks := s.viewRanks()
+	slices.SortFunc(sorted.Views, func(a, b *View) int {
+		return cmp.Or(cmp.Compare(viewRanks[a.Name], viewRanks[b.Name]), cmp.Compare(a.Name, b.Name))
+	})
+
+	return sorted
+}
+
+// References lists the tables referenced by the table's foreign keys, other
+// than itself.
+func (t *Table) References() []string {
+	var references []string
+	for _, foreignKey := range t.ForeignKeys {
+		references = append(references, foreignKey.Table)
+	}
+	for _, constraint := range t.Constraints {
+		if match := referencesPattern.FindStringSubmatch(constraint.Def); constraint.Type == "f" && match != nil {
+			references = append(references, match[1])
+		}
+	}
+
+	references = slices.DeleteFunc(references, func(name string) bool { return name == t.Name })
+	slices.Sort(references)
+	return slices.Compact(references)
+}
+
+// referencesPattern extracts the referenced table, without its schema, from
+// a foreign key constraint definition.
+var referencesPattern = regexp.MustCompile(`REFERENCES\s+(?:"?[^"\s(.]+"?\.)?"?([^"\s(]+)"?`)
+
+// tableRanks ranks each table one above the highest ranked table it
+// references.
+func (s *Schema) tableRanks() map[string]int {
+	return dependencyRanks(s.Tables, tableName, (*Table).References)
+}
+
+// viewRanks ranks each view one above the highest ranked view its definition
+// mentions.
+func (s *Schema) viewRanks() map[string]int {
+	return dependencyRanks(s.Views, func(v *View) string { return v.Name }, s.ViewDependencies)
+}
+
+// ViewDependencies lists the tables and other views of the schema that the
+// definition of v mentions, in name order.
+func (s *Schema) ViewDependencies(v *View) []string {
+	var dependencies []string
+	for _, table := range s.Tables {
+		if mentions(v.Def, table.Name) {
+			dependencies = append(dependencies, table.Name)
+		}
+	}
+	for _, other := range s.Views {
+		if other.Name != v.Name && mentions(v.Def, other.Name) {
+			dependencies = append(dependencies, other.Name)
+		}
+	}
+
+	slices.Sort(dependencies)
+	return slices.Compact(dependencies)
+}
+
+// mentions reports whether def holds name as a whole identifier.
+func mentions(def string, name string) bool {
+	return regexp.MustCompile(`(?i)(^|[^\w$])` + regexp.QuoteMeta(name) + `($|[^\w$])`).MatchString(def)
+}
+
+// dependencyRanks ranks objects so that each one ranks above its
+// dependencies. Dependencies outside of objects are ignored and cycles are
+// broken where first met.
+func dependencyRanks[T any](objects []T, name func(T) string, dependencies func(T) []string) map[string]int {
+	byName := make(map[string]T, len(objects))
+	for _, object := range objects {
+		byName[name(object)] = object
+	}
+
+	ranks := make(map[string]int, len(objects))
+	visiting := make(map[string]bool)
+
+	var rank func(name string) int
+	rank = func(objectName string) int {
+		if r, found := ranks[objectName]; found {
+			return r
+		}
+		if visiting[objectName] {
+			return 0
+		}
+		visiting[objectName] = true
+
+		r := 0
+		for _, dependency := range dependencies(byName[objectName]) {
+			if _, found := byName[dependency]; found {
+				r = max(r, rank(dependency)+1)
+			}
+		}
+
+		ranks[objectName] = r
+		return r
+	}
+
+	// Visiting names in order breaks cycles the same way every time
+	names := make([]string, 0, len(objects))
+	for _, object := range objects {
+		names = append(names, name(object))
+	}
+	slices.Sort(names)
+	for _, objectName := range names {
+		rank(objectName)
+	}
+
+	return ranks
+}
+
+// sortRemoved reorders the removed changes, which compareByName puts last,
+// so that dependents are removed before their dependencies.
+func sortRemoved[E any](changes []E, removed func(E) bool, name func(E) string, ranks map[string]int) {
+	first := slices.IndexFunc(changes, removed)
+	if first < 0 {
+		return
+	}
+
+	slices.SortStableFunc(changes[first:], func(a, b E) int {
+		return cmp.Or(cmp.Compare(ranks[name(b)], ranks[name(a)]), strings.Compare(name(a), name(b)))
+	})
+}
+
+func sortedByName[T any](objects []T, name func(T) string) []T {
+	return slices.SortedStableFunc(slices.Values(objects), func(a, b T) int {
+		return cmp.Compare(name(a), name(b))
+	})
+}
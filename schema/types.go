@@ -0,0 +1,85 @@
+package schema
+
+import "strings"
+
+// TypeEquivalence reports whether two column types should compare equal even
+// though they are spelled differently.
+type TypeEquivalence func(sourceType, targetType string) bool
+
+// EquivalentTypes declares each pair of types equivalent, both ways and
+// regardless of case. Chain pairs to make more than two types equivalent,
+// e.g. a domain and its base type alongside an alias of that base type.
+func EquivalentTypes(pairs ...[2]string) TypeEquivalence {
+	equivalent := make(map[[2]string]bool, 2*len(pairs))
+	for _, pair := range pairs {
+		a, b := strings.ToLower(pair[0]), strings.ToLower(pair[1])
+		equivalent[[2]string{a, b}] = true
+		equivalent[[2]string{b, a}] = true
+	}
+
+	return func(sourceType, targetType string) bool {
+		return equivalent[[2]string{strings.ToLower(sourceType), strings.ToLower(targetType)}]
+	}
+}
+
+// typeAliases maps type names to the canonical spelling used by
+// NormalizeType, following the Postgres names for SQL standard types.
+var typeAliases = map[string]string{
+	"INT":                         "INTEGER",
+	"INT4":                        "INTEGER",
+	"INT2":                        "SMALLINT",
+	"INT8":                        "BIGINT",
+	"BOOL":                        "BOOLEAN",
+	"FLOAT4":                      "REAL",
+	"FLOAT8":                      "DOUBLE PRECISION",
+	"DOUBLE":                      "DOUBLE PRECISION",
+	"DECIMAL":                     "NUMERIC",
+	"VARCHAR":                     "CHARACTER VARYING",
+	"CHAR VARYING":                "CHARACTER VARYING",
+	"CHAR":                        "CHARACTER",
+	"BPCHAR":                      "CHARACTER",
+	"VARBIT":                      "BIT VARYING",
+	"TIMESTAMP WITHOUT TIME ZONE": "TIMESTAMP",
+	"TIMESTAMPTZ":                 "TIMESTAMP WITH TIME ZONE",
+	"TIME WITHOUT TIME ZONE":      "TIME",
+	"TIMETZ":                      "TIME WITH TIME ZONE",
+	"SERIAL4":                     "SERIAL",
+	"SERIAL8":                     "BIGSERIAL",
+	"SERIAL2":                     "SMALLSERIAL",
+}
+
+// NormalizeType returns the canonical spelling of a column type: upper case,
+// with single spaces, no spaces around parentheses and commas, and aliases
+// such as INT or BOOL replaced by their standard name.
+func NormalizeType(columnType string) string {
+	normalized := strings.ToUpper(strings.Join(strings.Fields(columnType), " "))
+	for _, token := range []string{"(", ")", ",", "[", "]"} {
+		normalized = strings.ReplaceAll(normalized, " "+token, token)
+		normalized = strings.ReplaceAll(normalized, token+" ", token)
+	}
+
+	name, modifiers := normalized, ""
+	if i := strings.IndexAny(normalized, "(["); i >= 0 {
+		name, modifiers = normalized[:i], normalized[i:]
+	}
+
+	// Time zone qualifiers come after the precision, e.g. TIME(3) WITH TIME
+	// ZONE
+	if i := strings.Index(modifiers, ")"); i >= 0 && strings.HasPrefix(name, "TIME") {
+		if suffix := modifiers[i+1:]; strings.HasPrefix(suffix, "WITH") {
+			name, modifiers = name+" "+suffix, modifiers[:i+1]
+		}
+	}
+
+	if alias, found := typeAliases[name]; found {
+		name = alias
+	}
+
+	return name + modifiers
+}
+
+// NormalizedTypes makes types equivalent when they only differ by case,
+// spacing or aliases, see NormalizeType.
+func NormalizedTypes(sourceType, targetType string) bool {
+	return NormalizeType(sourceType) == NormalizeType(targetType)
+}